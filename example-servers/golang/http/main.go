@@ -27,6 +27,7 @@ func main() {
 		"Go HTTP Example",
 		"1.0.0",
 		server.WithToolCapabilities(true),
+		server.WithHooks(agnost.Hooks()),
 	)
 
 	// Add tools
@@ -62,10 +63,15 @@ func main() {
 	// Create SSE server
 	sseServer := server.NewSSEServer(s)
 
+	// Serve the SDK's Prometheus metrics next to the MCP endpoints
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", agnost.MetricsHandler())
+	mux.Handle("/", sseServer)
+
 	// Create HTTP server
 	httpServer := &http.Server{
 		Addr:         ":" + port,
-		Handler:      sseServer,
+		Handler:      mux,
 		ReadTimeout:  15 * time.Second,
 		WriteTimeout: 15 * time.Second,
 		IdleTimeout:  60 * time.Second,
@@ -79,7 +85,7 @@ func main() {
 		<-sigChan
 		log.Println("\nShutting down server...")
 
-		// Shutdown HTTP server
+		// Shutdown HTTP server, then analytics, within 5 seconds altogether
 		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 		defer cancel()
 
@@ -88,13 +94,16 @@ func main() {
 		}
 
 		// Shutdown analytics
-		agnost.Shutdown()
+		if err := agnost.ShutdownContext(ctx); err != nil {
+			log.Printf("Analytics shutdown error: %v", err)
+		}
 		os.Exit(0)
 	}()
 
 	// Start HTTP server
 	log.Printf("Starting MCP HTTP server with Agnost Analytics on port %s...", port)
 	log.Printf("Connect using: http://localhost:%s/sse", port)
+	log.Printf("Metrics at: http://localhost:%s/metrics", port)
 
 	if err := httpServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
 		log.Fatalf("Server error: %v", err)
@@ -85,7 +85,7 @@ func main() {
 		<-sigChan
 		log.Println("\nShutting down server...")
 
-		// Shutdown HTTP server
+		// Shutdown HTTP server, then analytics, within 5 seconds altogether
 		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 		defer cancel()
 
@@ -94,7 +94,9 @@ func main() {
 		}
 
 		// Shutdown analytics
-		agnost.Shutdown()
+		if err := agnost.ShutdownContext(ctx); err != nil {
+			log.Printf("Analytics shutdown error: %v", err)
+		}
 		os.Exit(0)
 	}()
 
@@ -7,6 +7,7 @@ import (
 	"os"
 	"os/signal"
 	"syscall"
+	"time"
 
 	"github.com/agnostai/agnost-go/agnost"
 	"github.com/mark3labs/mcp-go/mcp"
@@ -19,6 +20,7 @@ func main() {
 		"Go STDIO Example",
 		"1.0.0",
 		server.WithToolCapabilities(true),
+		server.WithHooks(agnost.Hooks()),
 	)
 
 	// Add tools
@@ -27,10 +29,11 @@ func main() {
 
 	// Enable Agnost Analytics tracking
 	err := agnost.Track(s, "da200bda-4d22-424e-a250-eabd0ac3b6ce", &agnost.Config{
-		Endpoint:      "http://localhost:8080",
-		DisableInput:  false,
-		DisableOutput: false,
-		LogLevel:      "debug",
+		Endpoint:       "http://localhost:8080",
+		DisableInput:   false,
+		DisableOutput:  false,
+		LogLevel:       "debug",
+		ConnectionType: agnost.ConnectionTypeStdio,
 	})
 
 	if err != nil {
@@ -47,10 +50,25 @@ func main() {
 	go func() {
 		<-sigChan
 		log.Println("\nShutting down...")
-		agnost.Shutdown()
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := agnost.ShutdownContext(ctx); err != nil {
+			log.Printf("Analytics shutdown error: %v", err)
+		}
 		os.Exit(0)
 	}()
 
+	// Print the SDK's stats on SIGUSR1; stdout carries the protocol, so the
+	// log package's stderr is used
+	statsChan := make(chan os.Signal, 1)
+	notifyStats(statsChan)
+
+	go func() {
+		for range statsChan {
+			printStats(agnost.GetStats())
+		}
+	}()
+
 	// Start STDIO server
 	log.Println("Starting MCP STDIO server with Agnost Analytics...")
 	if err := server.ServeStdio(s); err != nil {
@@ -106,6 +124,23 @@ func addAddTool(s *server.MCPServer) {
 	s.AddTool(tool, handler)
 }
 
+// printStats logs the SDK's pipeline counters
+func printStats(stats agnost.Stats) {
+	log.Printf("Agnost stats: queued=%d sent=%d retried=%d failed=%d dropped=%d queue_depth=%d",
+		stats.EventsQueued, stats.EventsSent, stats.EventsRetried, stats.EventsFailed, totalDrops(stats), stats.QueuedEvents)
+	if stats.LastSendError != "" {
+		log.Printf("Agnost last send error (%s): %s", stats.LastSendErrorAt.Format(time.RFC3339), stats.LastSendError)
+	}
+}
+
+func totalDrops(stats agnost.Stats) int64 {
+	var total int64
+	for _, n := range stats.Drops {
+		total += n
+	}
+	return total
+}
+
 func formatFloat(f float64) string {
 	if f == float64(int(f)) {
 		return fmt.Sprintf("%d", int(f))
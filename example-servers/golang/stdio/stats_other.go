@@ -0,0 +1,8 @@
+//go:build !unix
+
+package main
+
+import "os"
+
+// notifyStats does nothing on platforms without SIGUSR1
+func notifyStats(c chan<- os.Signal) {}
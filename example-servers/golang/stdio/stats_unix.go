@@ -0,0 +1,14 @@
+//go:build unix
+
+package main
+
+import (
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+// notifyStats relays SIGUSR1 to c, so `kill -USR1 <pid>` prints the SDK's stats
+func notifyStats(c chan<- os.Signal) {
+	signal.Notify(c, syscall.SIGUSR1)
+}
@@ -2,7 +2,11 @@ package agnost
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"strconv"
+	"sync/atomic"
 	"time"
 
 	"github.com/mark3labs/mcp-go/mcp"
@@ -16,9 +20,33 @@ type ServerAdapter interface {
 	ExtractTools() []string
 }
 
+// callTracker is implemented by adapters that report the full details of
+// every tool call, with its session pinned at call start
+type callTracker interface {
+	// TrackToolCalls patches the server like PatchServer, reporting calls to
+	// callback and pinning their session with pin if it is non-nil
+	TrackToolCalls(pin SessionPinFunc, callback ToolCallback) error
+}
+
+// serverDescriber is implemented by adapters that can read the server's
+// declared name, version and instructions
+type serverDescriber interface {
+	GetServerInfo() *ServerInfo
+}
+
+// toolHasher is implemented by adapters that can hash the server's tool
+// definitions
+type toolHasher interface {
+	ToolHashes() map[string]string
+}
+
 // MCPGoAdapter is an adapter for mcp-go servers
 type MCPGoAdapter struct {
-	server *server.MCPServer
+	server  *server.MCPServer
+	tracked func(name string) bool // nil tracks every tool
+	tracer  Tracer                 // nil leaves tool calls untraced
+	enabled func() bool            // nil tracks calls regardless
+	log     *levelLogger           // nil logs through the package logger
 }
 
 // NewMCPGoAdapter creates a new adapter for mcp-go servers
@@ -28,57 +56,102 @@ func NewMCPGoAdapter(s *server.MCPServer) *MCPGoAdapter {
 	}
 }
 
-// GetSessionInfo extracts session information from the server
+// GetSessionInfo returns the info of the default session, used for requests
+// outside client sessions and for the session started by Track; see
+// ClientSessionInfo
 func (a *MCPGoAdapter) GetSessionInfo() *SessionInfo {
-	// TODO: Extract from server.request_context when available
-	// For now, return default session info
 	return &SessionInfo{
 		SessionKey: "mcp-go-default",
 		ClientName: "mcp-go-client",
 	}
 }
 
-// PatchServer patches the server to intercept tool calls by wrapping existing tools
-func (a *MCPGoAdapter) PatchServer(callback AnalyticsCallback) error {
-	if a.server == nil {
-		return fmt.Errorf("server is nil")
-	}
+// clientSessionResolver is implemented by adapters that can tell the MCP
+// client sessions of a server apart
+type clientSessionResolver interface {
+	// ClientSessionInfo returns the session info of the client session whose
+	// request is running in ctx, or nil outside client sessions
+	ClientSessionInfo(ctx context.Context) *SessionInfo
+}
 
-	Info("Patching mcp-go server for analytics tracking")
+// clientSessionKey is the session key of the mcp-go client session with the
+// given ID
+func clientSessionKey(sessionID string) string {
+	return "client:" + sessionID
+}
 
-	// Get all existing tools
-	tools := a.server.ListTools()
-	if tools == nil || len(tools) == 0 {
-		Debug("No tools to wrap")
+// ClientSessionInfo keys the session on the ID of the mcp-go client session
+// in ctx, naming it after the client once it initialized
+func (a *MCPGoAdapter) ClientSessionInfo(ctx context.Context) *SessionInfo {
+	session := server.ClientSessionFromContext(ctx)
+	if session == nil || session.SessionID() == "" {
 		return nil
 	}
 
-	// Wrap each tool's handler with analytics
-	wrappedTools := make([]server.ServerTool, 0, len(tools))
-	for name, toolPtr := range tools {
-		if toolPtr == nil {
-			continue
+	info := &SessionInfo{
+		SessionKey: clientSessionKey(session.SessionID()),
+		ClientName: "mcp-go-client",
+	}
+	if withInfo, ok := session.(server.SessionWithClientInfo); ok {
+		if name := withInfo.GetClientInfo().Name; name != "" {
+			info.ClientName = name
 		}
+	}
+	if withTools, ok := session.(server.SessionWithTools); ok {
+		for name, tool := range withTools.GetSessionTools() {
+			if info.SessionTools == nil {
+				info.SessionTools = make(map[string]string)
+			}
+			info.SessionTools[name] = toolHash(&tool.Tool, a.log)
+		}
+	}
+	return info
+}
 
-		// Create wrapped handler
-		wrappedHandler := WrapToolHandler(name, toolPtr.Handler, callback)
-
-		// Create new ServerTool with wrapped handler
-		wrappedTools = append(wrappedTools, server.ServerTool{
-			Tool:    toolPtr.Tool,
-			Handler: wrappedHandler,
-		})
+// PatchServer patches the server to intercept tool calls, reporting them to
+// callback; see TrackToolCalls
+func (a *MCPGoAdapter) PatchServer(callback AnalyticsCallback) error {
+	return a.TrackToolCalls(nil, toolCallback(callback))
+}
 
-		Debug("Wrapped tool: %s", name)
+// TrackToolCalls points the calls ToolMiddleware intercepts at callback, and
+// wraps the handlers of the server's current tools for servers created
+// without it; tools added later are then not tracked.
+func (a *MCPGoAdapter) TrackToolCalls(pin SessionPinFunc, callback ToolCallback) error {
+	if a.server == nil {
+		return fmt.Errorf("server is nil")
 	}
 
-	// Replace all tools with wrapped versions
-	a.server.SetTools(wrappedTools...)
+	a.log.Info("Patching mcp-go server for analytics tracking")
+	startTime := time.Now()
 
-	Info("Successfully wrapped %d tools with analytics", len(wrappedTools))
+	tracker := trackerFor(a.server)
+	tracker.setSink(pin, callback, a.tracked, a.tracer, a.enabled, a.log)
+	a.log.Info("Tools added after Track are tracked only if the server was created with server.WithToolHandlerMiddleware(agnost.ToolMiddleware())")
+	wrapped := tracker.wrapInPlace()
+	a.log.Info("Successfully wrapped %d tools with analytics in %s", wrapped, time.Since(startTime).Round(time.Microsecond))
 	return nil
 }
 
+// SetTracer makes PatchServer start a span with tracer for every tracked tool
+// call
+func (a *MCPGoAdapter) SetTracer(tracer Tracer) {
+	a.tracer = tracer
+}
+
+// toolFilterer is implemented by adapters that can leave some tools untracked
+type toolFilterer interface {
+	// SetToolFilter makes PatchServer track only the tools for which tracked
+	// returns true
+	SetToolFilter(tracked func(name string) bool)
+}
+
+// SetToolFilter limits the tools PatchServer tracks; untracked tools keep
+// their handler as is
+func (a *MCPGoAdapter) SetToolFilter(tracked func(name string) bool) {
+	a.tracked = tracked
+}
+
 // ExtractTools extracts the list of tool names from the server
 func (a *MCPGoAdapter) ExtractTools() []string {
 	if a.server == nil {
@@ -94,36 +167,240 @@ func (a *MCPGoAdapter) ExtractTools() []string {
 	return names
 }
 
+// ToolHashes returns the definition hash of every tool on the server
+func (a *MCPGoAdapter) ToolHashes() map[string]string {
+	if a.server == nil {
+		return nil
+	}
+	tracker := trackerFor(a.server)
+	tools := a.server.ListTools()
+	hashes := make(map[string]string, len(tools))
+	for name, tool := range tools {
+		if tool != nil {
+			hashes[name] = tracker.hash(name, &tool.Tool)
+		}
+	}
+	return hashes
+}
+
+// inFlightCalls counts the wrapped tool calls currently executing, across
+// every server
+var inFlightCalls atomic.Int64
+
+// InFlightCalls returns the number of tracked tool calls currently executing
+// on every server; GetStats().InFlightCalls counts a single client's
+func InFlightCalls() int64 {
+	return inFlightCalls.Load()
+}
+
+// inFlightCounter is implemented by adapters that count the tool calls of
+// their server currently executing
+type inFlightCounter interface {
+	InFlightCalls() int64
+}
+
+// InFlightCalls returns the number of the server's tracked tool calls
+// currently executing
+func (a *MCPGoAdapter) InFlightCalls() int64 {
+	if t, tracked := toolTrackers.Load(a.server); tracked {
+		return t.(*toolTracker).inFlight.Load()
+	}
+	return 0
+}
+
+// progressTokenString returns the progress token of a request as a string
+func progressTokenString(meta *mcp.Meta) string {
+	if meta == nil || meta.ProgressToken == nil {
+		return ""
+	}
+	return metaString(meta.ProgressToken)
+}
+
+// metaString formats a _meta value as a string. Progress tokens and most IDs
+// are strings or numbers; numbers decoded from JSON are float64 and are
+// formatted without a fraction or exponent when integral.
+func metaString(v any) string {
+	switch value := v.(type) {
+	case string:
+		return value
+	case float64:
+		return strconv.FormatFloat(value, 'f', -1, 64)
+	case json.Number:
+		return value.String()
+	default:
+		return fmt.Sprint(value)
+	}
+}
+
+// errHandlerPanicked fails the recorded call of a tool handler that panicked
+var errHandlerPanicked = errors.New("tool handler panicked")
+
 // WrapToolHandler wraps a tool handler function with analytics tracking
 func WrapToolHandler(
 	toolName string,
 	handler server.ToolHandlerFunc,
 	callback AnalyticsCallback,
+) server.ToolHandlerFunc {
+	return wrapToolHandler(toolName, nil, "", handler, nil, toolCallback(callback), nil, nil, nil)
+}
+
+// WrapToolHandlerWithPin wraps a tool handler function with analytics
+// tracking, reporting every call's details to callback. The call's session is
+// pinned with pin when the call starts, so long-running calls are attributed
+// to the session they started in.
+func WrapToolHandlerWithPin(
+	toolName string,
+	handler server.ToolHandlerFunc,
+	pin SessionPinFunc,
+	callback ToolCallback,
+) server.ToolHandlerFunc {
+	return wrapToolHandler(toolName, nil, "", handler, pin, callback, nil, nil, nil)
+}
+
+// pinCall pins the session of a call with pin, if set, returning the
+// functions resolving and releasing it
+func pinCall(ctx context.Context, pin SessionPinFunc, log *levelLogger) (func() string, func()) {
+	var sessionID func() string
+	var release func()
+	if pin != nil {
+		guard(log, "session pinning", func() {
+			sessionID, release = pin(ctx)
+		})
+	}
+	if sessionID == nil {
+		sessionID = func() string { return "" }
+	}
+	if release == nil {
+		release = func() {}
+	}
+	return func() string {
+		var id string
+		guard(log, "session resolution", func() {
+			id = sessionID()
+		})
+		return id
+	}, release
+}
+
+// toolCallback reports tool calls to an AnalyticsCallback
+func toolCallback(callback AnalyticsCallback) ToolCallback {
+	return func(call *ToolCall) error {
+		callback(call.ToolName, call.Arguments, call.ExecTime, call.Success, call.Result, call.StartTime)
+		return nil
+	}
+}
+
+// wrapToolHandler wraps a tool handler, passing the tool's definition and its
+// hash, if known, on to the callback, and tracing the call with start if set.
+// The SDK's messages about the call go to log, and inFlight, if set, counts
+// the calls executing besides the global count.
+func wrapToolHandler(
+	toolName string,
+	tool *mcp.Tool,
+	hash string,
+	handler server.ToolHandlerFunc,
+	pin SessionPinFunc,
+	callback ToolCallback,
+	start spanStarter,
+	log *levelLogger,
+	inFlight *atomic.Int64,
 ) server.ToolHandlerFunc {
 	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
 		startTime := time.Now()
-		success := true
-		var result *mcp.CallToolResult
-		var err error
+		concurrentCalls := inFlightCalls.Add(1)
+		defer inFlightCalls.Add(-1)
+		if inFlight != nil {
+			concurrentCalls = inFlight.Add(1)
+			defer inFlight.Add(-1)
+		}
+
+		// Per-call state handlers can read and update
+		ctx, state := withCallState(ctx, "tool", toolName)
+		state.meta = request.Params.Meta
+		state.log = log
+
+		// Pin the session for the duration of the call
+		pinned, release := pinCall(ctx, pin, log)
+		defer guard(log, "session release", release)
+
+		// Start the call's span, if it is traced
+		ctx, span := startSpan(start, ctx, "tool", toolName, log)
+		traceID, spanID := spanIDs(span, log)
 
 		// Extract arguments
 		arguments := request.Params.Arguments
 
-		// Call original handler
-		result, err = handler(ctx, request)
+		// report records the call once the handler returned or panicked
+		report := func(result *mcp.CallToolResult, err error) error {
+			attributes := state.finish()
+			sessionID := pinned()
 
-		// Check for errors
-		if err != nil {
-			success = false
-		} else if result != nil && result.IsError {
-			success = false
+			// Tell a handler error from an error result; either fails the call
+			failureKind := FailureKindNone
+			if err != nil {
+				failureKind = FailureKindHandlerError
+			} else if result != nil && result.IsError {
+				failureKind = FailureKindToolError
+			}
+			success := failureKind == FailureKindNone
+
+			// Calculate execution time
+			execTime := time.Since(startTime).Milliseconds()
+			denied, denialReason := state.denial()
+			endSpan(span, SpanOutcome{SessionID: sessionID, Success: success, LatencyMs: execTime, Err: err}, log)
+
+			// Call analytics callback; under strict delivery a failed delivery fails
+			// the call, but a panic in it never affects the handler's result
+			var deliveryErr error
+			guard(log, "analytics callback", func() {
+				deliveryErr = callback(&ToolCall{
+					ToolName:        toolName,
+					Context:         ctx,
+					EventID:         state.eventID,
+					ParentEventID:   state.parentEventID,
+					Depth:           state.depth,
+					SessionID:       sessionID,
+					Arguments:       arguments,
+					Result:          result,
+					Success:         success,
+					FailureKind:     failureKind,
+					Err:             err,
+					StartTime:       startTime,
+					ExecTime:        execTime,
+					ValidationError: state.isValidationError(),
+					Denied:          denied,
+					DenialReason:    denialReason,
+					Tags:            state.tagsSnapshot(),
+					Attributes:      attributes,
+					Metadata:        state.metadataSnapshot(),
+					ConcurrentCalls: concurrentCalls,
+					ProgressToken:   progressTokenString(request.Params.Meta),
+					Meta:            request.Params.Meta,
+					Tool:            tool,
+					ToolHash:        hash,
+					TraceID:         traceID,
+					SpanID:          spanID,
+				})
+			})
+			return deliveryErr
 		}
 
-		// Calculate execution time
-		execTime := time.Since(startTime).Milliseconds()
+		// Record calls whose handler panics on the way out, keeping what the
+		// handler set before the panic, which then propagates unchanged
+		returned := false
+		defer func() {
+			if !returned {
+				report(nil, errHandlerPanicked)
+			}
+		}()
 
-		// Call analytics callback
-		callback(toolName, arguments, execTime, success, result, startTime)
+		// Call original handler
+		result, err := handler(ctx, request)
+		returned = true
+
+		if deliveryErr := report(result, err); deliveryErr != nil {
+			return nil, deliveryFailure(err, deliveryErr)
+		}
 
 		return result, err
 	}
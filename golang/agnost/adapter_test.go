@@ -0,0 +1,33 @@
+package agnost
+
+import "testing"
+
+func TestToolEventsCarryTheProgressToken(t *testing.T) {
+	s, _, collector := newTrackedServer(t, nil)
+	addEchoTool(s)
+
+	for _, tt := range []struct {
+		meta map[string]any
+		want string
+	}{
+		{meta: map[string]any{"progressToken": "upload-1"}, want: "upload-1"},
+		{meta: map[string]any{"progressToken": 42}, want: "42"},
+		{meta: map[string]any{"progressToken": 1234567890123}, want: "1234567890123"},
+		{meta: map[string]any{}, want: ""},
+		{meta: nil, want: ""},
+	} {
+		params := map[string]any{"name": "echo", "arguments": map[string]any{}}
+		if tt.meta != nil {
+			params["_meta"] = tt.meta
+		}
+		before := len(collector.Events("tool"))
+		handleRequest(t, s, "tools/call", params)
+		events := collector.Events("tool")
+		if len(events) != before+1 {
+			t.Fatalf("meta %v: got %d new events, want 1", tt.meta, len(events)-before)
+		}
+		if got := events[before].ProgressToken; got != tt.want {
+			t.Errorf("meta %v: got progress token %q, want %q", tt.meta, got, tt.want)
+		}
+	}
+}
@@ -2,6 +2,10 @@
 package agnost
 
 import (
+	"context"
+	"net/http"
+	"time"
+
 	"github.com/mark3labs/mcp-go/server"
 )
 
@@ -11,34 +15,87 @@ var globalClient = NewAgnostAnalytics()
 // Config is the configuration for Agnost Analytics
 type Config = AgnostConfig
 
-// Track enables analytics tracking for an MCP server by wrapping tool handlers
+// Track enables analytics tracking for an MCP server by intercepting tool calls
 //
-// This function must be called AFTER all tools have been added to the server,
-// as it wraps the existing tool handlers to track analytics.
+// Tools may be added before or after Track. Prompt requests and resource
+// reads are tracked for the handlers wrapped with PromptHandler,
+// ResourceMiddleware and ResourceTemplateHandler.
 //
 // Example:
 //
 //	s := server.NewMCPServer("my-server", "1.0.0")
-//
-//	// Add tools first
 //	s.AddTool(echoTool, echoHandler)
 //	s.AddTool(calcTool, calcHandler)
 //
-//	// Then enable analytics
+//	// Enable analytics
 //	err := agnost.Track(s, "your-org-id", &agnost.Config{
 //	    Endpoint:      "http://localhost:8080",
 //	    DisableInput:  false,
 //	    DisableOutput: false,
 //	    LogLevel:      "info",
 //	})
+//
+// An empty orgID is read from the AGNOST_ORG_ID environment variable, and the
+// fields config leaves unset from the other AGNOST_* variables, see
+// DefaultConfig.
+//
+// Track uses the default client, which tracks a single server; use New for a
+// client of its own per server to track several in one process.
 func Track(s *server.MCPServer, orgID string, config *Config) error {
 	if config == nil {
 		config = DefaultConfig()
 	}
-	return globalClient.TrackMCP(s, orgID, config)
+	return defaultClient.track(s, orgID, config)
 }
 
-// Shutdown gracefully shuts down the global analytics client
+// Shutdown gracefully shuts down the global analytics client, within 30 seconds
 func Shutdown() {
-	globalClient.Shutdown()
+	defaultClient.Shutdown()
+}
+
+// ShutdownContext gracefully shuts down the global analytics client, see
+// AgnostAnalytics.ShutdownContext
+func ShutdownContext(ctx context.Context) error {
+	return defaultClient.ShutdownContext(ctx)
+}
+
+// GetStats returns a snapshot of the global analytics client's internal state
+func GetStats() Stats {
+	return globalClient.Stats()
+}
+
+// Sessions returns a snapshot of every active session of the global analytics
+// client, oldest first
+func Sessions() []SessionSnapshot {
+	return globalClient.Sessions()
+}
+
+// Session returns a snapshot of the global analytics client's active session
+// with the given key
+func Session(key string) (SessionSnapshot, bool) {
+	return globalClient.Session(key)
+}
+
+// RecentDeliveryStats returns the global analytics client's event delivery
+// outcomes of the last 15 minutes
+func RecentDeliveryStats() []BucketStats {
+	return globalClient.RecentDeliveryStats()
+}
+
+// MetricsHandler returns an http.Handler serving the global analytics client's
+// metrics in the Prometheus text format, to mount at /metrics
+func MetricsHandler() http.Handler {
+	return globalClient.MetricsHandler()
+}
+
+// Flush sends every event the global analytics client queued so far, see
+// AgnostAnalytics.Flush
+func Flush(ctx context.Context) error {
+	return globalClient.Flush(ctx)
+}
+
+// HealthyWithin reports whether the global analytics client delivered an event
+// within the last d
+func HealthyWithin(d time.Duration) bool {
+	return globalClient.HealthyWithin(d)
 }
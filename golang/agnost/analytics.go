@@ -1,26 +1,71 @@
 package agnost
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"log/slog"
 	"net/http"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
+	"github.com/mark3labs/mcp-go/mcp"
 	"github.com/mark3labs/mcp-go/server"
 )
 
 // AgnostAnalytics is the main client for Agnost Analytics
 type AgnostAnalytics struct {
-	config         *AgnostConfig
-	orgID          string
-	initialized    bool
+	config          *AgnostConfig
+	orgID           string
+	initialized     bool
 	overrideApplied bool
 
-	httpClient      *http.Client
-	sessionManager  *SessionManager
-	eventProcessor  *EventProcessor
-	serverAdapter   ServerAdapter
+	// log is the client's logger, set by Initialize; nil logs through the
+	// package logger
+	log atomic.Pointer[levelLogger]
+
+	// internalErrors counts the panics recovered from the client's work
+	internalErrors atomic.Int64
+
+	httpClient     *http.Client
+	sessionManager *SessionManager
+	eventProcessor *EventProcessor
+	serverAdapter  ServerAdapter
+	datagram       *datagramExporter
+	truncation     *truncationTracker
+	toolStats      *toolStatsTracker
+	metrics        callMetrics
+	toolLatency    *toolLatencyTracker
+	deliveries     *deliveryRollup
+	drops          *dropCounter
+	tags           *tagGuard
+	origins        *toolOrigins
+	sealer         *payloadSealer // nil unless payloads are encrypted
+	stopReports    chan struct{}  // nil unless delivery reports are enabled
+
+	// queuedIdentity is the identity given to Identify before Initialize
+	queuedIdentity UserIdentity
+
+	// disabled is set while tracking is turned off with Disable
+	disabled atomic.Bool
+
+	// closing is set once Shutdown starts and until the next Initialize; it is
+	// read without the lock, which Shutdown holds while draining the queue
+	closing atomic.Bool
+
+	// schemasCaptured holds the names of tools whose schema was attached to an
+	// event, see Config.CaptureToolSchemas
+	schemasCaptured sync.Map
+
+	// recording counts the events being recorded outside the lock, which
+	// Shutdown waits for before shutting the event processor down
+	recording sync.WaitGroup
+
+	// patchDuration is how long patching the server's tools took
+	patchDuration time.Duration
 
 	mu sync.RWMutex
 }
@@ -29,16 +74,27 @@ type AgnostAnalytics struct {
 func NewAgnostAnalytics() *AgnostAnalytics {
 	return &AgnostAnalytics{
 		initialized: false,
+		truncation:  newTruncationTracker(),
+		toolStats:   newToolStatsTracker(),
+		toolLatency: newToolLatencyTracker(),
+		deliveries:  newDeliveryRollup(systemClock),
+		drops:       newDropCounter(),
+		origins:     newToolOrigins(),
 	}
 }
 
+// logger returns the client's logger
+func (a *AgnostAnalytics) logger() *levelLogger {
+	return a.log.Load()
+}
+
 // Initialize initializes the SDK with the given configuration
 func (a *AgnostAnalytics) Initialize(s *server.MCPServer, orgID string, config *AgnostConfig) error {
 	a.mu.Lock()
 	defer a.mu.Unlock()
 
 	if a.initialized {
-		Debug("SDK already initialized")
+		a.logger().Debug("SDK already initialized")
 		return nil
 	}
 
@@ -46,31 +102,95 @@ func (a *AgnostAnalytics) Initialize(s *server.MCPServer, orgID string, config *
 	if s == nil {
 		return fmt.Errorf("server cannot be nil")
 	}
-	if orgID == "" {
-		return fmt.Errorf("organization ID is required")
-	}
 	if config == nil {
 		config = DefaultConfig()
 	}
 
-	// Set log level
-	SetLogLevel(config.LogLevel)
+	// Fill what the caller left unset from the environment
+	orgID = orgIDOrEnv(orgID)
+	config, err := withEnv(config)
+	if err != nil {
+		return err
+	}
+	if err := checkConfig(orgID, config); err != nil {
+		return err
+	}
+
+	// Bound the payloads of configs leaving the caps unset, such as literals
+	if config.MaxInputBytes == 0 {
+		config.MaxInputBytes = defaultMaxPayloadBytes
+	}
+	if config.MaxOutputBytes == 0 {
+		config.MaxOutputBytes = defaultMaxPayloadBytes
+	}
 
-	Info("Initializing Agnost Analytics SDK - Org ID: %s, Endpoint: %s", orgID, config.Endpoint)
+	// Log through the configured logger at the configured level. The global
+	// client's logger also takes the messages not tied to a client.
+	log := config.logger()
+	log.recovered = &a.internalErrors
+	config.log = log
+	a.log.Store(log)
+	if a == globalClient {
+		packageLogger.Store(log)
+	}
+
+	if err := checkHeaders(config.Headers, log); err != nil {
+		return err
+	}
+
+	// Pick the endpoint of the configured region unless one is set explicitly
+	endpoint, err := resolveEndpoint(config, log)
+	if err != nil {
+		return err
+	}
+
+	var sealer *payloadSealer
+	if config.PayloadPublicKey != "" {
+		if sealer, err = newPayloadSealer(config.PayloadPublicKey, log); err != nil {
+			return err
+		}
+	}
+
+	httpClient, err := newHTTPClient(config)
+	if err != nil {
+		return err
+	}
+
+	a.logger().Info("Initializing Agnost Analytics SDK - Org ID: %s, Endpoint: %s", orgID, endpoint)
 
 	// Initialize components
 	a.config = config
 	a.orgID = orgID
-	a.httpClient = &http.Client{
-		Timeout: config.RequestTimeout,
+	a.httpClient = httpClient
+
+	// Open the datagram transport for udp:// endpoints
+	sessionEndpoint := endpoint
+	if config.SessionEndpoint != "" {
+		sessionEndpoint = config.SessionEndpoint
 	}
+	if isDatagramEndpoint(endpoint) || isDatagramEndpoint(sessionEndpoint) {
+		datagramEndpoint := endpoint
+		if !isDatagramEndpoint(datagramEndpoint) {
+			datagramEndpoint = sessionEndpoint
+		}
+		datagram, err := newDatagramExporter(datagramEndpoint, orgID, config.DatagramMaxBytes, log)
+		if err != nil {
+			return err
+		}
+		a.datagram = datagram
+	}
+
+	a.tags = newTagGuard(config.MaxTagValuesPerKey, config.ExemptTagKeys, log)
+	a.sealer = sealer
 
 	// Create server adapter
-	a.serverAdapter = NewMCPGoAdapter(s)
+	adapter := NewMCPGoAdapter(s)
+	adapter.log = log
+	a.serverAdapter = adapter
 
 	// Create session manager
 	a.sessionManager = NewSessionManager(
-		config.Endpoint,
+		sessionEndpoint,
 		orgID,
 		a.httpClient,
 		config,
@@ -78,19 +198,79 @@ func (a *AgnostAnalytics) Initialize(s *server.MCPServer, orgID string, config *
 	)
 
 	// Create event processor
-	a.eventProcessor = NewEventProcessor(
-		config.Endpoint,
+	a.eventProcessor = NewEventProcessorWithClient(
+		endpoint,
 		orgID,
+		a.httpClient,
 		config,
 	)
 
+	a.eventProcessor.deliveries = a.deliveries
+	a.eventProcessor.drops = a.drops
+	a.deliveries.setTarget(config.DeliveryTarget)
+	a.eventProcessor.sessions = a.sessionManager
+	a.sessionManager.auth = a.eventProcessor.auth
+	a.sessionManager.origins = a.origins
+	a.sessionManager.queuedIdentity, a.queuedIdentity = a.queuedIdentity, nil
+
+	// Probe collector capabilities once when both talk to the same collector
+	if sessionEndpoint == endpoint {
+		a.sessionManager.capabilities = a.eventProcessor.capabilities
+	}
+
+	// Route payloads for udp:// endpoints through the datagram transport
+	if isDatagramEndpoint(endpoint) {
+		a.eventProcessor.datagram = a.datagram
+	}
+	if isDatagramEndpoint(sessionEndpoint) {
+		a.sessionManager.datagram = a.datagram
+	}
+
+	// Report the SDK's own delivery reliability
+	if config.DeliveryReportInterval > 0 {
+		a.stopReports = make(chan struct{})
+		go a.reportDeliveries(config.DeliveryReportInterval, a.stopReports)
+	}
+
 	a.initialized = true
-	Info("Agnost Analytics SDK initialized successfully")
+	a.closing.Store(false)
+	a.logger().Info("Agnost Analytics SDK initialized successfully")
 
 	return nil
 }
 
-// RecordEvent records an analytics event
+// checkConfig validates the organization ID and the settings a configuration
+// can be checked for before a server is tracked
+func checkConfig(orgID string, config *AgnostConfig) error {
+	if orgID == "" {
+		return fmt.Errorf("organization ID is required; pass it or set %s", orgIDEnv)
+	}
+
+	// Refuse configurations that would corrupt a stdio transport
+	if err := checkStdioSafety(config); err != nil {
+		return err
+	}
+
+	switch config.QueueFullPolicy {
+	case "", QueueFullDrop, QueueFullBlock:
+	default:
+		return fmt.Errorf("unknown QueueFullPolicy %q, use %q or %q", config.QueueFullPolicy, QueueFullDrop, QueueFullBlock)
+	}
+
+	switch config.Compression {
+	case "", CompressionNone, CompressionGzip:
+	default:
+		return fmt.Errorf("unknown Compression %q, use %q or %q", config.Compression, CompressionNone, CompressionGzip)
+	}
+
+	if config.SkipValidation {
+		return nil
+	}
+	return validateConfig(config)
+}
+
+// RecordEvent records an analytics event, like RecordEventContext with a
+// background context
 func (a *AgnostAnalytics) RecordEvent(
 	primitiveType string,
 	primitiveName string,
@@ -98,78 +278,530 @@ func (a *AgnostAnalytics) RecordEvent(
 	latency int64,
 	success bool,
 	result any,
+	opts ...RecordOption,
 ) error {
-	a.mu.RLock()
-	defer a.mu.RUnlock()
+	return a.RecordEventContext(context.Background(), primitiveType, primitiveName, args, latency, success, result, opts...)
+}
+
+// RecordEventContext records an analytics event. With the BestEffort option the
+// event is dropped and ErrBackpressure returned while the pipeline is
+// congested. Events recorded once Shutdown started are dropped without an
+// error. Without request queuing the event is sent before it returns, and ctx
+// bounds the send: once it is done, the request in flight is aborted, no more
+// retries are made and its error is returned. Queued events are sent by the
+// worker, independently of ctx.
+func (a *AgnostAnalytics) RecordEventContext(
+	ctx context.Context,
+	primitiveType string,
+	primitiveName string,
+	args any,
+	latency int64,
+	success bool,
+	result any,
+	opts ...RecordOption,
+) error {
+	var options recordOptions
+	for _, opt := range opts {
+		opt(&options)
+	}
+	if options.bestEffort && !a.PipelineHealthy() {
+		a.drops.count(DropBackpressure)
+		return ErrBackpressure
+	}
+
+	err := a.recordEvent(&eventRecord{
+		ctx:           ctx,
+		primitiveType: primitiveType,
+		primitiveName: primitiveName,
+		args:          args,
+		latency:       latency,
+		success:       success,
+		result:        result,
+		tags:          options.tags,
+	})
+
+	// Events recorded while shutting down are dropped silently
+	if errors.Is(err, errShuttingDown) {
+		return nil
+	}
+	return err
+}
 
+// eventRecord carries everything known about a primitive call when it is recorded
+type eventRecord struct {
+	eventID       string // generated when empty
+	parentEventID string
+	sessionID     string // resolved from the current session when empty
+	primitiveType string
+	primitiveName string
+	args          any
+	latency       int64
+	success       bool
+	result        any
+	errorType     string
+	denialReason  string // set with ErrorTypeDenied
+	failureKind   string
+	errorMessage  string
+	errorTypeName string
+
+	concurrentCalls int64
+	progressToken   string
+	correlationID   string
+	tags            map[string]string
+	attributes      map[string]any
+	metadata        map[string]any
+	user            UserIdentity
+	traceID         string
+	spanID          string
+	toolHash        string
+	toolSchema      string
+
+	// ctx bounds the event's synchronous send; nil sends it unbounded
+	ctx context.Context
+
+	// delivered, if set, receives the outcome of a queued event's delivery
+	delivered chan error
+	queued    bool
+
+	// receipt, if set, is resolved with the event's delivery outcome
+	receipt *Receipt
+}
+
+// recordEvent records an analytics event. Once shutdown started, events are
+// counted as dropped and errShuttingDown returned; calls that finish while the
+// queue drains are expected and must not be reported as failures.
+func (a *AgnostAnalytics) recordEvent(rec *eventRecord) error {
+	if a.closing.Load() {
+		a.drops.count(DropShutdown)
+		return errShuttingDown
+	}
+
+	// Take what the event needs under the lock, and send it without holding
+	// it; Shutdown waits for the events being recorded
+	a.mu.RLock()
 	if !a.initialized {
-		return fmt.Errorf("SDK not initialized")
+		a.mu.RUnlock()
+		if a.closing.Load() {
+			a.drops.count(DropShutdown)
+			return errShuttingDown
+		}
+		return ErrNotTracked
 	}
+	config := a.config
+	sessionManager := a.sessionManager
+	eventProcessor := a.eventProcessor
+	sealer := a.sealer
+	tags := a.tags
+	var sessionInfo *SessionInfo
+	if rec.sessionID == "" {
+		sessionInfo = a.serverAdapter.GetSessionInfo()
+	}
+	a.recording.Add(1)
+	a.mu.RUnlock()
+	defer a.recording.Done()
 
 	// Get session info
-	sessionInfo := a.serverAdapter.GetSessionInfo()
-	sessionID, err := a.sessionManager.GetOrCreateSession(sessionInfo)
-	if err != nil {
-		Warning("Failed to get session: %v", err)
-		return err
+	sessionID := rec.sessionID
+	if sessionID == "" {
+		var err error
+		sessionID, err = sessionManager.GetOrCreateSession(sessionInfo)
+		if err != nil {
+			a.logger().Warning("Failed to get session: %v", err)
+			return err
+		}
 	}
 
-	// Prepare arguments
-	var argsJSON string
-	if !a.config.DisableInput && args != nil {
-		if jsonBytes, err := json.Marshal(args); err == nil {
-			argsJSON = string(jsonBytes)
+	// Sample after the session is resolved, so sessions are registered even
+	// when all of their events are sampled out
+	if config.sampledOut(rec.primitiveType) {
+		a.drops.count(DropSampledOut)
+		rec.receipt.resolve(nil)
+		return nil
+	}
+
+	now := time.Now()
+	sessionManager.RecordActivity(sessionID, now, rec.concurrentCalls)
+	var timeToFirstEvent *int64
+	if rec.primitiveType != "sdk" {
+		if ms, first := sessionManager.RecordAction(sessionID, now); first {
+			timeToFirstEvent = &ms
 		}
 	}
+	if outcome, ok := cacheOutcome(rec.attributes); ok {
+		sessionManager.RecordCacheOutcome(sessionID, outcome)
+	}
+	if rec.errorType == ErrorTypeDenied {
+		sessionManager.RecordDenial(sessionID, rec.primitiveName)
+	}
+	user := rec.user
+	if user != nil {
+		sessionManager.RecordIdentity(sessionID, user)
+	} else {
+		user = sessionManager.identity(sessionID)
+	}
+
+	// Oversized payloads of tools capturing large payloads are chunked at send time
+	captureLarge := config.ToolOverrides[rec.primitiveName].CaptureLargePayloads
+	var pendingInput, pendingOutput string
 
-	// Prepare result
+	// Prepare arguments
+	argsJSON, captured := config.capturePayload(rec.primitiveType, rec.primitiveName, rec.args)
+	if captured.redacted || captured.scrubbed {
+		a.logger().Debug("Redacted input of %s '%s'", rec.primitiveType, rec.primitiveName)
+	}
+	if captureLarge && config.MaxInputBytes > 0 && payloadSize(argsJSON, config.SizeLimitsInRunes) > config.MaxInputBytes {
+		pendingInput, argsJSON = argsJSON, ""
+	}
+
+	// Prepare result, preferring a tool result's structured content
 	var resultJSON string
-	if !a.config.DisableOutput && result != nil {
-		if jsonBytes, err := json.Marshal(result); err == nil {
-			resultJSON = string(jsonBytes)
+	output, resultSummary := capturedResult(rec.result)
+	if !config.outputDisabled(rec.primitiveName) && output != nil {
+		var redacted bool
+		if output, redacted = config.redactOutput(output); redacted {
+			a.logger().Debug("Redacted output of %s '%s'", rec.primitiveType, rec.primitiveName)
+		}
+		var truncated bool
+		if captureLarge {
+			resultJSON, _ = serializePayload(output, 0, false)
+			if config.MaxOutputBytes > 0 && payloadSize(resultJSON, config.SizeLimitsInRunes) > config.MaxOutputBytes {
+				pendingOutput, resultJSON = resultJSON, ""
+			}
+		} else {
+			resultJSON, truncated = serializePayload(output, config.MaxOutputBytes, config.SizeLimitsInRunes)
+		}
+		if a.truncation.observe(rec.primitiveName, truncated) {
+			a.logger().Warning("%d%% of the last %d outputs of '%s' were truncated; consider disabling its output capture with ToolOverrides: {%q: {DisableOutput: true}}",
+				int(truncationWarnRatio*100), truncationWindow, rec.primitiveName, rec.primitiveName)
 		}
 	}
 
 	// Create event data
+	eventID := rec.eventID
+	if eventID == "" {
+		eventID = generateUUID()
+	}
+
 	event := &EventData{
-		SessionID:     sessionID,
-		PrimitiveType: primitiveType,
-		PrimitiveName: primitiveName,
-		Latency:       latency,
-		Success:       success,
-		Input:         argsJSON,
-		Output:        resultJSON,
+		EventID:            eventID,
+		ParentEventID:      rec.parentEventID,
+		EnqueuedAt:         time.Now().UnixMilli(),
+		enqueuedMonotonic:  a.deliveries.clock.monotonic(),
+		SessionID:          sessionID,
+		PrimitiveType:      rec.primitiveType,
+		PrimitiveName:      config.captureName(rec.primitiveType, rec.primitiveName),
+		Latency:            rec.latency,
+		Success:            rec.success,
+		Input:              argsJSON,
+		Output:             resultJSON,
+		ResultSummary:      resultSummary,
+		ErrorType:          rec.errorType,
+		DenialReason:       rec.denialReason,
+		FailureKind:        rec.failureKind,
+		ErrorMessage:       rec.errorMessage,
+		ErrorTypeName:      rec.errorTypeName,
+		ConcurrentCalls:    rec.concurrentCalls,
+		ProgressToken:      rec.progressToken,
+		CorrelationID:      rec.correlationID,
+		Tags:               tags.apply(rec.tags),
+		Attributes:         rec.attributes,
+		Metadata:           rec.metadata,
+		UserData:           user,
+		TraceID:            rec.traceID,
+		SpanID:             rec.spanID,
+		ToolHash:           rec.toolHash,
+		ToolSchema:         rec.toolSchema,
+		TimeToFirstEventMs: timeToFirstEvent,
+		pendingInput:       pendingInput,
+		pendingOutput:      pendingOutput,
+		delivered:          rec.delivered,
+		receipt:            rec.receipt,
+	}
+
+	// Encrypt payloads to the org's key. Sealed payloads can't be split into
+	// chunks meaningfully, so oversized ones are truncated first.
+	if sealer != nil {
+		if event.pendingInput != "" {
+			event.Input, _ = truncatePayload(event.pendingInput, config.MaxInputBytes, config.SizeLimitsInRunes)
+			event.pendingInput = ""
+		}
+		if event.pendingOutput != "" {
+			event.Output, _ = truncatePayload(event.pendingOutput, config.MaxOutputBytes, config.SizeLimitsInRunes)
+			event.pendingOutput = ""
+		}
+		sealer.sealEvent(event)
+	}
+
+	// Label the tool's origin and compare latency against its SLO; exactly at
+	// the budget is not a breach
+	if rec.primitiveType == "tool" {
+		event.Origin = a.origins.origin(config, rec.primitiveName)
+		if slo, ok := lookupPattern(config.ToolSLOs, rec.primitiveName); ok && slo > 0 {
+			breached := rec.latency > slo.Milliseconds()
+			event.SLOMs = slo.Milliseconds()
+			event.SLOBreached = &breached
+		}
+		a.toolStats.record(event)
+		if config.CollectToolLatency {
+			a.toolLatency.record(rec.primitiveName, rec.latency)
+		}
 	}
 
 	// Queue event for processing
-	if a.config.EnableRequestQueuing {
-		a.eventProcessor.QueueEvent(event)
+	if config.EnableRequestQueuing {
+		rec.queued = true
+		eventProcessor.QueueEvent(event)
 	} else {
-		// Send synchronously
-		if err := a.eventProcessor.sendEvent(event); err != nil {
-			Warning("Failed to send event: %v", err)
+		// Send synchronously, within the caller's context
+		ctx := rec.ctx
+		if ctx == nil {
+			ctx = context.Background()
+		}
+		err := eventProcessor.sendEvent(ctx, event)
+		eventProcessor.complete(event, err)
+		if err != nil {
+			// A send the caller gave up on says nothing about the endpoint
+			if ctx.Err() != nil {
+				a.logger().Debug("Event send aborted: %v", err)
+			} else {
+				eventProcessor.warnSendFailure(err)
+			}
 			return err
 		}
 	}
 
-	Debug("Event recorded: %s/%s (success: %v, latency: %dms)", primitiveType, primitiveName, success, latency)
+	if log := a.logger(); log.enabled(LogLevelDebug) {
+		log.with(primitiveAttrs(rec.primitiveType, rec.primitiveName, sessionID, rec.latency)...).
+			Debug("Event recorded: %s/%s (success: %v, latency: %dms)", rec.primitiveType, rec.primitiveName, rec.success, rec.latency)
+	}
 	return nil
 }
 
-// analyticsCallback is the callback function for tool execution
-func (a *AgnostAnalytics) analyticsCallback(
-	toolName string,
-	arguments any,
-	execTime int64,
-	success bool,
-	result any,
-	startTime time.Time,
-) {
-	Debug("Recording analytics for tool '%s' - Execution time: %dms, Success: %v", toolName, execTime, success)
+// pinSession pins the current session at the start of a tool call; a session
+// that isn't cached yet is created in the background
+func (a *AgnostAnalytics) pinSession(ctx context.Context) (func() string, func()) {
+	a.mu.RLock()
+	if !a.initialized {
+		a.mu.RUnlock()
+		return func() string { return "" }, func() {}
+	}
+	sessionManager := a.sessionManager
+	sessionInfo := a.callSessionInfo(ctx)
+	a.mu.RUnlock()
 
-	if err := a.RecordEvent("tool", toolName, arguments, execTime, success, result); err != nil {
-		Warning("Failed to record event for tool '%s': %v", toolName, err)
+	pin := sessionManager.pinSession(sessionInfo)
+	if state := callStateFromContext(ctx); state != nil {
+		state.pin = pin
 	}
+	return pin.sessionID, pin.release
+}
+
+// callSessionInfo returns the session info of a call with ctx; a.mu is held
+func (a *AgnostAnalytics) callSessionInfo(ctx context.Context) *SessionInfo {
+	sessionInfo := a.serverAdapter.GetSessionInfo()
+
+	// Give every connected client its own session. The first one continues
+	// the session started by Track, so single-client servers keep one session.
+	if resolver, ok := a.serverAdapter.(clientSessionResolver); ok {
+		if clientInfo := resolver.ClientSessionInfo(ctx); clientInfo != nil {
+			a.sessionManager.AdoptSession(clientInfo, sessionInfo.SessionKey)
+			sessionInfo = clientInfo
+		}
+	}
+
+	// Key the session by the client's correlation ID when configured
+	if a.config.SessionFromCorrelation {
+		if state := callStateFromContext(ctx); state != nil {
+			if correlationID := a.config.correlationID(state.meta); correlationID != "" {
+				sessionInfo = &SessionInfo{
+					SessionKey: "correlation:" + correlationID,
+					ClientName: sessionInfo.ClientName,
+				}
+			}
+		}
+	}
+	return sessionInfo
+}
+
+// analyticsCallback is the callback function for tool execution. It only
+// returns an error under strict delivery, when the event wasn't delivered.
+func (a *AgnostAnalytics) analyticsCallback(call *ToolCall) error {
+	log := a.logger()
+	if log.enabled(LogLevelDebug) {
+		log.with(slog.String("tool", call.ToolName), slog.Int64("latency_ms", call.ExecTime), slog.Bool("success", call.Success)).
+			Debug("Recording analytics for tool '%s' - Execution time: %dms, Success: %v", call.ToolName, call.ExecTime, call.Success)
+	}
+
+	// Classify denied calls, which never succeed, and client-side argument
+	// validation failures
+	var errorType string
+	success := call.Success
+	switch {
+	case call.Denied:
+		errorType = ErrorTypeDenied
+		success = false
+	case !call.Success && (call.ValidationError || a.isValidationErrorResult(call.Result)):
+		errorType = ErrorTypeValidation
+	}
+
+	a.metrics.record(call.ToolName, success, call.ExecTime)
+
+	// Record why the call failed
+	var errorMessage, errorTypeName string
+	if !success {
+		errorMessage, errorTypeName = a.errorDetails(call)
+	}
+
+	// Link nested calls to their parent, up to the configured depth
+	parentEventID := call.ParentEventID
+	if call.Depth > a.maxCallDepth() {
+		parentEventID = ""
+	}
+
+	rec := &eventRecord{
+		ctx:             call.Context,
+		eventID:         call.EventID,
+		parentEventID:   parentEventID,
+		sessionID:       call.SessionID,
+		primitiveType:   "tool",
+		primitiveName:   call.ToolName,
+		args:            call.Arguments,
+		latency:         call.ExecTime,
+		success:         success,
+		result:          call.Result,
+		errorType:       errorType,
+		denialReason:    call.DenialReason,
+		failureKind:     call.FailureKind,
+		errorMessage:    errorMessage,
+		errorTypeName:   errorTypeName,
+		concurrentCalls: call.ConcurrentCalls,
+		progressToken:   call.ProgressToken,
+		correlationID:   a.correlationID(call.Meta),
+		tags:            call.Tags,
+		attributes:      call.Attributes,
+		metadata:        call.Metadata,
+		user:            a.identifyCall(call.Context, call.SessionID),
+		traceID:         call.TraceID,
+		spanID:          call.SpanID,
+		toolHash:        call.ToolHash,
+		toolSchema:      a.toolSchema(call),
+	}
+
+	strict, timeout := a.strictDelivery()
+	if strict {
+		rec.delivered = make(chan error, 1)
+	}
+
+	if err := a.recordEvent(rec); err != nil {
+		if errors.Is(err, errShuttingDown) {
+			log.with(slog.String("tool", call.ToolName)).Debug("Event for tool '%s' dropped: %v", call.ToolName, err)
+		} else {
+			log.with(slog.String("tool", call.ToolName)).Warning("Failed to record event for tool '%s': %v", call.ToolName, err)
+		}
+		if strict {
+			return err
+		}
+		return nil
+	}
+
+	// Wait for the queued event to be delivered, outside of any lock
+	if strict && rec.queued {
+		if err := awaitDelivery(rec.delivered, timeout); err != nil {
+			log.with(slog.String("tool", call.ToolName)).Warning("Event for tool '%s' was not delivered: %v", call.ToolName, err)
+			return err
+		}
+	}
+	return nil
+}
+
+// strictDelivery returns whether strict delivery is enabled and its timeout
+func (a *AgnostAnalytics) strictDelivery() (bool, time.Duration) {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	if a.config == nil {
+		return false, 0
+	}
+	return a.config.StrictDelivery, a.config.StrictTimeout
+}
+
+// errorDetails returns the error message and error type name of a failed call
+func (a *AgnostAnalytics) errorDetails(call *ToolCall) (string, string) {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	if a.config == nil {
+		return "", ""
+	}
+	return a.config.errorDetails(call)
+}
+
+// correlationID returns the client's correlation ID from a request's _meta
+func (a *AgnostAnalytics) correlationID(meta *mcp.Meta) string {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	if a.config == nil {
+		return ""
+	}
+	return a.config.correlationID(meta)
+}
+
+// maxCallDepth returns the deepest nesting level linked to a parent event
+func (a *AgnostAnalytics) maxCallDepth() int {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	if a.config == nil || a.config.MaxCallDepth <= 0 {
+		return defaultMaxCallDepth
+	}
+	return a.config.MaxCallDepth
+}
+
+// toolSchema returns the JSON input schema of the called tool if it is the
+// tool's first call under Config.CaptureToolSchemas, and empty otherwise.
+// Schemas are serialized on first use, so servers with thousands of tools only
+// pay for the ones that get called.
+func (a *AgnostAnalytics) toolSchema(call *ToolCall) string {
+	a.mu.RLock()
+	capture := a.config != nil && a.config.CaptureToolSchemas
+	a.mu.RUnlock()
+	if !capture || call.Tool == nil {
+		return ""
+	}
+	if _, captured := a.schemasCaptured.LoadOrStore(call.ToolName, true); captured {
+		return ""
+	}
+
+	if len(call.Tool.RawInputSchema) > 0 {
+		return string(call.Tool.RawInputSchema)
+	}
+	schema, err := json.Marshal(call.Tool.InputSchema)
+	if err != nil {
+		a.logger().Debug("Failed to serialize schema of tool '%s': %v", call.ToolName, err)
+		return ""
+	}
+	return string(schema)
+}
+
+// isValidationErrorResult reports whether an error result's text matches one of
+// the configured validation error patterns
+func (a *AgnostAnalytics) isValidationErrorResult(result any) bool {
+	toolResult, ok := result.(*mcp.CallToolResult)
+	if !ok || toolResult == nil || !toolResult.IsError {
+		return false
+	}
+
+	a.mu.RLock()
+	patterns := defaultValidationErrorPatterns
+	if a.config != nil && a.config.ValidationErrorPatterns != nil {
+		patterns = a.config.ValidationErrorPatterns
+	}
+	a.mu.RUnlock()
+
+	text := strings.ToLower(resultText(toolResult))
+	for _, pattern := range patterns {
+		if pattern != "" && strings.Contains(text, strings.ToLower(pattern)) {
+			return true
+		}
+	}
+	return false
 }
 
 // TrackMCP enables tracking for an MCP server instance
@@ -178,63 +810,145 @@ func (a *AgnostAnalytics) TrackMCP(s *server.MCPServer, orgID string, config *Ag
 	defer a.mu.Unlock()
 
 	if a.overrideApplied {
-		Debug("Server already tracked")
+		a.logger().Debug("Server already tracked")
 		return nil
 	}
 
 	// Initialize if not already initialized (must be done before using the adapter)
 	if !a.initialized {
 		a.mu.Unlock() // Unlock before calling Initialize which locks again
-		if err := a.Initialize(s, orgID, config); err != nil {
-			Error("Failed to initialize analytics: %v", err)
+		err := a.Initialize(s, orgID, config)
+		a.mu.Lock() // Re-lock after Initialize, also for the deferred unlock
+		if err != nil {
+			a.logger().Error("Failed to initialize analytics: %v", err)
 			return err
 		}
-		a.mu.Lock() // Re-lock after Initialize
 	}
 
-	// Patch the server to wrap tool handlers
-	if err := a.serverAdapter.PatchServer(a.analyticsCallback); err != nil {
-		Error("Failed to patch server: %v", err)
+	// Patch the server to intercept tool calls
+	patchStart := time.Now()
+	if filterer, ok := a.serverAdapter.(toolFilterer); ok && a.config.filtersTools() {
+		filterer.SetToolFilter(a.config.tracksTool)
+	}
+	if setter, ok := a.serverAdapter.(tracerSetter); ok && a.config.Tracer != nil {
+		setter.SetTracer(a.config.Tracer)
+	}
+	if sw, ok := a.serverAdapter.(trackingSwitch); ok {
+		sw.SetTrackingSwitch(a.Enabled)
+	}
+	var err error
+	if tracker, ok := a.serverAdapter.(callTracker); ok {
+		err = tracker.TrackToolCalls(a.pinSession, a.analyticsCallback)
+	} else {
+		err = a.serverAdapter.PatchServer(func(toolName string, arguments any, execTime int64, success bool, result any, startTime time.Time) {
+			a.analyticsCallback(&ToolCall{ToolName: toolName, Arguments: arguments, ExecTime: execTime, Success: success, Result: result, StartTime: startTime})
+		})
+	}
+	if err != nil {
+		a.logger().Error("Failed to patch server: %v", err)
 		return err
 	}
+	if patcher, ok := a.serverAdapter.(resourcePatcher); ok {
+		if err := patcher.PatchResources(a.pinSession, a.resourceCallback); err != nil {
+			a.logger().Warning("Failed to patch resources: %v", err)
+		}
+	}
+	if patcher, ok := a.serverAdapter.(promptPatcher); ok {
+		if err := patcher.PatchPrompts(a.pinSession, a.promptCallback); err != nil {
+			a.logger().Warning("Failed to patch prompts: %v", err)
+		}
+	}
+	a.patchDuration = time.Since(patchStart)
 
 	a.overrideApplied = true
-	Info("MCP server tracking enabled successfully")
+	a.logger().Info("MCP server tracking enabled successfully")
 
 	// Create initial session
-	go func() {
+	go guard(a.logger(), "initial session creation", func() {
 		sessionInfo := a.serverAdapter.GetSessionInfo()
 		if _, err := a.sessionManager.GetOrCreateSession(sessionInfo); err != nil {
-			Warning("Failed to create initial session: %v", err)
+			a.logger().Warning("Failed to create initial session: %v", err)
 		}
-	}()
+	})
 
 	return nil
 }
 
-// Shutdown gracefully shuts down the analytics client
+// defaultShutdownTimeout bounds Shutdown
+const defaultShutdownTimeout = 30 * time.Second
+
+// ErrEventsUndelivered is returned by ShutdownContext when events were left
+// undelivered
+var ErrEventsUndelivered = errors.New("events left undelivered")
+
+// Shutdown gracefully shuts down the analytics client, like ShutdownContext
+// with a 30-second deadline
 func (a *AgnostAnalytics) Shutdown() {
+	ctx, cancel := context.WithTimeout(context.Background(), defaultShutdownTimeout)
+	defer cancel()
+	if err := a.ShutdownContext(ctx); err != nil {
+		a.logger().Warning("Shutdown incomplete: %v", err)
+	}
+}
+
+// ShutdownContext gracefully shuts down the analytics client, sending the
+// queued events and ending the sessions until ctx is done. Sends still in
+// flight then are abandoned, and the events left undelivered, neither sent nor
+// spooled, are counted in the returned error, which wraps ErrEventsUndelivered.
+func (a *AgnostAnalytics) ShutdownContext(ctx context.Context) error {
+	// Stop accepting events first, so calls finishing while the queue drains
+	// don't wait for the lock only to find the SDK shut down
+	a.closing.Store(true)
+
 	a.mu.Lock()
 	defer a.mu.Unlock()
 
 	if !a.initialized {
-		return
+		return nil
 	}
 
-	Info("Shutting down Agnost Analytics SDK...")
+	a.logger().Info("Shutting down Agnost Analytics SDK...")
+
+	// Stop delivery reports
+	if a.stopReports != nil {
+		close(a.stopReports)
+		a.stopReports = nil
+	}
 
-	// Shutdown event processor
+	// Wait for the events being recorded, then shut the event processor down
+	recorded := make(chan struct{})
+	go func() {
+		a.recording.Wait()
+		close(recorded)
+	}()
+	select {
+	case <-recorded:
+	case <-ctx.Done():
+	}
+	var undelivered int64
 	if a.eventProcessor != nil {
-		a.eventProcessor.Shutdown()
+		undelivered = a.eventProcessor.shutdown(ctx)
 	}
 
-	// Clear session manager
+	// End and clear sessions
 	if a.sessionManager != nil {
+		a.sessionManager.endSessions(ctx)
 		a.sessionManager.Clear()
+		a.sessionManager.closeLifecycle()
+	}
+
+	// Close the datagram transport
+	if a.datagram != nil {
+		a.datagram.close()
+		a.datagram = nil
 	}
 
 	a.initialized = false
-	Info("Agnost Analytics SDK shut down successfully")
+	if undelivered > 0 {
+		return fmt.Errorf("%w: %d", ErrEventsUndelivered, undelivered)
+	}
+	a.logger().Info("Agnost Analytics SDK shut down successfully")
+	return nil
 }
 
 // IsInitialized returns whether the SDK is initialized
@@ -250,3 +964,93 @@ func (a *AgnostAnalytics) GetConfig() *AgnostConfig {
 	defer a.mu.RUnlock()
 	return a.config
 }
+
+// inFlightCallsLocked returns the number of the tracked server's tool calls
+// currently executing; the caller holds a.mu
+func (a *AgnostAnalytics) inFlightCallsLocked() int64 {
+	if counter, ok := a.serverAdapter.(inFlightCounter); ok {
+		return counter.InFlightCalls()
+	}
+	return 0
+}
+
+// Stats returns a snapshot of the SDK's internal state
+func (a *AgnostAnalytics) Stats() Stats {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	stats := Stats{
+		TruncationRatios: a.truncation.ratios(),
+		Tools:            a.toolStats.snapshot(),
+		InFlightCalls:    a.inFlightCallsLocked(),
+		Delivery:         a.deliveryReportLocked(),
+		PatchDuration:    a.patchDuration,
+		InternalErrors:   a.internalErrors.Load(),
+		Drops:            a.drops.snapshot(),
+	}
+	if a.eventProcessor != nil {
+		stats.QueuedEvents, stats.QueuedBytes, stats.SpoolBytes = a.eventProcessor.QueueStats()
+		stats.OverflowBytes = a.eventProcessor.overflowBytes()
+		a.eventProcessor.counters.addTo(&stats)
+		stats.BackoffSkips = a.eventProcessor.backoff.skipped.Load()
+		stats.ClockJumps = a.eventProcessor.clockJumps.jumps.Load()
+	}
+	if a.datagram != nil {
+		stats.DatagramsSent = a.datagram.sent.Load()
+		stats.DatagramsDropped = a.datagram.dropped.Load()
+	}
+	if a.sessionManager != nil {
+		stats.Sessions = a.sessionManager.Summaries()
+		stats.IdentityFailures = a.sessionManager.IdentityFailures()
+		stats.IdentityCacheHits, stats.IdentityCacheMisses = a.sessionManager.IdentityCacheStats()
+	}
+	return stats
+}
+
+// RecentDeliveryStats returns the event delivery outcomes of the last 15
+// minutes in 1-minute buckets, oldest first
+func (a *AgnostAnalytics) RecentDeliveryStats() []BucketStats {
+	return a.deliveries.recent()
+}
+
+// HealthyWithin reports whether at least one event was delivered within the
+// last d, at 1-minute granularity. Suitable for readiness checks.
+func (a *AgnostAnalytics) HealthyWithin(d time.Duration) bool {
+	return a.deliveries.deliveredWithin(d)
+}
+
+// Flush sends every event queued so far, returning once each was attempted,
+// or ctx's error if it is done first, for instance before a checkpoint or
+// before the process is frozen. It is safe to call alongside normal operation
+// and a no-op before Initialize and once Shutdown completed.
+func (a *AgnostAnalytics) Flush(ctx context.Context) error {
+	// Wait outside the lock, so Shutdown can proceed meanwhile
+	a.mu.RLock()
+	ep := a.eventProcessor
+	if !a.initialized {
+		ep = nil
+	}
+	a.mu.RUnlock()
+
+	if ep == nil {
+		return nil
+	}
+	return ep.flush(ctx)
+}
+
+// PipelineHealthy reports whether the event queue has room for more events. It is
+// always true when events are sent synchronously or the SDK isn't initialized.
+func (a *AgnostAnalytics) PipelineHealthy() bool {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	if a.eventProcessor == nil || !a.config.EnableRequestQueuing {
+		return true
+	}
+	return !a.eventProcessor.congested.Load()
+}
+
+// ResetTruncationStats discards the tracked per-tool truncation ratios and re-arms
+// the truncation warnings
+func (a *AgnostAnalytics) ResetTruncationStats() {
+	a.truncation.reset()
+}
@@ -0,0 +1,137 @@
+package agnost
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// eventCollector records the sessions, events, session updates and session
+// ends it receives, whether events come one by one or in batches
+type eventCollector struct {
+	*httptest.Server
+	mu       sync.Mutex
+	sessions []SessionData
+	events   []EventData
+	updates  []SessionUpdateData
+	ends     []SessionEndData
+}
+
+func newEventCollector(t *testing.T) *eventCollector {
+	c := &eventCollector{}
+	c.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		switch r.URL.Path {
+		case "/api/v1/capture-session":
+			var session SessionData
+			json.Unmarshal(body, &session)
+			c.sessions = append(c.sessions, session)
+			json.NewEncoder(w).Encode(SessionResponse{SessionID: session.SessionID})
+		case "/api/v1/capture-event":
+			var event EventData
+			json.Unmarshal(body, &event)
+			c.events = append(c.events, event)
+		case "/api/v1/capture-events":
+			var batch []EventData
+			json.Unmarshal(body, &batch)
+			c.events = append(c.events, batch...)
+		case "/api/v1/capture-session-update":
+			var update SessionUpdateData
+			json.Unmarshal(body, &update)
+			c.updates = append(c.updates, update)
+		case "/api/v1/capture-session-end":
+			var end SessionEndData
+			json.Unmarshal(body, &end)
+			c.ends = append(c.ends, end)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	t.Cleanup(c.Close)
+	return c
+}
+
+// Events returns the events received so far of the given primitive type
+func (c *eventCollector) Events(primitiveType string) []EventData {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var events []EventData
+	for _, event := range c.events {
+		if event.PrimitiveType == primitiveType {
+			events = append(events, event)
+		}
+	}
+	return events
+}
+
+// Sessions returns the sessions registered so far
+func (c *eventCollector) Sessions() []SessionData {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]SessionData(nil), c.sessions...)
+}
+
+// Updates returns the session updates received so far
+func (c *eventCollector) Updates() []SessionUpdateData {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]SessionUpdateData(nil), c.updates...)
+}
+
+// Ends returns the session ends received so far
+func (c *eventCollector) Ends() []SessionEndData {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]SessionEndData(nil), c.ends...)
+}
+
+// waitUntil polls cond for up to a second, reporting whether it came true
+func waitUntil(cond func() bool) bool {
+	for deadline := time.Now().Add(time.Second); !cond(); time.Sleep(5 * time.Millisecond) {
+		if time.Now().After(deadline) {
+			return false
+		}
+	}
+	return true
+}
+
+// newTrackedServer returns a server tracking its tools through ToolMiddleware,
+// tracked against a new collector with the configuration configure makes,
+// once the session started by Track is registered. Events are sent
+// synchronously, so a call's event is recorded by the time the call returns.
+func newTrackedServer(t *testing.T, configure func(*AgnostConfig)) (*server.MCPServer, *AgnostAnalytics, *eventCollector) {
+	s := server.NewMCPServer("test", "1.0.0", server.WithToolHandlerMiddleware(ToolMiddleware()))
+	a := NewAgnostAnalytics()
+	return s, a, trackServer(t, s, a, configure)
+}
+
+// trackServer is newTrackedServer for a server and client set up beforehand
+func trackServer(t *testing.T, s *server.MCPServer, a *AgnostAnalytics, configure func(*AgnostConfig)) *eventCollector {
+	t.Helper()
+	collector := newEventCollector(t)
+	config := DefaultConfig()
+	config.Endpoint = collector.URL
+	config.Logger = NewLogger(io.Discard)
+	config.EnableRequestQueuing = false
+	config.MaxRetries = 0
+	if configure != nil {
+		configure(config)
+	}
+
+	if err := a.TrackMCP(s, "org", config); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(a.Shutdown)
+	if !waitUntil(func() bool { return len(collector.Sessions()) > 0 }) {
+		t.Fatal("the session started by Track wasn't registered")
+	}
+	return collector
+}
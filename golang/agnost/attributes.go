@@ -0,0 +1,88 @@
+package agnost
+
+import (
+	"context"
+	"reflect"
+	"strings"
+)
+
+// Limits on the attributes attached to a single event
+const (
+	maxEventAttributes      = 32
+	maxAttributeKeyBytes    = 64
+	maxAttributeStringBytes = 256
+)
+
+// reservedAttributeKeys are the JSON field names of EventData, which attributes
+// must not shadow
+var reservedAttributeKeys = func() map[string]bool {
+	reserved := make(map[string]bool)
+	t := reflect.TypeOf(EventData{})
+	for i := 0; i < t.NumField(); i++ {
+		if name, _, _ := strings.Cut(t.Field(i).Tag.Get("json"), ","); name != "" && name != "-" {
+			reserved[name] = true
+		}
+	}
+	return reserved
+}()
+
+// SetEventAttribute attaches a small typed attribute, such as a moderation
+// verdict, to the event of the tracked tool call running in ctx. Values must
+// be strings of at most 256 bytes, booleans or numbers, and an event holds at
+// most 32 attributes. Keys named like an event field are rejected with a
+// warning, as are attributes set after the handler returned. It is a no-op
+// outside a tracked tool handler.
+func SetEventAttribute(ctx context.Context, key string, value any) {
+	state := callStateFromContext(ctx)
+	if state == nil {
+		return
+	}
+
+	if key == "" || len(key) > maxAttributeKeyBytes {
+		state.log.Warning("Event attribute key %q rejected: must be 1 to %d bytes", key, maxAttributeKeyBytes)
+		return
+	}
+	if reservedAttributeKeys[key] {
+		state.log.Warning("Event attribute '%s' rejected: the name is reserved for an event field", key)
+		return
+	}
+	if !validAttributeValue(value) {
+		state.log.Warning("Event attribute '%s' rejected: values must be booleans, numbers or strings of at most %d bytes", key, maxAttributeStringBytes)
+		return
+	}
+
+	state.setAttribute(key, value)
+}
+
+// setAttribute attaches a validated attribute to the call's event
+func (s *callState) setAttribute(key string, value any) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.finished {
+		s.log.Warning("Event attribute '%s' ignored: set after the tool call returned", key)
+		return
+	}
+	if _, exists := s.attributes[key]; !exists && len(s.attributes) >= maxEventAttributes {
+		s.log.Warning("Event attribute '%s' rejected: events hold at most %d attributes", key, maxEventAttributes)
+		return
+	}
+	if s.attributes == nil {
+		s.attributes = make(map[string]any)
+	}
+	s.attributes[key] = value
+}
+
+// validAttributeValue reports whether value is a supported attribute value
+func validAttributeValue(value any) bool {
+	switch v := value.(type) {
+	case string:
+		return len(v) <= maxAttributeStringBytes
+	case bool,
+		int, int8, int16, int32, int64,
+		uint, uint8, uint16, uint32, uint64,
+		float32, float64:
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,86 @@
+package agnost
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+func TestSetEventAttributeAttachesValidAttributes(t *testing.T) {
+	var log syncBuffer
+	s, _, collector := newTrackedServer(t, func(config *AgnostConfig) {
+		config.Logger = NewLogger(&log)
+	})
+	s.AddTool(mcp.NewTool("moderate"), func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		SetEventAttribute(ctx, "verdict", "allowed")
+		SetEventAttribute(ctx, "flagged", false)
+		SetEventAttribute(ctx, "score", 0.25)
+		SetEventAttribute(ctx, "retries", 2)
+		SetEventAttribute(ctx, "retries", 3)
+		SetEventAttribute(ctx, "session_id", "shadowed")
+		SetEventAttribute(ctx, "", "empty")
+		SetEventAttribute(ctx, strings.Repeat("k", maxAttributeKeyBytes+1), "long key")
+		SetEventAttribute(ctx, "long", strings.Repeat("v", maxAttributeStringBytes+1))
+		SetEventAttribute(ctx, "list", []string{"a"})
+		return mcp.NewToolResultText("ok"), nil
+	})
+
+	callTool(t, s, "moderate")
+	events := collector.Events("tool")
+	if len(events) != 1 {
+		t.Fatalf("got %d tool events, want 1", len(events))
+	}
+	want := map[string]any{"verdict": "allowed", "flagged": false, "score": 0.25, "retries": float64(3)}
+	if got := events[0].Attributes; fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("got attributes %v, want %v", got, want)
+	}
+	if got := strings.Count(log.String(), "rejected"); got != 5 {
+		t.Errorf("logged %d rejections, want 5:\n%s", got, log.String())
+	}
+}
+
+func TestSetEventAttributeCapsAttributesPerEvent(t *testing.T) {
+	s, _, collector := newTrackedServer(t, nil)
+	s.AddTool(mcp.NewTool("many"), func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		for i := range maxEventAttributes + 5 {
+			SetEventAttribute(ctx, fmt.Sprint("attr", i), i)
+		}
+		// Existing attributes can still be updated at the cap
+		SetEventAttribute(ctx, "attr0", "updated")
+		return mcp.NewToolResultText("ok"), nil
+	})
+
+	callTool(t, s, "many")
+	attributes := collector.Events("tool")[0].Attributes
+	if len(attributes) != maxEventAttributes {
+		t.Errorf("event holds %d attributes, want %d", len(attributes), maxEventAttributes)
+	}
+	if got := attributes["attr0"]; got != "updated" {
+		t.Errorf("attribute at the cap wasn't updated: %v", got)
+	}
+}
+
+func TestSetEventAttributeAfterTheCallReturnedIsIgnored(t *testing.T) {
+	var log syncBuffer
+	s, _, collector := newTrackedServer(t, func(config *AgnostConfig) {
+		config.Logger = NewLogger(&log)
+	})
+	var handlerCtx context.Context
+	s.AddTool(mcp.NewTool("leaky"), func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		handlerCtx = ctx
+		return mcp.NewToolResultText("ok"), nil
+	})
+
+	callTool(t, s, "leaky")
+	SetEventAttribute(handlerCtx, "late", true)
+	SetEventAttribute(context.Background(), "outside", true)
+	if got := collector.Events("tool")[0].Attributes; got != nil {
+		t.Errorf("got attributes %v, want none", got)
+	}
+	if !strings.Contains(log.String(), "set after the tool call returned") {
+		t.Errorf("late attribute wasn't reported:\n%s", log.String())
+	}
+}
@@ -0,0 +1,45 @@
+package agnost
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"sync/atomic"
+)
+
+// apiKeyEnv holds the API key when Config.APIKey is not set
+const apiKeyEnv = "AGNOST_API_KEY"
+
+// errAuthRejected is wrapped by the errors of requests the collector refused
+// for their credentials
+var errAuthRejected = errors.New("collector rejected the API key")
+
+// authorize adds the API key, if any, to a request to the collector
+func authorize(req *http.Request, apiKey string) {
+	if apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+apiKey)
+	}
+}
+
+// authMonitor watches the collector's responses for rejected credentials. A
+// rejection is logged as an error once, rather than for every request, until
+// a request is accepted again.
+type authMonitor struct {
+	rejected atomic.Bool
+	log      *levelLogger
+}
+
+// observe records the status of a collector response and returns an error
+// wrapping errAuthRejected if it refused the credentials
+func (m *authMonitor) observe(status int) error {
+	if status != http.StatusUnauthorized && status != http.StatusForbidden {
+		if status >= 200 && status < 300 {
+			m.rejected.Store(false)
+		}
+		return nil
+	}
+	if m.rejected.CompareAndSwap(false, true) {
+		m.log.Error("The collector rejected the SDK's credentials (status %d); set Config.APIKey or %s. Sends back off until a request is accepted.", status, apiKeyEnv)
+	}
+	return fmt.Errorf("%w (status %d)", errAuthRejected, status)
+}
@@ -0,0 +1,89 @@
+package agnost
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// The endpoint cools down for minEndpointCooldown after a transport error or
+// rejected credentials, doubling with every further one up to
+// maxEndpointCooldown
+const (
+	minEndpointCooldown = time.Second
+	maxEndpointCooldown = 30 * time.Second
+)
+
+// maxEndpointDeferrals is how many flushes an event failed locally during a
+// cooldown is kept for, when it can't be spooled, before it is dropped
+const maxEndpointDeferrals = 3
+
+// errEndpointCoolingDown is returned for sends failed locally, without dialing,
+// while the endpoint cools down after a transport error or rejected credentials
+var errEndpointCoolingDown = errors.New("endpoint cooling down after a failed send")
+
+// endpointUnreachable reports whether a send failed without reaching the
+// collector, failing on a transport error or locally during a cooldown
+func endpointUnreachable(err error) bool {
+	return errors.Is(err, errEndpointCoolingDown) || classifyTransportError(err) != transportErrorNone
+}
+
+// endpointBackoff shares transport failures across the sends to the endpoint.
+// Once a send fails to reach the collector, sends fail locally for a cooldown
+// instead of each event dialing the dead endpoint and retrying on its own.
+// HTTP error statuses don't start a cooldown, except for rejected credentials,
+// which would fail every send alike; they are retried per event.
+type endpointBackoff struct {
+	mu       sync.Mutex
+	until    time.Time     // sends fail locally until then
+	cooldown time.Duration // length of the last cooldown, 0 while healthy
+	lastErr  error         // the error that started the cooldown
+	clock    *clock        // cooldowns follow its steady time
+	log      *levelLogger
+
+	skipped atomic.Int64 // sends failed locally
+}
+
+// check returns an error wrapping errEndpointCoolingDown while the endpoint
+// cools down
+func (b *endpointBackoff) check() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.cooldown == 0 || !b.clock.steady().Before(b.until) {
+		return nil
+	}
+	b.skipped.Add(1)
+	return fmt.Errorf("%w (%v)", errEndpointCoolingDown, b.lastErr)
+}
+
+// coolingDown reports whether the endpoint cools down, without counting a skip
+func (b *endpointBackoff) coolingDown() bool {
+	return b.coolingDownAfter(0)
+}
+
+// coolingDownAfter reports whether the endpoint will still cool down once d
+// elapsed
+func (b *endpointBackoff) coolingDownAfter(d time.Duration) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.cooldown != 0 && b.clock.steady().Add(d).Before(b.until)
+}
+
+// observe records the outcome of a send attempt, starting or extending the
+// cooldown after a transport error or rejected credentials. Any other response
+// from the collector, even an error status, ends it.
+func (b *endpointBackoff) observe(err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if classifyTransportError(err) == transportErrorNone && !errors.Is(err, errAuthRejected) {
+		b.cooldown = 0
+		b.lastErr = nil
+		return
+	}
+	b.cooldown = min(max(b.cooldown*2, minEndpointCooldown), maxEndpointCooldown)
+	b.until = b.clock.steady().Add(b.cooldown)
+	b.lastErr = err
+	b.log.Debug("Endpoint unavailable, failing sends locally for %v: %v", b.cooldown, err)
+}
@@ -0,0 +1,56 @@
+package agnost
+
+import (
+	"context"
+	"errors"
+)
+
+// ErrBackpressure is returned when recording a best-effort event while the
+// analytics pipeline is congested
+var ErrBackpressure = errors.New("analytics pipeline congested")
+
+// The event queue is considered congested once it is three quarters full, and
+// healthy again once it drains below a quarter. The gap keeps the signal from
+// flapping around a single threshold.
+const (
+	congestedQueueFraction = 0.75
+	recoveredQueueFraction = 0.25
+)
+
+// RecordOption configures how a custom event is recorded
+type RecordOption func(*recordOptions)
+
+type recordOptions struct {
+	bestEffort bool
+	tags       map[string]string
+}
+
+// BestEffort makes RecordEvent drop the event and return ErrBackpressure
+// immediately when the pipeline is congested, instead of queueing it. Use it
+// for optional enrichment events.
+func BestEffort() RecordOption {
+	return func(o *recordOptions) {
+		o.bestEffort = true
+	}
+}
+
+// PipelineHealthy reports whether the global analytics client's pipeline has
+// room for more events. Handlers can check it before doing optional work whose
+// only purpose is to record events. ctx is the handler's context.
+func PipelineHealthy(ctx context.Context) bool {
+	return globalClient.PipelineHealthy()
+}
+
+// updateCongestion re-evaluates the congestion signal from the queue depth
+func (ep *EventProcessor) updateCongestion() {
+	depth := float64(len(ep.queue)) / float64(cap(ep.queue))
+	if ep.congested.Load() {
+		if depth <= recoveredQueueFraction {
+			ep.congested.Store(false)
+			ep.log.Debug("Event queue recovered from congestion")
+		}
+	} else if depth >= congestedQueueFraction {
+		ep.congested.Store(true)
+		ep.log.Debug("Event queue congested")
+	}
+}
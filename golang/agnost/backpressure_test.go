@@ -0,0 +1,60 @@
+package agnost
+
+import "testing"
+
+func TestPipelineHealthFlipsWithQueueDepth(t *testing.T) {
+	held, received, release := newHeldCollector(t)
+	_, a, _ := newTrackedServer(t, func(config *AgnostConfig) {
+		config.SessionEndpoint = config.Endpoint
+		config.Endpoint = held.URL
+		config.EnableRequestQueuing = true
+		config.QueueSize = 4
+		config.BatchSize = 1
+	})
+	if !a.PipelineHealthy() {
+		t.Fatal("pipeline unhealthy before any event")
+	}
+
+	// The worker holds the first event, then the queue fills to three quarters
+	a.RecordEvent("tool", "first", nil, 1, true, nil)
+	<-received
+	for range 2 {
+		a.RecordEvent("tool", "queued", nil, 1, true, nil)
+	}
+	if !a.PipelineHealthy() {
+		t.Error("pipeline unhealthy at half its queue")
+	}
+	a.RecordEvent("tool", "queued", nil, 1, true, nil)
+	if a.PipelineHealthy() {
+		t.Error("pipeline healthy at three quarters of its queue")
+	}
+	if err := a.RecordEvent("tool", "optional", nil, 1, true, nil, BestEffort()); err != ErrBackpressure {
+		t.Errorf("best-effort event on a congested pipeline got %v, want ErrBackpressure", err)
+	}
+
+	// Draining the queue recovers it
+	release()
+	if !waitUntil(a.PipelineHealthy) {
+		t.Fatal("pipeline didn't recover once the queue drained")
+	}
+	if err := a.RecordEvent("tool", "optional", nil, 1, true, nil, BestEffort()); err != nil {
+		t.Errorf("best-effort event on a recovered pipeline got %v", err)
+	}
+	if got := a.Stats().Drops[DropBackpressure]; got != 1 {
+		t.Errorf("counted %d backpressure drops, want 1", got)
+	}
+}
+
+func TestSynchronousPipelineIsAlwaysHealthy(t *testing.T) {
+	_, a, _ := newTrackedServer(t, nil)
+	if !a.PipelineHealthy() {
+		t.Error("pipeline without queuing is unhealthy")
+	}
+	if err := a.RecordEvent("tool", "optional", nil, 1, true, nil, BestEffort()); err != nil {
+		t.Errorf("best-effort event without queuing got %v", err)
+	}
+	var unset AgnostAnalytics
+	if !unset.PipelineHealthy() {
+		t.Error("pipeline of an uninitialized client is unhealthy")
+	}
+}
@@ -0,0 +1,148 @@
+package agnost
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// echoCall is the JSON-RPC request calling the echo tool
+var echoCall, _ = json.Marshal(map[string]any{
+	"jsonrpc": "2.0", "id": 1, "method": "tools/call",
+	"params": map[string]any{"name": "echo", "arguments": map[string]any{"text": "hello"}},
+})
+
+// benchmarkConfig returns a configuration pointed at a local collector, as
+// configure changes it
+func benchmarkConfig(tb testing.TB, configure func(*AgnostConfig)) *AgnostConfig {
+	collector := newSessionCollector(tb, 0)
+	config := DefaultConfig()
+	config.Endpoint = collector.URL
+	config.Logger = NewLogger(io.Discard)
+	config.SkipValidation = true
+	// Measure sends, not the drops of a queue that fills up
+	config.QueueSize = 1 << 20
+	configure(config)
+	return config
+}
+
+// benchmarkServer returns a server with an echo tool, tracked with the
+// configuration configure makes unless it is nil
+func benchmarkServer(tb testing.TB, configure func(*AgnostConfig)) *server.MCPServer {
+	s := server.NewMCPServer("bench", "1.0.0", server.WithToolHandlerMiddleware(ToolMiddleware()))
+	addEchoTool(s)
+	if configure == nil {
+		return s
+	}
+
+	a := NewAgnostAnalytics()
+	if err := a.TrackMCP(s, "org", benchmarkConfig(tb, configure)); err != nil {
+		tb.Fatal(err)
+	}
+	tb.Cleanup(a.Shutdown)
+	return s
+}
+
+// withoutCapture disables input and output capture
+func withoutCapture(config *AgnostConfig) {
+	config.DisableInput = true
+	config.DisableOutput = true
+}
+
+// benchmarkCalls calls the echo tool of s b.N times
+func benchmarkCalls(b *testing.B, s *server.MCPServer) {
+	ctx := context.Background()
+	s.HandleMessage(ctx, echoCall)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for range b.N {
+		if _, ok := s.HandleMessage(ctx, echoCall).(mcp.JSONRPCResponse); !ok {
+			b.Fatal("tool call failed")
+		}
+	}
+}
+
+func BenchmarkToolCall(b *testing.B) {
+	b.Run("untracked", func(b *testing.B) {
+		benchmarkCalls(b, benchmarkServer(b, nil))
+	})
+	b.Run("tracked", func(b *testing.B) {
+		benchmarkCalls(b, benchmarkServer(b, withoutCapture))
+	})
+	b.Run("tracked_capture", func(b *testing.B) {
+		benchmarkCalls(b, benchmarkServer(b, func(*AgnostConfig) {}))
+	})
+	b.Run("tracked_sync", func(b *testing.B) {
+		benchmarkCalls(b, benchmarkServer(b, func(config *AgnostConfig) {
+			withoutCapture(config)
+			config.EnableRequestQueuing = false
+		}))
+	})
+}
+
+// benchmarkAnalytics returns a client initialized with the configuration
+// configure makes
+func benchmarkAnalytics(b *testing.B, configure func(*AgnostConfig)) *AgnostAnalytics {
+	a := NewAgnostAnalytics()
+	if err := a.Initialize(benchmarkServer(b, nil), "org", benchmarkConfig(b, configure)); err != nil {
+		b.Fatal(err)
+	}
+	b.Cleanup(a.Shutdown)
+	return a
+}
+
+func BenchmarkRecordEvent(b *testing.B) {
+	payloads := map[string]map[string]any{
+		"small": {"text": "hello"},
+		"large": {"text": strings.Repeat("lorem ipsum ", 2000)},
+	}
+	for _, name := range []string{"small", "large"} {
+		b.Run(name, func(b *testing.B) {
+			a := benchmarkAnalytics(b, func(*AgnostConfig) {})
+			args := payloads[name]
+			b.ReportAllocs()
+			b.ResetTimer()
+			for range b.N {
+				if err := a.RecordEvent("tool", "echo", args, 1, true, args); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+// BenchmarkQueueThroughput records events until the collector received them all
+func BenchmarkQueueThroughput(b *testing.B) {
+	a := benchmarkAnalytics(b, func(*AgnostConfig) {})
+	args := map[string]any{"text": "hello"}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for range b.N {
+		a.RecordEvent("tool", "echo", args, 1, true, nil)
+	}
+	if err := a.Flush(context.Background()); err != nil {
+		b.Fatal(err)
+	}
+}
+
+// BenchmarkFlush flushes 100 queued events
+func BenchmarkFlush(b *testing.B) {
+	a := benchmarkAnalytics(b, func(*AgnostConfig) {})
+	args := map[string]any{"text": "hello"}
+	b.ReportAllocs()
+	for range b.N {
+		b.StopTimer()
+		for range 100 {
+			a.RecordEvent("tool", "echo", args, 1, true, nil)
+		}
+		b.StartTimer()
+		if err := a.Flush(context.Background()); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,52 @@
+package agnost
+
+import "context"
+
+// cacheAttribute is the event attribute set by MarkCacheHit and MarkCacheMiss
+const cacheAttribute = "cache"
+
+// Values of the cache attribute
+const (
+	CacheHit  = "hit"
+	CacheMiss = "miss"
+)
+
+// MarkCacheHit records that the tracked tool call running in ctx was answered
+// from the handler's cache, setting the event's "cache" attribute to "hit".
+// Hit ratios are aggregated per tool in Stats and per session in the session
+// summary. It is a no-op outside a tracked tool handler.
+func MarkCacheHit(ctx context.Context) {
+	markCache(ctx, CacheHit)
+}
+
+// MarkCacheMiss records that the tracked tool call running in ctx missed the
+// handler's cache, see MarkCacheHit
+func MarkCacheMiss(ctx context.Context) {
+	markCache(ctx, CacheMiss)
+}
+
+func markCache(ctx context.Context, outcome string) {
+	state := callStateFromContext(ctx)
+	if state == nil {
+		return
+	}
+	state.setAttribute(cacheAttribute, outcome)
+}
+
+// cacheOutcome returns the cache attribute of an event, if set
+func cacheOutcome(attributes map[string]any) (string, bool) {
+	outcome, ok := attributes[cacheAttribute].(string)
+	if !ok || (outcome != CacheHit && outcome != CacheMiss) {
+		return "", false
+	}
+	return outcome, true
+}
+
+// cacheHitRatio returns the fraction of hits among the calls marked as a hit
+// or miss, 0 if none were
+func cacheHitRatio(hits int64, misses int64) float64 {
+	if hits+misses == 0 {
+		return 0
+	}
+	return float64(hits) / float64(hits+misses)
+}
@@ -0,0 +1,83 @@
+package agnost
+
+import (
+	"context"
+	"testing"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// addCachedTool adds a tool marking its calls with mark, if set
+func addCachedTool(s *server.MCPServer, name string, mark func(ctx context.Context)) {
+	s.AddTool(mcp.NewTool(name), func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		if mark != nil {
+			mark(ctx)
+		}
+		return mcp.NewToolResultText("ok"), nil
+	})
+}
+
+func TestCacheMarksAreCountedPerToolAndSession(t *testing.T) {
+	s, a, collector := newTrackedServer(t, nil)
+	addCachedTool(s, "hit", MarkCacheHit)
+	addCachedTool(s, "miss", MarkCacheMiss)
+	addCachedTool(s, "unmarked", nil)
+
+	for _, name := range []string{"hit", "hit", "hit", "miss", "unmarked"} {
+		callTool(t, s, name)
+	}
+
+	for _, event := range collector.Events("tool") {
+		want := map[string]any{"hit": CacheHit, "miss": CacheMiss}[event.PrimitiveName]
+		if got := event.Attributes[cacheAttribute]; got != want {
+			t.Errorf("%s event has cache attribute %v, want %v", event.PrimitiveName, got, want)
+		}
+	}
+
+	stats := a.Stats()
+	if hit := stats.Tools["hit"]; hit.CacheHits != 3 || hit.CacheMisses != 0 || hit.CacheHitRatio() != 1 {
+		t.Errorf("hit tool counted %+v", hit)
+	}
+	if unmarked := stats.Tools["unmarked"]; unmarked.CacheHits+unmarked.CacheMisses != 0 || unmarked.CacheHitRatio() != 0 {
+		t.Errorf("unmarked tool counted %+v", unmarked)
+	}
+
+	a.Shutdown()
+	ends := collector.Ends()
+	if len(ends) != 1 {
+		t.Fatalf("got %d session ends, want 1", len(ends))
+	}
+	if end := ends[0]; end.CacheHits != 3 || end.CacheMisses != 1 || end.CacheHitRatio != 0.75 {
+		t.Errorf("session ended with %d hits, %d misses and ratio %v, want 3, 1 and 0.75", end.CacheHits, end.CacheMisses, end.CacheHitRatio)
+	}
+}
+
+func TestCacheOutcomeIgnoresOtherValues(t *testing.T) {
+	for _, attributes := range []map[string]any{nil, {cacheAttribute: "stale"}, {cacheAttribute: true}} {
+		if outcome, ok := cacheOutcome(attributes); ok {
+			t.Errorf("cacheOutcome(%v) = %q", attributes, outcome)
+		}
+	}
+	MarkCacheHit(context.Background())
+}
+
+func TestPanickingHandlerKeepsItsCacheMark(t *testing.T) {
+	s, a, collector := newTrackedServer(t, nil)
+	s.AddTool(mcp.NewTool("crash"), func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		MarkCacheMiss(ctx)
+		panic("handler failed")
+	})
+
+	func() {
+		defer func() { recover() }()
+		callToolError(t, s, "crash")
+	}()
+	events := collector.Events("tool")
+	if len(events) != 1 || events[0].Success || events[0].Attributes[cacheAttribute] != CacheMiss {
+		t.Fatalf("got events %+v, want one failed call marked as a miss", events)
+	}
+	if got := a.Stats().Tools["crash"].CacheMisses; got != 1 {
+		t.Errorf("counted %d misses for the panicking call, want 1", got)
+	}
+}
@@ -0,0 +1,140 @@
+package agnost
+
+import (
+	"context"
+	"sync"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// defaultMaxCallDepth is used when Config.MaxCallDepth is not set
+const defaultMaxCallDepth = 8
+
+// callState holds per-call state that handlers can update through the context
+// passed to them by WrapToolHandler
+type callState struct {
+	toolName      string
+	primitiveType string
+	meta          *mcp.Meta // the request's _meta, nil if absent
+
+	// Call tree linkage: the event recorded for this call, the event of the
+	// enclosing tracked call and the nesting level (1 for top-level calls)
+	eventID       string
+	parentEventID string
+	depth         int
+
+	// pin is the session pinned for the call by the analytics client, nil if
+	// none was
+	pin *sessionPin
+
+	// log receives the SDK's messages about the call, nil for the package logger
+	log *levelLogger
+
+	mu              sync.Mutex
+	validationError bool
+	denied          bool
+	denialReason    string
+	tags            map[string]string
+	attributes      map[string]any
+	metadata        map[string]any // set with WithEventMetadata, never modified in place
+	finished        bool           // set once the handler returned
+}
+
+type callStateKey struct{}
+
+// withCallState returns a context carrying a fresh call state for the named
+// primitive, nested under the tracked call already running in ctx, if any
+func withCallState(ctx context.Context, primitiveType string, name string) (context.Context, *callState) {
+	state := &callState{
+		toolName:      name,
+		primitiveType: primitiveType,
+		eventID:       generateUUID(),
+		depth:         1,
+		metadata:      metadataFromContext(ctx),
+	}
+	if parent := callStateFromContext(ctx); parent != nil {
+		state.parentEventID = parent.eventID
+		state.depth = parent.depth + 1
+	}
+	return context.WithValue(ctx, callStateKey{}, state), state
+}
+
+// callStateFromContext returns the call state of the innermost tracked call, or nil
+func callStateFromContext(ctx context.Context) *callState {
+	if ctx == nil {
+		return nil
+	}
+	state, _ := ctx.Value(callStateKey{}).(*callState)
+	return state
+}
+
+// ToolNameFromContext returns the name of the tracked tool whose handler is
+// running in ctx. With nested tracked calls it returns the innermost tool.
+func ToolNameFromContext(ctx context.Context) (string, bool) {
+	state := callStateFromContext(ctx)
+	if state == nil {
+		return "", false
+	}
+	return state.toolName, true
+}
+
+// PrimitiveTypeFromContext returns the MCP primitive type ("tool", ...) of the
+// tracked call whose handler is running in ctx
+func PrimitiveTypeFromContext(ctx context.Context) (string, bool) {
+	state := callStateFromContext(ctx)
+	if state == nil {
+		return "", false
+	}
+	return state.primitiveType, true
+}
+
+// MarkValidationError classifies the current tool call's failure as a client-side
+// argument validation error. It is a no-op outside a tracked tool handler.
+func MarkValidationError(ctx context.Context) {
+	state := callStateFromContext(ctx)
+	if state == nil {
+		return
+	}
+	state.mu.Lock()
+	state.validationError = true
+	state.mu.Unlock()
+}
+
+// tagsSnapshot returns a copy of the tags set on the call
+func (s *callState) tagsSnapshot() map[string]string {
+	if s == nil {
+		return nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.tags) == 0 {
+		return nil
+	}
+	tags := make(map[string]string, len(s.tags))
+	for key, value := range s.tags {
+		tags[key] = value
+	}
+	return tags
+}
+
+// finish marks the call as returned, so later attributes are ignored, and
+// returns the attributes set on it
+func (s *callState) finish() map[string]any {
+	if s == nil {
+		return nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.finished = true
+	return s.attributes
+}
+
+// isValidationError reports whether the call was marked as a validation error
+func (s *callState) isValidationError() bool {
+	if s == nil {
+		return false
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.validationError
+}
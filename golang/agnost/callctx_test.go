@@ -0,0 +1,58 @@
+package agnost
+
+import (
+	"context"
+	"encoding/json"
+	"slices"
+	"sync"
+	"testing"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+func TestCallContextOutsideATrackedCall(t *testing.T) {
+	if name, ok := ToolNameFromContext(context.Background()); ok || name != "" {
+		t.Errorf("got tool name %q, %v outside a tracked call", name, ok)
+	}
+	if kind, ok := PrimitiveTypeFromContext(context.Background()); ok || kind != "" {
+		t.Errorf("got primitive type %q, %v outside a tracked call", kind, ok)
+	}
+}
+
+func TestCallContextNamesTheInnermostCall(t *testing.T) {
+	s, _, _ := newTrackedServer(t, nil)
+	var mu sync.Mutex
+	var seen []string
+	observe := func(ctx context.Context) {
+		name, ok := ToolNameFromContext(ctx)
+		kind, _ := PrimitiveTypeFromContext(ctx)
+		mu.Lock()
+		defer mu.Unlock()
+		if !ok {
+			name = "<none>"
+		}
+		seen = append(seen, kind+":"+name)
+	}
+
+	s.AddTool(mcp.NewTool("inner"), func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		observe(ctx)
+		return mcp.NewToolResultText("ok"), nil
+	})
+	s.AddTool(mcp.NewTool("outer"), func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		observe(ctx)
+		message, _ := json.Marshal(map[string]any{"jsonrpc": "2.0", "id": 2, "method": "tools/call",
+			"params": map[string]any{"name": "inner", "arguments": map[string]any{}}})
+		s.HandleMessage(ctx, message)
+		// The nested call's context doesn't leak into its caller's
+		observe(ctx)
+		return mcp.NewToolResultText("ok"), nil
+	})
+
+	callTool(t, s, "outer")
+	want := []string{"tool:outer", "tool:inner", "tool:outer"}
+	mu.Lock()
+	defer mu.Unlock()
+	if !slices.Equal(seen, want) {
+		t.Errorf("handlers saw %v, want %v", seen, want)
+	}
+}
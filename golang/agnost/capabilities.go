@@ -0,0 +1,106 @@
+package agnost
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Collector capabilities advertised by GET /api/v1/capabilities
+const (
+	capabilityEventChunks  = "event_chunks"
+	capabilitySessionBatch = "session_batch"
+)
+
+// capabilityRetryInterval is how long to wait after a failed capability probe
+// before probing the collector again
+const capabilityRetryInterval = 30 * time.Second
+
+// capabilitiesResponse is the response of the capabilities endpoint
+type capabilitiesResponse struct {
+	Capabilities []string `json:"capabilities"`
+}
+
+// capabilityProbe discovers the optional features supported by the collector
+type capabilityProbe struct {
+	endpoint   string
+	orgID      string
+	apiKey     string
+	headers    map[string]string
+	httpClient *http.Client
+	timeout    time.Duration
+	log        *levelLogger
+
+	mu           sync.Mutex
+	capabilities map[string]bool // nil until the collector answered
+	retryAt      time.Time       // when to probe again after a failed probe
+}
+
+func newCapabilityProbe(endpoint string, orgID string, config *AgnostConfig, httpClient *http.Client) *capabilityProbe {
+	return &capabilityProbe{
+		endpoint:   endpoint,
+		orgID:      orgID,
+		apiKey:     config.apiKey(),
+		headers:    config.Headers,
+		httpClient: httpClient,
+		timeout:    config.requestTimeout(),
+		log:        config.logger(),
+	}
+}
+
+// supports reports whether the collector advertises the given capability. The
+// collector's answer is kept once it gave one; collectors without the
+// endpoint support nothing. A probe that got no answer supports nothing
+// either, and is retried after capabilityRetryInterval.
+func (p *capabilityProbe) supports(capability string) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.capabilities == nil && !time.Now().Before(p.retryAt) {
+		if p.capabilities = p.probe(); p.capabilities == nil {
+			p.retryAt = time.Now().Add(capabilityRetryInterval)
+		}
+	}
+	return p.capabilities[capability]
+}
+
+// probe fetches the collector's capabilities, or nil if it didn't answer
+func (p *capabilityProbe) probe() map[string]bool {
+	url := fmt.Sprintf("%s/api/v1/capabilities", p.endpoint)
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return map[string]bool{}
+	}
+	addHeaders(req, p.headers)
+	req.Header.Set("X-Org-id", p.orgID)
+	authorize(req, p.apiKey)
+
+	resp, err := doRequest(p.httpClient, req, p.timeout)
+	if err != nil {
+		p.log.Debug("Capability probe failed: %v", err)
+		return nil
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 500 {
+		p.log.Debug("Capability probe failed (status %d)", resp.StatusCode)
+		return nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		p.log.Debug("Collector does not advertise capabilities (status %d)", resp.StatusCode)
+		return map[string]bool{}
+	}
+
+	var body capabilitiesResponse
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		p.log.Debug("Failed to decode capabilities: %v", err)
+		return nil
+	}
+	capabilities := make(map[string]bool, len(body.Capabilities))
+	for _, c := range body.Capabilities {
+		capabilities[c] = true
+	}
+	p.log.Debug("Collector capabilities: %v", body.Capabilities)
+	return capabilities
+}
@@ -0,0 +1,66 @@
+package agnost
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// capabilityCollector answers capability probes with status until it is
+// changed, counting the probes
+func capabilityCollector(t *testing.T, status *atomic.Int64, probes *atomic.Int64) *capabilityProbe {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		probes.Add(1)
+		if code := int(status.Load()); code != http.StatusOK {
+			w.WriteHeader(code)
+			return
+		}
+		json.NewEncoder(w).Encode(capabilitiesResponse{Capabilities: []string{capabilityEventChunks}})
+	}))
+	t.Cleanup(server.Close)
+	config := DefaultConfig()
+	config.Logger = NewLogger(io.Discard)
+	return newCapabilityProbe(server.URL, "org", config, http.DefaultClient)
+}
+
+func TestCapabilityProbeRetriesAFailedProbe(t *testing.T) {
+	var status, probes atomic.Int64
+	status.Store(http.StatusServiceUnavailable)
+	p := capabilityCollector(t, &status, &probes)
+
+	if p.supports(capabilityEventChunks) {
+		t.Fatal("supported a capability of a failing collector")
+	}
+	status.Store(http.StatusOK)
+	if p.supports(capabilityEventChunks) || probes.Load() != 1 {
+		t.Fatalf("probed %d times before the retry interval, want 1", probes.Load())
+	}
+
+	p.retryAt = time.Now()
+	if !p.supports(capabilityEventChunks) {
+		t.Fatal("the failed probe wasn't retried")
+	}
+	p.supports(capabilitySessionBatch)
+	if got := probes.Load(); got != 2 {
+		t.Errorf("probed %d times, want the advertised capabilities kept", got)
+	}
+}
+
+func TestCapabilityProbeKeepsACollectorWithoutTheEndpoint(t *testing.T) {
+	var status, probes atomic.Int64
+	status.Store(http.StatusNotFound)
+	p := capabilityCollector(t, &status, &probes)
+
+	for range 3 {
+		if p.supports(capabilityEventChunks) {
+			t.Fatal("supported a capability the collector doesn't advertise")
+		}
+	}
+	if got := probes.Load(); got != 1 {
+		t.Errorf("probed %d times, want the collector's answer kept", got)
+	}
+}
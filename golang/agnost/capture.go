@@ -0,0 +1,239 @@
+package agnost
+
+import (
+	"encoding/json"
+	"strings"
+	"sync"
+	"unicode/utf8"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+const (
+	// truncationWindow is the number of recent events considered per tool
+	truncationWindow = 50
+	// truncationWarnRatio is the fraction of truncated events in the window that triggers a warning
+	truncationWarnRatio = 0.8
+	// maxTrackedTruncationTools bounds the number of tools tracked for truncation
+	maxTrackedTruncationTools = 100
+)
+
+// truncatedPayload is stored in place of a payload that exceeded its size cap
+type truncatedPayload struct {
+	Truncated     bool   `json:"truncated"`
+	OriginalBytes int    `json:"original_bytes"`
+	OriginalRunes int    `json:"original_runes"`
+	Preview       string `json:"preview"`
+}
+
+// serializePayload marshals v to JSON, truncating it to limit when limit is
+// positive. The limit counts runes with inRunes, bytes otherwise. The returned
+// string is always valid JSON and valid UTF-8.
+func serializePayload(v any, limit int, inRunes bool) (string, bool) {
+	jsonBytes, err := json.Marshal(v)
+	if err != nil {
+		return "", false
+	}
+	return truncatePayload(string(jsonBytes), limit, inRunes)
+}
+
+// truncatePayload truncates serialized JSON to limit when limit is positive,
+// replacing it with a truncation marker that is itself valid JSON. The preview
+// is cut on a rune boundary and shortened until the marker fits the limit;
+// limits too small for the marker itself leave the preview empty.
+func truncatePayload(payload string, limit int, inRunes bool) (string, bool) {
+	if limit <= 0 || payloadSize(payload, inRunes) <= limit {
+		return payload, false
+	}
+
+	marker := truncatedPayload{
+		Truncated:     true,
+		OriginalBytes: len(payload),
+		OriginalRunes: utf8.RuneCountInString(payload),
+		Preview:       payload[:payloadPrefix(payload, limit, inRunes)],
+	}
+	for {
+		data, err := json.Marshal(marker)
+		if err != nil {
+			return "", true
+		}
+		excess := payloadSize(string(data), inRunes) - limit
+		if excess <= 0 || marker.Preview == "" {
+			return string(data), true
+		}
+		previewSize := payloadSize(marker.Preview, inRunes)
+		marker.Preview = marker.Preview[:payloadPrefix(marker.Preview, max(previewSize-excess, 0), inRunes)]
+	}
+}
+
+// payloadSize measures s for the size caps, in runes with inRunes and in bytes
+// otherwise
+func payloadSize(s string, inRunes bool) int {
+	if inRunes {
+		return utf8.RuneCountInString(s)
+	}
+	return len(s)
+}
+
+// payloadPrefix returns the length in bytes of the longest prefix of s that
+// fits in n runes with inRunes, or n bytes without splitting a rune otherwise
+func payloadPrefix(s string, n int, inRunes bool) int {
+	if !inRunes {
+		return runeBoundary(s, n)
+	}
+	for i := range s {
+		if n == 0 {
+			return i
+		}
+		n--
+	}
+	return len(s)
+}
+
+// runeBoundary returns the largest index <= n that falls on a rune boundary of s
+func runeBoundary(s string, n int) int {
+	if n >= len(s) {
+		return len(s)
+	}
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+	return n
+}
+
+// toolTruncation tracks recent truncation outcomes for a single tool
+type toolTruncation struct {
+	window    [truncationWindow]bool
+	next      int
+	filled    int
+	truncated int
+	total     int64
+	warned    bool
+}
+
+// ratio returns the fraction of truncated events in the window
+func (t *toolTruncation) ratio() float64 {
+	if t.filled == 0 {
+		return 0
+	}
+	return float64(t.truncated) / float64(t.filled)
+}
+
+// truncationTracker tracks per-tool truncation ratios over a sliding window of
+// recent events, bounded to the most active tools
+type truncationTracker struct {
+	mu    sync.Mutex
+	tools map[string]*toolTruncation
+}
+
+func newTruncationTracker() *truncationTracker {
+	return &truncationTracker{
+		tools: make(map[string]*toolTruncation),
+	}
+}
+
+// observe records whether an event for the tool was truncated and reports
+// whether the tool just crossed the warning threshold for the first time
+func (tt *truncationTracker) observe(toolName string, truncated bool) bool {
+	tt.mu.Lock()
+	defer tt.mu.Unlock()
+
+	t, exists := tt.tools[toolName]
+	if !exists {
+		if len(tt.tools) >= maxTrackedTruncationTools {
+			tt.evictLeastActive()
+		}
+		t = &toolTruncation{}
+		tt.tools[toolName] = t
+	}
+
+	// Slide the window
+	if t.filled == truncationWindow {
+		if t.window[t.next] {
+			t.truncated--
+		}
+	} else {
+		t.filled++
+	}
+	t.window[t.next] = truncated
+	if truncated {
+		t.truncated++
+	}
+	t.next = (t.next + 1) % truncationWindow
+	t.total++
+
+	if !t.warned && t.filled == truncationWindow && t.ratio() >= truncationWarnRatio {
+		t.warned = true
+		return true
+	}
+	return false
+}
+
+// evictLeastActive drops the tool with the fewest observed events; callers hold tt.mu
+func (tt *truncationTracker) evictLeastActive() {
+	var victim string
+	var fewest int64 = -1
+	for name, t := range tt.tools {
+		if fewest < 0 || t.total < fewest {
+			victim, fewest = name, t.total
+		}
+	}
+	delete(tt.tools, victim)
+}
+
+// ratios returns the current truncation ratio of every tracked tool
+func (tt *truncationTracker) ratios() map[string]float64 {
+	tt.mu.Lock()
+	defer tt.mu.Unlock()
+
+	ratios := make(map[string]float64, len(tt.tools))
+	for name, t := range tt.tools {
+		ratios[name] = t.ratio()
+	}
+	return ratios
+}
+
+// reset discards all tracked truncation state, re-arming the warnings
+func (tt *truncationTracker) reset() {
+	tt.mu.Lock()
+	defer tt.mu.Unlock()
+	tt.tools = make(map[string]*toolTruncation)
+}
+
+// capturedResult returns the part of a result to capture as the event output.
+// Tool results carrying structuredContent are captured as that object rather
+// than the whole result, since their text content usually duplicates it. The
+// summary records which forms a tool result carried; it is nil for other results.
+func capturedResult(result any) (any, *ResultSummary) {
+	toolResult, ok := result.(*mcp.CallToolResult)
+	if !ok || toolResult == nil {
+		return result, nil
+	}
+
+	summary := &ResultSummary{
+		ContentItems:  len(toolResult.Content),
+		HasStructured: toolResult.StructuredContent != nil,
+	}
+	for _, content := range toolResult.Content {
+		if _, isText := mcp.AsTextContent(content); isText {
+			summary.HasText = true
+			break
+		}
+	}
+
+	if summary.HasStructured {
+		return toolResult.StructuredContent, summary
+	}
+	return toolResult, summary
+}
+
+// resultText returns the concatenated text content of a tool result
+func resultText(result *mcp.CallToolResult) string {
+	var parts []string
+	for _, content := range result.Content {
+		if text, ok := mcp.AsTextContent(content); ok {
+			parts = append(parts, text.Text)
+		}
+	}
+	return strings.Join(parts, "\n")
+}
@@ -0,0 +1,175 @@
+package agnost
+
+import (
+	"encoding/json"
+	"net/url"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// redactedValue replaces the values of keys listed in Config.RedactKeys
+const redactedValue = "[REDACTED]"
+
+// DefaultRedactKeys are the keys DefaultConfig redacts, which commonly hold
+// credentials
+var DefaultRedactKeys = []string{
+	"password", "passwd", "secret", "token", "access_token", "refresh_token",
+	"api_key", "apikey", "authorization", "cookie",
+}
+
+// captureFlags reports what the input capture policy did to a payload
+type captureFlags struct {
+	disabled  bool // input capture is disabled for the primitive, nothing was captured
+	redacted  bool // values of keys in RedactKeys were replaced
+	scrubbed  bool // query parameters were stripped from resource URIs
+	truncated bool // the payload exceeded MaxInputBytes and was replaced with a truncation marker
+}
+
+// capturePayload applies the input capture policy to the input of a primitive
+// and returns it serialized. Every primitive type (tool, prompt, resource,
+// completion and custom events) goes through it, so that DisableInput,
+// ToolOverrides, RedactKeys and MaxInputBytes apply alike; resource inputs
+// additionally have query parameters outside ResourceQueryAllowlist stripped
+// from their URIs. Inputs of primitives capturing large payloads are not
+// truncated, the caller chunks them instead.
+func (c *AgnostConfig) capturePayload(kind string, name string, value any) (string, captureFlags) {
+	var flags captureFlags
+	if value == nil {
+		return "", flags
+	}
+	if c.inputDisabled(name) {
+		flags.disabled = true
+		return "", flags
+	}
+
+	if c.redacts() || kind == "resource" {
+		value = c.scrubPayload(kind, value, &flags)
+	}
+
+	limit := c.MaxInputBytes
+	if c.ToolOverrides[name].CaptureLargePayloads {
+		limit = 0
+	}
+	payload, truncated := serializePayload(value, limit, c.SizeLimitsInRunes)
+	flags.truncated = truncated
+	return payload, flags
+}
+
+// redactOutput returns a copy of an output with the values of RedactKeys and
+// RedactKeyPatterns replaced. Text content is not parsed, so only structured
+// outputs are redacted.
+func (c *AgnostConfig) redactOutput(value any) (any, bool) {
+	if !c.redacts() {
+		return value, false
+	}
+	var flags captureFlags
+	value = c.scrubPayload("output", value, &flags)
+	return value, flags.redacted
+}
+
+// redacts reports whether any key is redacted
+func (c *AgnostConfig) redacts() bool {
+	return len(c.RedactKeys) > 0 || len(c.RedactKeyPatterns) > 0
+}
+
+// redactsKey reports whether the value of key is redacted
+func (c *AgnostConfig) redactsKey(key string) bool {
+	if slices.ContainsFunc(c.RedactKeys, func(redact string) bool { return strings.EqualFold(redact, key) }) {
+		return true
+	}
+	return slices.ContainsFunc(c.RedactKeyPatterns, func(pattern *regexp.Regexp) bool { return pattern.MatchString(key) })
+}
+
+// captureName returns the primitive name sent for a primitive of the given
+// kind. Resources, and the completions that refer to them, are named after
+// their URI, whose query parameters are scrubbed like resource inputs.
+func (c *AgnostConfig) captureName(kind string, name string) string {
+	if kind != "resource" && kind != "completion" {
+		return name
+	}
+	scrubbed, _ := scrubResourceURI(name, c.ResourceQueryAllowlist)
+	return scrubbed
+}
+
+// scrubPayload returns a copy of value with the values of RedactKeys replaced
+// and, for resources, URIs scrubbed. The copy is a JSON round trip, so that
+// structs and typed maps are redacted like the maps clients send.
+func (c *AgnostConfig) scrubPayload(kind string, value any, flags *captureFlags) any {
+	if uri, ok := value.(string); ok {
+		if kind == "resource" {
+			uri, flags.scrubbed = scrubResourceURI(uri, c.ResourceQueryAllowlist)
+		}
+		return uri
+	}
+
+	data, err := json.Marshal(value)
+	if err != nil {
+		return value
+	}
+	decoder := json.NewDecoder(strings.NewReader(string(data)))
+	decoder.UseNumber()
+	var tree any
+	if err := decoder.Decode(&tree); err != nil {
+		return value
+	}
+	return c.scrubValue(kind, tree, flags)
+}
+
+// scrubValue applies RedactKeys, RedactKeyPatterns and URI scrubbing to a decoded JSON value
+func (c *AgnostConfig) scrubValue(kind string, value any, flags *captureFlags) any {
+	switch v := value.(type) {
+	case map[string]any:
+		for key, item := range v {
+			if c.redactsKey(key) {
+				v[key] = redactedValue
+				flags.redacted = true
+				continue
+			}
+			if uri, ok := item.(string); ok && kind == "resource" && strings.EqualFold(key, "uri") {
+				var scrubbed bool
+				v[key], scrubbed = scrubResourceURI(uri, c.ResourceQueryAllowlist)
+				flags.scrubbed = flags.scrubbed || scrubbed
+				continue
+			}
+			v[key] = c.scrubValue(kind, item, flags)
+		}
+	case []any:
+		for i, item := range v {
+			v[i] = c.scrubValue(kind, item, flags)
+		}
+	}
+	return value
+}
+
+// scrubResourceURI strips the query parameters not in allowlist from uri, as
+// resource URIs often carry access tokens in their query string. It reports
+// whether any parameter was stripped.
+func scrubResourceURI(uri string, allowlist []string) (string, bool) {
+	base, rawQuery, found := strings.Cut(uri, "?")
+	if !found {
+		return uri, false
+	}
+	rawQuery, fragment, hasFragment := strings.Cut(rawQuery, "#")
+
+	query, err := url.ParseQuery(rawQuery)
+	if err != nil {
+		// Unparseable queries can't be filtered safely, so drop them whole
+		query = url.Values{}
+	}
+	kept := url.Values{}
+	for key, values := range query {
+		if slices.Contains(allowlist, key) {
+			kept[key] = values
+		}
+	}
+
+	scrubbed := base
+	if len(kept) > 0 {
+		scrubbed += "?" + kept.Encode()
+	}
+	if hasFragment {
+		scrubbed += "#" + fragment
+	}
+	return scrubbed, len(kept) < len(query) || err != nil
+}
@@ -0,0 +1,194 @@
+package agnost
+
+import (
+	"context"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+func TestScrubResourceURI(t *testing.T) {
+	tests := []struct {
+		uri       string
+		allowlist []string
+		want      string
+		scrubbed  bool
+	}{
+		{"file://notes.txt", nil, "file://notes.txt", false},
+		{"https://api/doc?token=abc", nil, "https://api/doc", true},
+		{"https://api/doc?token=abc&page=2", []string{"page"}, "https://api/doc?page=2", true},
+		{"https://api/doc?page=2", []string{"page"}, "https://api/doc?page=2", false},
+		{"https://api/doc?token=abc#section", nil, "https://api/doc#section", true},
+		{"https://api/doc?%zz", []string{"page"}, "https://api/doc", true},
+	}
+	for _, tt := range tests {
+		got, scrubbed := scrubResourceURI(tt.uri, tt.allowlist)
+		if got != tt.want || scrubbed != tt.scrubbed {
+			t.Errorf("scrubResourceURI(%q, %v) = %q, %v, want %q, %v", tt.uri, tt.allowlist, got, scrubbed, tt.want, tt.scrubbed)
+		}
+	}
+}
+
+func TestCapturePayloadRedactsKeysAtAnyDepth(t *testing.T) {
+	config := DefaultConfig()
+	config.RedactKeyPatterns = []*regexp.Regexp{regexp.MustCompile(`^x-.*-key$`)}
+	type credentials struct {
+		User     string `json:"user"`
+		Password string `json:"Password"`
+	}
+	input := map[string]any{
+		"query":   "weather",
+		"auth":    credentials{User: "ana", Password: "hunter2"},
+		"headers": []any{map[string]any{"Authorization": "Bearer abc", "x-api-key": "k"}},
+	}
+
+	payload, flags := config.capturePayload("tool", "search", input)
+	if !flags.redacted {
+		t.Error("redaction wasn't flagged")
+	}
+	for _, secret := range []string{"hunter2", "Bearer abc", `"k"`} {
+		if strings.Contains(payload, secret) {
+			t.Errorf("payload leaks %s: %s", secret, payload)
+		}
+	}
+	if !strings.Contains(payload, "weather") || !strings.Contains(payload, "ana") {
+		t.Errorf("payload lost values that aren't redacted: %s", payload)
+	}
+	if input["auth"].(credentials).Password != "hunter2" {
+		t.Error("redaction modified the caller's input")
+	}
+}
+
+func TestCapturePayloadAppliesToEveryPrimitiveType(t *testing.T) {
+	config := DefaultConfig()
+	config.MaxInputBytes = 64
+	config.ToolOverrides = map[string]ToolOverride{"private": {DisableInput: true}}
+	long := map[string]any{"text": strings.Repeat("x", 100)}
+
+	for _, kind := range []string{"tool", "prompt", "resource", "completion", "custom"} {
+		if payload, flags := config.capturePayload(kind, "private", map[string]any{"a": 1}); payload != "" || !flags.disabled {
+			t.Errorf("%s: captured %q with input disabled", kind, payload)
+		}
+		if payload, _ := config.capturePayload(kind, "open", map[string]any{"token": "abc"}); strings.Contains(payload, "abc") {
+			t.Errorf("%s: payload leaks a redacted key: %s", kind, payload)
+		}
+		if _, flags := config.capturePayload(kind, "open", long); !flags.truncated {
+			t.Errorf("%s: oversized input wasn't truncated", kind)
+		}
+	}
+
+	config.ToolOverrides["large"] = ToolOverride{CaptureLargePayloads: true}
+	if payload, flags := config.capturePayload("tool", "large", long); flags.truncated || !strings.Contains(payload, long["text"].(string)) {
+		t.Error("input of a tool capturing large payloads was truncated")
+	}
+}
+
+func TestCapturePayloadScrubsResourceURIs(t *testing.T) {
+	config := DefaultConfig()
+	config.ResourceQueryAllowlist = []string{"page"}
+
+	payload, flags := config.capturePayload("resource", "docs", map[string]any{"uri": "https://api/doc?token=abc&page=2"})
+	if !flags.scrubbed || strings.Contains(payload, "abc") || !strings.Contains(payload, "page=2") {
+		t.Errorf("resource input captured as %s (scrubbed %v)", payload, flags.scrubbed)
+	}
+	// Only resource URIs are scrubbed
+	if payload, _ := config.capturePayload("tool", "fetch", map[string]any{"uri": "https://api/doc?q=1"}); !strings.Contains(payload, "q=1") {
+		t.Errorf("tool input captured as %s", payload)
+	}
+
+	for kind, want := range map[string]string{
+		"resource":   "https://api/doc?page=2",
+		"completion": "https://api/doc?page=2",
+		"tool":       "https://api/doc?token=abc&page=2",
+	} {
+		if got := config.captureName(kind, "https://api/doc?token=abc&page=2"); got != want {
+			t.Errorf("captureName(%q) = %q, want %q", kind, got, want)
+		}
+	}
+}
+
+func TestToolInputsAreRedacted(t *testing.T) {
+	s, _, collector := newTrackedServer(t, nil)
+	s.AddTool(mcp.NewTool("login"), func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		return mcp.NewToolResultText("ok"), nil
+	})
+
+	handleRequest(t, s, "tools/call", map[string]any{"name": "login", "arguments": map[string]any{"user": "ana", "password": "hunter2"}})
+	events := collector.Events("tool")
+	if len(events) != 1 {
+		t.Fatalf("got %d tool events, want 1", len(events))
+	}
+	if strings.Contains(events[0].Input, "hunter2") || !strings.Contains(events[0].Input, redactedValue) {
+		t.Errorf("input sent as %s", events[0].Input)
+	}
+}
+
+func TestRedactKeysDontModifyTheCallersValues(t *testing.T) {
+	config := DefaultConfig()
+	input := map[string]any{
+		"token":  "abc",
+		"nested": map[string]any{"Password": "hunter2", "user": "ana"},
+		"list":   []any{map[string]any{"api_key": "k"}},
+	}
+	if payload, flags := config.capturePayload("tool", "login", input); !flags.redacted || strings.Contains(payload, "hunter2") {
+		t.Fatalf("input captured as %s (redacted %v)", payload, flags.redacted)
+	}
+	output, redacted := config.redactOutput(input)
+	if !redacted || output.(map[string]any)["token"] != redactedValue {
+		t.Fatalf("output redacted as %v (redacted %v)", output, redacted)
+	}
+
+	if input["token"] != "abc" || input["nested"].(map[string]any)["Password"] != "hunter2" || input["list"].([]any)[0].(map[string]any)["api_key"] != "k" {
+		t.Errorf("redaction modified the caller's map: %v", input)
+	}
+}
+
+func TestRedactKeysReplaceTheDefaults(t *testing.T) {
+	input := map[string]any{"password": "hunter2", "SSN": "123"}
+	for _, tt := range []struct {
+		keys     []string
+		redacted []string
+		kept     []string
+	}{
+		{DefaultRedactKeys, []string{"hunter2"}, []string{"123"}},
+		{[]string{"ssn"}, []string{"123"}, []string{"hunter2"}},
+		{nil, nil, []string{"hunter2", "123"}},
+	} {
+		config := DefaultConfig()
+		config.RedactKeys = tt.keys
+		payload, flags := config.capturePayload("tool", "form", input)
+		for _, value := range tt.redacted {
+			if strings.Contains(payload, value) {
+				t.Errorf("keys %v: payload leaks %s: %s", tt.keys, value, payload)
+			}
+		}
+		for _, value := range tt.kept {
+			if !strings.Contains(payload, value) {
+				t.Errorf("keys %v: payload lost %s: %s", tt.keys, value, payload)
+			}
+		}
+		if flags.redacted != (len(tt.redacted) > 0) {
+			t.Errorf("keys %v: flagged redacted %v", tt.keys, flags.redacted)
+		}
+	}
+}
+
+func TestHandlersGetTheUnredactedArguments(t *testing.T) {
+	s, _, collector := newTrackedServer(t, nil)
+	var password any
+	s.AddTool(mcp.NewTool("login"), func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		password = request.GetArguments()["password"]
+		return mcp.NewToolResultStructuredOnly(map[string]any{"token": "issued"}), nil
+	})
+
+	handleRequest(t, s, "tools/call", map[string]any{"name": "login", "arguments": map[string]any{"password": "hunter2"}})
+	if password != "hunter2" {
+		t.Errorf("handler got password %v, want the client's", password)
+	}
+	event := collector.Events("tool")[0]
+	if strings.Contains(event.Input, "hunter2") || strings.Contains(event.Output, "issued") {
+		t.Errorf("event captured input %s and output %s, want both redacted", event.Input, event.Output)
+	}
+}
@@ -0,0 +1,248 @@
+package agnost
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// checkTruncation checks the invariants of a payload truncated to limit: the
+// result is valid UTF-8 and valid JSON, fits the limit unless even an empty
+// preview doesn't, and its preview is a prefix of the original
+func checkTruncation(t *testing.T, original string, got string, truncated bool, limit int, inRunes bool) {
+	t.Helper()
+	if !utf8.ValidString(got) {
+		t.Fatalf("result isn't valid UTF-8: %q", got)
+	}
+	if !json.Valid([]byte(got)) {
+		t.Fatalf("result isn't valid JSON: %s", got)
+	}
+	if !truncated {
+		if got != original {
+			t.Fatalf("untruncated payload changed from %s to %s", original, got)
+		}
+		if limit > 0 && payloadSize(got, inRunes) > limit {
+			t.Fatalf("untruncated payload of size %d exceeds the limit %d", payloadSize(got, inRunes), limit)
+		}
+		return
+	}
+
+	var marker truncatedPayload
+	if err := json.Unmarshal([]byte(got), &marker); err != nil || !marker.Truncated {
+		t.Fatalf("truncated payload isn't a marker: %s", got)
+	}
+	if marker.OriginalBytes != len(original) || marker.OriginalRunes != utf8.RuneCountInString(original) {
+		t.Errorf("marker records %d bytes and %d runes, want %d and %d",
+			marker.OriginalBytes, marker.OriginalRunes, len(original), utf8.RuneCountInString(original))
+	}
+	if !strings.HasPrefix(original, marker.Preview) {
+		t.Errorf("preview %q isn't a prefix of the payload", marker.Preview)
+	}
+	if marker.Preview != "" && payloadSize(got, inRunes) > limit {
+		t.Errorf("marker of size %d exceeds the limit %d", payloadSize(got, inRunes), limit)
+	}
+}
+
+func TestTruncatePayloadCutsOnRuneBoundaries(t *testing.T) {
+	payload, _ := json.Marshal(strings.Repeat("日本語", 40))
+	for _, inRunes := range []bool{false, true} {
+		for _, limit := range []int{0, 1, 60, 80, 100, 200, len(payload)} {
+			got, truncated := truncatePayload(string(payload), limit, inRunes)
+			checkTruncation(t, string(payload), got, truncated, limit, inRunes)
+		}
+	}
+}
+
+func TestSizeLimitsInRunesCountCharacters(t *testing.T) {
+	payload, _ := json.Marshal("héllo wörld")
+	if _, truncated := truncatePayload(string(payload), 13, true); truncated {
+		t.Error("payload of 13 runes was truncated to 13 runes")
+	}
+	if _, truncated := truncatePayload(string(payload), 13, false); !truncated {
+		t.Error("payload of 15 bytes wasn't truncated to 13 bytes")
+	}
+}
+
+func FuzzSerializePayload(f *testing.F) {
+	f.Add("hello", 100, false)
+	f.Add(strings.Repeat("é", 200), 120, false)
+	f.Add(strings.Repeat("€", 200), 120, true)
+	f.Add("emoji 🎉 and \"quotes\" \\ and \x00 control", 40, true)
+	f.Add("\xff\xfe invalid", 10, false)
+	f.Fuzz(func(t *testing.T, text string, limit int, inRunes bool) {
+		limit %= 4096
+		original, err := json.Marshal(map[string]any{"text": text})
+		if err != nil {
+			t.Skip()
+		}
+		got, truncated := serializePayload(map[string]any{"text": text}, limit, inRunes)
+		checkTruncation(t, string(original), got, truncated, limit, inRunes)
+	})
+}
+
+func TestUnsetPayloadCapsDefaultTo64KB(t *testing.T) {
+	large := strings.Repeat("x", 100<<10)
+	for _, limit := range []int{0, -1} {
+		_, a, collector := newTrackedServer(t, func(config *AgnostConfig) {
+			config.MaxInputBytes = limit
+			config.MaxOutputBytes = limit
+		})
+		if err := a.RecordEvent("tool", "large", map[string]any{"text": large}, 1, true, large); err != nil {
+			t.Fatal(err)
+		}
+		event := collector.Events("tool")[0]
+		bounded := len(event.Input) <= defaultMaxPayloadBytes && len(event.Output) <= defaultMaxPayloadBytes
+		if want := limit == 0; bounded != want {
+			t.Errorf("limit %d: got input of %d bytes and output of %d, want bounded %v", limit, len(event.Input), len(event.Output), want)
+		}
+	}
+}
+
+func TestToolResultsPreferTheirStructuredContent(t *testing.T) {
+	structured := map[string]any{"temperature": 21.5}
+	tests := []struct {
+		name    string
+		result  *mcp.CallToolResult
+		output  string // a substring of the captured output
+		summary ResultSummary
+	}{
+		{
+			name:    "text only",
+			result:  mcp.NewToolResultText("sunny"),
+			output:  `"text":"sunny"`,
+			summary: ResultSummary{ContentItems: 1, HasText: true},
+		},
+		{
+			name:    "structured only",
+			result:  &mcp.CallToolResult{StructuredContent: structured},
+			output:  `{"temperature":21.5}`,
+			summary: ResultSummary{HasStructured: true},
+		},
+		{
+			name:    "both",
+			result:  mcp.NewToolResultStructured(structured, "21.5 degrees"),
+			output:  `{"temperature":21.5}`,
+			summary: ResultSummary{ContentItems: 1, HasText: true, HasStructured: true},
+		},
+	}
+
+	s, _, collector := newTrackedServer(t, nil)
+	for _, tt := range tests {
+		s.AddTool(mcp.NewTool(tt.name), func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+			return tt.result, nil
+		})
+		callTool(t, s, tt.name)
+	}
+
+	events := toolEvents(t, collector, len(tests))
+	for _, tt := range tests {
+		event := events[tt.name]
+		if !strings.Contains(event.Output, tt.output) {
+			t.Errorf("%s: captured output %s, want it to contain %s", tt.name, event.Output, tt.output)
+		}
+		if tt.summary.HasStructured && strings.Contains(event.Output, "degrees") {
+			t.Errorf("%s: captured the text fallback %s instead of the structured content", tt.name, event.Output)
+		}
+		if event.ResultSummary == nil || *event.ResultSummary != tt.summary {
+			t.Errorf("%s: got result summary %+v, want %+v", tt.name, event.ResultSummary, tt.summary)
+		}
+	}
+}
+
+func TestTruncationRatiosSlideOverTheWindow(t *testing.T) {
+	tt := newTruncationTracker()
+	warnings := 0
+	for i := range truncationWindow {
+		// The first 80% of the window is truncated
+		if tt.observe("search", i < truncationWindow*4/5) {
+			warnings++
+		}
+	}
+	if got := tt.ratios()["search"]; got != truncationWarnRatio {
+		t.Errorf("ratio is %v, want %v", got, truncationWarnRatio)
+	}
+	if warnings != 1 {
+		t.Errorf("warned %d times crossing the threshold, want once", warnings)
+	}
+
+	for range truncationWindow {
+		if tt.observe("search", true) {
+			t.Fatal("warned again for the same tool")
+		}
+	}
+	for range truncationWindow / 2 {
+		tt.observe("search", false)
+	}
+	if got := tt.ratios()["search"]; got != 0.5 {
+		t.Errorf("ratio is %v once half the window is untruncated, want 0.5", got)
+	}
+
+	tt.reset()
+	if len(tt.ratios()) != 0 {
+		t.Error("reset kept ratios")
+	}
+	for range truncationWindow - 1 {
+		if tt.observe("search", true) {
+			t.Fatal("warned before the window filled")
+		}
+	}
+	if !tt.observe("search", true) {
+		t.Error("reset didn't re-arm the warning")
+	}
+}
+
+func TestTruncationTrackerEvictsTheLeastActiveTool(t *testing.T) {
+	tt := newTruncationTracker()
+	for i := range maxTrackedTruncationTools {
+		name := string(rune('a'+i%26)) + strings.Repeat("x", i/26)
+		tt.observe(name, false)
+		if name != "a" {
+			tt.observe(name, false)
+		}
+	}
+	tt.observe("new", true)
+
+	ratios := tt.ratios()
+	if len(ratios) != maxTrackedTruncationTools {
+		t.Errorf("tracking %d tools, want at most %d", len(ratios), maxTrackedTruncationTools)
+	}
+	if _, tracked := ratios["a"]; tracked {
+		t.Error("the least active tool wasn't evicted")
+	}
+	if ratios["new"] != 1 {
+		t.Errorf("new tool has ratio %v, want 1", ratios["new"])
+	}
+}
+
+func TestStatsReportTruncationRatios(t *testing.T) {
+	var log syncBuffer
+	_, a, _ := newTrackedServer(t, func(config *AgnostConfig) {
+		config.Logger = NewLogger(&log)
+		config.MaxOutputBytes = 16
+	})
+	large := strings.Repeat("x", 64)
+	for range truncationWindow {
+		a.RecordEvent("tool", "large", nil, 1, true, large)
+		a.RecordEvent("tool", "small", nil, 1, true, "ok")
+	}
+
+	ratios := a.Stats().TruncationRatios
+	if ratios["large"] != 1 || ratios["small"] != 0 {
+		t.Errorf("got truncation ratios %v, want large 1 and small 0", ratios)
+	}
+	if got := strings.Count(log.String(), "outputs of 'large' were truncated"); got != 1 {
+		t.Errorf("logged the truncation warning %d times, want once:\n%s", got, log.String())
+	}
+	if strings.Contains(log.String(), "'small' were truncated") {
+		t.Error("warned about a tool whose outputs fit")
+	}
+
+	a.ResetTruncationStats()
+	if ratios := a.Stats().TruncationRatios; len(ratios) != 0 {
+		t.Errorf("got truncation ratios %v after resetting them", ratios)
+	}
+}
@@ -0,0 +1,154 @@
+package agnost
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"unicode/utf8"
+)
+
+// deliverChunks sends the oversized payloads of an event as ordered chunks and
+// records the chunk counts on the event. If the collector doesn't support chunks
+// the payloads are truncated instead; if any chunk ultimately fails, the event
+// is flagged as having an incomplete payload, as it is once ctx is done.
+func (ep *EventProcessor) deliverChunks(ctx context.Context, event *EventData) {
+	if event.pendingInput == "" && event.pendingOutput == "" {
+		return
+	}
+	input, output := event.pendingInput, event.pendingOutput
+	event.pendingInput, event.pendingOutput = "", ""
+
+	if !ep.capabilities.supports(capabilityEventChunks) {
+		if input != "" {
+			event.Input, _ = truncatePayload(input, ep.config.MaxInputBytes, ep.config.SizeLimitsInRunes)
+		}
+		if output != "" {
+			event.Output, _ = truncatePayload(output, ep.config.MaxOutputBytes, ep.config.SizeLimitsInRunes)
+		}
+		return
+	}
+
+	event.PayloadRef = generateUUID()
+	if input != "" {
+		count, err := ep.sendChunks(ctx, event.PayloadRef, "args", input, ep.config.MaxInputBytes)
+		event.InputChunks = count
+		if err != nil {
+			ep.log.Warning("Abandoning chunked input of %s/%s: %v", event.PrimitiveType, event.PrimitiveName, err)
+			event.PayloadIncomplete = true
+			return
+		}
+	}
+	if output != "" {
+		count, err := ep.sendChunks(ctx, event.PayloadRef, "result", output, ep.config.MaxOutputBytes)
+		event.OutputChunks = count
+		if err != nil {
+			ep.log.Warning("Abandoning chunked output of %s/%s: %v", event.PrimitiveType, event.PrimitiveName, err)
+			event.PayloadIncomplete = true
+		}
+	}
+}
+
+// sendChunks splits data into chunks of at most chunkSize, measured like the
+// size caps, and sends them in order, stopping at the first chunk that fails
+// after retries
+func (ep *EventProcessor) sendChunks(ctx context.Context, ref string, field string, data string, chunkSize int) (int, error) {
+	pieces := splitPayload(data, chunkSize, ep.config.SizeLimitsInRunes)
+	for i, piece := range pieces {
+		chunk := EventChunk{
+			PayloadRef: ref,
+			Field:      field,
+			Index:      i,
+			Count:      len(pieces),
+			Data:       piece,
+		}
+		if err := ep.sendChunk(ctx, &chunk); err != nil {
+			return len(pieces), fmt.Errorf("chunk %d/%d: %v", i+1, len(pieces), err)
+		}
+	}
+	return len(pieces), nil
+}
+
+// sendChunk sends a single chunk with retries, giving up once ctx is done
+func (ep *EventProcessor) sendChunk(ctx context.Context, chunk *EventChunk) error {
+	jsonData, err := json.Marshal(chunk)
+	if err != nil {
+		return fmt.Errorf("failed to marshal chunk: %v", err)
+	}
+
+	url := fmt.Sprintf("%s/api/v1/capture-event-chunk", ep.endpoint)
+
+	var lastErr error
+	maxRetries := ep.config.maxRetries()
+	for attempt := 0; attempt <= maxRetries; attempt++ {
+		if attempt > 0 {
+			ep.log.Debug("Retrying chunk send (attempt %d/%d)", attempt, maxRetries)
+			if err := sleepContext(ctx, ep.config.RetryDelay); err != nil {
+				return err
+			}
+		}
+
+		req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewReader(jsonData))
+		if err != nil {
+			return fmt.Errorf("failed to create chunk request: %v", err)
+		}
+		addHeaders(req, ep.config.Headers)
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("X-Org-id", ep.orgID)
+		authorize(req, ep.apiKey)
+
+		// Don't dial an endpoint known to be unreachable
+		if err := ep.backoff.check(); err != nil {
+			return err
+		}
+		resp, err := doRequest(ep.httpClient, req, ep.config.requestTimeout())
+		if err != nil && ctx.Err() != nil {
+			return ctx.Err()
+		}
+		ep.backoff.observe(err)
+		if err != nil {
+			lastErr = err
+			if endpointUnreachable(err) {
+				return lastErr
+			}
+			continue
+		}
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+
+		if err := ep.auth.observe(resp.StatusCode); err != nil {
+			ep.backoff.observe(err)
+			return err
+		}
+		if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+			return nil
+		}
+		lastErr = fmt.Errorf("chunk send failed with status %d: %s", resp.StatusCode, string(body))
+	}
+
+	return lastErr
+}
+
+// splitPayload splits s into pieces of at most n runes with inRunes, or n
+// bytes otherwise, without splitting runes
+func splitPayload(s string, n int, inRunes bool) []string {
+	var pieces []string
+	for len(s) > 0 {
+		cut := payloadPrefix(s, n, inRunes)
+		if cut == 0 {
+			// A single rune wider than n; send it whole
+			cut = len(s)
+			for i := 1; i < len(s); i++ {
+				if utf8.RuneStart(s[i]) {
+					cut = i
+					break
+				}
+			}
+		}
+		pieces = append(pieces, s[:cut])
+		s = s[cut:]
+	}
+	return pieces
+}
@@ -0,0 +1,154 @@
+package agnost
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"unicode/utf8"
+)
+
+// chunkCollector is an event collector receiving oversized payloads in
+// chunks, if it advertises them
+type chunkCollector struct {
+	*eventCollector
+	server *httptest.Server
+
+	mu     sync.Mutex
+	chunks []EventChunk
+}
+
+// newChunkCollector returns a collector advertising event chunks if
+// chunked, failing the chunk at index fail if it isn't negative
+func newChunkCollector(t *testing.T, chunked bool, fail int) *chunkCollector {
+	c := &chunkCollector{eventCollector: newEventCollector(t)}
+	c.server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/v1/capabilities":
+			if !chunked {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			json.NewEncoder(w).Encode(capabilitiesResponse{Capabilities: []string{capabilityEventChunks}})
+		case "/api/v1/capture-event-chunk":
+			var chunk EventChunk
+			json.NewDecoder(r.Body).Decode(&chunk)
+			if chunk.Index == fail {
+				w.WriteHeader(http.StatusInternalServerError)
+				return
+			}
+			c.mu.Lock()
+			defer c.mu.Unlock()
+			if len(c.eventCollector.Events("tool")) > 0 {
+				t.Error("chunk received after the event referencing it")
+			}
+			c.chunks = append(c.chunks, chunk)
+		default:
+			c.eventCollector.Config.Handler.ServeHTTP(w, r)
+		}
+	}))
+	t.Cleanup(c.server.Close)
+	return c
+}
+
+func (c *chunkCollector) Chunks() []EventChunk {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]EventChunk(nil), c.chunks...)
+}
+
+// recordLargeOutput records a tool event whose output exceeds MaxOutputBytes,
+// on a tool that opted into large payloads, returning the serialized output
+func recordLargeOutput(t *testing.T, collector *chunkCollector) string {
+	_, a, _ := newTrackedServer(t, func(config *AgnostConfig) {
+		config.SessionEndpoint = config.Endpoint
+		config.Endpoint = collector.server.URL
+		config.MaxOutputBytes = 100
+		config.ToolOverrides = map[string]ToolOverride{"large": {CaptureLargePayloads: true}}
+	})
+	output := strings.Repeat("é", 120)
+	if err := a.RecordEvent("tool", "large", nil, 1, true, output); err != nil {
+		t.Fatal(err)
+	}
+	serialized, _ := json.Marshal(output)
+	return string(serialized)
+}
+
+func TestLargePayloadsAreDeliveredInChunks(t *testing.T) {
+	collector := newChunkCollector(t, true, -1)
+	output := recordLargeOutput(t, collector)
+
+	events := collector.Events("tool")
+	if len(events) != 1 {
+		t.Fatalf("got %d events, want 1", len(events))
+	}
+	event := events[0]
+	chunks := collector.Chunks()
+	if len(chunks) == 0 || event.OutputChunks != len(chunks) || event.PayloadRef == "" || event.PayloadIncomplete {
+		t.Fatalf("event references %d chunks as %q (incomplete %v), collector got %d",
+			event.OutputChunks, event.PayloadRef, event.PayloadIncomplete, len(chunks))
+	}
+	if event.Output != "" {
+		t.Errorf("event carries output %q besides its chunks", event.Output)
+	}
+	var data strings.Builder
+	for i, chunk := range chunks {
+		if chunk.PayloadRef != event.PayloadRef || chunk.Field != "result" || chunk.Index != i || chunk.Count != len(chunks) {
+			t.Errorf("chunk %d is %+v", i, chunk)
+		}
+		if len(chunk.Data) > 100 || !utf8.ValidString(chunk.Data) {
+			t.Errorf("chunk %d holds %d bytes, split mid-rune or past the cap", i, len(chunk.Data))
+		}
+		data.WriteString(chunk.Data)
+	}
+	if data.String() != output {
+		t.Error("chunks don't add up to the serialized output")
+	}
+}
+
+func TestLargePayloadsAreTruncatedWithoutChunkSupport(t *testing.T) {
+	collector := newChunkCollector(t, false, -1)
+	recordLargeOutput(t, collector)
+
+	event := collector.Events("tool")[0]
+	if len(collector.Chunks()) != 0 || event.PayloadRef != "" || event.OutputChunks != 0 {
+		t.Errorf("sent chunks to a collector without chunk support")
+	}
+	var marker truncatedPayload
+	if err := json.Unmarshal([]byte(event.Output), &marker); err != nil || !marker.Truncated || len(event.Output) > 100 {
+		t.Errorf("got output %s, want a truncation marker within the cap", event.Output)
+	}
+}
+
+func TestFailedChunksFlagThePayloadIncomplete(t *testing.T) {
+	collector := newChunkCollector(t, true, 1)
+	recordLargeOutput(t, collector)
+
+	event := collector.Events("tool")[0]
+	if !event.PayloadIncomplete {
+		t.Error("event with a failed chunk isn't flagged as incomplete")
+	}
+	if got := len(collector.Chunks()); got != 1 {
+		t.Errorf("collector got %d chunks, want the one before the failure", got)
+	}
+}
+
+func TestSplitPayloadKeepsRunesWhole(t *testing.T) {
+	for _, inRunes := range []bool{false, true} {
+		pieces := splitPayload("aé€😀", 2, inRunes)
+		if strings.Join(pieces, "") != "aé€😀" {
+			t.Fatalf("in runes %v: pieces %q don't add up to the payload", inRunes, pieces)
+		}
+		for _, piece := range pieces {
+			if !utf8.ValidString(piece) {
+				t.Errorf("in runes %v: split mid-rune into %q", inRunes, pieces)
+			}
+		}
+	}
+	// Without runes, a rune wider than the cap is sent whole
+	if pieces := splitPayload("😀😀", 2, false); len(pieces) != 2 || pieces[0] != "😀" {
+		t.Errorf("got pieces %q, want each emoji whole", pieces)
+	}
+}
@@ -0,0 +1,147 @@
+package agnost
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// Client tracks one MCP server for one organization. Clients are independent:
+// each has sessions, a queue and a worker of its own, so a process hosting
+// servers for several organizations creates one client per server. The
+// package-level functions use a default client. Each client logs with the
+// logger and level of its own configuration; messages not tied to a client go
+// to the default client's.
+type Client struct {
+	analytics *AgnostAnalytics
+	orgID     string
+	config    *Config
+
+	mu     sync.Mutex
+	server *server.MCPServer // the tracked server, nil until Track
+}
+
+// defaultClient backs the package-level functions; its organization and
+// configuration are given to Track
+var defaultClient = &Client{analytics: globalClient}
+
+// New creates a client sending the analytics of the server it tracks to orgID,
+// or to AGNOST_ORG_ID if orgID is empty. A nil config uses DefaultConfig; the
+// fields a config leaves unset are filled from the environment, see
+// DefaultConfig.
+//
+// Example:
+//
+//	client, err := agnost.New("your-org-id", &agnost.Config{LogLevel: "info"})
+//	if err != nil {
+//	    log.Fatal(err)
+//	}
+//	defer client.Shutdown()
+//	err = client.Track(s)
+func New(orgID string, config *Config) (*Client, error) {
+	if config == nil {
+		config = DefaultConfig()
+	}
+	orgID = orgIDOrEnv(orgID)
+	config, err := withEnv(config)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkConfig(orgID, config); err != nil {
+		return nil, err
+	}
+	return &Client{
+		analytics: NewAgnostAnalytics(),
+		orgID:     orgID,
+		config:    config,
+	}, nil
+}
+
+// Track enables analytics tracking for s, see the package-level Track. A
+// client tracks a single server; tracking s again is a no-op, and tracking
+// another server fails.
+func (c *Client) Track(s *server.MCPServer) error {
+	return c.track(s, c.orgID, c.config)
+}
+
+// track tracks s with the given organization and configuration
+func (c *Client) track(s *server.MCPServer, orgID string, config *Config) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.server != nil && c.server != s {
+		return fmt.Errorf("client already tracks a server; create another client with New to track more")
+	}
+	if err := c.analytics.TrackMCP(s, orgID, config); err != nil {
+		return err
+	}
+	c.server = s
+	return nil
+}
+
+// Flush sends every event the client queued so far, see AgnostAnalytics.Flush
+func (c *Client) Flush(ctx context.Context) error {
+	return c.analytics.Flush(ctx)
+}
+
+// Shutdown gracefully shuts down the client, within 30 seconds
+func (c *Client) Shutdown() {
+	c.analytics.Shutdown()
+}
+
+// ShutdownContext gracefully shuts down the client, see
+// AgnostAnalytics.ShutdownContext
+func (c *Client) ShutdownContext(ctx context.Context) error {
+	return c.analytics.ShutdownContext(ctx)
+}
+
+// Disable turns the client's tracking off, see AgnostAnalytics.Disable
+func (c *Client) Disable() {
+	c.analytics.Disable()
+}
+
+// Enable turns the client's tracking back on
+func (c *Client) Enable() {
+	c.analytics.Enable()
+}
+
+// Identify sets the identity of the client's session
+func (c *Client) Identify(identity UserIdentity) {
+	c.analytics.Identify(identity)
+}
+
+// IdentifyContext sets the identity of the client's session of the call with ctx
+func (c *Client) IdentifyContext(ctx context.Context, identity UserIdentity) {
+	c.analytics.IdentifyContext(ctx, identity)
+}
+
+// Capture records an app-level event with the client
+func (c *Client) Capture(ctx context.Context, name string, properties map[string]any) error {
+	return c.analytics.Capture(ctx, name, properties)
+}
+
+// Hooks returns hooks with every hook of the client, to pass to
+// server.WithHooks when creating the server it tracks, see AgnostAnalytics.Hooks
+func (c *Client) Hooks() *server.Hooks {
+	return c.analytics.Hooks()
+}
+
+// Stats returns a snapshot of the client's internal state
+func (c *Client) Stats() Stats {
+	return c.analytics.Stats()
+}
+
+// MetricsHandler returns an http.Handler serving the client's metrics in the
+// Prometheus text format
+func (c *Client) MetricsHandler() http.Handler {
+	return c.analytics.MetricsHandler()
+}
+
+// Analytics returns the analytics client behind c, for the rest of its API,
+// such as RecordEvent and Sessions
+func (c *Client) Analytics() *AgnostAnalytics {
+	return c.analytics
+}
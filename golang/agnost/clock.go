@@ -0,0 +1,101 @@
+package agnost
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// clockJumpThreshold is how far the wall clock must move apart from the
+// monotonic clock between two checks to count as a jump, well above what NTP
+// slewing does in one worker tick
+const clockJumpThreshold = time.Minute
+
+// clock reads the wall and the monotonic time. The wall clock jumps when the
+// system time is set or the machine wakes from sleep, while the monotonic
+// clock only advances while the machine runs, so the SDK's time windows
+// (delivery rollup buckets, delivery latencies, endpoint cooldowns) are
+// measured with the monotonic clock.
+type clock struct {
+	wall      func() time.Time     // without a monotonic reading
+	monotonic func() time.Duration // time since an arbitrary fixed point
+
+	// origin is the wall time when the clock was created, and originMonotonic
+	// the monotonic time then; together they anchor steady times
+	origin          time.Time
+	originMonotonic time.Duration
+}
+
+func newClock(wall func() time.Time, monotonic func() time.Duration) *clock {
+	return &clock{
+		wall:            wall,
+		monotonic:       monotonic,
+		origin:          wall(),
+		originMonotonic: monotonic(),
+	}
+}
+
+// systemClock reads the system's clocks, the monotonic one through the
+// monotonic reading Go keeps in every time.Now
+var systemClock = func() *clock {
+	start := time.Now()
+	return newClock(
+		func() time.Time { return time.Now().Round(0) },
+		func() time.Duration { return time.Since(start) },
+	)
+}()
+
+// steady returns the wall time the clock was created at plus the monotonic
+// time elapsed since. It falls behind the wall clock by the time the machine
+// slept, but never jumps.
+func (c *clock) steady() time.Time {
+	return c.origin.Add(c.monotonic() - c.originMonotonic)
+}
+
+// clockJumpDetector compares the wall and monotonic time elapsed between checks
+// to notice wall-clock jumps
+type clockJumpDetector struct {
+	clock *clock
+
+	mu            sync.Mutex
+	lastWall      time.Time
+	lastMonotonic time.Duration
+
+	jumps atomic.Int64
+}
+
+func newClockJumpDetector(c *clock) *clockJumpDetector {
+	return &clockJumpDetector{
+		clock:         c,
+		lastWall:      c.wall(),
+		lastMonotonic: c.monotonic(),
+	}
+}
+
+// check returns how far the wall clock jumped since the previous check, if
+// it did. The first jump is logged as a warning, later ones at debug level.
+func (d *clockJumpDetector) check() (time.Duration, bool) {
+	wall, monotonic := d.clock.wall(), d.clock.monotonic()
+
+	d.mu.Lock()
+	elapsed := monotonic - d.lastMonotonic
+	jump := wall.Sub(d.lastWall) - elapsed
+	d.lastWall, d.lastMonotonic = wall, monotonic
+	d.mu.Unlock()
+
+	if jump > -clockJumpThreshold && jump < clockJumpThreshold {
+		return 0, false
+	}
+
+	direction := "forward"
+	if jump < 0 {
+		direction = "backward"
+	}
+	log := Debug
+	if d.jumps.Add(1) == 1 {
+		log = Warning
+	}
+	log("System clock jumped %s %s over %s of monotonic time, e.g. after the machine slept; delivery windows and cooldowns keep following the monotonic clock",
+		direction, jump.Abs().Round(time.Second), elapsed.Round(time.Millisecond))
+	return jump, true
+}
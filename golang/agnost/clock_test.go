@@ -0,0 +1,53 @@
+package agnost
+
+import (
+	"testing"
+	"time"
+)
+
+func TestClockJumpsAreDetectedBothWays(t *testing.T) {
+	capturePackageLogger(t)
+	c, m := newManualClock(time.Date(2026, 1, 1, 12, 0, 30, 0, time.UTC))
+	d := newClockJumpDetector(c)
+
+	m.advance(5 * time.Second)
+	if jump, ok := d.check(); ok {
+		t.Errorf("steady time detected as a %v jump", jump)
+	}
+
+	m.advance(5 * time.Second)
+	m.jump(2 * time.Hour)
+	if jump, ok := d.check(); !ok || jump != 2*time.Hour {
+		t.Errorf("got jump %v, %v, want 2h forward", jump, ok)
+	}
+	m.jump(-2 * time.Hour)
+	if jump, ok := d.check(); !ok || jump != -2*time.Hour {
+		t.Errorf("got jump %v, %v, want 2h backward", jump, ok)
+	}
+	if got := d.jumps.Load(); got != 2 {
+		t.Errorf("counted %d jumps, want 2", got)
+	}
+}
+
+func TestDeliveriesSurviveAWallClockJump(t *testing.T) {
+	c, m := newManualClock(time.Date(2026, 1, 1, 12, 0, 30, 0, time.UTC))
+	r := newDeliveryRollup(c)
+	m.advance(time.Second) // a zero monotonic time means unknown
+	r.record(outcomeDelivered, 0, c.monotonic())
+
+	// An event recorded just before the wall clock jumps 2 hours ahead
+	enqueuedAt, enqueuedMonotonic := c.wall().UnixMilli(), c.monotonic()
+	m.jump(2 * time.Hour)
+	m.advance(10 * time.Millisecond)
+	r.record(outcomeDelivered, enqueuedAt, enqueuedMonotonic)
+
+	if !r.deliveredWithin(time.Minute) {
+		t.Error("the jump expired the deliveries of the last minute")
+	}
+	if got := r.recent()[rollupBuckets-1].Sent; got != 2 {
+		t.Errorf("current bucket has %d deliveries, want both", got)
+	}
+	if report := r.report(time.Minute); report.OnTime != 2 {
+		t.Errorf("%d of 2 deliveries on time, want the jump not to count as latency", report.OnTime)
+	}
+}
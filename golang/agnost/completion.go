@@ -0,0 +1,107 @@
+package agnost
+
+import (
+	"context"
+	"math/rand/v2"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// defaultCompletionSampleRate is used when Config.CompletionSampleRate is not
+// set. Clients request completions on every keystroke, so most are skipped.
+const defaultCompletionSampleRate = 0.05
+
+// CompletionHandlerFunc handles completion/complete requests
+type CompletionHandlerFunc func(ctx context.Context, request mcp.CompleteRequest) (*mcp.CompleteResult, error)
+
+// WrapCompletionHandler wraps a completion handler with the global analytics
+// client's tracking, see AgnostAnalytics.WrapCompletionHandler
+func WrapCompletionHandler(handler CompletionHandlerFunc) CompletionHandlerFunc {
+	return globalClient.WrapCompletionHandler(handler)
+}
+
+// WrapCompletionHandler wraps a completion handler so that, with
+// Config.TrackCompletions, a sample of completion requests is recorded as
+// "completion" events named after the prompt or resource they complete for.
+// Events carry the argument name and the number of suggestions returned; the
+// partial value the user typed is only captured with input capture enabled.
+//
+// mcp-go doesn't route completion/complete requests to servers, so Track can't
+// patch them in; servers that answer completions themselves wrap their handler.
+func (a *AgnostAnalytics) WrapCompletionHandler(handler CompletionHandlerFunc) CompletionHandlerFunc {
+	return func(ctx context.Context, request mcp.CompleteRequest) (*mcp.CompleteResult, error) {
+		sampleRate, track := a.completionSampling()
+		if !track || !a.Enabled() {
+			return handler(ctx, request)
+		}
+		if rand.Float64() >= sampleRate {
+			a.drops.count(DropSampledOut)
+			return handler(ctx, request)
+		}
+
+		startTime := time.Now()
+		result, err := handler(ctx, request)
+		latency := time.Since(startTime).Milliseconds()
+
+		suggestions := 0
+		if result != nil {
+			suggestions = len(result.Completion.Values)
+		}
+
+		if recordErr := a.recordEvent(&eventRecord{
+			primitiveType: "completion",
+			primitiveName: completionRefName(request.Params.Ref),
+			args:          map[string]string{"value": request.Params.Argument.Value},
+			latency:       latency,
+			success:       err == nil,
+			attributes: map[string]any{
+				"argument":    request.Params.Argument.Name,
+				"suggestions": suggestions,
+				"empty":       suggestions == 0,
+			},
+		}); recordErr != nil {
+			a.logger().Debug("Failed to record completion event: %v", recordErr)
+		}
+
+		return result, err
+	}
+}
+
+// completionSampling returns the completion sample rate and whether
+// completions are tracked at all
+func (a *AgnostAnalytics) completionSampling() (float64, bool) {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	if !a.initialized || !a.config.TrackCompletions {
+		return 0, false
+	}
+	if a.config.CompletionSampleRate > 0 {
+		return a.config.CompletionSampleRate, true
+	}
+	return defaultCompletionSampleRate, true
+}
+
+// completionRefName returns the prompt name or resource URI a completion
+// request refers to
+func completionRefName(ref any) string {
+	switch r := ref.(type) {
+	case mcp.PromptReference:
+		return r.Name
+	case *mcp.PromptReference:
+		return r.Name
+	case mcp.ResourceReference:
+		return r.URI
+	case *mcp.ResourceReference:
+		return r.URI
+	case map[string]any:
+		// Decoded from JSON without a concrete type
+		if name, ok := r["name"].(string); ok {
+			return name
+		}
+		if uri, ok := r["uri"].(string); ok {
+			return uri
+		}
+	}
+	return "unknown"
+}
@@ -0,0 +1,36 @@
+package agnost
+
+import (
+	"bytes"
+	"compress/gzip"
+)
+
+// Encodings of event request bodies, see Config.Compression
+const (
+	CompressionNone = "none"
+	CompressionGzip = "gzip"
+)
+
+// compressMinBytes is the smallest payload that is compressed; smaller ones
+// would barely shrink, if at all
+const compressMinBytes = 1024
+
+// compressBody returns the request body of a serialized payload and its
+// Content-Encoding, empty if it is sent as is. A payload that fails to
+// compress is sent uncompressed.
+func (ep *EventProcessor) compressBody(payload []byte) ([]byte, string) {
+	if ep.config.Compression != CompressionGzip || len(payload) < compressMinBytes {
+		return payload, ""
+	}
+	var buf bytes.Buffer
+	writer := gzip.NewWriter(&buf)
+	if _, err := writer.Write(payload); err != nil {
+		ep.log.Debug("Failed to compress payload, sending it uncompressed: %v", err)
+		return payload, ""
+	}
+	if err := writer.Close(); err != nil {
+		ep.log.Debug("Failed to compress payload, sending it uncompressed: %v", err)
+		return payload, ""
+	}
+	return buf.Bytes(), CompressionGzip
+}
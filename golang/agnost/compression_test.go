@@ -0,0 +1,121 @@
+package agnost
+
+import (
+	"compress/gzip"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// encodedRequest is a request body as a collector decodes it
+type encodedRequest struct {
+	path     string
+	encoding string
+	body     string
+}
+
+// newDecodingCollector returns a collector decoding gzip request bodies the
+// way the Agnost collector does, and the requests it received
+func newDecodingCollector(t *testing.T) (*httptest.Server, func() []encodedRequest) {
+	var mu sync.Mutex
+	var requests []encodedRequest
+	collector := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body io.Reader = r.Body
+		if r.Header.Get("Content-Encoding") == CompressionGzip {
+			reader, err := gzip.NewReader(r.Body)
+			if err != nil {
+				http.Error(w, "invalid gzip body", http.StatusBadRequest)
+				return
+			}
+			defer reader.Close()
+			body = reader
+		}
+		decoded, err := io.ReadAll(body)
+		if err != nil {
+			http.Error(w, "invalid gzip body", http.StatusBadRequest)
+			return
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		requests = append(requests, encodedRequest{r.URL.Path, r.Header.Get("Content-Encoding"), string(decoded)})
+	}))
+	t.Cleanup(collector.Close)
+	return collector, func() []encodedRequest {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]encodedRequest(nil), requests...)
+	}
+}
+
+func newCompressingProcessor(t *testing.T, endpoint, compression string) *EventProcessor {
+	config := DefaultConfig()
+	config.Logger = NewLogger(io.Discard)
+	config.MaxRetries = 0
+	config.Compression = compression
+	ep := NewEventProcessor(endpoint, "org", config)
+	t.Cleanup(ep.Shutdown)
+	return ep
+}
+
+func TestGzippedEventsDecodeToTheEvent(t *testing.T) {
+	collector, requests := newDecodingCollector(t)
+	ep := newCompressingProcessor(t, collector.URL, CompressionGzip)
+	large := strings.Repeat("gzip me ", compressMinBytes/4)
+
+	for _, input := range []string{large, "small"} {
+		event := &EventData{SessionID: "s", PrimitiveType: "tool", PrimitiveName: "echo", Input: input}
+		if err := ep.sendEvent(context.Background(), event); err != nil {
+			t.Fatalf("input of %d bytes: %v", len(input), err)
+		}
+	}
+	got := requests()
+	if len(got) != 2 {
+		t.Fatalf("collector got %d requests, want 2", len(got))
+	}
+	if got[0].encoding != CompressionGzip || !strings.Contains(got[0].body, large) {
+		t.Errorf("large event sent with encoding %q, not decoding to its input", got[0].encoding)
+	}
+	if got[1].encoding != "" || !strings.Contains(got[1].body, `"small"`) {
+		t.Errorf("small event sent with encoding %q and body %s, want it as is", got[1].encoding, got[1].body)
+	}
+}
+
+func TestGzippedBatchesDecodeToTheEvents(t *testing.T) {
+	collector, requests := newDecodingCollector(t)
+	ep := newCompressingProcessor(t, collector.URL, CompressionGzip)
+	large := strings.Repeat("gzip me ", compressMinBytes/4)
+
+	batch := []*EventData{
+		{SessionID: "s", PrimitiveType: "tool", PrimitiveName: "first", Input: large},
+		{SessionID: "s", PrimitiveType: "tool", PrimitiveName: "second"},
+	}
+	if retry, err := ep.sendBatch(batch); err != nil || len(retry) != 0 {
+		t.Fatalf("batch left %d events to retry: %v", len(retry), err)
+	}
+	got := requests()
+	if len(got) != 1 || got[0].path != "/api/v1/capture-events" || got[0].encoding != CompressionGzip {
+		t.Fatalf("collector got %+v, want one gzipped batch", got)
+	}
+	if !strings.Contains(got[0].body, large) || !strings.Contains(got[0].body, `"second"`) {
+		t.Error("gzipped batch doesn't decode to both events")
+	}
+}
+
+func TestUncompressedEventsAreSentAsIs(t *testing.T) {
+	large := strings.Repeat("gzip me ", compressMinBytes/4)
+	for _, compression := range []string{"", CompressionNone} {
+		collector, requests := newDecodingCollector(t)
+		ep := newCompressingProcessor(t, collector.URL, compression)
+		event := &EventData{SessionID: "s", PrimitiveType: "tool", PrimitiveName: "echo", Input: large}
+		if err := ep.sendEvent(context.Background(), event); err != nil {
+			t.Fatal(err)
+		}
+		if got := requests(); len(got) != 1 || got[0].encoding != "" {
+			t.Errorf("compression %q: sent %+v, want no encoding", compression, got)
+		}
+	}
+}
@@ -0,0 +1,51 @@
+package agnost
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+func TestEventsRecordTheCallsInFlight(t *testing.T) {
+	const calls = 3
+	s, a, collector := newTrackedServer(t, nil)
+	var started sync.WaitGroup
+	started.Add(calls)
+	release := make(chan struct{})
+	s.AddTool(mcp.NewTool("wait"), func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		started.Done()
+		<-release
+		return mcp.NewToolResultText("ok"), nil
+	})
+
+	var done sync.WaitGroup
+	for range calls {
+		done.Add(1)
+		go func() {
+			defer done.Done()
+			callTool(t, s, "wait")
+		}()
+	}
+	started.Wait()
+	close(release)
+	done.Wait()
+
+	var highest int64
+	for _, event := range collector.Events("tool") {
+		if event.ConcurrentCalls < 1 || event.ConcurrentCalls > calls {
+			t.Errorf("event recorded %d calls in flight, want 1 to %d", event.ConcurrentCalls, calls)
+		}
+		highest = max(highest, event.ConcurrentCalls)
+	}
+	if highest != calls {
+		t.Errorf("events recorded %d calls in flight at most, want %d", highest, calls)
+	}
+
+	a.Shutdown()
+	ends := collector.Ends()
+	if len(ends) != 1 || ends[0].MaxConcurrentCalls != calls {
+		t.Errorf("session ends %+v, want one with %d concurrent calls at most", ends, calls)
+	}
+}
@@ -0,0 +1,76 @@
+package agnost
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// callCorrelated calls the echo tool with the given _meta
+func callCorrelated(t *testing.T, s *server.MCPServer, meta map[string]any) {
+	t.Helper()
+	handleRequest(t, s, "tools/call", map[string]any{"name": "echo", "arguments": map[string]any{}, "_meta": meta})
+}
+
+func TestCorrelationIDsAreRecordedFromTheConfiguredKey(t *testing.T) {
+	s, _, collector := newTrackedServer(t, func(config *AgnostConfig) {
+		config.CorrelationMetaKey = "trace"
+	})
+	addEchoTool(s)
+
+	callCorrelated(t, s, map[string]any{"trace": "run-1"})
+	callCorrelated(t, s, map[string]any{"correlationId": "ignored"})
+	callCorrelated(t, s, map[string]any{"trace": strings.Repeat("x", 200)})
+
+	events := collector.Events("tool")
+	if len(events) != 3 {
+		t.Fatalf("got %d tool events, want 3", len(events))
+	}
+	if got := events[0].CorrelationID; got != "run-1" {
+		t.Errorf("got correlation ID %q, want run-1", got)
+	}
+	if got := events[1].CorrelationID; got != "" {
+		t.Errorf("default key recorded correlation ID %q with CorrelationMetaKey set", got)
+	}
+	if got := len(events[2].CorrelationID); got != maxCorrelationIDBytes {
+		t.Errorf("long correlation ID recorded with %d bytes, want %d", got, maxCorrelationIDBytes)
+	}
+	// Without SessionFromCorrelation every call stays in the client's session
+	for _, event := range events[1:] {
+		if event.SessionID != events[0].SessionID {
+			t.Errorf("events are in sessions %s and %s, want one", events[0].SessionID, event.SessionID)
+		}
+	}
+}
+
+func TestSessionFromCorrelationGroupsCallsByCorrelationID(t *testing.T) {
+	s, _, collector := newTrackedServer(t, func(config *AgnostConfig) {
+		config.SessionFromCorrelation = true
+	})
+	addEchoTool(s)
+	trackSession := collector.Sessions()[0].SessionID
+
+	callCorrelated(t, s, map[string]any{"correlationId": "a"})
+	callCorrelated(t, s, map[string]any{"correlationId": "b"})
+	callCorrelated(t, s, map[string]any{"correlationId": "a"})
+	callCorrelated(t, s, map[string]any{})
+
+	events := collector.Events("tool")
+	if len(events) != 4 {
+		t.Fatalf("got %d tool events, want 4", len(events))
+	}
+	a1, b, a2, uncorrelated := events[0].SessionID, events[1].SessionID, events[2].SessionID, events[3].SessionID
+	if a1 != a2 {
+		t.Errorf("calls of correlation ID a are in sessions %s and %s", a1, a2)
+	}
+	if a1 == b || a1 == trackSession || b == trackSession {
+		t.Errorf("correlation IDs a and b got sessions %s and %s next to %s, want their own", a1, b, trackSession)
+	}
+	if uncorrelated != trackSession {
+		t.Errorf("uncorrelated call is in session %s, want the client's %s", uncorrelated, trackSession)
+	}
+	if got := len(collector.Sessions()); got != 3 {
+		t.Errorf("registered %d sessions, want 3", got)
+	}
+}
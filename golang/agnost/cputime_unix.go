@@ -0,0 +1,18 @@
+//go:build unix
+
+package agnost
+
+import (
+	"syscall"
+	"time"
+)
+
+// processCPUTime returns the user and system CPU time the whole process used
+// so far, or false if it can't be read
+func processCPUTime() (time.Duration, bool) {
+	var usage syscall.Rusage
+	if err := syscall.Getrusage(syscall.RUSAGE_SELF, &usage); err != nil {
+		return 0, false
+	}
+	return time.Duration(usage.Utime.Nano() + usage.Stime.Nano()), true
+}
@@ -0,0 +1,61 @@
+//go:build unix
+
+package agnost
+
+import (
+	"runtime"
+	"testing"
+	"time"
+)
+
+// burnCPU keeps a core busy for about d
+func burnCPU(d time.Duration) {
+	for deadline := time.Now().Add(d); time.Now().Before(deadline); {
+	}
+}
+
+func TestProcessCPUTimeGrowsWithWork(t *testing.T) {
+	before, ok := processCPUTime()
+	if !ok {
+		t.Fatal("process CPU time is unavailable")
+	}
+	burnCPU(50 * time.Millisecond)
+	after, _ := processCPUTime()
+	if used := after - before; used < 10*time.Millisecond {
+		t.Errorf("50ms of busy work used %v of CPU time", used)
+	}
+}
+
+func TestCPUSamplerReportsTheTimeSinceTheLastSample(t *testing.T) {
+	var s cpuSampler
+	if _, _, ok := s.sample(); ok {
+		t.Error("the first sample reported usage without a previous one")
+	}
+	burnCPU(50 * time.Millisecond)
+	cpu, wall, ok := s.sample()
+	if !ok || cpu < 10*time.Millisecond || wall < 50*time.Millisecond {
+		t.Errorf("got %v of CPU over %v (%v), want the busy work", cpu, wall, ok)
+	}
+
+	attributes := map[string]any{}
+	burnCPU(20 * time.Millisecond)
+	s.addStats(attributes)
+	cores, _ := attributes["process_cpu_cores"].(float64)
+	if _, ok := attributes["process_cpu_ms"].(int64); !ok || cores <= 0 || cores > float64(runtime.NumCPU())+1 {
+		t.Errorf("got CPU attributes %v, want the time used and plausible busy cores", attributes)
+	}
+}
+
+func TestDeliveryReportsCarryCPUTime(t *testing.T) {
+	_, _, collector := newTrackedServer(t, func(config *AgnostConfig) {
+		config.DeliveryReportInterval = 20 * time.Millisecond
+		config.CollectCPUTime = true
+	})
+	attributes := awaitDeliveryReport(t, collector)
+	if got, ok := attributes["process_cpu_ms"].(float64); !ok || got < 0 {
+		t.Errorf("reported process_cpu_ms %v, want a duration", attributes["process_cpu_ms"])
+	}
+	if got, ok := attributes["process_cpu_cores"].(float64); !ok || got < 0 || got > float64(runtime.NumCPU())+1 {
+		t.Errorf("reported process_cpu_cores %v, want at most the cores available", attributes["process_cpu_cores"])
+	}
+}
@@ -0,0 +1,148 @@
+package agnost
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"math"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// ErrNotTracked is returned when recording an event before Track
+var ErrNotTracked = errors.New("SDK not initialized: call Track first")
+
+// PrimitiveTypeCustom is the primitive type of the events recorded with Capture
+const PrimitiveTypeCustom = "custom"
+
+// Reserved Capture properties, which set fields of the event
+const (
+	// PropertySuccess reports whether the milestone succeeded (default: true)
+	PropertySuccess = "$success"
+
+	// PropertyLatencyMs is how long the milestone took, in milliseconds
+	PropertyLatencyMs = "$latency_ms"
+)
+
+// Capture records an app-level event with the global analytics client
+func Capture(ctx context.Context, name string, properties map[string]any) error {
+	return globalClient.Capture(ctx, name, properties)
+}
+
+// Capture records an app-level event named name with the given properties
+func (a *AgnostAnalytics) Capture(ctx context.Context, name string, properties map[string]any) error {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return errors.New("event name is required")
+	}
+	properties, success, latency, err := reservedProperties(properties)
+	if err != nil {
+		return fmt.Errorf("event %q: %w", name, err)
+	}
+
+	var sessionID string
+	if state := callStateFromContext(ctx); state != nil && state.pin != nil {
+		sessionID = state.pin.sessionID()
+	}
+
+	var args any
+	if len(properties) > 0 {
+		args = properties
+	}
+	err = a.recordEvent(&eventRecord{
+		ctx:           ctx,
+		sessionID:     sessionID,
+		primitiveType: PrimitiveTypeCustom,
+		primitiveName: name,
+		args:          args,
+		latency:       latency,
+		success:       success,
+	})
+
+	// Events captured while shutting down are dropped silently
+	if errors.Is(err, errShuttingDown) {
+		return nil
+	}
+	return err
+}
+
+// reservedProperties splits the reserved properties off into event fields
+func reservedProperties(properties map[string]any) (map[string]any, bool, int64, error) {
+	success, latency := true, int64(0)
+	reserved := false
+	for key := range properties {
+		if strings.HasPrefix(key, "$") {
+			reserved = true
+			break
+		}
+	}
+	if !reserved {
+		return properties, success, latency, nil
+	}
+
+	properties = maps.Clone(properties)
+	for key, value := range properties {
+		if !strings.HasPrefix(key, "$") {
+			continue
+		}
+		delete(properties, key)
+		switch key {
+		case PropertySuccess:
+			b, ok := value.(bool)
+			if !ok {
+				return nil, false, 0, fmt.Errorf("property %s must be a bool, not %T", key, value)
+			}
+			success = b
+		case PropertyLatencyMs:
+			ms, ok := milliseconds(value)
+			if !ok {
+				return nil, false, 0, fmt.Errorf("property %s must be a non-negative number or time.Duration, not %v", key, value)
+			}
+			latency = ms
+		default:
+			return nil, false, 0, fmt.Errorf("property %s is reserved", key)
+		}
+	}
+	return properties, success, latency, nil
+}
+
+// milliseconds returns a latency given as a number of milliseconds, of any
+// numeric type or as a json.Number, or a time.Duration
+func milliseconds(value any) (int64, bool) {
+	switch v := value.(type) {
+	case time.Duration:
+		return v.Milliseconds(), v >= 0
+	case json.Number:
+		if ms, err := v.Int64(); err == nil {
+			return ms, ms >= 0
+		}
+		f, err := v.Float64()
+		if err != nil {
+			return 0, false
+		}
+		return floatMilliseconds(f)
+	}
+
+	rv := reflect.ValueOf(value)
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return rv.Int(), rv.Int() >= 0
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return int64(rv.Uint()), rv.Uint() <= math.MaxInt64
+	case reflect.Float32, reflect.Float64:
+		return floatMilliseconds(rv.Float())
+	}
+	return 0, false
+}
+
+// floatMilliseconds truncates a latency in milliseconds, rejecting NaN and
+// values out of range
+func floatMilliseconds(f float64) (int64, bool) {
+	if math.IsNaN(f) || f < 0 || f >= math.MaxInt64 {
+		return 0, false
+	}
+	return int64(f), true
+}
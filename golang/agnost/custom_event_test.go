@@ -0,0 +1,82 @@
+package agnost
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"math"
+	"testing"
+	"time"
+)
+
+func TestMilliseconds(t *testing.T) {
+	tests := []struct {
+		value any
+		want  int64
+		ok    bool
+	}{
+		{1500 * time.Millisecond, 1500, true},
+		{12, 12, true},
+		{int32(12), 12, true},
+		{int8(12), 12, true},
+		{uint(12), 12, true},
+		{uint64(12), 12, true},
+		{float32(12.5), 12, true},
+		{12.9, 12, true},
+		{json.Number("12"), 12, true},
+		{json.Number("12.5"), 12, true},
+		{-1, 0, false},
+		{-time.Second, 0, false},
+		{json.Number("-3"), 0, false},
+		{json.Number("soon"), 0, false},
+		{uint64(math.MaxUint64), 0, false},
+		{math.NaN(), 0, false},
+		{math.Inf(1), 0, false},
+		{math.Inf(-1), 0, false},
+		{"12", 0, false},
+		{nil, 0, false},
+	}
+	for _, tt := range tests {
+		got, ok := milliseconds(tt.value)
+		if ok != tt.ok || (ok && got != tt.want) {
+			t.Errorf("milliseconds(%#v) = %d, %v, want %d, %v", tt.value, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestReservedPropertiesSetEventFields(t *testing.T) {
+	properties := map[string]any{"step": "export", PropertySuccess: false, PropertyLatencyMs: int32(42)}
+	rest, success, latency, err := reservedProperties(properties)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if success || latency != 42 {
+		t.Errorf("got success %v, latency %d, want false, 42", success, latency)
+	}
+	if len(rest) != 1 || rest["step"] != "export" {
+		t.Errorf("got properties %v, want only step", rest)
+	}
+	if len(properties) != 3 {
+		t.Error("the caller's properties were changed")
+	}
+
+	for _, properties := range []map[string]any{
+		{PropertySuccess: "yes"},
+		{PropertyLatencyMs: math.NaN()},
+		{"$user": "u1"},
+	} {
+		if _, _, _, err := reservedProperties(properties); err == nil {
+			t.Errorf("accepted %v", properties)
+		}
+	}
+}
+
+func TestCaptureBeforeTrack(t *testing.T) {
+	a := NewAgnostAnalytics()
+	if err := a.Capture(context.Background(), "workflow_completed", nil); !errors.Is(err, ErrNotTracked) {
+		t.Errorf("got %v, want ErrNotTracked", err)
+	}
+	if err := a.Capture(context.Background(), " ", nil); err == nil || errors.Is(err, ErrNotTracked) {
+		t.Errorf("got %v for an empty name", err)
+	}
+}
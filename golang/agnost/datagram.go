@@ -0,0 +1,213 @@
+package agnost
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+)
+
+const (
+	// defaultDatagramMaxBytes keeps datagrams within a typical path MTU
+	defaultDatagramMaxBytes = 1400
+	// datagramSessionInterval is how often active sessions are re-announced,
+	// since any single session datagram may be lost
+	datagramSessionInterval = time.Minute
+)
+
+// Datagram envelope types
+const (
+	datagramTypeEvent         = "event"
+	datagramTypeSession       = "session"
+	datagramTypeSessions      = "sessions"
+	datagramTypeSessionUpdate = "session_update"
+	datagramTypeSessionEnd    = "session_end"
+)
+
+// datagramTypes maps API paths to the envelope type used for their payloads
+var datagramTypes = map[string]string{
+	"/api/v1/capture-event":          datagramTypeEvent,
+	"/api/v1/capture-session":        datagramTypeSession,
+	"/api/v1/capture-sessions":       datagramTypeSessions,
+	"/api/v1/capture-session-update": datagramTypeSessionUpdate,
+	"/api/v1/capture-session-end":    datagramTypeSessionEnd,
+}
+
+// datagramEnvelope wraps a payload sent as a datagram, since datagrams carry no headers
+type datagramEnvelope struct {
+	Type  string `json:"type"`
+	OrgID string `json:"org_id"`
+	Data  any    `json:"data"`
+}
+
+// isDatagramEndpoint reports whether the endpoint uses the datagram transport
+func isDatagramEndpoint(endpoint string) bool {
+	return strings.HasPrefix(endpoint, "udp://")
+}
+
+// datagramExporter sends payloads as fire-and-forget UDP datagrams, without
+// acknowledgements or retries
+type datagramExporter struct {
+	orgID    string
+	maxBytes int
+	conn     net.Conn
+	log      *levelLogger
+
+	sent    atomic.Int64
+	dropped atomic.Int64
+
+	mu       sync.Mutex
+	sessions map[string]*SessionData // sessions re-announced periodically
+	stop     chan struct{}
+	done     chan struct{}
+}
+
+// newDatagramExporter connects a datagram exporter to a udp://host:port endpoint
+func newDatagramExporter(endpoint string, orgID string, maxBytes int, log *levelLogger) (*datagramExporter, error) {
+	u, err := url.Parse(endpoint)
+	if err != nil || u.Host == "" {
+		return nil, fmt.Errorf("invalid datagram endpoint %q", endpoint)
+	}
+	conn, err := net.Dial("udp", u.Host)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open datagram endpoint %q: %v", endpoint, err)
+	}
+	if maxBytes <= 0 {
+		maxBytes = defaultDatagramMaxBytes
+	}
+
+	d := &datagramExporter{
+		orgID:    orgID,
+		maxBytes: maxBytes,
+		conn:     conn,
+		log:      log,
+		sessions: make(map[string]*SessionData),
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+	go d.announceSessions()
+	return d, nil
+}
+
+// sendEvent sends an event, dropping its payloads if needed to fit a single datagram
+func (d *datagramExporter) sendEvent(event *EventData) {
+	data, err := d.encode(datagramTypeEvent, event)
+	if err == nil && len(data) > d.maxBytes && (event.Input != "" || event.Output != "" || event.ErrorMessage != "") {
+		stripped := *event
+		stripped.Input, stripped.Output, stripped.ErrorMessage = "", "", ""
+		data, err = d.encode(datagramTypeEvent, &stripped)
+	}
+	if err != nil {
+		d.log.Warning("Failed to encode event datagram: %v", err)
+		return
+	}
+	d.write(data)
+}
+
+// send sends a payload posted to the given API path
+func (d *datagramExporter) send(path string, payload any) {
+	kind, ok := datagramTypes[path]
+	if !ok {
+		d.log.Debug("No datagram type for %s, payload dropped", path)
+		return
+	}
+
+	// Track sessions so they can be re-announced
+	switch p := payload.(type) {
+	case SessionData:
+		d.trackSession(&p)
+	case *SessionData:
+		d.trackSession(p)
+	case []*SessionData:
+		for _, session := range p {
+			d.trackSession(session)
+		}
+	case SessionEndData:
+		d.untrackSession(p.SessionID)
+	}
+
+	data, err := d.encode(kind, payload)
+	if err != nil {
+		d.log.Warning("Failed to encode %s datagram: %v", kind, err)
+		return
+	}
+	d.write(data)
+}
+
+func (d *datagramExporter) trackSession(session *SessionData) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.sessions[session.SessionID] = session
+}
+
+func (d *datagramExporter) untrackSession(sessionID string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	delete(d.sessions, sessionID)
+}
+
+// announceSessions periodically re-sends the tracked sessions
+func (d *datagramExporter) announceSessions() {
+	defer close(d.done)
+
+	ticker := time.NewTicker(datagramSessionInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			d.mu.Lock()
+			sessions := make([]*SessionData, 0, len(d.sessions))
+			for _, session := range d.sessions {
+				sessions = append(sessions, session)
+			}
+			d.mu.Unlock()
+
+			for _, session := range sessions {
+				if data, err := d.encode(datagramTypeSession, session); err == nil {
+					d.write(data)
+				}
+			}
+		case <-d.stop:
+			return
+		}
+	}
+}
+
+func (d *datagramExporter) encode(kind string, payload any) ([]byte, error) {
+	return json.Marshal(datagramEnvelope{
+		Type:  kind,
+		OrgID: d.orgID,
+		Data:  payload,
+	})
+}
+
+// write sends a single datagram, counting oversized datagrams as dropped
+func (d *datagramExporter) write(data []byte) {
+	if len(data) > d.maxBytes {
+		d.dropped.Add(1)
+		d.log.Debug("Datagram of %d bytes exceeds the %d byte limit, dropped", len(data), d.maxBytes)
+		return
+	}
+	if _, err := d.conn.Write(data); err != nil {
+		if errors.Is(err, syscall.EMSGSIZE) {
+			d.dropped.Add(1)
+		}
+		d.log.Debug("Failed to send datagram: %v", err)
+		return
+	}
+	d.sent.Add(1)
+}
+
+// close stops re-announcing sessions and closes the socket
+func (d *datagramExporter) close() {
+	close(d.stop)
+	<-d.done
+	d.conn.Close()
+}
@@ -0,0 +1,169 @@
+package agnost
+
+import (
+	"encoding/json"
+	"io"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// datagramListener receives the datagrams sent to a local udp:// endpoint
+type datagramListener struct {
+	net.PacketConn
+}
+
+func newDatagramListener(t *testing.T) *datagramListener {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return &datagramListener{conn}
+}
+
+func (l *datagramListener) Endpoint() string {
+	return "udp://" + l.LocalAddr().String()
+}
+
+// next returns the next datagram's envelope, with its data left undecoded,
+// or false if none arrives within a second
+func (l *datagramListener) next(t *testing.T) (datagramEnvelope, json.RawMessage, bool) {
+	t.Helper()
+	buf := make([]byte, 64*1024)
+	l.SetReadDeadline(time.Now().Add(time.Second))
+	n, _, err := l.ReadFrom(buf)
+	if err != nil {
+		return datagramEnvelope{}, nil, false
+	}
+	var data json.RawMessage
+	envelope := datagramEnvelope{Data: &data}
+	if err := json.Unmarshal(buf[:n], &envelope); err != nil {
+		t.Fatalf("datagram isn't an envelope: %v", err)
+	}
+	return envelope, data, true
+}
+
+func newTestDatagramExporter(t *testing.T, endpoint string, maxBytes int) *datagramExporter {
+	d, err := newDatagramExporter(endpoint, "org", maxBytes, newLevelLogger(NewLogger(io.Discard), "debug"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(d.close)
+	return d
+}
+
+func TestDatagramEventsFitTheSizeLimit(t *testing.T) {
+	listener := newDatagramListener(t)
+	d := newTestDatagramExporter(t, listener.Endpoint(), 400)
+
+	d.sendEvent(&EventData{PrimitiveName: "small", Input: `{"a":1}`})
+	envelope, data, ok := listener.next(t)
+	if !ok {
+		t.Fatal("no datagram for a small event")
+	}
+	var event EventData
+	json.Unmarshal(data, &event)
+	if envelope.Type != datagramTypeEvent || envelope.OrgID != "org" {
+		t.Errorf("got envelope type %q for org %q", envelope.Type, envelope.OrgID)
+	}
+	if event.PrimitiveName != "small" || event.Input != `{"a":1}` {
+		t.Errorf("small event arrived as %+v", event)
+	}
+
+	d.sendEvent(&EventData{PrimitiveName: "large", Input: strings.Repeat("x", 1000), Output: "out"})
+	if _, data, ok = listener.next(t); !ok {
+		t.Fatal("no datagram for an event with oversized payloads")
+	}
+	event = EventData{}
+	json.Unmarshal(data, &event)
+	if event.PrimitiveName != "large" || event.Input != "" || event.Output != "" {
+		t.Errorf("event arrived with its payloads: %+v", event)
+	}
+
+	// Without payloads to drop, an oversized event is dropped whole
+	d.sendEvent(&EventData{PrimitiveName: strings.Repeat("n", 1000)})
+	if _, _, ok = listener.next(t); ok {
+		t.Error("oversized event was sent")
+	}
+	if sent, dropped := d.sent.Load(), d.dropped.Load(); sent != 2 || dropped != 1 {
+		t.Errorf("counted %d sent and %d dropped, want 2 and 1", sent, dropped)
+	}
+}
+
+func TestDatagramSessionsAreReannouncedUntilEnded(t *testing.T) {
+	listener := newDatagramListener(t)
+	d := newTestDatagramExporter(t, listener.Endpoint(), 0)
+
+	d.send("/api/v1/capture-session", SessionData{SessionID: "s1"})
+	if envelope, _, ok := listener.next(t); !ok || envelope.Type != datagramTypeSession {
+		t.Fatalf("got %q datagram for a session, want %q", envelope.Type, datagramTypeSession)
+	}
+	if _, ok := d.sessions["s1"]; !ok {
+		t.Error("registered session isn't re-announced")
+	}
+
+	d.send("/api/v1/capture-session-end", SessionEndData{SessionSummary: SessionSummary{SessionID: "s1"}})
+	if envelope, _, ok := listener.next(t); !ok || envelope.Type != datagramTypeSessionEnd {
+		t.Fatalf("got %q datagram for a session end, want %q", envelope.Type, datagramTypeSessionEnd)
+	}
+	if _, ok := d.sessions["s1"]; ok {
+		t.Error("ended session is still re-announced")
+	}
+
+	d.send("/api/v1/unknown", SessionData{SessionID: "s2"})
+	if _, _, ok := listener.next(t); ok {
+		t.Error("payload for a path without a datagram type was sent")
+	}
+}
+
+func TestInvalidDatagramEndpointFailsInitialize(t *testing.T) {
+	config := DefaultConfig()
+	config.Endpoint = "udp://"
+	config.Logger = NewLogger(io.Discard)
+	config.SkipValidation = true
+	capturePackageLogger(t)
+	s := server.NewMCPServer("test", "1.0.0")
+	if err := NewAgnostAnalytics().TrackMCP(s, "org", config); err == nil {
+		t.Error("initialized with a datagram endpoint without host")
+	}
+}
+
+func TestTrackSendsEventsAsDatagrams(t *testing.T) {
+	listener := newDatagramListener(t)
+	config := DefaultConfig()
+	config.Endpoint = listener.Endpoint()
+	config.Logger = NewLogger(io.Discard)
+	config.EnableRequestQueuing = false
+
+	s := server.NewMCPServer("test", "1.0.0", server.WithToolHandlerMiddleware(ToolMiddleware()))
+	a := NewAgnostAnalytics()
+	if err := a.TrackMCP(s, "org", config); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(a.Shutdown)
+	addEchoTool(s)
+	callTool(t, s, "echo")
+
+	for {
+		envelope, data, ok := listener.next(t)
+		if !ok {
+			t.Fatal("no datagram for the tool call")
+		}
+		if envelope.Type != datagramTypeEvent {
+			continue
+		}
+		var event EventData
+		json.Unmarshal(data, &event)
+		if event.PrimitiveType != "tool" || event.PrimitiveName != "echo" || envelope.OrgID != "org" {
+			t.Errorf("tool call arrived as %+v", event)
+		}
+		break
+	}
+	if stats := a.Stats(); stats.DatagramsSent == 0 {
+		t.Error("sent datagrams weren't counted")
+	}
+}
@@ -0,0 +1,46 @@
+package agnost
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// defaultStrictTimeout is used when Config.StrictTimeout is not set
+const defaultStrictTimeout = 5 * time.Second
+
+// ErrDeliveryFailed is returned from tracked tool calls under strict delivery
+// when the call's event could not be delivered
+var ErrDeliveryFailed = errors.New("analytics delivery failed")
+
+var (
+	errQueueFull         = errors.New("event queue full")
+	errProcessorShutDown = errors.New("event processor shut down")
+	errDeliveryTimeout   = errors.New("timed out waiting for delivery")
+	errShuttingDown      = errors.New("analytics shutting down")
+)
+
+// awaitDelivery waits for the outcome of an event's delivery, bounded by timeout
+func awaitDelivery(delivered <-chan error, timeout time.Duration) error {
+	if timeout <= 0 {
+		timeout = defaultStrictTimeout
+	}
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	select {
+	case err := <-delivered:
+		return err
+	case <-timer.C:
+		return errDeliveryTimeout
+	}
+}
+
+// deliveryFailure builds the error returned from a tool call whose event could
+// not be delivered, wrapping the handler's own error if it had one
+func deliveryFailure(handlerErr error, deliveryErr error) error {
+	if handlerErr != nil {
+		return fmt.Errorf("%w; %w: %v", handlerErr, ErrDeliveryFailed, deliveryErr)
+	}
+	return fmt.Errorf("%w: %v", ErrDeliveryFailed, deliveryErr)
+}
@@ -0,0 +1,182 @@
+package agnost
+
+import (
+	"runtime"
+	"time"
+)
+
+const (
+	// defaultDeliveryTarget is used when Config.DeliveryTarget is not set
+	defaultDeliveryTarget = 10 * time.Second
+
+	// defaultDeliveryReportWindow is used when Config.DeliveryReportWindow is not set
+	defaultDeliveryReportWindow = 5 * time.Minute
+)
+
+// DeliveryReport measures the SDK's own reliability: how many of the events
+// that reached a terminal state within the window were delivered on time
+type DeliveryReport struct {
+	// Window is the period covered, at 1-minute granularity
+	Window time.Duration
+
+	// Target is the record-to-delivery latency within which a delivery counts as on time
+	Target time.Duration
+
+	// Delivered, Failed and Dropped count events by terminal state, and OnTime
+	// the delivered events that met the target
+	Delivered int64
+	Failed    int64
+	Dropped   int64
+	OnTime    int64
+
+	// SuccessRatio is OnTime over all events that reached a terminal state, or
+	// 0 when there were none
+	SuccessRatio float64
+
+	// P50, P90 and P99 are record-to-delivery latency percentiles of delivered
+	// events, rounded up to the latency histogram's bin bounds
+	P50 time.Duration
+	P90 time.Duration
+	P99 time.Duration
+}
+
+// deliveryReportWindow returns the configured report window, capped to the
+// buckets the rollup keeps
+func deliveryReportWindow(config *AgnostConfig) time.Duration {
+	window := config.DeliveryReportWindow
+	if window <= 0 {
+		window = defaultDeliveryReportWindow
+	}
+	return min(window, rollupBuckets*rollupBucketWidth)
+}
+
+// DeliveryReport returns the delivery report of the configured window
+func (a *AgnostAnalytics) DeliveryReport() DeliveryReport {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	return a.deliveryReportLocked()
+}
+
+func (a *AgnostAnalytics) deliveryReportLocked() DeliveryReport {
+	window := defaultDeliveryReportWindow
+	if a.config != nil {
+		window = deliveryReportWindow(a.config)
+	}
+	return a.deliveries.report(window)
+}
+
+// addRuntimeStatsLocked adds a snapshot of the runtime and of the memory the
+// SDK holds to a report's attributes. It reads runtime.MemStats, which stops
+// the world briefly, so it runs at most once per report interval.
+func (a *AgnostAnalytics) addRuntimeStatsLocked(attributes map[string]any) {
+	var memStats runtime.MemStats
+	runtime.ReadMemStats(&memStats)
+	attributes["goroutines"] = runtime.NumGoroutine()
+	attributes["heap_alloc_bytes"] = memStats.HeapAlloc
+
+	if a.eventProcessor != nil {
+		events, bytes, spoolBytes := a.eventProcessor.QueueStats()
+		attributes["queue_events"] = events
+		attributes["queue_bytes"] = bytes
+		attributes["spool_bytes"] = spoolBytes
+		attributes["overflow_bytes"] = a.eventProcessor.overflowBytes()
+	}
+	if a.sessionManager != nil {
+		attributes["sessions"] = a.sessionManager.SessionCount()
+	}
+}
+
+// cpuSampler measures the CPU time the process used between two reports.
+// Go can't attribute CPU time to goroutines, so this is process-wide: it
+// includes the server's work outside tool calls, and the SDK's own.
+type cpuSampler struct {
+	cpu time.Duration
+	at  time.Time
+	ok  bool
+}
+
+// sample records the process CPU time used so far, returning the CPU time and
+// wall time elapsed since the previous sample
+func (s *cpuSampler) sample() (cpu time.Duration, wall time.Duration, ok bool) {
+	now := time.Now()
+	total, available := processCPUTime()
+	if available && s.ok {
+		cpu, wall, ok = total-s.cpu, now.Sub(s.at), true
+	}
+	s.cpu, s.at, s.ok = total, now, available
+	return cpu, wall, ok
+}
+
+// addStats adds the process CPU time used since the previous report to a
+// report's attributes, and the average number of cores it kept busy. Nothing
+// is added on platforms where process CPU time can't be read.
+func (s *cpuSampler) addStats(attributes map[string]any) {
+	cpu, wall, ok := s.sample()
+	if !ok {
+		return
+	}
+	attributes["process_cpu_ms"] = cpu.Milliseconds()
+	if wall > 0 {
+		attributes["process_cpu_cores"] = cpu.Seconds() / wall.Seconds()
+	}
+}
+
+// reportDeliveries periodically hands the delivery report to
+// Config.OnDeliveryReport and records it as an "sdk"/"delivery_report" event,
+// until stop is closed
+func (a *AgnostAnalytics) reportDeliveries(interval time.Duration, stop <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	var cpu cpuSampler
+	cpu.sample()
+
+	for {
+		select {
+		case <-ticker.C:
+			a.mu.RLock()
+			report := a.deliveryReportLocked()
+			callback := a.config.OnDeliveryReport
+			attributes := map[string]any{
+				"window_ms":     report.Window.Milliseconds(),
+				"target_ms":     report.Target.Milliseconds(),
+				"delivered":     report.Delivered,
+				"failed":        report.Failed,
+				"dropped":       report.Dropped,
+				"on_time":       report.OnTime,
+				"success_ratio": report.SuccessRatio,
+				"p50_ms":        report.P50.Milliseconds(),
+				"p90_ms":        report.P90.Milliseconds(),
+				"p99_ms":        report.P99.Milliseconds(),
+			}
+			for reason, count := range a.drops.snapshot() {
+				attributes["dropped_"+string(reason)] = count
+			}
+			if a.config.CollectRuntimeStats {
+				a.addRuntimeStatsLocked(attributes)
+			}
+			if a.config.CollectCPUTime {
+				cpu.addStats(attributes)
+			}
+			if a.config.CollectToolLatency {
+				a.toolLatency.addStats(attributes)
+			}
+			a.mu.RUnlock()
+
+			if callback != nil {
+				guard(a.logger(), "OnDeliveryReport", func() { callback(report) })
+			}
+			if err := a.recordEvent(&eventRecord{
+				primitiveType: "sdk",
+				primitiveName: "delivery_report",
+				success:       true,
+				attributes:    attributes,
+			}); err != nil {
+				a.logger().Debug("Failed to record delivery report: %v", err)
+			}
+
+		case <-stop:
+			return
+		}
+	}
+}
@@ -0,0 +1,57 @@
+package agnost
+
+import (
+	"testing"
+	"time"
+)
+
+// awaitDeliveryReport returns the attributes of the first delivery report event
+func awaitDeliveryReport(t *testing.T, collector *eventCollector) map[string]any {
+	t.Helper()
+	var reports []EventData
+	if !waitUntil(func() bool { reports = collector.Events("sdk"); return len(reports) > 0 }) {
+		t.Fatal("no delivery report was recorded")
+	}
+	if reports[0].PrimitiveName != "delivery_report" {
+		t.Fatalf("got sdk event %q, want delivery_report", reports[0].PrimitiveName)
+	}
+	return reports[0].Attributes
+}
+
+func TestDeliveryReportsCarryRuntimeStats(t *testing.T) {
+	_, _, collector := newTrackedServer(t, func(config *AgnostConfig) {
+		config.DeliveryReportInterval = 20 * time.Millisecond
+		config.CollectRuntimeStats = true
+	})
+	attributes := awaitDeliveryReport(t, collector)
+
+	if got, _ := attributes["goroutines"].(float64); got < 1 {
+		t.Errorf("reported %v goroutines, want at least 1", attributes["goroutines"])
+	}
+	if got, _ := attributes["heap_alloc_bytes"].(float64); got <= 0 {
+		t.Errorf("reported a heap of %v bytes", attributes["heap_alloc_bytes"])
+	}
+	if got, _ := attributes["sessions"].(float64); got != 1 {
+		t.Errorf("reported %v sessions, want the one started by Track", attributes["sessions"])
+	}
+	for _, key := range []string{"queue_events", "queue_bytes", "spool_bytes", "overflow_bytes"} {
+		if got, ok := attributes[key].(float64); !ok || got < 0 {
+			t.Errorf("reported %s %v, want a count", key, attributes[key])
+		}
+	}
+	if _, ok := attributes["process_cpu_ms"]; ok {
+		t.Error("CPU time reported without CollectCPUTime")
+	}
+}
+
+func TestDeliveryReportsLeaveOutRuntimeStatsByDefault(t *testing.T) {
+	_, _, collector := newTrackedServer(t, func(config *AgnostConfig) {
+		config.DeliveryReportInterval = 20 * time.Millisecond
+	})
+	attributes := awaitDeliveryReport(t, collector)
+	for _, key := range []string{"goroutines", "heap_alloc_bytes", "process_cpu_ms"} {
+		if _, ok := attributes[key]; ok {
+			t.Errorf("report has %s without opting in", key)
+		}
+	}
+}
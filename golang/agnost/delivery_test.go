@@ -0,0 +1,110 @@
+package agnost
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// callToolError calls the named tool and returns the error message of the
+// JSON-RPC error it failed with, or "" if it succeeded
+func callToolError(t *testing.T, s *server.MCPServer, name string) string {
+	t.Helper()
+	message, _ := json.Marshal(map[string]any{"jsonrpc": "2.0", "id": 1, "method": "tools/call",
+		"params": map[string]any{"name": name, "arguments": map[string]any{}}})
+	switch response := s.HandleMessage(context.Background(), message).(type) {
+	case mcp.JSONRPCResponse:
+		return ""
+	case mcp.JSONRPCError:
+		return response.Error.Message
+	default:
+		t.Fatalf("got %#v", response)
+		return ""
+	}
+}
+
+// newStrictServer returns a tracked server under strict delivery whose events
+// go to a collector answering them after delay with status
+func newStrictServer(t *testing.T, queued bool, status int, delay time.Duration) *server.MCPServer {
+	events := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(delay)
+		w.WriteHeader(status)
+	}))
+	t.Cleanup(events.Close)
+
+	s, _, _ := newTrackedServer(t, func(config *AgnostConfig) {
+		config.SessionEndpoint = config.Endpoint
+		config.Endpoint = events.URL
+		config.EnableRequestQueuing = queued
+		config.BatchSize = 1
+		config.StrictDelivery = true
+		config.StrictTimeout = 200 * time.Millisecond
+	})
+	addEchoTool(s)
+	return s
+}
+
+func TestStrictDeliveryFailsCallsWhoseEventIsRejected(t *testing.T) {
+	for _, queued := range []bool{false, true} {
+		s := newStrictServer(t, queued, http.StatusInternalServerError, 0)
+		if message := callToolError(t, s, "echo"); !strings.Contains(message, ErrDeliveryFailed.Error()) {
+			t.Errorf("queued %v: call failed with %q, want a delivery failure", queued, message)
+		}
+	}
+}
+
+func TestStrictDeliveryPassesCallsWhoseEventIsDelivered(t *testing.T) {
+	for _, queued := range []bool{false, true} {
+		s := newStrictServer(t, queued, http.StatusOK, 0)
+		if message := callToolError(t, s, "echo"); message != "" {
+			t.Errorf("queued %v: delivered call failed with %q", queued, message)
+		}
+	}
+}
+
+func TestStrictDeliveryTimesOut(t *testing.T) {
+	s := newStrictServer(t, true, http.StatusOK, time.Second)
+	start := time.Now()
+	message := callToolError(t, s, "echo")
+	if !strings.Contains(message, errDeliveryTimeout.Error()) {
+		t.Errorf("call failed with %q, want a delivery timeout", message)
+	}
+	if elapsed := time.Since(start); elapsed > 900*time.Millisecond {
+		t.Errorf("call waited %v for delivery, longer than the strict timeout", elapsed)
+	}
+}
+
+func TestUndeliveredEventsDontFailCallsByDefault(t *testing.T) {
+	events := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	t.Cleanup(events.Close)
+	s, _, _ := newTrackedServer(t, func(config *AgnostConfig) {
+		config.SessionEndpoint = config.Endpoint
+		config.Endpoint = events.URL
+	})
+	addEchoTool(s)
+
+	if message := callToolError(t, s, "echo"); message != "" {
+		t.Errorf("call failed with %q without strict delivery", message)
+	}
+}
+
+func TestDeliveryFailureWrapsTheHandlerError(t *testing.T) {
+	handlerErr := errors.New("handler failed")
+	err := deliveryFailure(handlerErr, errQueueFull)
+	if !errors.Is(err, handlerErr) || !errors.Is(err, ErrDeliveryFailed) {
+		t.Errorf("got %v, want both the handler error and ErrDeliveryFailed", err)
+	}
+	if err := deliveryFailure(nil, errQueueFull); !errors.Is(err, ErrDeliveryFailed) || !strings.Contains(err.Error(), errQueueFull.Error()) {
+		t.Errorf("got %v, want ErrDeliveryFailed with the delivery error", err)
+	}
+}
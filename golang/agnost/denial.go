@@ -0,0 +1,77 @@
+package agnost
+
+import (
+	"context"
+	"sync"
+)
+
+// maxDenialReasonBytes caps the denial reason sent with an event
+const maxDenialReasonBytes = 200
+
+// MarkDenied classifies the tracked tool call running in ctx as denied, for
+// authorization middleware that refuses a call before it reaches the real
+// handler. The call's event is recorded as failed with error_type "denied"
+// and the reason, cut to 200 bytes, as denial_reason; denials are counted per
+// tool in Stats and in the session summary. It is a no-op outside a tracked
+// tool handler.
+func MarkDenied(ctx context.Context, reason string) {
+	state := callStateFromContext(ctx)
+	if state == nil {
+		return
+	}
+	state.mu.Lock()
+	state.denied = true
+	state.denialReason = reason[:runeBoundary(reason, maxDenialReasonBytes)]
+	state.mu.Unlock()
+}
+
+// denial returns whether the call was marked as denied, and the reason
+func (s *callState) denial() (bool, string) {
+	if s == nil {
+		return false, ""
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.denied, s.denialReason
+}
+
+// denialCounter counts a session's denied calls per tool
+type denialCounter struct {
+	mu     sync.Mutex
+	counts map[string]int64
+}
+
+func (c *denialCounter) add(tool string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.counts == nil {
+		c.counts = make(map[string]int64)
+	}
+	c.counts[tool]++
+}
+
+// snapshot returns a copy of the counts, nil if nothing was denied
+func (c *denialCounter) snapshot() map[string]int64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(c.counts) == 0 {
+		return nil
+	}
+	counts := make(map[string]int64, len(c.counts))
+	for tool, count := range c.counts {
+		counts[tool] = count
+	}
+	return counts
+}
+
+// RecordDenial counts a denied call of the named tool in the session with the
+// given ID
+func (sm *SessionManager) RecordDenial(sessionID string, tool string) {
+	sm.mu.RLock()
+	entry, exists := sm.byID[sessionID]
+	sm.mu.RUnlock()
+	if !exists {
+		return
+	}
+	entry.denials.add(tool)
+}
@@ -0,0 +1,73 @@
+package agnost
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+func TestDeniedCallsAreRecordedAsFailures(t *testing.T) {
+	s, a, collector := newTrackedServer(t, nil)
+	addCachedTool(s, "delete", func(ctx context.Context) { MarkDenied(ctx, "missing scope repo:write") })
+	// A denial overrides the handler's result
+	s.AddTool(mcp.NewTool("refuse"), func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		MarkDenied(ctx, strings.Repeat("é", 150))
+		return mcp.NewToolResultError("forbidden"), nil
+	})
+	addEchoTool(s)
+
+	for _, name := range []string{"delete", "delete", "refuse", "echo"} {
+		callTool(t, s, name)
+	}
+	for _, event := range collector.Events("tool") {
+		denied := event.PrimitiveName != "echo"
+		if denied != (event.ErrorType == ErrorTypeDenied) || denied == event.Success {
+			t.Errorf("%s event has success %v and error type %q", event.PrimitiveName, event.Success, event.ErrorType)
+		}
+		switch event.PrimitiveName {
+		case "delete":
+			if event.DenialReason != "missing scope repo:write" {
+				t.Errorf("denial reason %q", event.DenialReason)
+			}
+		case "refuse":
+			if len(event.DenialReason) > maxDenialReasonBytes || !utf8.ValidString(event.DenialReason) {
+				t.Errorf("long denial reason sent as %q", event.DenialReason)
+			}
+		case "echo":
+			if event.DenialReason != "" {
+				t.Errorf("allowed call has denial reason %q", event.DenialReason)
+			}
+		}
+	}
+
+	stats := a.Stats()
+	if got := stats.Tools["delete"].Denials; got != 2 {
+		t.Errorf("counted %d denials of delete, want 2", got)
+	}
+	if got := stats.Tools["echo"].Denials; got != 0 {
+		t.Errorf("counted %d denials of echo, want 0", got)
+	}
+	a.Shutdown()
+	ends := collector.Ends()
+	if len(ends) != 1 {
+		t.Fatalf("got %d session ends, want 1", len(ends))
+	}
+	if got := ends[0].Denials; len(got) != 2 || got["delete"] != 2 || got["refuse"] != 1 {
+		t.Errorf("session ended with denials %v", got)
+	}
+}
+
+func TestMarkDeniedOutsideAToolCallIsANoOp(t *testing.T) {
+	MarkDenied(context.Background(), "no call")
+	var state *callState
+	if denied, reason := state.denial(); denied || reason != "" {
+		t.Errorf("nil state reports a denial %q", reason)
+	}
+	var counter denialCounter
+	if got := counter.snapshot(); got != nil {
+		t.Errorf("empty counter snapshots to %v, want nil", got)
+	}
+}
@@ -0,0 +1,60 @@
+package agnost
+
+import (
+	"context"
+
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// Hooks returns hooks with every hook of the global analytics client, see
+// AgnostAnalytics.Hooks
+func Hooks() *server.Hooks {
+	return globalClient.Hooks()
+}
+
+// HookDisconnects registers a hook ending the sessions of disconnected
+// clients with the global analytics client, see AgnostAnalytics.HookDisconnects
+func HookDisconnects(hooks *server.Hooks) {
+	globalClient.HookDisconnects(hooks)
+}
+
+// Hooks returns hooks registering both HookDisconnects and HookResources,
+// to pass to server.WithHooks when creating the server:
+//
+//	s := server.NewMCPServer("my-server", "1.0.0", server.WithHooks(agnost.Hooks()))
+//
+// Add the server's own hooks to the returned ones.
+func (a *AgnostAnalytics) Hooks() *server.Hooks {
+	hooks := &server.Hooks{}
+	a.HookDisconnects(hooks)
+	a.HookResources(hooks)
+	return hooks
+}
+
+// HookDisconnects registers an OnUnregisterSession hook, which mcp-go calls
+// when an SSE or stdio client, or the GET stream of a streamable HTTP client,
+// goes away, evicting and ending the client's session. mcp-go only accepts
+// hooks when the server is created, so pass the same hooks to
+// server.WithHooks.
+func (a *AgnostAnalytics) HookDisconnects(hooks *server.Hooks) {
+	hooks.AddOnUnregisterSession(func(ctx context.Context, session server.ClientSession) {
+		guard(a.logger(), "client disconnect", func() {
+			if session.SessionID() != "" {
+				a.clientDisconnected(clientSessionKey(session.SessionID()))
+			}
+		})
+	})
+}
+
+// clientDisconnected ends the session of a client that disconnected
+func (a *AgnostAnalytics) clientDisconnected(sessionKey string) {
+	a.mu.RLock()
+	sessionManager := a.sessionManager
+	initialized := a.initialized
+	a.mu.RUnlock()
+	if !initialized || sessionManager == nil {
+		return
+	}
+	a.logger().Debug("Client disconnected (key: %s)", sessionKey)
+	sessionManager.Disconnect(sessionKey)
+}
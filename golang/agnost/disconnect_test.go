@@ -0,0 +1,60 @@
+package agnost
+
+import (
+	"context"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// fakeClientSession is a client session registered by hand, as a transport would
+type fakeClientSession struct {
+	id string
+}
+
+func (s *fakeClientSession) Initialize()       {}
+func (s *fakeClientSession) Initialized() bool { return true }
+func (s *fakeClientSession) SessionID() string { return s.id }
+func (s *fakeClientSession) NotificationChannel() chan<- mcp.JSONRPCNotification {
+	return make(chan mcp.JSONRPCNotification, 1)
+}
+
+func TestHooksEndSessionOfDisconnectedClient(t *testing.T) {
+	collector := newSessionCollector(t, 0)
+	a := NewAgnostAnalytics()
+	a.log.Store(newLevelLogger(NewLogger(io.Discard), "error"))
+	a.sessionManager = newTestSessionManager(collector.URL, nil)
+	a.initialized = true
+
+	s := server.NewMCPServer("test", "1.0.0", server.WithHooks(a.Hooks()))
+	session := &fakeClientSession{id: "abc"}
+	if err := s.RegisterSession(context.Background(), session); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := a.sessionManager.GetOrCreateSession(&SessionInfo{SessionKey: clientSessionKey("abc")}); err != nil {
+		t.Fatal(err)
+	}
+
+	s.UnregisterSession(context.Background(), "abc")
+	if got := a.sessionManager.SessionCount(); got != 0 {
+		t.Errorf("cached %d sessions after the client disconnected, want 0", got)
+	}
+	deadline := time.Now().Add(time.Second)
+	for collector.ended.Load() == 0 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	if got := collector.ended.Load(); got != 1 {
+		t.Errorf("ended %d sessions, want 1", got)
+	}
+}
+
+func TestHooksIgnoreDisconnectsBeforeTrack(t *testing.T) {
+	s := server.NewMCPServer("test", "1.0.0", server.WithHooks(NewAgnostAnalytics().Hooks()))
+	if err := s.RegisterSession(context.Background(), &fakeClientSession{id: "abc"}); err != nil {
+		t.Fatal(err)
+	}
+	s.UnregisterSession(context.Background(), "abc")
+}
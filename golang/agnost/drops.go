@@ -0,0 +1,96 @@
+package agnost
+
+import "sync/atomic"
+
+// DropReason says why an event left the pipeline without being delivered
+type DropReason string
+
+// Drop reasons counted in Stats.Drops. Some are reserved for policies not every
+// configuration uses.
+const (
+	// DropSampledOut events were skipped by sampling
+	DropSampledOut DropReason = "sampled_out"
+
+	// DropExcludedTool events belong to a tool excluded from tracking
+	DropExcludedTool DropReason = "excluded_tool"
+
+	// DropRateLimited events exceeded a rate cap
+	DropRateLimited DropReason = "rate_limited"
+
+	// DropDeduplicated events repeated an event already recorded
+	DropDeduplicated DropReason = "deduplicated"
+
+	// DropQueueFull events found the queue full
+	DropQueueFull DropReason = "queue_full"
+
+	// DropBackpressure events were recorded with BestEffort while the pipeline
+	// was congested
+	DropBackpressure DropReason = "backpressure"
+
+	// DropCircuitOpen events were discarded while delivery was suspended
+	DropCircuitOpen DropReason = "circuit_open"
+
+	// DropShutdown events were recorded or still queued while shutting down
+	DropShutdown DropReason = "shutdown"
+
+	// DropConsentPending events were recorded before the user consented
+	DropConsentPending DropReason = "consent_pending"
+
+	// DropDeliveryFailed events failed delivery and could not be spooled
+	DropDeliveryFailed DropReason = "delivery_failed"
+
+	// DropOverflowFull events were the oldest in a full overflow file
+	DropOverflowFull DropReason = "overflow_full"
+)
+
+// DropReasons lists every drop reason
+var DropReasons = []DropReason{
+	DropSampledOut,
+	DropExcludedTool,
+	DropRateLimited,
+	DropDeduplicated,
+	DropQueueFull,
+	DropBackpressure,
+	DropCircuitOpen,
+	DropShutdown,
+	DropConsentPending,
+	DropDeliveryFailed,
+	DropOverflowFull,
+}
+
+// dropCounter counts dropped events per reason
+type dropCounter struct {
+	counts map[DropReason]*atomic.Int64 // fixed at creation, so reads need no lock
+}
+
+func newDropCounter() *dropCounter {
+	counts := make(map[DropReason]*atomic.Int64, len(DropReasons))
+	for _, reason := range DropReasons {
+		counts[reason] = new(atomic.Int64)
+	}
+	return &dropCounter{counts: counts}
+}
+
+// count records a dropped event
+func (c *dropCounter) count(reason DropReason) {
+	if c == nil {
+		return
+	}
+	if counter, ok := c.counts[reason]; ok {
+		counter.Add(1)
+	}
+}
+
+// snapshot returns the counts of the reasons that dropped any event
+func (c *dropCounter) snapshot() map[DropReason]int64 {
+	snapshot := make(map[DropReason]int64)
+	if c == nil {
+		return snapshot
+	}
+	for reason, counter := range c.counts {
+		if n := counter.Load(); n > 0 {
+			snapshot[reason] = n
+		}
+	}
+	return snapshot
+}
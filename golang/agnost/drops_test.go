@@ -0,0 +1,97 @@
+package agnost
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestDropCounterSnapshotsTheReasonsThatDropped(t *testing.T) {
+	c := newDropCounter()
+	c.count(DropQueueFull)
+	c.count(DropQueueFull)
+	c.count(DropShutdown)
+	c.count(DropReason("unknown"))
+
+	snapshot := c.snapshot()
+	if len(snapshot) != 2 || snapshot[DropQueueFull] != 2 || snapshot[DropShutdown] != 1 {
+		t.Errorf("got drops %v, want 2 queue_full and 1 shutdown", snapshot)
+	}
+
+	var unset *dropCounter
+	unset.count(DropQueueFull)
+	if got := unset.snapshot(); len(got) != 0 {
+		t.Errorf("nil counter has drops %v", got)
+	}
+}
+
+func TestDropReasonsAreDistinct(t *testing.T) {
+	seen := make(map[DropReason]bool)
+	for _, reason := range DropReasons {
+		if seen[reason] {
+			t.Errorf("drop reason %q is listed twice", reason)
+		}
+		seen[reason] = true
+	}
+}
+
+func TestSampledOutEventsAreCountedAsDropped(t *testing.T) {
+	s, a, collector := newTrackedServer(t, func(config *AgnostConfig) {
+		config.SampleRate = 1e-12
+	})
+	addEchoTool(s)
+
+	for range 3 {
+		callTool(t, s, "echo")
+	}
+	if got := len(collector.Events("tool")); got != 0 {
+		t.Errorf("sent %d sampled out events", got)
+	}
+	if got := a.Stats().Drops[DropSampledOut]; got != 3 {
+		t.Errorf("counted %d sampled out events, want 3", got)
+	}
+}
+
+func TestRejectedEventsAreCountedAsDeliveryFailures(t *testing.T) {
+	events := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+	}))
+	t.Cleanup(events.Close)
+	s, a, _ := newTrackedServer(t, func(config *AgnostConfig) {
+		config.SessionEndpoint = config.Endpoint
+		config.Endpoint = events.URL
+	})
+	addEchoTool(s)
+
+	callTool(t, s, "echo")
+	if got := a.Stats().Drops; got[DropDeliveryFailed] != 1 {
+		t.Errorf("got drops %v, want 1 delivery_failed", got)
+	}
+}
+
+func TestEventsPastAFullQueueAreCountedAsDropped(t *testing.T) {
+	release := make(chan struct{})
+	blocked := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	t.Cleanup(blocked.Close)
+
+	config := DefaultConfig()
+	config.Logger = NewLogger(io.Discard)
+	config.QueueSize = 1
+	config.BatchSize = 1
+	config.MaxRetries = 0
+	ep := NewEventProcessor(blocked.URL, "org", config)
+	ep.drops = newDropCounter()
+	t.Cleanup(ep.Shutdown)
+	t.Cleanup(func() { close(release) })
+
+	for range 10 {
+		ep.QueueEvent(&EventData{SessionID: "s", PrimitiveType: "tool", PrimitiveName: "echo"})
+	}
+	// The worker holds at most one event and the queue one more
+	if got := ep.drops.snapshot()[DropQueueFull]; got < 8 {
+		t.Errorf("counted %d drops of a full queue, want at least 8", got)
+	}
+}
@@ -0,0 +1,174 @@
+package agnost
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdh"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+)
+
+// Encrypted payloads are sealed to an X25519 public key: the payload is
+// encrypted with AES-256-GCM under a key derived with HKDF-SHA256 from the
+// shared secret of a fresh ephemeral key and the recipient's key. The sealed
+// payload is the standard base64 encoding of
+//
+//	version (1 byte) | ephemeral public key (32 bytes) | nonce (12 bytes) | ciphertext and tag
+//
+// the HKDF salt is the ephemeral public key followed by the recipient's and
+// the info is payloadSealInfo.
+const (
+	payloadSealVersion = 1
+	payloadSealInfo    = "agnost payload v1"
+)
+
+// payloadSealer encrypts event payloads to the org's public key, see
+// Config.PayloadPublicKey
+type payloadSealer struct {
+	recipient *ecdh.PublicKey
+	log       *levelLogger
+}
+
+// newPayloadSealer parses a base64 X25519 public key, such as a NaCl box key
+func newPayloadSealer(publicKey string, log *levelLogger) (*payloadSealer, error) {
+	raw, err := base64.StdEncoding.DecodeString(publicKey)
+	if err != nil {
+		return nil, fmt.Errorf("invalid PayloadPublicKey: %v", err)
+	}
+	recipient, err := ecdh.X25519().NewPublicKey(raw)
+	if err != nil {
+		return nil, fmt.Errorf("invalid PayloadPublicKey: %v", err)
+	}
+	return &payloadSealer{recipient: recipient, log: log}, nil
+}
+
+// seal encrypts a payload, returning it base64 encoded
+func (s *payloadSealer) seal(payload string) (string, error) {
+	ephemeral, err := ecdh.X25519().GenerateKey(rand.Reader)
+	if err != nil {
+		return "", err
+	}
+	shared, err := ephemeral.ECDH(s.recipient)
+	if err != nil {
+		return "", err
+	}
+	ephemeralPublic := ephemeral.PublicKey().Bytes()
+	aead, err := payloadAEAD(shared, ephemeralPublic, s.recipient.Bytes())
+	if err != nil {
+		return "", err
+	}
+
+	sealed := make([]byte, 0, 1+len(ephemeralPublic)+aead.NonceSize()+len(payload)+aead.Overhead())
+	sealed = append(sealed, payloadSealVersion)
+	sealed = append(sealed, ephemeralPublic...)
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	sealed = append(sealed, nonce...)
+	sealed = aead.Seal(sealed, nonce, []byte(payload), nil)
+	return base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// sealEvent encrypts the event's input, output and error message in place. A
+// payload that can't be encrypted is dropped rather than sent in plaintext.
+func (s *payloadSealer) sealEvent(event *EventData) {
+	for _, payload := range []*string{&event.Input, &event.Output, &event.ErrorMessage} {
+		if *payload == "" {
+			continue
+		}
+		sealed, err := s.seal(*payload)
+		if err != nil {
+			s.log.Warning("Failed to encrypt payload of %s/%s, dropping it: %v", event.PrimitiveType, event.PrimitiveName, err)
+			sealed = ""
+		}
+		*payload = sealed
+	}
+	event.PayloadEncrypted = event.Input != "" || event.Output != "" || event.ErrorMessage != ""
+}
+
+// OpenPayload decrypts an event payload encrypted to the public key matching
+// privateKey, a base64 X25519 private key, see Config.PayloadPublicKey
+func OpenPayload(privateKey string, payload string) (string, error) {
+	raw, err := base64.StdEncoding.DecodeString(privateKey)
+	if err != nil {
+		return "", fmt.Errorf("invalid private key: %v", err)
+	}
+	recipient, err := ecdh.X25519().NewPrivateKey(raw)
+	if err != nil {
+		return "", fmt.Errorf("invalid private key: %v", err)
+	}
+	sealed, err := base64.StdEncoding.DecodeString(payload)
+	if err != nil {
+		return "", fmt.Errorf("invalid payload: %v", err)
+	}
+
+	const ephemeralSize = 32
+	if len(sealed) < 1+ephemeralSize || sealed[0] != payloadSealVersion {
+		return "", errors.New("invalid payload: unknown format")
+	}
+	ephemeral, err := ecdh.X25519().NewPublicKey(sealed[1 : 1+ephemeralSize])
+	if err != nil {
+		return "", fmt.Errorf("invalid payload: %v", err)
+	}
+	shared, err := recipient.ECDH(ephemeral)
+	if err != nil {
+		return "", err
+	}
+	aead, err := payloadAEAD(shared, ephemeral.Bytes(), recipient.PublicKey().Bytes())
+	if err != nil {
+		return "", err
+	}
+
+	rest := sealed[1+ephemeralSize:]
+	if len(rest) < aead.NonceSize() {
+		return "", errors.New("invalid payload: truncated")
+	}
+	plaintext, err := aead.Open(nil, rest[:aead.NonceSize()], rest[aead.NonceSize():], nil)
+	if err != nil {
+		return "", fmt.Errorf("failed to decrypt payload: %v", err)
+	}
+	return string(plaintext), nil
+}
+
+// GeneratePayloadKey generates an X25519 key pair for Config.PayloadPublicKey,
+// returning both keys base64 encoded
+func GeneratePayloadKey() (publicKey string, privateKey string, err error) {
+	key, err := ecdh.X25519().GenerateKey(rand.Reader)
+	if err != nil {
+		return "", "", err
+	}
+	return base64.StdEncoding.EncodeToString(key.PublicKey().Bytes()), base64.StdEncoding.EncodeToString(key.Bytes()), nil
+}
+
+// payloadAEAD derives the AES-256-GCM cipher of a sealed payload
+func payloadAEAD(shared []byte, ephemeralPublic []byte, recipientPublic []byte) (cipher.AEAD, error) {
+	salt := append(append([]byte{}, ephemeralPublic...), recipientPublic...)
+	block, err := aes.NewCipher(hkdfSHA256(shared, salt, []byte(payloadSealInfo), 32))
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// hkdfSHA256 derives length bytes of key material with HKDF-SHA256 (RFC 5869)
+func hkdfSHA256(secret []byte, salt []byte, info []byte, length int) []byte {
+	extract := hmac.New(sha256.New, salt)
+	extract.Write(secret)
+	prk := extract.Sum(nil)
+
+	var okm, block []byte
+	for counter := byte(1); len(okm) < length; counter++ {
+		expand := hmac.New(sha256.New, prk)
+		expand.Write(block)
+		expand.Write(info)
+		expand.Write([]byte{counter})
+		block = expand.Sum(nil)
+		okm = append(okm, block...)
+	}
+	return okm[:length]
+}
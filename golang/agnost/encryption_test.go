@@ -0,0 +1,117 @@
+package agnost
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// newTestSealer returns a sealer to a fresh key pair and its private key
+func newTestSealer(t *testing.T) (*payloadSealer, string) {
+	t.Helper()
+	publicKey, privateKey, err := GeneratePayloadKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	sealer, err := newPayloadSealer(publicKey, newLevelLogger(NewLogger(io.Discard), "debug"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return sealer, privateKey
+}
+
+func TestSealedPayloadsOpenWithThePrivateKey(t *testing.T) {
+	sealer, privateKey := newTestSealer(t)
+	payload := `{"query":"weather in Zürich"}`
+
+	first, err := sealer.seal(payload)
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, _ := sealer.seal(payload)
+	if first == second {
+		t.Error("sealing the same payload twice gave the same ciphertext")
+	}
+	for _, sealed := range []string{first, second} {
+		if opened, err := OpenPayload(privateKey, sealed); err != nil || opened != payload {
+			t.Errorf("OpenPayload() = %q, %v, want %q", opened, err, payload)
+		}
+	}
+}
+
+func TestOpenPayloadRejectsTheWrongKeyAndTampering(t *testing.T) {
+	sealer, privateKey := newTestSealer(t)
+	sealed, _ := sealer.seal("secret")
+	_, otherKey, _ := GeneratePayloadKey()
+	if _, err := OpenPayload(otherKey, sealed); err == nil {
+		t.Error("opened a payload with another key")
+	}
+
+	raw, _ := base64.StdEncoding.DecodeString(sealed)
+	raw[len(raw)-1] ^= 1
+	if _, err := OpenPayload(privateKey, base64.StdEncoding.EncodeToString(raw)); err == nil {
+		t.Error("opened a tampered payload")
+	}
+	for _, payload := range []string{"not base64!", base64.StdEncoding.EncodeToString([]byte{1, 2, 3})} {
+		if _, err := OpenPayload(privateKey, payload); err == nil {
+			t.Errorf("opened malformed payload %q", payload)
+		}
+	}
+}
+
+func TestInvalidPayloadPublicKeyFailsInitialize(t *testing.T) {
+	for _, key := range []string{"not base64!", base64.StdEncoding.EncodeToString([]byte("short"))} {
+		config := DefaultConfig()
+		config.Logger = NewLogger(io.Discard)
+		config.PayloadPublicKey = key
+		s := server.NewMCPServer("test", "1.0.0")
+		if err := NewAgnostAnalytics().TrackMCP(s, "org", config); err == nil || !strings.Contains(err.Error(), "PayloadPublicKey") {
+			t.Errorf("key %q: got error %v, want an invalid PayloadPublicKey", key, err)
+		}
+	}
+}
+
+func TestEventPayloadsAreSentEncrypted(t *testing.T) {
+	publicKey, privateKey, _ := GeneratePayloadKey()
+	s, _, collector := newTrackedServer(t, func(config *AgnostConfig) {
+		config.PayloadPublicKey = publicKey
+		config.MaxInputBytes = 200
+	})
+	s.AddTool(mcp.NewTool("search"), func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		return mcp.NewToolResultText("sunny"), nil
+	})
+
+	handleRequest(t, s, "tools/call", map[string]any{"name": "search", "arguments": map[string]any{"query": "weather"}})
+	handleRequest(t, s, "tools/call", map[string]any{"name": "search", "arguments": map[string]any{"query": strings.Repeat("x", 500)}})
+	events := collector.Events("tool")
+	if len(events) != 2 {
+		t.Fatalf("got %d tool events, want 2", len(events))
+	}
+
+	event := events[0]
+	if !event.PayloadEncrypted || event.PrimitiveName != "search" {
+		t.Errorf("event sent as %+v, want encrypted payloads and plaintext fields", event)
+	}
+	if strings.Contains(event.Input, "weather") || strings.Contains(event.Output, "sunny") {
+		t.Errorf("payloads sent in plaintext: %s, %s", event.Input, event.Output)
+	}
+	if input, err := OpenPayload(privateKey, event.Input); err != nil || !strings.Contains(input, "weather") {
+		t.Errorf("input opened as %q, %v", input, err)
+	}
+
+	// Oversized payloads are truncated before they are sealed
+	input, err := OpenPayload(privateKey, events[1].Input)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var marker truncatedPayload
+	if json.Unmarshal([]byte(input), &marker); !marker.Truncated {
+		t.Errorf("oversized input opened as %s, want a truncation marker", input)
+	}
+}
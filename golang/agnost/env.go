@@ -0,0 +1,131 @@
+package agnost
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// orgIDEnv holds the organization ID when none is given to Track
+const orgIDEnv = "AGNOST_ORG_ID"
+
+// envSetting is a Config field that an environment variable can set
+type envSetting struct {
+	name string
+
+	// set parses value into the field, overwriting a value already set only
+	// if override is true
+	set func(c *AgnostConfig, value string, override bool) error
+}
+
+// envSettings are the Config fields that can be set from the environment
+var envSettings = []envSetting{
+	envVar("AGNOST_ENDPOINT", parseString, func(c *AgnostConfig) *string { return &c.Endpoint }),
+	envVar("AGNOST_REGION", parseString, func(c *AgnostConfig) *string { return &c.Region }),
+	envVar("AGNOST_DISABLE_INPUT", strconv.ParseBool, func(c *AgnostConfig) *bool { return &c.DisableInput }),
+	envVar("AGNOST_DISABLE_OUTPUT", strconv.ParseBool, func(c *AgnostConfig) *bool { return &c.DisableOutput }),
+	envVar("AGNOST_DISABLE_ERROR_DETAILS", strconv.ParseBool, func(c *AgnostConfig) *bool { return &c.DisableErrorDetails }),
+	envVar("AGNOST_LOG_LEVEL", parseString, func(c *AgnostConfig) *string { return &c.LogLevel }),
+	envVar("AGNOST_BATCH_SIZE", parseCount, func(c *AgnostConfig) *int { return &c.BatchSize }),
+	envVar("AGNOST_QUEUE_SIZE", parseCount, func(c *AgnostConfig) *int { return &c.QueueSize }),
+	envVar("AGNOST_MAX_RETRIES", parseCount, func(c *AgnostConfig) *int { return &c.MaxRetries }),
+	envVar("AGNOST_REQUEST_TIMEOUT", time.ParseDuration, func(c *AgnostConfig) *time.Duration { return &c.RequestTimeout }),
+	envVar("AGNOST_SAMPLE_RATE", parseRate, func(c *AgnostConfig) *float64 { return &c.SampleRate }),
+	envVar("AGNOST_CONNECTION_TYPE", parseString, func(c *AgnostConfig) *string { return &c.ConnectionType }),
+}
+
+// envVar returns the setting of the field the environment variable name
+// holds, parsed with parse
+func envVar[T comparable](name string, parse func(string) (T, error), field func(*AgnostConfig) *T) envSetting {
+	return envSetting{
+		name: name,
+		set: func(c *AgnostConfig, value string, override bool) error {
+			parsed, err := parse(value)
+			var numErr *strconv.NumError
+			if errors.As(err, &numErr) {
+				err = numErr.Err
+			}
+			if err != nil {
+				return fmt.Errorf("invalid %s %q: %v", name, value, err)
+			}
+			// An unset bool can't be told from an explicit false, so bools
+			// only override, as for a config from DefaultConfig
+			var zero T
+			_, isBool := any(zero).(bool)
+			if p := field(c); override || (*p == zero && !isBool) {
+				*p = parsed
+			}
+			return nil
+		},
+	}
+}
+
+func parseString(value string) (string, error) {
+	return value, nil
+}
+
+// parseCount parses a non-negative integer
+func parseCount(value string) (int, error) {
+	n, err := strconv.Atoi(value)
+	if err == nil && n < 0 {
+		err = errors.New("must not be negative")
+	}
+	return n, err
+}
+
+// parseRate parses a fraction between 0 and 1
+func parseRate(value string) (float64, error) {
+	rate, err := strconv.ParseFloat(value, 64)
+	if err == nil && (rate < 0 || rate > 1) {
+		err = errors.New("must be between 0 and 1")
+	}
+	return rate, err
+}
+
+// applyEnv sets config's fields from the AGNOST_* environment variables that
+// are set, overwriting the fields already set only if override is true.
+// Without override, boolean variables are left out. It
+// returns an error naming every variable with an invalid value, which leaves
+// its field unchanged.
+func applyEnv(config *AgnostConfig, override bool) error {
+	var errs []error
+	for _, setting := range envSettings {
+		value := strings.TrimSpace(os.Getenv(setting.name))
+		if value == "" {
+			continue
+		}
+		if err := setting.set(config, value, override); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// withEnv returns a copy of config with the fields left unset filled from
+// the environment, so explicit fields take precedence while the caller's
+// config isn't changed. A config from DefaultConfig already took the
+// environment, so its values are only checked.
+func withEnv(config *AgnostConfig) (*AgnostConfig, error) {
+	filled := *config
+	target := &filled
+	if config.envApplied {
+		checked := filled
+		target = &checked
+	}
+	if err := applyEnv(target, false); err != nil {
+		return nil, err
+	}
+	return &filled, nil
+}
+
+// orgIDOrEnv returns orgID, or the AGNOST_ORG_ID environment variable if
+// orgID is empty
+func orgIDOrEnv(orgID string) string {
+	if orgID != "" {
+		return orgID
+	}
+	return strings.TrimSpace(os.Getenv(orgIDEnv))
+}
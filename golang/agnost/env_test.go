@@ -0,0 +1,81 @@
+package agnost
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWithEnvFillsOnlyUnsetFields(t *testing.T) {
+	t.Setenv("AGNOST_MAX_RETRIES", "7")
+	t.Setenv("AGNOST_SAMPLE_RATE", "0.5")
+	t.Setenv("AGNOST_REQUEST_TIMEOUT", "2s")
+
+	config, err := withEnv(&AgnostConfig{RequestTimeout: time.Second})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if config.MaxRetries != 7 || config.SampleRate != 0.5 {
+		t.Errorf("unset fields weren't filled: MaxRetries %d, SampleRate %v", config.MaxRetries, config.SampleRate)
+	}
+	if config.RequestTimeout != time.Second {
+		t.Errorf("explicit RequestTimeout was overridden with %v", config.RequestTimeout)
+	}
+}
+
+func TestWithEnvKeepsZeroFieldsOfDefaultConfig(t *testing.T) {
+	t.Setenv("AGNOST_MAX_RETRIES", "7")
+	t.Setenv("AGNOST_SAMPLE_RATE", "0.5")
+
+	config := DefaultConfig()
+	if config.MaxRetries != 7 {
+		t.Fatalf("DefaultConfig didn't apply AGNOST_MAX_RETRIES: %d", config.MaxRetries)
+	}
+	config.MaxRetries = 0
+	config.SampleRate = 0
+	filled, err := withEnv(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if filled.MaxRetries != 0 || filled.SampleRate != 0 {
+		t.Errorf("explicit zero fields were overridden: MaxRetries %d, SampleRate %v", filled.MaxRetries, filled.SampleRate)
+	}
+}
+
+func TestWithEnvReportsInvalidValues(t *testing.T) {
+	t.Setenv("AGNOST_MAX_RETRIES", "-1")
+	t.Setenv("AGNOST_SAMPLE_RATE", "2")
+
+	for _, config := range []*AgnostConfig{{}, DefaultConfig()} {
+		_, err := withEnv(config)
+		if err == nil || !strings.Contains(err.Error(), "AGNOST_MAX_RETRIES") || !strings.Contains(err.Error(), "AGNOST_SAMPLE_RATE") {
+			t.Errorf("got %v, want both invalid variables named", err)
+		}
+	}
+}
+
+func TestBoolEnvNeverOverridesAnExplicitFalse(t *testing.T) {
+	t.Setenv("AGNOST_DISABLE_OUTPUT", "true")
+
+	config, err := withEnv(&AgnostConfig{DisableOutput: false})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if config.DisableOutput {
+		t.Error("AGNOST_DISABLE_OUTPUT overrode the explicit false of a config literal")
+	}
+
+	config = DefaultConfig()
+	if !config.DisableOutput {
+		t.Fatal("DefaultConfig didn't apply AGNOST_DISABLE_OUTPUT")
+	}
+	config.DisableOutput = false
+	if filled, err := withEnv(config); err != nil || filled.DisableOutput {
+		t.Errorf("AGNOST_DISABLE_OUTPUT overrode DisableOutput set back to false (err %v)", err)
+	}
+
+	t.Setenv("AGNOST_DISABLE_INPUT", "maybe")
+	if _, err := withEnv(&AgnostConfig{}); err == nil || !strings.Contains(err.Error(), "AGNOST_DISABLE_INPUT") {
+		t.Errorf("invalid boolean gave error %v", err)
+	}
+}
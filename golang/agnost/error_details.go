@@ -0,0 +1,49 @@
+package agnost
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// maxErrorMessageBytes caps the error message recorded for a failed call
+const maxErrorMessageBytes = 1024
+
+// errorDetails returns the error message of a failed tool call and, if its
+// handler returned an error, the error's Go type name. Without a returned
+// error, the message is the text of an error result, unless the tool's output
+// capture is disabled.
+func (c *AgnostConfig) errorDetails(call *ToolCall) (message string, typeName string) {
+	if c.DisableErrorDetails {
+		return "", ""
+	}
+
+	if call.Err != nil {
+		message, typeName = call.Err.Error(), fmt.Sprintf("%T", call.Err)
+	} else if result, ok := call.Result.(*mcp.CallToolResult); ok && result != nil && result.IsError && !c.outputDisabled(call.ToolName) {
+		message = errorResultText(result)
+	}
+	return truncateErrorMessage(message), typeName
+}
+
+// errorResultText joins the text contents of an error result
+func errorResultText(result *mcp.CallToolResult) string {
+	var texts []string
+	for _, content := range result.Content {
+		if text, ok := mcp.AsTextContent(content); ok && text.Text != "" {
+			texts = append(texts, text.Text)
+		}
+	}
+	return strings.Join(texts, "\n")
+}
+
+// truncateErrorMessage cuts message to maxErrorMessageBytes on a rune
+// boundary, marking the cut with an ellipsis
+func truncateErrorMessage(message string) string {
+	if len(message) <= maxErrorMessageBytes {
+		return message
+	}
+	const ellipsis = "…"
+	return message[:payloadPrefix(message, maxErrorMessageBytes-len(ellipsis), false)] + ellipsis
+}
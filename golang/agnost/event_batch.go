@@ -0,0 +1,107 @@
+package agnost
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// sendBatch sends a batch of events in a single request and completes the
+// events the collector recorded. It returns the events to send individually
+// instead: the ones the collector rejected, or the whole batch if the
+// collector has no batch route or failed the request with an error status. If
+// the request didn't reach the collector it returns the error, which applies
+// to every event.
+func (ep *EventProcessor) sendBatch(batch []*EventData) ([]*EventData, error) {
+	for _, event := range batch {
+		ep.prepareEvent(event)
+		ep.deliverChunks(ep.sendCtx, event)
+	}
+
+	jsonData, err := json.Marshal(batch)
+	if err != nil {
+		ep.log.Warning("Failed to marshal event batch, sending individually: %v", err)
+		return batch, nil
+	}
+
+	payload, encoding := ep.compressBody(jsonData)
+	url := fmt.Sprintf("%s/api/v1/capture-events", ep.endpoint)
+	req, err := http.NewRequestWithContext(ep.sendCtx, "POST", url, bytes.NewBuffer(payload))
+	if err != nil {
+		ep.log.Warning("Failed to create event batch request, sending individually: %v", err)
+		return batch, nil
+	}
+
+	addHeaders(req, ep.config.Headers)
+	if encoding != "" {
+		req.Header.Set("Content-Encoding", encoding)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Org-id", ep.orgID)
+	authorize(req, ep.apiKey)
+
+	if err := ep.backoff.check(); err != nil {
+		return nil, err
+	}
+	resp, err := doRequest(ep.httpClient, req, ep.config.requestTimeout())
+	if err != nil && req.Context().Err() != nil {
+		return nil, fmt.Errorf("failed to send event batch: %w", req.Context().Err())
+	}
+	ep.backoff.observe(err)
+	if err != nil {
+		ep.counters.recordError(err)
+		return nil, fmt.Errorf("failed to send event batch: %w", err)
+	}
+
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+
+	// Rejected credentials apply to every event; back off instead of sending
+	// them individually
+	if err := ep.auth.observe(resp.StatusCode); err != nil {
+		ep.backoff.observe(err)
+		ep.counters.recordError(err)
+		return nil, err
+	}
+
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		ep.log.Info("Collector does not accept event batches, sending events individually")
+		ep.batchUnsupported.Store(true)
+		return batch, nil
+	case resp.StatusCode < 200 || resp.StatusCode >= 300:
+		ep.log.Warning("Event batch failed with status %d, sending individually: %s", resp.StatusCode, string(body))
+		return batch, nil
+	}
+	ep.sendSucceeded()
+
+	// Without a readable response the collector recorded the whole batch
+	var result EventBatchResponse
+	if len(body) > 0 {
+		if err := json.Unmarshal(body, &result); err != nil {
+			ep.log.Debug("Failed to decode event batch response: %v", err)
+		}
+	}
+
+	rejected := make(map[int]bool, len(result.Rejected))
+	for _, r := range result.Rejected {
+		if r.Index < 0 || r.Index >= len(batch) {
+			continue
+		}
+		rejected[r.Index] = true
+		ep.log.forEvent(batch[r.Index]).Debug("Event %s/%s rejected from batch, sending individually: %s", batch[r.Index].PrimitiveType, batch[r.Index].PrimitiveName, r.Error)
+	}
+
+	var retry []*EventData
+	for i, event := range batch {
+		if rejected[i] {
+			retry = append(retry, event)
+			continue
+		}
+		ep.complete(event, nil)
+	}
+	ep.log.Debug("Event batch sent: %d recorded, %d rejected", len(batch)-len(retry), len(retry))
+	return retry, nil
+}
@@ -0,0 +1,122 @@
+package agnost
+
+import (
+	"sync"
+	"time"
+)
+
+const (
+	// eventHookQueueSize is the number of outcomes buffered for the event
+	// hooks; outcomes are dropped while it is full
+	eventHookQueueSize = 1024
+
+	// eventHookDrainTimeout bounds how long shutdown waits for buffered
+	// outcomes to be delivered
+	eventHookDrainTimeout = time.Second
+)
+
+// eventOutcome is the terminal outcome of an event, for the event hooks
+type eventOutcome struct {
+	event  *EventData
+	err    error      // set for failed and dropped events
+	reason DropReason // set for dropped events
+	kind   deliveryOutcome
+}
+
+// eventHooks hands event outcomes to Config.OnEventSent, OnEventFailed and
+// OnEventDropped on a goroutine of their own, so the callbacks run outside the
+// SDK's locks and a slow or panicking one never holds up the worker
+type eventHooks struct {
+	onSent    func(event *EventData)
+	onFailed  func(event *EventData, err error)
+	onDropped func(event *EventData, reason DropReason)
+
+	outcomes chan eventOutcome
+	done     chan struct{}
+	log      *levelLogger
+
+	mu     sync.Mutex
+	closed bool
+}
+
+// newEventHooks starts delivering outcomes to the configured hooks, or returns
+// nil if none is set
+func newEventHooks(config *AgnostConfig) *eventHooks {
+	if config.OnEventSent == nil && config.OnEventFailed == nil && config.OnEventDropped == nil {
+		return nil
+	}
+	h := &eventHooks{
+		onSent:    config.OnEventSent,
+		onFailed:  config.OnEventFailed,
+		onDropped: config.OnEventDropped,
+		outcomes:  make(chan eventOutcome, eventHookQueueSize),
+		done:      make(chan struct{}),
+		log:       config.logger(),
+	}
+	go h.run()
+	return h
+}
+
+func (h *eventHooks) run() {
+	defer close(h.done)
+	for outcome := range h.outcomes {
+		h.deliver(outcome)
+	}
+}
+
+// deliver invokes the hook of an outcome, recovering from its panics
+func (h *eventHooks) deliver(outcome eventOutcome) {
+	switch outcome.kind {
+	case outcomeDelivered:
+		if h.onSent != nil {
+			guard(h.log, "OnEventSent", func() { h.onSent(outcome.event) })
+		}
+	case outcomeFailed:
+		if h.onFailed != nil {
+			guard(h.log, "OnEventFailed", func() { h.onFailed(outcome.event, outcome.err) })
+		}
+	case outcomeDropped:
+		if h.onDropped != nil {
+			guard(h.log, "OnEventDropped", func() { h.onDropped(outcome.event, outcome.reason) })
+		}
+	}
+}
+
+// notify queues an outcome without blocking, dropping it if the queue is full
+func (h *eventHooks) notify(outcome eventOutcome) {
+	if h == nil {
+		return
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.closed {
+		return
+	}
+	select {
+	case h.outcomes <- outcome:
+	default:
+		h.log.Debug("Event hook queue full, outcome of %s/%s dropped", outcome.event.PrimitiveType, outcome.event.PrimitiveName)
+	}
+}
+
+// close stops accepting outcomes and waits a bounded time for queued ones to
+// be delivered
+func (h *eventHooks) close() {
+	if h == nil {
+		return
+	}
+	h.mu.Lock()
+	if h.closed {
+		h.mu.Unlock()
+		return
+	}
+	h.closed = true
+	close(h.outcomes)
+	h.mu.Unlock()
+
+	select {
+	case <-h.done:
+	case <-time.After(eventHookDrainTimeout):
+		h.log.Warning("Timed out delivering event outcomes to the event hooks")
+	}
+}
@@ -0,0 +1,144 @@
+package agnost
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"sync"
+	"testing"
+)
+
+// hookRecorder records the events handed to the event hooks, by outcome
+type hookRecorder struct {
+	mu      sync.Mutex
+	sent    []string
+	failed  map[string]error
+	dropped map[string]DropReason
+}
+
+func newHookRecorder(config *AgnostConfig) *hookRecorder {
+	r := &hookRecorder{failed: map[string]error{}, dropped: map[string]DropReason{}}
+	config.OnEventSent = func(event *EventData) {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		r.sent = append(r.sent, event.PrimitiveName)
+	}
+	config.OnEventFailed = func(event *EventData, err error) {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		r.failed[event.PrimitiveName] = err
+	}
+	config.OnEventDropped = func(event *EventData, reason DropReason) {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		r.dropped[event.PrimitiveName] = reason
+	}
+	return r
+}
+
+func TestEventHooksReceiveSentAndFailedEvents(t *testing.T) {
+	collector := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if body, _ := io.ReadAll(r.Body); bytes.Contains(body, []byte("doomed")) {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}))
+	t.Cleanup(collector.Close)
+	config := DefaultConfig()
+	config.Logger = NewLogger(io.Discard)
+	config.BatchSize = 1
+	config.MaxRetries = 0
+	hooks := newHookRecorder(config)
+	ep := NewEventProcessor(collector.URL, "org", config)
+
+	for _, name := range []string{"ok", "doomed"} {
+		ep.QueueEvent(&EventData{SessionID: "s", PrimitiveType: "tool", PrimitiveName: name})
+	}
+	ep.Flush()
+	// Shutting down waits for the hooks to get the outcomes
+	ep.Shutdown()
+
+	hooks.mu.Lock()
+	defer hooks.mu.Unlock()
+	if !slices.Equal(hooks.sent, []string{"ok"}) {
+		t.Errorf("OnEventSent got %v, want [ok]", hooks.sent)
+	}
+	if err, failed := hooks.failed["doomed"]; !failed || err == nil || len(hooks.failed) != 1 {
+		t.Errorf("OnEventFailed got %v, want doomed with its error", hooks.failed)
+	}
+	if len(hooks.dropped) != 0 {
+		t.Errorf("OnEventDropped got %v, want nothing", hooks.dropped)
+	}
+}
+
+func TestEventHooksReceiveDroppedEvents(t *testing.T) {
+	held, received, release := newHeldCollector(t)
+	config := DefaultConfig()
+	config.Logger = NewLogger(io.Discard)
+	config.QueueSize = 1
+	config.BatchSize = 1
+	config.MaxRetries = 0
+	hooks := newHookRecorder(config)
+	ep := NewEventProcessor(held.URL, "org", config)
+
+	ep.QueueEvent(&EventData{SessionID: "s", PrimitiveType: "tool", PrimitiveName: "sending"})
+	<-received
+	ep.QueueEvent(&EventData{SessionID: "s", PrimitiveType: "tool", PrimitiveName: "queued"})
+	ep.QueueEvent(&EventData{SessionID: "s", PrimitiveType: "tool", PrimitiveName: "overflowing"})
+	release()
+	ep.Shutdown()
+
+	hooks.mu.Lock()
+	defer hooks.mu.Unlock()
+	if reason := hooks.dropped["overflowing"]; reason != DropQueueFull {
+		t.Errorf("OnEventDropped got %v, want overflowing dropped for %s", hooks.dropped, DropQueueFull)
+	}
+	if !slices.Equal(hooks.sent, []string{"sending", "queued"}) {
+		t.Errorf("OnEventSent got %v, want [sending queued]", hooks.sent)
+	}
+}
+
+func TestEventHooksDontHoldUpTheWorker(t *testing.T) {
+	collector := newEventCollector(t)
+	config := DefaultConfig()
+	config.Logger = NewLogger(io.Discard)
+	config.BatchSize = 1
+	unblock := make(chan struct{})
+	var mu sync.Mutex
+	var sent []string
+	config.OnEventSent = func(event *EventData) {
+		if event.PrimitiveName == "panics" {
+			panic("hook failed")
+		}
+		<-unblock
+		mu.Lock()
+		defer mu.Unlock()
+		sent = append(sent, event.PrimitiveName)
+	}
+	ep := NewEventProcessor(collector.URL, "org", config)
+
+	for _, name := range []string{"panics", "a", "b"} {
+		ep.QueueEvent(&EventData{SessionID: "s", PrimitiveType: "tool", PrimitiveName: name})
+	}
+	if !waitUntil(func() bool { return len(collector.Events("tool")) == 3 }) {
+		t.Fatal("a blocked hook held up delivering the events")
+	}
+	close(unblock)
+	ep.Shutdown()
+
+	mu.Lock()
+	defer mu.Unlock()
+	if !slices.Equal(sent, []string{"a", "b"}) {
+		t.Errorf("OnEventSent got %v after a panic, want [a b]", sent)
+	}
+}
+
+func TestEventHooksAreOffWithoutCallbacks(t *testing.T) {
+	if h := newEventHooks(DefaultConfig()); h != nil {
+		t.Error("started event hooks without callbacks")
+	}
+	var unset *eventHooks
+	unset.notify(eventOutcome{event: &EventData{}, kind: outcomeDelivered})
+	unset.close()
+}
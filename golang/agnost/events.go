@@ -4,10 +4,13 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"log/slog"
 	"net/http"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -17,6 +20,9 @@ type EventProcessor struct {
 	orgID      string
 	httpClient *http.Client
 	config     *AgnostConfig
+	log        *levelLogger
+	apiKey     string
+	auth       *authMonitor // shared with the session manager
 
 	queue      chan *EventData
 	batchQueue []*EventData
@@ -24,40 +30,166 @@ type EventProcessor struct {
 	wg         sync.WaitGroup
 	ctx        context.Context
 	cancel     context.CancelFunc
+
+	// sendCtx bounds the worker's sends; abandon cancels it once a shutdown
+	// deadline passed, aborting the sends in flight
+	sendCtx context.Context
+	abandon context.CancelFunc
+
+	startWorker sync.Once
+	running     atomic.Bool        // the worker was started
+	flushes     chan chan struct{} // flush requests, closed by the worker once done
+
+	capabilities *capabilityProbe
+	datagram     *datagramExporter // nil unless events are sent as datagrams
+	deliveries   *deliveryRollup   // nil unless delivery outcomes are rolled up
+	drops        *dropCounter      // nil unless drops are counted
+	sessions     *SessionManager   // re-registers sessions the collector forgot
+	spool        *eventSpool       // nil unless failed events are spooled
+	overflow     *eventOverflow    // nil unless events finding the queue full spill to disk
+	hooks        *eventHooks       // nil unless an event hook is configured
+
+	// congested is set while the queue is close to capacity
+	congested atomic.Bool
+
+	// queuedBytes approximates the memory held by queued event payloads
+	queuedBytes atomic.Int64
+
+	// counters counts the events through the pipeline, see Stats
+	counters pipelineCounters
+
+	// failures condenses repeated identical send failures in the logs
+	failures failureStreak
+
+	// backoff fails sends locally while the endpoint is unreachable
+	backoff endpointBackoff
+
+	// clockJumps notices wall-clock jumps between worker ticks
+	clockJumps *clockJumpDetector
+
+	// undelivered counts the events that failed or were dropped once shutdown
+	// started
+	undelivered atomic.Int64
+
+	// batchUnsupported is set once the collector answered 404 on the batch
+	// route; batches are then sent one event at a time
+	batchUnsupported atomic.Bool
 }
 
 // NewEventProcessor creates a new event processor
 func NewEventProcessor(endpoint string, orgID string, config *AgnostConfig) *EventProcessor {
+	return NewEventProcessorWithClient(endpoint, orgID, config.httpClient(), config)
+}
+
+// NewEventProcessorWithClient creates a new event processor sending its
+// requests through httpClient
+func NewEventProcessorWithClient(endpoint string, orgID string, httpClient *http.Client, config *AgnostConfig) *EventProcessor {
 	ctx, cancel := context.WithCancel(context.Background())
+	sendCtx, abandon := context.WithCancel(context.Background())
 
+	log := config.logger()
 	ep := &EventProcessor{
 		endpoint:   endpoint,
 		orgID:      orgID,
-		httpClient: &http.Client{Timeout: config.RequestTimeout},
+		httpClient: httpClient,
 		config:     config,
-		queue:      make(chan *EventData, 100), // Buffered channel
+		log:        log,
+		apiKey:     config.apiKey(),
+		auth:       &authMonitor{log: log},
+		queue:      make(chan *EventData, config.queueSize()),
+		flushes:    make(chan chan struct{}),
 		batchQueue: make([]*EventData, 0, config.BatchSize),
 		ctx:        ctx,
 		cancel:     cancel,
+		sendCtx:    sendCtx,
+		abandon:    abandon,
+
+		capabilities: newCapabilityProbe(endpoint, orgID, config, httpClient),
+		clockJumps:   newClockJumpDetector(systemClock),
+	}
+	ep.backoff.clock = systemClock
+	ep.backoff.log = log
+
+	if config.SpoolDir != "" && !config.FireAndForget {
+		ep.spool = newEventSpool(config.SpoolDir, orgID, config.SpoolMaxBytes, log)
+	}
+	if config.OverflowDir != "" {
+		ep.overflow = newEventOverflow(config.OverflowDir, orgID, config.OverflowMaxBytes, log)
 	}
+	ep.hooks = newEventHooks(config)
 
-	// Start background worker
-	ep.wg.Add(1)
-	go ep.worker()
+	// Start the background worker; without queuing events are sent directly and
+	// the worker only starts if an event is queued anyway
+	if config.EnableRequestQueuing {
+		ep.start()
+	}
 
 	return ep
 }
 
+// start starts the background worker unless it is already running
+func (ep *EventProcessor) start() {
+	ep.startWorker.Do(func() {
+		ep.running.Store(true)
+		ep.wg.Add(1)
+		go ep.worker()
+	})
+}
+
 // QueueEvent queues an event for processing
 func (ep *EventProcessor) QueueEvent(event *EventData) {
+	if ep.ctx.Err() == nil {
+		ep.start()
+	}
+
 	select {
 	case ep.queue <- event:
-		Debug("Event queued: %s/%s", event.PrimitiveType, event.PrimitiveName)
+		ep.queued(event)
 	case <-ep.ctx.Done():
-		Warning("Event processor shutting down, event dropped")
+		ep.log.Warning("Event processor shutting down, event dropped")
+		ep.drop(event, DropShutdown, errProcessorShutDown)
 	default:
-		Warning("Event queue full, event dropped: %s/%s", event.PrimitiveType, event.PrimitiveName)
+		if timeout := ep.config.queueFullTimeout(); timeout > 0 && ep.waitForRoom(event, timeout) {
+			return
+		}
+		if ep.overflowEvent(event) {
+			ep.congested.Store(true)
+			return
+		}
+		ep.log.forEvent(event).Warning("Event queue full (%d/%d queued), event dropped: %s/%s; consider raising QueueSize",
+			len(ep.queue), cap(ep.queue), event.PrimitiveType, event.PrimitiveName)
+		ep.congested.Store(true)
+		ep.drop(event, DropQueueFull, errQueueFull)
+	}
+}
+
+// waitForRoom waits up to timeout for room in the full queue to queue event,
+// reporting whether the event was taken care of. Shutting down drops it rather
+// than waiting on a queue no worker drains anymore.
+func (ep *EventProcessor) waitForRoom(event *EventData, timeout time.Duration) bool {
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	select {
+	case ep.queue <- event:
+		ep.queued(event)
+	case <-ep.ctx.Done():
+		ep.log.Warning("Event processor shutting down, event dropped")
+		ep.drop(event, DropShutdown, errProcessorShutDown)
+	case <-timer.C:
+		return false
+	}
+	return true
+}
+
+// queued accounts for an event that entered the queue
+func (ep *EventProcessor) queued(event *EventData) {
+	if ep.log.enabled(LogLevelDebug) {
+		ep.log.forEvent(event).Debug("Event queued: %s/%s", event.PrimitiveType, event.PrimitiveName)
 	}
+	ep.counters.queued.Add(1)
+	ep.queuedBytes.Add(event.payloadBytes())
+	ep.updateCongestion()
 }
 
 // worker processes events from the queue
@@ -67,32 +199,62 @@ func (ep *EventProcessor) worker() {
 	ticker := time.NewTicker(5 * time.Second) // Flush batch every 5 seconds
 	defer ticker.Stop()
 
+	// Replay events spooled or overflowed by a previous process
+	if ep.spool != nil {
+		ep.spool.drain(ep.sendSpooled)
+	}
+	ep.drainOverflow()
+
 	for {
 		select {
 		case event := <-ep.queue:
-			ep.addToBatch(event)
+			ep.receive(event)
 
-			// Send batch if it's full
-			if len(ep.batchQueue) >= ep.config.BatchSize {
-				ep.flushBatch()
-			}
+		case done := <-ep.flushes:
+			ep.drainQueue()
+			ep.flushBatch()
+			close(done)
 
 		case <-ticker.C:
+			ep.clockJumps.check()
+
 			// Periodic flush
 			if len(ep.batchQueue) > 0 {
 				ep.flushBatch()
 			}
+			if ep.spool != nil {
+				ep.spool.drain(ep.sendSpooled)
+			}
+			ep.drainOverflow()
 
 		case <-ep.ctx.Done():
 			// Flush remaining events before shutdown
-			if len(ep.batchQueue) > 0 {
-				ep.flushBatch()
-			}
+			ep.drainQueue()
+			ep.flushBatch()
 			return
 		}
 	}
 }
 
+// receive moves an event taken from the queue into the batch, sending the
+// batch if it's full or someone is waiting for the event's delivery
+func (ep *EventProcessor) receive(event *EventData) {
+	ep.queuedBytes.Add(-event.payloadBytes())
+	ep.updateCongestion()
+	ep.addToBatch(event)
+
+	if len(ep.batchQueue) >= ep.config.BatchSize || event.delivered != nil {
+		ep.flushBatch()
+	}
+}
+
+// drainQueue moves the events queued so far into the batch
+func (ep *EventProcessor) drainQueue() {
+	for n := len(ep.queue); n > 0; n-- {
+		ep.receive(<-ep.queue)
+	}
+}
+
 // addToBatch adds an event to the batch queue
 func (ep *EventProcessor) addToBatch(event *EventData) {
 	ep.mu.Lock()
@@ -113,79 +275,429 @@ func (ep *EventProcessor) flushBatch() {
 	ep.batchQueue = make([]*EventData, 0, ep.config.BatchSize)
 	ep.mu.Unlock()
 
-	Debug("Flushing batch of %d events", len(batch))
+	ep.log.Debug("Flushing batch of %d events", len(batch))
 
-	// Send each event (TODO: implement batch API endpoint)
-	for _, event := range batch {
-		if err := ep.sendEvent(event); err != nil {
-			Warning("Failed to send event: %v", err)
+	var deferred []*EventData
+	settle := func(event *EventData, err error) {
+		if err != nil {
+			if ep.spoolEvent(event) {
+				return
+			}
+			// Keep events that never reached the collector for the next flush,
+			// unless this is the final flush
+			if endpointUnreachable(err) && event.deferrals < maxEndpointDeferrals && ep.ctx.Err() == nil {
+				event.deferrals++
+				deferred = append(deferred, event)
+				return
+			}
 		}
+		ep.complete(event, err)
+	}
+
+	// Send the batch in one request, then individually whatever it didn't
+	// deliver; datagrams have no batch form
+	individual := batch
+	if len(batch) > 1 && ep.datagram == nil && !ep.batchUnsupported.Load() {
+		retry, err := ep.sendBatch(batch)
+		if err != nil {
+			if !errors.Is(err, errEndpointCoolingDown) {
+				ep.warnSendFailure(err)
+			}
+			for _, event := range batch {
+				settle(event, err)
+			}
+		}
+		individual = retry
+	}
+
+	for _, event := range individual {
+		err := ep.sendEvent(ep.sendCtx, event)
+		if err != nil && !errors.Is(err, errEndpointCoolingDown) {
+			ep.warnSendFailure(err)
+		}
+		settle(event, err)
+	}
+
+	if len(deferred) > 0 {
+		ep.mu.Lock()
+		ep.batchQueue = append(deferred, ep.batchQueue...)
+		ep.mu.Unlock()
 	}
 }
 
-// sendEvent sends a single event to the API
-func (ep *EventProcessor) sendEvent(event *EventData) error {
-	// Marshal to JSON
+// sendEvent sends a single event to the API, giving up once ctx is done
+func (ep *EventProcessor) sendEvent(ctx context.Context, event *EventData) error {
+	ep.prepareEvent(event)
+
+	// Datagrams are fire-and-forget: no chunks, acknowledgements or retries
+	if ep.datagram != nil {
+		ep.datagram.sendEvent(event)
+		return nil
+	}
+
+	// Deliver oversized payloads ahead of the event that references them
+	ep.deliverChunks(ctx, event)
+
+	// Marshal to JSON, compressing once for every attempt
 	jsonData, err := json.Marshal(event)
 	if err != nil {
 		return fmt.Errorf("failed to marshal event: %v", err)
 	}
+	body, encoding := ep.compressBody(jsonData)
+
+	// Without retries, send once and skip the retry loop altogether
+	maxRetries := ep.config.maxRetries()
+	if maxRetries == 0 {
+		req, err := ep.newEventRequest(ctx, body, encoding)
+		if err != nil {
+			return err
+		}
+		if err := ep.attemptEvent(req, event); err != nil {
+			return fmt.Errorf("failed to send event: %w", err)
+		}
+		return nil
+	}
+
+	// Send request with retries, RetryDelay apart; retries stop early only
+	// when the endpoint's cooldown outlasts the delay, as they would fail
+	// locally anyway
+	var lastErr error
+	for attempt := 0; attempt <= maxRetries; attempt++ {
+		if attempt > 0 {
+			if ep.backoff.coolingDownAfter(ep.config.RetryDelay) || errors.Is(lastErr, errAuthRejected) || ctx.Err() != nil {
+				break
+			}
+			ep.log.forEvent(event).with(slog.Int("attempt", attempt), slog.Int("max_retries", maxRetries)).
+				Debug("Retrying event send (attempt %d/%d)", attempt, maxRetries)
+			if err := sleepContext(ctx, ep.config.RetryDelay); err != nil {
+				return fmt.Errorf("failed to send event: %w", err)
+			}
+			ep.counters.retried.Add(1)
+		}
+
+		// Each attempt gets a request of its own, as the previous one's body
+		// was consumed
+		req, err := ep.newEventRequest(ctx, body, encoding)
+		if err != nil {
+			return err
+		}
+		if lastErr = ep.attemptEvent(req, event); lastErr == nil {
+			return nil
+		}
+	}
+	if endpointUnreachable(lastErr) || errors.Is(lastErr, errAuthRejected) || ctx.Err() != nil {
+		return fmt.Errorf("failed to send event: %w", lastErr)
+	}
+
+	return fmt.Errorf("failed after %d retries: %w", maxRetries, lastErr)
+}
 
-	// Create HTTP request
-	url := fmt.Sprintf("%s/api/v1/capture-event", ep.endpoint)
-	req, err := http.NewRequest("POST", url, bytes.NewBuffer(jsonData))
+// newEventRequest creates the request of an event delivery attempt, with the
+// body's Content-Encoding if it is compressed. Its body can be replayed
+// through GetBody, so redirects that keep the method and body resend the full
+// event.
+func (ep *EventProcessor) newEventRequest(ctx context.Context, body []byte, encoding string) (*http.Request, error) {
+	url := ep.endpoint + "/api/v1/capture-event"
+	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewReader(body))
 	if err != nil {
-		return fmt.Errorf("failed to create event request: %v", err)
+		return nil, fmt.Errorf("failed to create event request: %v", err)
+	}
+	if encoding != "" {
+		req.Header.Set("Content-Encoding", encoding)
 	}
 
+	addHeaders(req, ep.config.Headers)
 	req.Header.Set("Content-Type", "application/json")
 	req.Header.Set("X-Org-id", ep.orgID)
+	authorize(req, ep.apiKey)
+	return req, nil
+}
 
-	// Send request with retries
-	var lastErr error
-	for attempt := 0; attempt <= ep.config.MaxRetries; attempt++ {
-		if attempt > 0 {
-			Debug("Retrying event send (attempt %d/%d)", attempt, ep.config.MaxRetries)
-			time.Sleep(ep.config.RetryDelay)
-		}
+// prepareEvent stamps an event for a send attempt
+func (ep *EventProcessor) prepareEvent(event *EventData) {
+	if event.DeliveryMode == "" {
+		event.DeliveryMode = DeliveryModeLive
+	}
+	event.SentAt = time.Now().UnixMilli()
+}
+
+// attemptEvent makes a single delivery attempt of an event request, failing
+// it locally while the endpoint cools down after a transport error. An attempt
+// aborted by the caller's context says nothing about the endpoint, so it
+// doesn't start a cooldown.
+func (ep *EventProcessor) attemptEvent(req *http.Request, event *EventData) error {
+	if err := ep.backoff.check(); err != nil {
+		return err
+	}
+	err := ep.postEvent(req, event)
+	if err != nil && req.Context().Err() != nil {
+		return req.Context().Err()
+	}
+	ep.backoff.observe(err)
+	if err != nil {
+		ep.counters.recordError(err)
+	}
+	return err
+}
 
-		resp, err := ep.httpClient.Do(req)
+// postEvent makes a single delivery attempt of an event request. If the
+// collector forgot the event's session, the session is re-registered and the
+// event re-sent once as part of the same attempt.
+func (ep *EventProcessor) postEvent(req *http.Request, event *EventData) error {
+	resent := false
+	for {
+		resp, err := doRequest(ep.httpClient, req, ep.config.requestTimeout())
 		if err != nil {
-			lastErr = err
-			continue
+			return err
 		}
 
 		// Read and close response body
 		body, _ := io.ReadAll(resp.Body)
 		resp.Body.Close()
 
+		// Re-register the session and retry once if the collector forgot it
+		if !resent && ep.sessions != nil && isUnknownSession(resp, body) {
+			resent = true
+			if ep.sessions.resendSession(event.SessionID) {
+				if req.Body, err = req.GetBody(); err != nil {
+					return fmt.Errorf("failed to rewind event request: %v", err)
+				}
+				ep.counters.retried.Add(1)
+				continue
+			}
+		}
+
 		// Check status code
+		if err := ep.auth.observe(resp.StatusCode); err != nil {
+			return err
+		}
 		if resp.StatusCode >= 200 && resp.StatusCode < 300 {
-			Debug("Event sent successfully: %s/%s", event.PrimitiveType, event.PrimitiveName)
+			if ep.log.enabled(LogLevelDebug) {
+				ep.log.forEvent(event).Debug("Event sent successfully: %s/%s", event.PrimitiveType, event.PrimitiveName)
+			}
+			ep.sendSucceeded()
 			return nil
 		}
 
-		lastErr = fmt.Errorf("event send failed with status %d: %s", resp.StatusCode, string(body))
+		return fmt.Errorf("event send failed with status %d: %s", resp.StatusCode, string(body))
 	}
+}
 
-	return fmt.Errorf("failed after %d retries: %v", ep.config.MaxRetries, lastErr)
+// The collector reports events referencing a session it doesn't know, e.g.
+// after losing its session store, with a header on the response or with a
+// 409 response carrying the unknown session error code
+const (
+	unknownSessionHeader = "X-Agnost-Unknown-Session"
+	unknownSessionCode   = "unknown_session"
+)
+
+// isUnknownSession reports whether an event response says the collector doesn't
+// know the event's session
+func isUnknownSession(resp *http.Response, body []byte) bool {
+	if resp.Header.Get(unknownSessionHeader) == "1" {
+		return true
+	}
+	if resp.StatusCode != http.StatusConflict {
+		return false
+	}
+	var errorBody struct {
+		Code string `json:"code"`
+	}
+	return json.Unmarshal(body, &errorBody) == nil && errorBody.Code == unknownSessionCode
+}
+
+// complete records the terminal outcome of an event's delivery
+func (ep *EventProcessor) complete(event *EventData, err error) {
+	outcome := outcomeDelivered
+	if err != nil {
+		outcome = outcomeFailed
+		ep.drops.count(DropDeliveryFailed)
+		ep.counters.failed.Add(1)
+		if ep.ctx.Err() != nil {
+			ep.undelivered.Add(1)
+		}
+	} else {
+		ep.counters.sent.Add(1)
+	}
+	ep.deliveries.record(outcome, event.EnqueuedAt, event.enqueuedMonotonic)
+	event.resolve(err)
+	ep.hooks.notify(eventOutcome{event: event, err: err, kind: outcome})
+}
+
+// drop records an event discarded before its delivery was attempted
+func (ep *EventProcessor) drop(event *EventData, reason DropReason, err error) {
+	ep.deliveries.record(outcomeDropped, event.EnqueuedAt, event.enqueuedMonotonic)
+	ep.drops.count(reason)
+	if ep.ctx.Err() != nil {
+		ep.undelivered.Add(1)
+	}
+	event.resolve(err)
+	ep.hooks.notify(eventOutcome{event: event, err: err, reason: reason, kind: outcomeDropped})
 }
 
-// Shutdown gracefully shuts down the event processor
+// spoolEvent spools an event that failed delivery, reporting whether it was
+// spooled. Spooled events count as delivered for whoever waits on them; their
+// outcome is recorded once the spool is drained.
+func (ep *EventProcessor) spoolEvent(event *EventData) bool {
+	if ep.spool == nil {
+		return false
+	}
+	if err := ep.spool.write(event); err != nil {
+		ep.log.Warning("Failed to spool event: %v", err)
+		return false
+	}
+	ep.log.forEvent(event).Debug("Event spooled: %s/%s", event.PrimitiveType, event.PrimitiveName)
+	event.resolve(nil)
+	return true
+}
+
+// overflowEvent writes an event that found the queue full to the overflow
+// file, reporting whether it was written. Like spooled events, overflowed
+// events count as delivered for whoever waits on them.
+func (ep *EventProcessor) overflowEvent(event *EventData) bool {
+	if ep.overflow == nil {
+		return false
+	}
+	dropped, err := ep.overflow.write(event)
+	if err != nil {
+		ep.log.Warning("Failed to write event to the overflow file: %v", err)
+		return false
+	}
+	if dropped > 0 {
+		ep.log.Warning("Overflow file full, dropped its %d oldest events", dropped)
+		for range dropped {
+			ep.drops.count(DropOverflowFull)
+		}
+	}
+	ep.log.forEvent(event).Debug("Event written to the overflow file: %s/%s", event.PrimitiveType, event.PrimitiveName)
+	event.resolve(nil)
+	return true
+}
+
+// drainOverflow sends the events of the overflow file, a chunk at a time,
+// for as long as the queue keeps room and the endpoint is reachable
+func (ep *EventProcessor) drainOverflow() {
+	if ep.overflow == nil {
+		return
+	}
+	sent := 0
+	for ep.ctx.Err() == nil && !ep.congested.Load() && !ep.backoff.coolingDown() {
+		events := ep.overflow.take(overflowDrainChunk)
+		if len(events) == 0 {
+			break
+		}
+		for _, event := range events {
+			ep.addToBatch(event)
+			if len(ep.batchQueue) >= ep.config.BatchSize {
+				ep.flushBatch()
+			}
+		}
+		ep.flushBatch()
+		sent += len(events)
+	}
+	if sent > 0 {
+		ep.log.Info("Sent %d events from the overflow file", sent)
+	}
+}
+
+// sendSpooled sends an event drained from the spool
+func (ep *EventProcessor) sendSpooled(event *EventData) error {
+	err := ep.sendEvent(ep.sendCtx, event)
+	if err == nil {
+		ep.complete(event, nil)
+	}
+	return err
+}
+
+// Shutdown gracefully shuts down the event processor, waiting for the worker
+// to send the queued events however long it takes
 func (ep *EventProcessor) Shutdown() {
-	Info("Shutting down event processor...")
+	ep.shutdown(context.Background())
+}
+
+// shutdown stops the worker once it sent the queued events, and returns how
+// many events were left undelivered. Once ctx is done the sends in flight are
+// abandoned. Events the worker didn't get to are spooled or overflowed if
+// enabled, and otherwise their delivery is resolved as failed so nobody waits
+// on them.
+func (ep *EventProcessor) shutdown(ctx context.Context) int64 {
+	ep.log.Info("Shutting down event processor...")
 	ep.cancel()
-	ep.wg.Wait()
-	Info("Event processor shut down")
+
+	stopped := make(chan struct{})
+	go func() {
+		ep.wg.Wait()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+	case <-ctx.Done():
+		ep.log.Warning("Shutdown deadline passed, abandoning event sends in flight")
+		ep.abandon()
+		<-stopped
+	}
+	ep.abandon()
+
+	for len(ep.queue) > 0 {
+		event := <-ep.queue
+		ep.queuedBytes.Add(-event.payloadBytes())
+		if !ep.spoolEvent(event) && !ep.overflowEvent(event) {
+			ep.drop(event, DropShutdown, errProcessorShutDown)
+		}
+	}
+	ep.hooks.close()
+	ep.log.Info("Event processor shut down")
+	return ep.undelivered.Load()
 }
 
-// Flush flushes any pending events
+// overflowBytes returns the size of the overflow file
+func (ep *EventProcessor) overflowBytes() int64 {
+	if ep.overflow == nil {
+		return 0
+	}
+	return ep.overflow.size()
+}
+
+// QueueStats returns the number of queued events, the approximate bytes their
+// payloads hold and the size of the spool
+func (ep *EventProcessor) QueueStats() (events int, bytes int64, spoolBytes int64) {
+	if ep.spool != nil {
+		spoolBytes = ep.spool.size()
+	}
+	return len(ep.queue), ep.queuedBytes.Load(), spoolBytes
+}
+
+// Flush sends any pending events, waiting until they were attempted
 func (ep *EventProcessor) Flush() {
-	ep.mu.Lock()
-	if len(ep.batchQueue) > 0 {
-		ep.mu.Unlock()
-		ep.flushBatch()
-	} else {
-		ep.mu.Unlock()
+	ep.flush(context.Background())
+}
+
+// flush has the worker send the events queued so far along with its batch,
+// returning once they were attempted, or early with ctx's error. The worker
+// only starts for it if events were queued.
+func (ep *EventProcessor) flush(ctx context.Context) error {
+	if !ep.running.Load() {
+		if len(ep.queue) == 0 || ep.ctx.Err() != nil {
+			return nil
+		}
+		ep.start()
+	}
+
+	done := make(chan struct{})
+	select {
+	case ep.flushes <- done:
+	case <-ep.ctx.Done():
+		return errProcessorShutDown
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+
+	select {
+	case <-done:
+		return nil
+	case <-ep.ctx.Done():
+		return errProcessorShutDown
+	case <-ctx.Done():
+		return ctx.Err()
 	}
 }
@@ -0,0 +1,221 @@
+package agnost
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// flakyCollector accepts events once fail returns false for an attempt
+type flakyCollector struct {
+	*httptest.Server
+	attempts atomic.Int64
+}
+
+// dropConnection fails an attempt with a transport error
+func dropConnection(w http.ResponseWriter) {
+	conn, _, err := w.(http.Hijacker).Hijack()
+	if err == nil {
+		conn.Close()
+	}
+}
+
+func newFlakyCollector(t *testing.T, fail func(attempt int64, w http.ResponseWriter) bool) *flakyCollector {
+	c := &flakyCollector{}
+	c.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.Copy(io.Discard, r.Body)
+		if !fail(c.attempts.Add(1), w) {
+			w.WriteHeader(http.StatusOK)
+		}
+	}))
+	t.Cleanup(c.Close)
+	return c
+}
+
+// frozenClock returns a clock whose monotonic time stands still
+func frozenClock() *clock {
+	return newClock(func() time.Time { return time.Now().Round(0) }, func() time.Duration { return 0 })
+}
+
+func newTestEventProcessor(t *testing.T, endpoint string, clock *clock) *EventProcessor {
+	config := DefaultConfig()
+	config.Logger = NewLogger(io.Discard)
+	config.MaxRetries = 3
+	config.RetryDelay = 10 * time.Millisecond
+	ep := NewEventProcessor(endpoint, "org", config)
+	ep.backoff.clock = clock
+	t.Cleanup(ep.Shutdown)
+	return ep
+}
+
+func TestSendEventRetriesOnceTheCooldownElapsed(t *testing.T) {
+	collector := newFlakyCollector(t, func(attempt int64, w http.ResponseWriter) bool {
+		if attempt > 1 {
+			return false
+		}
+		dropConnection(w)
+		return true
+	})
+	ep := newTestEventProcessor(t, collector.URL, systemClock)
+	ep.config.RetryDelay = minEndpointCooldown + 50*time.Millisecond
+
+	if err := ep.sendEvent(context.Background(), &EventData{SessionID: "s", PrimitiveType: "tool", PrimitiveName: "echo"}); err != nil {
+		t.Fatalf("event wasn't retried after a transport error: %v", err)
+	}
+	if got := collector.attempts.Load(); got != 2 {
+		t.Errorf("made %d attempts, want 2", got)
+	}
+}
+
+func TestSendEventStopsRetryingWhileTheCooldownOutlastsTheDelay(t *testing.T) {
+	collector := newFlakyCollector(t, func(attempt int64, w http.ResponseWriter) bool {
+		dropConnection(w)
+		return true
+	})
+	ep := newTestEventProcessor(t, collector.URL, frozenClock())
+
+	err := ep.sendEvent(context.Background(), &EventData{SessionID: "s", PrimitiveType: "tool", PrimitiveName: "echo"})
+	if !endpointUnreachable(err) {
+		t.Fatalf("got %v, want a transport error", err)
+	}
+	if got := collector.attempts.Load(); got != 1 {
+		t.Errorf("dialed a cooling down endpoint: made %d attempts, want 1", got)
+	}
+}
+
+func TestSendEventRetriesErrorStatuses(t *testing.T) {
+	collector := newFlakyCollector(t, func(attempt int64, w http.ResponseWriter) bool {
+		if attempt > 2 {
+			return false
+		}
+		w.WriteHeader(http.StatusServiceUnavailable)
+		return true
+	})
+	ep := newTestEventProcessor(t, collector.URL, frozenClock())
+
+	if err := ep.sendEvent(context.Background(), &EventData{SessionID: "s", PrimitiveType: "tool", PrimitiveName: "echo"}); err != nil {
+		t.Fatal(err)
+	}
+	if got := collector.attempts.Load(); got != 3 {
+		t.Errorf("made %d attempts, want 3", got)
+	}
+}
+
+// recordingCollector records the body of every event delivery attempt,
+// answering each with status
+type recordingCollector struct {
+	*httptest.Server
+	mu     sync.Mutex
+	bodies []string
+}
+
+func newRecordingCollector(t *testing.T, status func(attempt int, r *http.Request, w http.ResponseWriter) int) *recordingCollector {
+	c := &recordingCollector{}
+	c.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		c.mu.Lock()
+		c.bodies = append(c.bodies, string(body))
+		attempt := len(c.bodies)
+		c.mu.Unlock()
+		w.WriteHeader(status(attempt, r, w))
+	}))
+	t.Cleanup(c.Close)
+	return c
+}
+
+func TestSendEventRetriesWithTheFullBody(t *testing.T) {
+	collector := newRecordingCollector(t, func(attempt int, r *http.Request, w http.ResponseWriter) int {
+		if attempt == 1 {
+			return http.StatusInternalServerError
+		}
+		return http.StatusOK
+	})
+	ep := newTestEventProcessor(t, collector.URL, frozenClock())
+
+	event := &EventData{SessionID: "s", PrimitiveType: "tool", PrimitiveName: "echo", Input: `{"text":"hello"}`}
+	if err := ep.sendEvent(context.Background(), event); err != nil {
+		t.Fatal(err)
+	}
+	if len(collector.bodies) != 2 {
+		t.Fatalf("made %d attempts, want 2", len(collector.bodies))
+	}
+	for i, body := range collector.bodies {
+		var got EventData
+		if err := json.Unmarshal([]byte(body), &got); err != nil || got.Input != event.Input {
+			t.Errorf("attempt %d carried %q, want the whole event", i+1, body)
+		}
+	}
+}
+
+func TestSendEventResendsTheFullBodyOnRedirect(t *testing.T) {
+	collector := newRecordingCollector(t, func(attempt int, r *http.Request, w http.ResponseWriter) int {
+		if r.URL.Path != "/moved/api/v1/capture-event" {
+			w.Header().Set("Location", "/moved"+r.URL.Path)
+			return http.StatusTemporaryRedirect
+		}
+		return http.StatusOK
+	})
+	ep := newTestEventProcessor(t, collector.URL, frozenClock())
+
+	event := &EventData{SessionID: "s", PrimitiveType: "tool", PrimitiveName: "echo", Input: `{"text":"hello"}`}
+	if err := ep.sendEvent(context.Background(), event); err != nil {
+		t.Fatal(err)
+	}
+	if len(collector.bodies) != 2 || collector.bodies[0] != collector.bodies[1] {
+		t.Errorf("the redirected request carried %q, want %q", collector.bodies[len(collector.bodies)-1], collector.bodies[0])
+	}
+}
+
+func TestWorkerOnlyStartsOnceAnEventIsQueued(t *testing.T) {
+	collector := newEventCollector(t)
+	config := DefaultConfig()
+	config.Logger = NewLogger(io.Discard)
+	config.EnableRequestQueuing = false
+	ep := NewEventProcessor(collector.URL, "org", config)
+	t.Cleanup(ep.Shutdown)
+
+	ep.Flush()
+	if ep.running.Load() {
+		t.Fatal("worker started without queuing or queued events")
+	}
+
+	ep.QueueEvent(&EventData{SessionID: "s", PrimitiveType: "tool", PrimitiveName: "echo"})
+	if !ep.running.Load() {
+		t.Fatal("worker didn't start for a queued event")
+	}
+	ep.Flush()
+	if got := len(collector.Events("tool")); got != 1 {
+		t.Errorf("flushed %d events, want the queued one", got)
+	}
+}
+
+func TestSyncTrackingRunsNoWorker(t *testing.T) {
+	s, a, collector := newTrackedServer(t, nil)
+	addEchoTool(s)
+
+	callTool(t, s, "echo")
+	if got := len(collector.Events("tool")); got != 1 {
+		t.Fatalf("got %d tool events, want 1", got)
+	}
+	if a.eventProcessor.running.Load() {
+		t.Error("worker started although events are sent synchronously")
+	}
+
+	// Shutting down without a worker returns right away
+	done := make(chan struct{})
+	go func() {
+		a.Shutdown()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Shutdown without a worker hung")
+	}
+}
@@ -0,0 +1,158 @@
+package agnost_test
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/mark3labs/mcp-go/client"
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+
+	"github.com/agnostai/agnost-go/agnost"
+	"github.com/agnostai/agnost-go/agnosttest"
+)
+
+// echo is a tool handler returning its text argument
+func echo(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	return mcp.NewToolResultText(request.GetString("text", "")), nil
+}
+
+// callTool calls the named tool of s through an in-process client, the way
+// a client connected over stdio calls it
+func callTool(s *server.MCPServer, name string, args map[string]any) {
+	ctx := context.Background()
+	c, err := client.NewInProcessClient(s)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer c.Close()
+	if err := c.Start(ctx); err != nil {
+		log.Fatal(err)
+	}
+	initialize := mcp.InitializeRequest{}
+	initialize.Params.ProtocolVersion = mcp.LATEST_PROTOCOL_VERSION
+	initialize.Params.ClientInfo = mcp.Implementation{Name: "example", Version: "1.0.0"}
+	if _, err := c.Initialize(ctx, initialize); err != nil {
+		log.Fatal(err)
+	}
+
+	request := mcp.CallToolRequest{}
+	request.Params.Name = name
+	request.Params.Arguments = args
+	if _, err := c.CallTool(ctx, request); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// printEvents prints the events of the given primitive type the collector
+// received
+func printEvents(collector *agnosttest.Collector, primitiveType string) {
+	for _, event := range collector.Events() {
+		if event.PrimitiveType == primitiveType {
+			fmt.Println(event.PrimitiveName, event.Success)
+		}
+	}
+}
+
+func ExampleTrack() {
+	collector := agnosttest.NewCollector()
+	defer collector.Close()
+
+	s := server.NewMCPServer("my-server", "1.0.0")
+	s.AddTool(mcp.NewTool("echo", mcp.WithString("text")), echo)
+
+	// Collector.Config points the SDK at the fake collector; use
+	// agnost.DefaultConfig in production
+	if err := agnost.Track(s, "your-org-id", collector.Config()); err != nil {
+		log.Fatal(err)
+	}
+	defer agnost.Shutdown()
+
+	callTool(s, "echo", map[string]any{"text": "hello"})
+	collector.WaitForEvents(1, time.Second)
+	printEvents(collector, "tool")
+	// Output: echo true
+}
+
+func ExampleToolMiddleware() {
+	collector := agnosttest.NewCollector()
+	defer collector.Close()
+
+	// With the middleware, tools added after Track are tracked as well
+	s := server.NewMCPServer("my-server", "1.0.0", server.WithToolHandlerMiddleware(agnost.ToolMiddleware()))
+	c, err := agnost.New("your-org-id", collector.Config())
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer c.Shutdown()
+	if err := c.Track(s); err != nil {
+		log.Fatal(err)
+	}
+	s.AddTool(mcp.NewTool("echo", mcp.WithString("text")), echo)
+
+	callTool(s, "echo", map[string]any{"text": "hello"})
+	collector.WaitForEvents(1, time.Second)
+	printEvents(collector, "tool")
+	// Output: echo true
+}
+
+func ExampleClient_IdentifyContext() {
+	collector := agnosttest.NewCollector()
+	defer collector.Close()
+
+	c, err := agnost.New("your-org-id", collector.Config())
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer c.Shutdown()
+
+	// Identify the session once a tool call learned who the user is
+	s := server.NewMCPServer("my-server", "1.0.0", server.WithToolHandlerMiddleware(agnost.ToolMiddleware()))
+	s.AddTool(mcp.NewTool("login"), func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		c.IdentifyContext(ctx, agnost.UserIdentity{"user_id": "user-123", "role": "admin"})
+		return mcp.NewToolResultText("welcome"), nil
+	})
+	if err := c.Track(s); err != nil {
+		log.Fatal(err)
+	}
+
+	callTool(s, "login", nil)
+	for deadline := time.Now().Add(time.Second); len(collector.SessionUpdates()) == 0 && time.Now().Before(deadline); {
+		time.Sleep(10 * time.Millisecond)
+	}
+	for _, update := range collector.SessionUpdates() {
+		fmt.Println(update.Kind, update.UserData["user_id"])
+	}
+	// Output: identified user-123
+}
+
+func ExampleClient_Capture() {
+	collector := agnosttest.NewCollector()
+	defer collector.Close()
+
+	s := server.NewMCPServer("my-server", "1.0.0")
+	c, err := agnost.New("your-org-id", collector.Config())
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer c.Shutdown()
+	if err := c.Track(s); err != nil {
+		log.Fatal(err)
+	}
+
+	// Record an app-level milestone in the same timeline as the tool calls
+	err = c.Capture(context.Background(), "workflow_completed", map[string]any{
+		"steps":                  3,
+		agnost.PropertyLatencyMs: 1200 * time.Millisecond,
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+	collector.WaitForEvents(1, time.Second)
+	for _, event := range collector.Events() {
+		fmt.Println(event.PrimitiveType, event.PrimitiveName, event.Latency)
+	}
+	// Output: custom workflow_completed 1200
+}
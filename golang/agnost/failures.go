@@ -0,0 +1,182 @@
+package agnost
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"net"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// failureStreakHintAfter is the number of consecutive identical transport
+// errors after which a diagnostic hint is logged and further repeats are
+// suppressed
+const failureStreakHintAfter = 5
+
+// transportErrorClass is the kind of network failure behind a transport error
+type transportErrorClass string
+
+const (
+	transportErrorNone    transportErrorClass = ""
+	transportErrorDNS     transportErrorClass = "dns"
+	transportErrorRefused transportErrorClass = "connection refused"
+	transportErrorTimeout transportErrorClass = "timeout"
+	transportErrorTLS     transportErrorClass = "tls"
+	transportErrorOther   transportErrorClass = "network"
+)
+
+// classifyTransportError returns the kind of network failure behind err, or
+// transportErrorNone if err is not a transport error, e.g. an error status
+func classifyTransportError(err error) transportErrorClass {
+	if err == nil {
+		return transportErrorNone
+	}
+
+	var dnsErr *net.DNSError
+	if errors.As(err, &dnsErr) {
+		return transportErrorDNS
+	}
+	if errors.Is(err, syscall.ECONNREFUSED) {
+		return transportErrorRefused
+	}
+
+	var (
+		verifyErr    *tls.CertificateVerificationError
+		authorityErr x509.UnknownAuthorityError
+		hostnameErr  x509.HostnameError
+		invalidErr   x509.CertificateInvalidError
+		headerErr    tls.RecordHeaderError
+		alertErr     tls.AlertError
+	)
+	if errors.As(err, &verifyErr) || errors.As(err, &authorityErr) || errors.As(err, &hostnameErr) ||
+		errors.As(err, &invalidErr) || errors.As(err, &headerErr) || errors.As(err, &alertErr) {
+		return transportErrorTLS
+	}
+
+	var netErr net.Error
+	if errors.Is(err, context.DeadlineExceeded) || (errors.As(err, &netErr) && netErr.Timeout()) {
+		return transportErrorTimeout
+	}
+
+	var urlErr *url.Error
+	var opErr *net.OpError
+	if errors.As(err, &urlErr) || errors.As(err, &opErr) {
+		return transportErrorOther
+	}
+	return transportErrorNone
+}
+
+// failureStreak tracks consecutive identical transport errors of the event
+// sender, so an unreachable collector yields one actionable error instead of
+// an endless stream of identical warnings
+type failureStreak struct {
+	mu      sync.Mutex
+	message string // error of the current streak
+	count   int
+}
+
+// warnSendFailure logs a failed event delivery. Once the same transport error
+// repeated failureStreakHintAfter times it logs an error with a diagnostic
+// hint, then suppresses repeats until the error changes or a send succeeds.
+// The hint resolves the endpoint's host, so it is logged in the background.
+func (ep *EventProcessor) warnSendFailure(err error) {
+	// The auth monitor already reported the rejected credentials, and
+	// shutdown the abandoned sends
+	if errors.Is(err, errAuthRejected) || ep.sendCtx.Err() != nil {
+		ep.log.Debug("Failed to send event: %v", err)
+		return
+	}
+
+	class := classifyTransportError(err)
+	if class == transportErrorNone {
+		ep.failures.reset()
+		ep.log.Warning("Failed to send event: %v", err)
+		return
+	}
+
+	ep.failures.mu.Lock()
+	if ep.failures.message != err.Error() {
+		ep.failures.message = err.Error()
+		ep.failures.count = 0
+	}
+	ep.failures.count++
+	count := ep.failures.count
+	ep.failures.mu.Unlock()
+
+	switch {
+	case count < failureStreakHintAfter:
+		ep.log.Warning("Failed to send event: %v", err)
+	case count == failureStreakHintAfter:
+		go guard(ep.log, "failure hint", func() {
+			ep.log.Error("Failed to send %d events in a row (%s error): %v; %s. Repeats of this error are suppressed until it changes or an event is delivered.",
+				count, class, err, ep.failureHint(class))
+		})
+	default:
+		ep.log.Debug("Failed to send event: %v", err)
+	}
+}
+
+// sendSucceeded ends the current failure streak
+func (ep *EventProcessor) sendSucceeded() {
+	if count := ep.failures.reset(); count >= failureStreakHintAfter {
+		ep.log.Info("Event delivery recovered after %d consecutive failures", count)
+	}
+}
+
+// reset ends the streak, returning its length
+func (s *failureStreak) reset() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	count := s.count
+	s.message = ""
+	s.count = 0
+	return count
+}
+
+// failureHint describes the endpoint and network setup for a failing class of
+// transport error
+func (ep *EventProcessor) failureHint(class transportErrorClass) string {
+	hints := []string{"endpoint is " + ep.endpoint}
+
+	if parsed, err := url.Parse(ep.endpoint); err == nil && parsed.Hostname() != "" {
+		ctx, cancel := context.WithTimeout(ep.sendCtx, 2*time.Second)
+		defer cancel()
+		if addrs, err := net.DefaultResolver.LookupHost(ctx, parsed.Hostname()); err != nil {
+			if ep.sendCtx.Err() == nil {
+				hints = append(hints, parsed.Hostname()+" does not resolve")
+			}
+		} else {
+			hints = append(hints, parsed.Hostname()+" resolves to "+strings.Join(addrs, ", "))
+		}
+	}
+
+	var proxies []string
+	for _, name := range []string{"HTTPS_PROXY", "https_proxy", "HTTP_PROXY", "http_proxy", "NO_PROXY", "no_proxy"} {
+		if os.Getenv(name) != "" {
+			proxies = append(proxies, name)
+		}
+	}
+	if len(proxies) > 0 {
+		hints = append(hints, "proxy settings in effect: "+strings.Join(proxies, ", "))
+	} else {
+		hints = append(hints, "no proxy environment variables are set")
+	}
+
+	switch class {
+	case transportErrorDNS:
+		hints = append(hints, "check Config.Endpoint for typos and that DNS works from this host")
+	case transportErrorRefused:
+		hints = append(hints, "check that the collector is running and listening on that address")
+	case transportErrorTimeout:
+		hints = append(hints, "check firewalls and proxies between this host and the collector, or raise Config.RequestTimeout")
+	case transportErrorTLS:
+		hints = append(hints, "check the endpoint's certificate and scheme, and any TLS-intercepting proxy")
+	}
+	return strings.Join(hints, "; ")
+}
@@ -0,0 +1,83 @@
+package agnost
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestClassifyTransportError(t *testing.T) {
+	refused := httptest.NewServer(http.NotFoundHandler())
+	refusedURL := refused.URL
+	refused.Close()
+	tlsServer := httptest.NewUnstartedServer(http.NotFoundHandler())
+	tlsServer.Config.ErrorLog = log.New(io.Discard, "", 0)
+	tlsServer.StartTLS()
+	defer tlsServer.Close()
+
+	get := func(url string) error {
+		resp, err := http.Get(url)
+		if err == nil {
+			resp.Body.Close()
+		}
+		return err
+	}
+	tests := []struct {
+		name string
+		err  error
+		want transportErrorClass
+	}{
+		{"nil", nil, transportErrorNone},
+		{"status", errors.New("API returned status 500"), transportErrorNone},
+		{"dns", &net.DNSError{Err: "no such host", Name: "collector.invalid", IsNotFound: true}, transportErrorDNS},
+		{"refused", get(refusedURL), transportErrorRefused},
+		{"timeout", fmt.Errorf("send: %w", context.DeadlineExceeded), transportErrorTimeout},
+		{"tls", get(tlsServer.URL), transportErrorTLS},
+		{"other", &net.OpError{Op: "read", Net: "tcp", Err: errors.New("connection reset by peer")}, transportErrorOther},
+	}
+	for _, tt := range tests {
+		if got := classifyTransportError(tt.err); got != tt.want {
+			t.Errorf("%s: classified %v as %q, want %q", tt.name, tt.err, got, tt.want)
+		}
+	}
+}
+
+func TestRepeatedSendFailuresLogOneHint(t *testing.T) {
+	var buf syncBuffer
+	ep := newTestEventProcessor(t, "http://collector.invalid", systemClock)
+	ep.log = newLevelLogger(NewLogger(&buf), "warning")
+	err := &net.OpError{Op: "dial", Net: "tcp", Err: errors.New("connection refused")}
+
+	for range 2 * failureStreakHintAfter {
+		ep.warnSendFailure(err)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for !strings.Contains(buf.String(), "in a row") && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	logged := buf.String()
+	if got := strings.Count(logged, "in a row"); got != 1 {
+		t.Fatalf("logged %d hints, want 1:\n%s", got, logged)
+	}
+	if !strings.Contains(logged, "endpoint is http://collector.invalid") {
+		t.Errorf("hint doesn't name the endpoint:\n%s", logged)
+	}
+	if got := strings.Count(logged, "Failed to send event"); got != failureStreakHintAfter-1 {
+		t.Errorf("logged %d warnings before the hint, want %d", got, failureStreakHintAfter-1)
+	}
+
+	ep.sendSucceeded()
+	ep.warnSendFailure(err)
+	if got := strings.Count(buf.String(), "Failed to send event"); got != failureStreakHintAfter {
+		t.Errorf("a success didn't end the suppression")
+	}
+}
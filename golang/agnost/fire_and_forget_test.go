@@ -0,0 +1,72 @@
+package agnost
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestFireAndForgetOverridesRetriesAndTimeout(t *testing.T) {
+	tests := []struct {
+		name        string
+		config      AgnostConfig
+		wantRetries int
+		wantTimeout time.Duration
+	}{
+		{"defaults", AgnostConfig{MaxRetries: 3, RequestTimeout: 5 * time.Second}, 3, 5 * time.Second},
+		{"negative retries", AgnostConfig{MaxRetries: -1}, 0, 0},
+		{"fire and forget", AgnostConfig{FireAndForget: true, MaxRetries: 3, RequestTimeout: 5 * time.Second}, 0, fireAndForgetTimeout},
+		{"fire and forget without timeout", AgnostConfig{FireAndForget: true}, 0, fireAndForgetTimeout},
+		{"fire and forget with a shorter timeout", AgnostConfig{FireAndForget: true, RequestTimeout: 100 * time.Millisecond}, 0, 100 * time.Millisecond},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.config.maxRetries(); got != tt.wantRetries {
+				t.Errorf("maxRetries() = %d, want %d", got, tt.wantRetries)
+			}
+			if got := tt.config.requestTimeout(); got != tt.wantTimeout {
+				t.Errorf("requestTimeout() = %v, want %v", got, tt.wantTimeout)
+			}
+		})
+	}
+}
+
+func TestSendEventWithoutRetriesMakesOneAttempt(t *testing.T) {
+	for _, configure := range []func(*AgnostConfig){
+		func(config *AgnostConfig) { config.MaxRetries = 0 },
+		func(config *AgnostConfig) { config.FireAndForget = true },
+	} {
+		collector := newFlakyCollector(t, func(attempt int64, w http.ResponseWriter) bool {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return true
+		})
+		ep := newTestEventProcessor(t, collector.URL, frozenClock())
+		ep.config.RetryDelay = time.Hour
+		configure(ep.config)
+
+		start := time.Now()
+		if err := ep.sendEvent(context.Background(), &EventData{SessionID: "s", PrimitiveType: "tool", PrimitiveName: "echo"}); err == nil {
+			t.Fatal("rejected event was reported as sent")
+		}
+		if got := collector.attempts.Load(); got != 1 {
+			t.Errorf("made %d attempts, want 1", got)
+		}
+		if elapsed := time.Since(start); elapsed > time.Second {
+			t.Errorf("waited %v for a send without retries", elapsed)
+		}
+	}
+}
+
+func TestFireAndForgetDoesNotSpool(t *testing.T) {
+	config := DefaultConfig()
+	config.Logger = NewLogger(io.Discard)
+	config.SpoolDir = t.TempDir()
+	config.FireAndForget = true
+	ep := NewEventProcessor("http://127.0.0.1:1", "org", config)
+	defer ep.Shutdown()
+	if ep.spool != nil {
+		t.Error("fire-and-forget processor spools events")
+	}
+}
@@ -0,0 +1,35 @@
+package agnost
+
+import (
+	"runtime/debug"
+	"sync/atomic"
+)
+
+// internalErrors counts panics recovered from the SDK's own code and the
+// hooks it calls, across every client
+var internalErrors atomic.Int64
+
+// InternalErrors returns the number of panics the SDK recovered from in every
+// client; GetStats().InternalErrors counts a single client's
+func InternalErrors() int64 {
+	return internalErrors.Load()
+}
+
+// guard runs SDK work that must never take down its caller, such as the
+// analytics recorded after a tool handler returned. A panic in fn, including
+// in user hooks it calls, is logged to log and counted instead of propagated.
+func guard(log *levelLogger, where string, fn func()) {
+	defer func() {
+		if r := recover(); r != nil {
+			internalErrors.Add(1)
+			if log == nil {
+				log = packageLogger.Load()
+			}
+			if log.recovered != nil {
+				log.recovered.Add(1)
+			}
+			log.Error("Recovered from panic in %s: %v\n%s", where, r, debug.Stack())
+		}
+	}()
+	fn()
+}
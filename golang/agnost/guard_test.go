@@ -0,0 +1,92 @@
+package agnost
+
+import (
+	"context"
+	"io"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+func TestGuardRecoversAndCountsPanics(t *testing.T) {
+	var buf syncBuffer
+	var recovered atomic.Int64
+	log := newLevelLogger(NewLogger(&buf), "debug")
+	log.recovered = &recovered
+	before := InternalErrors()
+
+	ran := false
+	guard(log, "test work", func() { panic("boom") })
+	guard(log, "test work", func() { ran = true })
+
+	if !ran {
+		t.Error("guard didn't run fn")
+	}
+	if got := recovered.Load(); got != 1 {
+		t.Errorf("client counted %d panics, want 1", got)
+	}
+	if got := InternalErrors() - before; got != 1 {
+		t.Errorf("package counted %d panics, want 1", got)
+	}
+	if out := buf.String(); !strings.Contains(out, "Recovered from panic in test work: boom") || !strings.Contains(out, "guard_test.go") {
+		t.Errorf("log lacks the panic and its stack:\n%s", out)
+	}
+}
+
+func TestGuardWithoutLoggerLogsToThePackageLogger(t *testing.T) {
+	global := capturePackageLogger(t)
+	guard(nil, "detached work", func() { panic("boom") })
+	if !strings.Contains(global.String(), "Recovered from panic in detached work") {
+		t.Errorf("package log lacks the panic:\n%s", global.String())
+	}
+}
+
+func TestPanicsInTrackingLeaveTheToolCallAlone(t *testing.T) {
+	var recovered atomic.Int64
+	log := newLevelLogger(NewLogger(io.Discard), "debug")
+	log.recovered = &recovered
+
+	s := server.NewMCPServer("test", "1.0.0", server.WithToolHandlerMiddleware(ToolMiddleware()))
+	pin := func(ctx context.Context) (func() string, func()) { panic("pin failed") }
+	callback := func(call *ToolCall) error { panic("callback failed") }
+	trackerFor(s).setSink(pin, callback, nil, nil, nil, log)
+	handled := false
+	s.AddTool(mcp.NewTool("echo"), func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		handled = true
+		return mcp.NewToolResultText("ok"), nil
+	})
+
+	if message := callToolError(t, s, "echo"); message != "" {
+		t.Fatalf("call failed with %q after panics in tracking", message)
+	}
+	if !handled {
+		t.Error("handler didn't run")
+	}
+	if got := recovered.Load(); got != 2 {
+		t.Errorf("counted %d recovered panics, want the pin's and the callback's", got)
+	}
+}
+
+func TestHandlerPanicsPropagateAndAreRecorded(t *testing.T) {
+	s, _, collector := newTrackedServer(t, nil)
+	s.AddTool(mcp.NewTool("crash"), func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		panic("handler failed")
+	})
+
+	func() {
+		defer func() {
+			if r := recover(); r != "handler failed" {
+				t.Errorf("recovered %v, want the handler's own panic", r)
+			}
+		}()
+		callToolError(t, s, "crash")
+	}()
+
+	events := collector.Events("tool")
+	if len(events) != 1 || events[0].Success {
+		t.Fatalf("got events %+v, want one failed call", events)
+	}
+}
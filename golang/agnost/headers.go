@@ -0,0 +1,64 @@
+package agnost
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// sdkHeaders are set by the SDK on every request to the collector and take
+// precedence over Config.Headers
+var sdkHeaders = []string{"Content-Type", "X-Org-Id"}
+
+// checkHeaders returns an error if a configured header name or value can't be
+// sent in a request, warning about ones the SDK overrides
+func checkHeaders(headers map[string]string, log *levelLogger) error {
+	for name, value := range headers {
+		if !validHeaderName(name) {
+			return fmt.Errorf("invalid header name %q in Headers", name)
+		}
+		if !validHeaderValue(value) {
+			return fmt.Errorf("invalid value for header %s in Headers", name)
+		}
+		for _, sdkHeader := range sdkHeaders {
+			if http.CanonicalHeaderKey(name) == sdkHeader {
+				log.Warning("Header %s in Headers is set by the SDK and ignored", name)
+			}
+		}
+	}
+	return nil
+}
+
+// validHeaderName reports whether name is an RFC 7230 token
+func validHeaderName(name string) bool {
+	if name == "" {
+		return false
+	}
+	for i := 0; i < len(name); i++ {
+		c := name[i]
+		if c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || strings.IndexByte("!#$%&'*+-.^_`|~", c) >= 0 {
+			continue
+		}
+		return false
+	}
+	return true
+}
+
+// validHeaderValue reports whether value holds no control characters other
+// than tabs, so it can't break the request apart
+func validHeaderValue(value string) bool {
+	for i := 0; i < len(value); i++ {
+		if c := value[i]; c < ' ' && c != '\t' || c == 0x7f {
+			return false
+		}
+	}
+	return true
+}
+
+// addHeaders sets the configured headers on a request to the collector,
+// before the SDK sets its own
+func addHeaders(req *http.Request, headers map[string]string) {
+	for name, value := range headers {
+		req.Header.Set(name, value)
+	}
+}
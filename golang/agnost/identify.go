@@ -0,0 +1,74 @@
+package agnost
+
+import (
+	"context"
+	"maps"
+)
+
+// Identify sets the identity of the global analytics client's session
+func Identify(identity UserIdentity) {
+	globalClient.Identify(identity)
+}
+
+// IdentifyContext sets the identity of the global client's session of the call with ctx
+func IdentifyContext(ctx context.Context, identity UserIdentity) {
+	globalClient.IdentifyContext(ctx, identity)
+}
+
+// Identify sets the identity of the session started by Track
+func (a *AgnostAnalytics) Identify(identity UserIdentity) {
+	a.IdentifyContext(context.Background(), identity)
+}
+
+// IdentifyContext is Identify for the session of the call with ctx
+func (a *AgnostAnalytics) IdentifyContext(ctx context.Context, identity UserIdentity) {
+	userID := identityUserID(identity)
+	if userID == "" {
+		a.logger().Warning("Identity ignored: it has no user_id")
+		return
+	}
+	identity = maps.Clone(identity)
+	if a.queueIdentity(identity) {
+		a.logger().Debug("Identity of user %s queued until the session is created", userID)
+		return
+	}
+
+	// Inside a tracked call, identify the session it pinned
+	a.mu.RLock()
+	if !a.initialized {
+		a.mu.RUnlock()
+		return
+	}
+	sessionManager := a.sessionManager
+	var pin *sessionPin
+	if state := callStateFromContext(ctx); state != nil {
+		pin = state.pin
+	}
+	var sessionInfo *SessionInfo
+	if pin == nil {
+		sessionInfo = a.callSessionInfo(ctx)
+	}
+	a.mu.RUnlock()
+
+	// Otherwise pin the session of ctx just until it is resolved
+	if pin == nil {
+		pin = sessionManager.pinSession(sessionInfo)
+		defer pin.release()
+	}
+	pin.then(func(sessionID string) {
+		if sessionID != "" && !sessionManager.SetIdentity(sessionID, identity) {
+			a.logger().Warning("Identity of user %s ignored: session %s is no longer cached", userID, sessionID)
+		}
+	})
+}
+
+// queueIdentity holds identity for the first session before Initialize
+func (a *AgnostAnalytics) queueIdentity(identity UserIdentity) bool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.initialized {
+		return false
+	}
+	a.queuedIdentity = identity
+	return true
+}
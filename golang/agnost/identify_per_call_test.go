@@ -0,0 +1,113 @@
+package agnost
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync/atomic"
+	"testing"
+)
+
+type tenantKey struct{}
+
+// headerIdentity identifies calls by their X-User header or, without an HTTP
+// request, by the tenant in their context, counting its evaluations
+func headerIdentity(calls *atomic.Int64) IdentifyErrFunc {
+	return func(req *http.Request, env map[string]string) (UserIdentity, error) {
+		calls.Add(1)
+		if req == nil {
+			return nil, nil
+		}
+		if user := req.Header.Get("X-User"); user != "" {
+			return UserIdentity{"user_id": user}, nil
+		}
+		if tenant, ok := req.Context().Value(tenantKey{}).(string); ok {
+			return UserIdentity{"user_id": tenant}, nil
+		}
+		return nil, errors.New("anonymous call")
+	}
+}
+
+// userRequest returns a context carrying an HTTP request from user
+func userRequest(user string) context.Context {
+	r, _ := http.NewRequest(http.MethodPost, "http://localhost/mcp", nil)
+	r.Header.Set("X-User", user)
+	return WithHTTPRequest(context.Background(), r)
+}
+
+func TestIdentifyPerCallIdentifiesEachCall(t *testing.T) {
+	var evaluations atomic.Int64
+	s, _, collector := newTrackedServer(t, func(config *AgnostConfig) {
+		config.IdentifyE = headerIdentity(&evaluations)
+		config.IdentifyPerCall = true
+	})
+	addEchoTool(s)
+	echo := map[string]any{"name": "echo", "arguments": map[string]any{}}
+
+	for _, user := range []string{"alice", "alice", "bob"} {
+		handleRequestContext(t, s, userRequest(user), "tools/call", echo)
+	}
+	handleRequestContext(t, s, context.WithValue(context.Background(), tenantKey{}, "acme"), "tools/call", echo)
+
+	events := collector.Events("tool")
+	if len(events) != 4 {
+		t.Fatalf("got %d tool events, want 4", len(events))
+	}
+	for i, want := range []string{"alice", "alice", "bob", "acme"} {
+		if got := events[i].UserData["user_id"]; got != want {
+			t.Errorf("event %d has user %v, want %s", i, got, want)
+		}
+	}
+	// Once for the session, then once per call
+	if got := evaluations.Load(); got != 5 {
+		t.Errorf("evaluated the identify function %d times, want 5", got)
+	}
+
+	// The session's user is reported each time it changes
+	if !waitUntil(func() bool { return len(collector.Updates()) == 3 }) {
+		t.Fatalf("got %d session updates, want 3", len(collector.Updates()))
+	}
+	identified := make(map[any]bool)
+	for _, update := range collector.Updates() {
+		if update.Kind == SessionUpdateIdentified {
+			identified[update.UserData["user_id"]] = true
+		}
+	}
+	if len(identified) != 3 || !identified["alice"] || !identified["bob"] || !identified["acme"] {
+		t.Errorf("got session updates %+v, want alice, bob and acme identified", collector.Updates())
+	}
+}
+
+func TestIdentifyPerCallFailuresLeaveTheCallAnonymous(t *testing.T) {
+	var evaluations atomic.Int64
+	s, a, collector := newTrackedServer(t, func(config *AgnostConfig) {
+		config.IdentifyE = headerIdentity(&evaluations)
+		config.IdentifyPerCall = true
+	})
+	addEchoTool(s)
+	callTool(t, s, "echo")
+
+	events := collector.Events("tool")
+	if len(events) != 1 || !events[0].Success || events[0].UserData != nil {
+		t.Errorf("got events %+v, want one successful anonymous call", events)
+	}
+	if got := a.Stats().IdentityFailures; got != 1 {
+		t.Errorf("counted %d identity failures, want 1", got)
+	}
+}
+
+func TestIdentifyRunsOncePerSessionByDefault(t *testing.T) {
+	var evaluations atomic.Int64
+	s, _, collector := newTrackedServer(t, func(config *AgnostConfig) {
+		config.IdentifyE = headerIdentity(&evaluations)
+	})
+	addEchoTool(s)
+	handleRequestContext(t, s, userRequest("alice"), "tools/call", map[string]any{"name": "echo", "arguments": map[string]any{}})
+
+	if got := evaluations.Load(); got != 1 {
+		t.Errorf("evaluated the identify function %d times, want 1", got)
+	}
+	if events := collector.Events("tool"); len(events) != 1 || events[0].UserData != nil {
+		t.Errorf("got events %+v, want one without a per-call user", events)
+	}
+}
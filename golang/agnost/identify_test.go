@@ -0,0 +1,71 @@
+package agnost
+
+import (
+	"context"
+	"testing"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+func TestIdentifyBeforeTrackIdentifiesTheFirstSession(t *testing.T) {
+	s := server.NewMCPServer("test", "1.0.0", server.WithToolHandlerMiddleware(ToolMiddleware()))
+	a := NewAgnostAnalytics()
+	a.Identify(UserIdentity{"user_id": "early", "plan": "pro"})
+	collector := trackServer(t, s, a, nil)
+
+	if user := collector.Sessions()[0].UserData; user["user_id"] != "early" || user["plan"] != "pro" {
+		t.Errorf("first session registered with user %v, want the queued identity", user)
+	}
+	if got := len(collector.Updates()); got != 0 {
+		t.Errorf("sent %d session updates for the queued identity", got)
+	}
+}
+
+func TestIdentifyUpdatesTheSessionAndItsLaterEvents(t *testing.T) {
+	s, a, collector := newTrackedServer(t, nil)
+	addEchoTool(s)
+	callTool(t, s, "echo")
+	a.Identify(UserIdentity{"user_id": "alice"})
+	if !waitUntil(func() bool { return len(collector.Updates()) == 1 }) {
+		t.Fatalf("got %d session updates, want 1", len(collector.Updates()))
+	}
+	callTool(t, s, "echo")
+
+	if update := collector.Updates()[0]; update.Kind != SessionUpdateIdentified || update.UserData["user_id"] != "alice" {
+		t.Errorf("got session update %+v, want alice identified", update)
+	}
+	events := collector.Events("tool")
+	if len(events) != 2 || events[0].UserData != nil || events[1].UserData["user_id"] != "alice" {
+		t.Errorf("got events %+v, want only the later one of alice", events)
+	}
+}
+
+func TestIdentifyContextFromAToolHandler(t *testing.T) {
+	s, a, collector := newTrackedServer(t, nil)
+	s.AddTool(mcp.NewTool("login"), func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		a.IdentifyContext(ctx, UserIdentity{"user_id": "bob"})
+		return mcp.NewToolResultText("ok"), nil
+	})
+	addEchoTool(s)
+	callTool(t, s, "login")
+	callTool(t, s, "echo")
+
+	if !waitUntil(func() bool { return len(collector.Updates()) == 1 }) {
+		t.Fatalf("got %d session updates, want 1", len(collector.Updates()))
+	}
+	events := collector.Events("tool")
+	if len(events) != 2 || events[1].UserData["user_id"] != "bob" {
+		t.Errorf("got events %+v, want the call after the login to be bob's", events)
+	}
+}
+
+func TestIdentifyIgnoresIdentitiesWithoutAUserID(t *testing.T) {
+	_, a, collector := newTrackedServer(t, nil)
+	a.Identify(UserIdentity{"email": "alice@example.com"})
+	a.Identify(nil)
+	a.Shutdown()
+	if got := len(collector.Updates()); got != 0 {
+		t.Errorf("sent %d session updates for identities without a user_id", got)
+	}
+}
@@ -0,0 +1,195 @@
+package agnost
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"log/slog"
+	"maps"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+// deriveIdentity builds the anonymous identity of a client of this
+// installation. The user_id is stable for as long as the installation ID is,
+// and the "derived" flag tells it apart from identities returned by Identify.
+func deriveIdentity(installationID string, clientName string) UserIdentity {
+	hash := sha256.Sum256([]byte(installationID + "\x00" + clientName))
+	return UserIdentity{
+		"user_id": hex.EncodeToString(hash[:16]),
+		"derived": true,
+	}
+}
+
+// installationID returns the ID of this installation, read from the state
+// file or persisted there on first use. If the state file can't be used, the
+// ID only lasts as long as the process.
+func (sm *SessionManager) installationID() string {
+	sm.installationOnce.Do(func() {
+		state := sm.state
+		if state == nil {
+			var err error
+			if state, err = newStateStore(sm.config.StateDir, sm.orgID, sm.log); err != nil {
+				sm.log.Warning("Derived identities won't survive restarts: %v", err)
+				sm.installation = generateUUID()
+				return
+			}
+		}
+		id, err := state.installationID()
+		if err != nil {
+			sm.log.Warning("Derived identities won't survive restarts: %v", err)
+			id = generateUUID()
+		}
+		sm.installation = id
+	})
+	return sm.installation
+}
+
+// environ returns the process environment as identify functions receive it
+func environ() map[string]string {
+	env := make(map[string]string)
+	for _, e := range os.Environ() {
+		pair := strings.SplitN(e, "=", 2)
+		if len(pair) == 2 {
+			env[pair[0]] = pair[1]
+		}
+	}
+	return env
+}
+
+type httpRequestKey struct{}
+
+// WithHTTPRequest returns a context carrying r, for server.WithHTTPContextFunc
+func WithHTTPRequest(ctx context.Context, r *http.Request) context.Context {
+	return context.WithValue(ctx, httpRequestKey{}, r)
+}
+
+// callRequest returns the request identify functions receive for a tool call
+func callRequest(ctx context.Context) *http.Request {
+	if r, ok := ctx.Value(httpRequestKey{}).(*http.Request); ok && r != nil {
+		return r.WithContext(ctx)
+	}
+	return (&http.Request{Method: http.MethodPost, URL: &url.URL{}, Header: http.Header{}}).WithContext(ctx)
+}
+
+// identifyCall returns the identity of a tool call under Config.IdentifyPerCall
+func (a *AgnostAnalytics) identifyCall(ctx context.Context, sessionID string) UserIdentity {
+	a.mu.RLock()
+	config := a.config
+	var identify IdentifyErrFunc
+	if config != nil && config.IdentifyPerCall {
+		identify = config.identifyFunc()
+	}
+	sessionManager := a.sessionManager
+	a.mu.RUnlock()
+	if identify == nil || sessionManager == nil || ctx == nil {
+		return nil
+	}
+	cache := sessionManager.identities
+
+	var user UserIdentity
+	var key string
+	var err error
+	ok := false
+	guard(a.logger(), "identify", func() {
+		req := callRequest(ctx)
+		if cache != nil {
+			if key = config.identityCacheKey(req); key != "" {
+				if cached, hit := cache.get(key, time.Now()); hit {
+					user, ok = cached, true
+					key = ""
+					return
+				}
+			}
+		}
+		user, err = identify(req, environ())
+		ok = true
+	})
+	switch {
+	case !ok:
+		sessionManager.identityFailures.Add(1)
+		return nil
+	case err != nil:
+		sessionManager.identityFailures.Add(1)
+		a.logger().Warning("Failed to identify user of tool call: %v", err)
+		return nil
+	}
+	if key != "" {
+		cache.put(key, sessionID, user, time.Now())
+	}
+	return user
+}
+
+// RecordIdentity updates the user of a session to the identity of one of its calls
+func (sm *SessionManager) RecordIdentity(sessionID string, user UserIdentity) {
+	sm.updateIdentity(sessionID, user, false)
+}
+
+// SetIdentity sets the identity of a session, returning false if it isn't cached
+func (sm *SessionManager) SetIdentity(sessionID string, user UserIdentity) bool {
+	return sm.updateIdentity(sessionID, user, true)
+}
+
+// identity returns the identity set with SetIdentity for a session, or nil
+func (sm *SessionManager) identity(sessionID string) UserIdentity {
+	sm.mu.RLock()
+	defer sm.mu.RUnlock()
+	if entry, ok := sm.byID[sessionID]; ok {
+		return entry.identity
+	}
+	return nil
+}
+
+// updateIdentity records user as the identity of a session if it is cached
+func (sm *SessionManager) updateIdentity(sessionID string, user UserIdentity, explicit bool) bool {
+	userID := identityUserID(user)
+	if userID == "" {
+		return false
+	}
+	user = maps.Clone(user)
+
+	sm.mu.Lock()
+	entry, ok := sm.byID[sessionID]
+	changed := ok && (explicit || entry.userID != userID)
+	var previous string
+	if changed {
+		previous = entry.userID
+		entry.userID = userID
+		if explicit {
+			entry.identity = user
+		}
+		if entry.data != nil {
+			data := *entry.data
+			data.UserData = user
+			entry.data = &data
+		}
+	}
+	sm.mu.Unlock()
+	if !changed {
+		return ok
+	}
+
+	sm.log.with(slog.String("session_id", sessionID), slog.String("user_id", userID)).
+		Debug("Session %s identified as user %s", sessionID, userID)
+	details := map[string]string{"user_id": userID}
+	if previous != "" {
+		details["previous_user_id"] = previous
+	}
+	sm.emitLifecycle(SessionLifecycleIdentityChanged, sessionID, details)
+	// Report it in the background, since calls identify their session
+	update := &SessionUpdateData{
+		SessionID: sessionID,
+		Kind:      SessionUpdateIdentified,
+		UpdatedAt: time.Now().UnixMilli(),
+		UserData:  user,
+	}
+	sm.updates.Add(1)
+	go func() {
+		defer sm.updates.Done()
+		guard(sm.log, "session update", func() { sm.sendSessionUpdate(update) })
+	}()
+	return true
+}
@@ -0,0 +1,142 @@
+package agnost
+
+import (
+	"container/list"
+	"context"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// defaultIdentifyCacheSize is used when Config.IdentifyCacheSize is not set
+const defaultIdentifyCacheSize = 1000
+
+// identityCacheEntry is an identity cached under a caller-supplied key
+type identityCacheEntry struct {
+	key       string
+	sessionID string // the session of the call that evaluated it, if pinned
+	user      UserIdentity
+	expiresAt time.Time
+}
+
+// identityCache is an LRU of per-call identities kept for Config.IdentifyCacheTTL
+type identityCache struct {
+	ttl  time.Duration
+	size int
+
+	mu      sync.Mutex
+	entries map[string]*list.Element // of *identityCacheEntry
+	lru     *list.List               // most recently used key first
+
+	hits   atomic.Int64
+	misses atomic.Int64
+}
+
+// newIdentityCache returns a cache keeping identities for ttl, nil if ttl is 0
+func newIdentityCache(ttl time.Duration, size int) *identityCache {
+	if ttl <= 0 {
+		return nil
+	}
+	if size <= 0 {
+		size = defaultIdentifyCacheSize
+	}
+	return &identityCache{
+		ttl:     ttl,
+		size:    size,
+		entries: make(map[string]*list.Element),
+		lru:     list.New(),
+	}
+}
+
+// get returns the identity cached under key, if it is still fresh at now
+func (c *identityCache) get(key string, now time.Time) (UserIdentity, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	element, ok := c.entries[key]
+	if ok {
+		entry := element.Value.(*identityCacheEntry)
+		if now.Before(entry.expiresAt) {
+			c.lru.MoveToFront(element)
+			c.hits.Add(1)
+			return entry.user, true
+		}
+		c.removeLocked(element)
+	}
+	c.misses.Add(1)
+	return nil, false
+}
+
+// put caches user under key, evicting the least recently used key at capacity
+func (c *identityCache) put(key string, sessionID string, user UserIdentity, now time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry := &identityCacheEntry{
+		key:       key,
+		sessionID: sessionID,
+		user:      user,
+		expiresAt: now.Add(c.ttl),
+	}
+	if element, ok := c.entries[key]; ok {
+		element.Value = entry
+		c.lru.MoveToFront(element)
+		return
+	}
+	c.entries[key] = c.lru.PushFront(entry)
+	if c.lru.Len() > c.size {
+		c.removeLocked(c.lru.Back())
+	}
+}
+
+// invalidateSession drops the identities evaluated in the session
+func (c *identityCache) invalidateSession(sessionID string) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for element := c.lru.Front(); element != nil; {
+		next := element.Next()
+		if element.Value.(*identityCacheEntry).sessionID == sessionID {
+			c.removeLocked(element)
+		}
+		element = next
+	}
+}
+
+// clear drops every cached identity
+func (c *identityCache) clear() {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries = make(map[string]*list.Element)
+	c.lru.Init()
+}
+
+// removeLocked removes element; the caller holds c.mu
+func (c *identityCache) removeLocked(element *list.Element) {
+	c.lru.Remove(element)
+	delete(c.entries, element.Value.(*identityCacheEntry).key)
+}
+
+// identityCacheKey returns the key the identity of a call with req is cached under
+func (c *AgnostConfig) identityCacheKey(req *http.Request) string {
+	if c.IdentifyCacheKey != nil {
+		return c.IdentifyCacheKey(req)
+	}
+	return clientSessionID(req.Context())
+}
+
+// clientSessionID returns the ID of the mcp-go client session in ctx, or ""
+func clientSessionID(ctx context.Context) string {
+	if session := server.ClientSessionFromContext(ctx); session != nil {
+		return session.SessionID()
+	}
+	return ""
+}
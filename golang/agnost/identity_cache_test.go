@@ -0,0 +1,120 @@
+package agnost
+
+import (
+	"context"
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestIdentityCacheExpiresEntriesAfterTheTTL(t *testing.T) {
+	if newIdentityCache(0, 10) != nil {
+		t.Error("cache without a TTL isn't nil")
+	}
+	cache := newIdentityCache(time.Minute, 0)
+	if cache.size != defaultIdentifyCacheSize {
+		t.Errorf("cache without a size holds %d keys, want %d", cache.size, defaultIdentifyCacheSize)
+	}
+
+	now := time.Now()
+	cache.put("token", "s1", UserIdentity{"user_id": "alice"}, now)
+	if user, hit := cache.get("token", now.Add(59*time.Second)); !hit || user["user_id"] != "alice" {
+		t.Errorf("fresh entry got %v, %v", user, hit)
+	}
+	if _, hit := cache.get("token", now.Add(time.Minute)); hit {
+		t.Error("entry was returned once its TTL elapsed")
+	}
+	if _, hit := cache.get("token", now); hit {
+		t.Error("expired entry wasn't removed")
+	}
+	if hits, misses := cache.hits.Load(), cache.misses.Load(); hits != 1 || misses != 2 {
+		t.Errorf("counted %d hits and %d misses, want 1 and 2", hits, misses)
+	}
+}
+
+func TestIdentityCacheEvictsTheLeastRecentlyUsedKey(t *testing.T) {
+	cache := newIdentityCache(time.Minute, 2)
+	now := time.Now()
+	cache.put("a", "s1", UserIdentity{"user_id": "a"}, now)
+	cache.put("b", "s1", UserIdentity{"user_id": "b"}, now)
+	cache.get("a", now)
+	cache.put("c", "s1", UserIdentity{"user_id": "c"}, now)
+
+	if _, hit := cache.get("b", now); hit {
+		t.Error("least recently used key wasn't evicted")
+	}
+	for _, key := range []string{"a", "c"} {
+		if _, hit := cache.get(key, now); !hit {
+			t.Errorf("key %s was evicted", key)
+		}
+	}
+	// Replacing a key doesn't take another slot
+	cache.put("a", "s2", UserIdentity{"user_id": "a2"}, now)
+	if user, _ := cache.get("a", now); user["user_id"] != "a2" || cache.lru.Len() != 2 {
+		t.Errorf("replaced key got %v with %d keys cached", user, cache.lru.Len())
+	}
+}
+
+func TestIdentityCacheDropsTheEntriesOfEndedSessions(t *testing.T) {
+	collector := newSessionCollector(t, 0)
+	sm := newTestSessionManager(collector.URL, func(config *AgnostConfig) {
+		config.IdentifyCacheTTL = time.Minute
+	})
+	first, _ := sm.GetOrCreateSession(&SessionInfo{SessionKey: "client:1", ClientName: "test"})
+	second, _ := sm.GetOrCreateSession(&SessionInfo{SessionKey: "client:2", ClientName: "test"})
+	now := time.Now()
+	sm.identities.put("alice", first, UserIdentity{"user_id": "alice"}, now)
+	sm.identities.put("bob", second, UserIdentity{"user_id": "bob"}, now)
+
+	sm.Evict("client:1")
+	if _, hit := sm.identities.get("alice", now); hit {
+		t.Error("identity of an evicted session is still cached")
+	}
+	if _, hit := sm.identities.get("bob", now); !hit {
+		t.Error("identity of another session was dropped")
+	}
+	sm.identities.clear()
+	if _, hit := sm.identities.get("bob", now); hit {
+		t.Error("cleared cache still has identities")
+	}
+
+	var unset *identityCache
+	unset.invalidateSession(first)
+	unset.clear()
+}
+
+func TestCachedIdentitiesSkipTheIdentifyFunction(t *testing.T) {
+	var evaluations atomic.Int64
+	s, a, collector := newTrackedServer(t, func(config *AgnostConfig) {
+		config.IdentifyE = headerIdentity(&evaluations)
+		config.IdentifyPerCall = true
+		config.IdentifyCacheTTL = time.Minute
+		config.IdentifyCacheKey = func(req *http.Request) string {
+			return req.Header.Get("X-User")
+		}
+	})
+	addEchoTool(s)
+	echo := map[string]any{"name": "echo", "arguments": map[string]any{}}
+
+	for _, user := range []string{"alice", "alice", "alice", "bob"} {
+		handleRequestContext(t, s, userRequest(user), "tools/call", echo)
+	}
+	// Uncached calls are evaluated every time
+	handleRequestContext(t, s, context.Background(), "tools/call", echo)
+	handleRequestContext(t, s, context.Background(), "tools/call", echo)
+
+	// Once for the session, then for alice, bob and both uncached calls
+	if got := evaluations.Load(); got != 5 {
+		t.Errorf("evaluated the identify function %d times, want 5", got)
+	}
+	for i, event := range collector.Events("tool")[:3] {
+		if event.UserData["user_id"] != "alice" {
+			t.Errorf("event %d has user %v, want the cached alice", i, event.UserData)
+		}
+	}
+	stats := a.Stats()
+	if stats.IdentityCacheHits != 2 || stats.IdentityCacheMisses != 2 {
+		t.Errorf("counted %d hits and %d misses, want 2 and 2", stats.IdentityCacheHits, stats.IdentityCacheMisses)
+	}
+}
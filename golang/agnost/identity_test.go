@@ -0,0 +1,138 @@
+package agnost
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"path/filepath"
+	"testing"
+)
+
+func TestFailedIdentifyLeavesTheSessionAnonymous(t *testing.T) {
+	_, a, collector := newTrackedServer(t, func(config *AgnostConfig) {
+		config.IdentifyE = func(req *http.Request, env map[string]string) (UserIdentity, error) {
+			return nil, errors.New("no token")
+		}
+	})
+
+	sessions := collector.Sessions()
+	if len(sessions) != 1 {
+		t.Fatalf("registered %d sessions, want 1", len(sessions))
+	}
+	if sessions[0].UserData != nil {
+		t.Errorf("session was registered with user %v", sessions[0].UserData)
+	}
+	if got := a.Stats().IdentityFailures; got != 1 {
+		t.Errorf("counted %d identity failures, want 1", got)
+	}
+}
+
+func TestIdentifyETakesPrecedenceOverIdentify(t *testing.T) {
+	_, _, collector := newTrackedServer(t, func(config *AgnostConfig) {
+		config.Identify = func(req *http.Request, env map[string]string) UserIdentity {
+			return UserIdentity{"user_id": "from-identify"}
+		}
+		config.IdentifyE = func(req *http.Request, env map[string]string) (UserIdentity, error) {
+			return UserIdentity{"user_id": "from-identify-e"}, nil
+		}
+	})
+
+	sessions := collector.Sessions()
+	if len(sessions) != 1 || sessions[0].UserData["user_id"] != "from-identify-e" {
+		t.Errorf("registered sessions %+v, want one of user from-identify-e", sessions)
+	}
+}
+
+func TestSessionsAreUnidentifiedByDefault(t *testing.T) {
+	if DefaultConfig().DeriveAnonymousIdentity {
+		t.Error("anonymous identities are derived by default")
+	}
+	_, _, collector := newTrackedServer(t, func(config *AgnostConfig) {
+		config.StateDir = t.TempDir()
+	})
+	if user := collector.Sessions()[0].UserData; user != nil {
+		t.Errorf("session was registered with user %v", user)
+	}
+}
+
+func TestIdentifyTakesPrecedenceOverTheDerivedIdentity(t *testing.T) {
+	_, _, collector := newTrackedServer(t, func(config *AgnostConfig) {
+		config.StateDir = t.TempDir()
+		config.DeriveAnonymousIdentity = true
+		config.Identify = func(req *http.Request, env map[string]string) UserIdentity {
+			return UserIdentity{"user_id": "alice"}
+		}
+	})
+	if user := collector.Sessions()[0].UserData; user["user_id"] != "alice" || user["derived"] != nil {
+		t.Errorf("session was registered with user %v, want alice", user)
+	}
+
+	_, _, collector = newTrackedServer(t, func(config *AgnostConfig) {
+		config.StateDir = t.TempDir()
+		config.DeriveAnonymousIdentity = true
+	})
+	if user := collector.Sessions()[0].UserData; user["user_id"] == nil || user["derived"] != true {
+		t.Errorf("session was registered with user %v, want a derived one", user)
+	}
+}
+
+func TestDerivedIdentitiesAreStable(t *testing.T) {
+	first := deriveIdentity("installation", "claude")
+	if again := deriveIdentity("installation", "claude"); again["user_id"] != first["user_id"] {
+		t.Errorf("the same client got user IDs %v and %v", first["user_id"], again["user_id"])
+	}
+	if other := deriveIdentity("installation", "cursor"); other["user_id"] == first["user_id"] {
+		t.Error("different clients got the same user ID")
+	}
+	if other := deriveIdentity("other installation", "claude"); other["user_id"] == first["user_id"] {
+		t.Error("different installations got the same user ID")
+	}
+	if first["derived"] != true {
+		t.Errorf("derived identity %v isn't flagged derived", first)
+	}
+}
+
+func TestInstallationIDPersistsInTheStateDir(t *testing.T) {
+	dir := t.TempDir()
+	newManager := func(dir string) *SessionManager {
+		return newTestSessionManager("http://127.0.0.1:1", func(config *AgnostConfig) {
+			config.StateDir = dir
+		})
+	}
+
+	id := newManager(dir).installationID()
+	if id == "" {
+		t.Fatal("got an empty installation ID")
+	}
+	if again := newManager(dir).installationID(); again != id {
+		t.Errorf("installation ID changed from %q to %q across restarts", id, again)
+	}
+	if other := newManager(t.TempDir()).installationID(); other == id {
+		t.Error("another state directory has the same installation ID")
+	}
+}
+
+func TestStateFilesStayInTheirDirectory(t *testing.T) {
+	dir := t.TempDir()
+	log := newLevelLogger(NewLogger(io.Discard), "error")
+	for _, orgID := range []string{"org", "../../etc/passwd", "/tmp/org", `..\org`} {
+		state, err := newStateStore(dir, orgID, log)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if filepath.Dir(state.path) != dir {
+			t.Errorf("org %q has its state file at %s, outside %s", orgID, state.path, dir)
+		}
+		if spool := newEventSpool(dir, orgID, 0, log); filepath.Dir(spool.path) != dir {
+			t.Errorf("org %q has its spool at %s, outside %s", orgID, spool.path, dir)
+		}
+		if overflow := newEventOverflow(dir, orgID, 0, log); filepath.Dir(overflow.path) != dir {
+			t.Errorf("org %q has its overflow file at %s, outside %s", orgID, overflow.path, dir)
+		}
+	}
+	a, _ := newStateStore(dir, "org-a", log)
+	b, _ := newStateStore(dir, "org-b", log)
+	if a.path == b.path {
+		t.Error("different orgs share a state file")
+	}
+}
@@ -0,0 +1,58 @@
+package agnost
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// maxInventoryPages bounds the pages read from one listing
+const maxInventoryPages = 1000
+
+// inventoryKey marks the context of the listings the SDK requests to learn
+// the server's resources and prompts, so its hooks don't count them
+type inventoryKey struct{}
+
+// isInventory reports whether ctx is the context of an inventory listing
+func isInventory(ctx context.Context) bool {
+	marked, _ := ctx.Value(inventoryKey{}).(bool)
+	return marked
+}
+
+// listAll requests every page of a listing from s through its public message
+// handling, mcp-go having no accessor for registered resources and prompts,
+// and calls page with the result of each. It returns false if the server
+// refused the listing, such as for lack of the capability.
+func listAll[R any](s *server.MCPServer, method string, page func(result *R) (nextCursor mcp.Cursor)) bool {
+	ctx := context.WithValue(context.Background(), inventoryKey{}, true)
+	var cursor mcp.Cursor
+	for range maxInventoryPages {
+		params := map[string]any{}
+		if cursor != "" {
+			params["cursor"] = cursor
+		}
+		message, err := json.Marshal(map[string]any{"jsonrpc": mcp.JSONRPC_VERSION, "id": "agnost-inventory", "method": method, "params": params})
+		if err != nil {
+			return false
+		}
+		response, ok := s.HandleMessage(ctx, message).(mcp.JSONRPCResponse)
+		if !ok {
+			return false
+		}
+		var result *R
+		switch r := response.Result.(type) {
+		case R:
+			result = &r
+		case *R:
+			result = r
+		default:
+			return false
+		}
+		if cursor = page(result); cursor == "" {
+			return true
+		}
+	}
+	return true
+}
@@ -0,0 +1,154 @@
+package agnost
+
+import (
+	"sync"
+	"time"
+)
+
+// Session lifecycle transitions recorded in SessionLifecycleEvent.Kind
+const (
+	// SessionLifecycleCreated sessions were newly created and registered
+	SessionLifecycleCreated = "created"
+
+	// SessionLifecycleResumed sessions were persisted by a previous process and
+	// reused by this one
+	SessionLifecycleResumed = "resumed"
+
+	// SessionLifecycleReregistered sessions were re-sent because the collector
+	// reported them as unknown
+	SessionLifecycleReregistered = "reregistered"
+
+	// SessionLifecycleEvicted sessions were removed from the cache
+	SessionLifecycleEvicted = "evicted"
+
+	// SessionLifecycleExpired sessions outlived Config.SessionTTL and were
+	// replaced with a fresh session
+	SessionLifecycleExpired = "expired"
+
+	// SessionLifecycleEnded sessions were ended on shutdown, after expiring or
+	// when their client disconnected
+	SessionLifecycleEnded = "ended"
+
+	// SessionLifecycleIdentityChanged sessions were given a new user, by
+	// Identify or by the identity of one of their calls
+	SessionLifecycleIdentityChanged = "identity_changed"
+)
+
+const (
+	// lifecycleQueueSize is the number of lifecycle events buffered for
+	// Config.OnSessionLifecycle; events are dropped while it is full
+	lifecycleQueueSize = 256
+
+	// lifecycleDrainTimeout bounds how long shutdown waits for buffered
+	// lifecycle events to be delivered
+	lifecycleDrainTimeout = time.Second
+)
+
+// SessionLifecycleEvent describes a transition of a session
+type SessionLifecycleEvent struct {
+	// Kind is the transition, one of the SessionLifecycle constants
+	Kind string
+
+	SessionID string
+	Timestamp time.Time
+
+	// Details holds transition-specific values, such as the session key; it is
+	// a copy owned by the receiver
+	Details map[string]string
+}
+
+// lifecycleNotifier hands session lifecycle events to Config.OnSessionLifecycle
+// on a goroutine of its own, so a slow or panicking callback never holds up
+// the session manager
+type lifecycleNotifier struct {
+	callback func(event SessionLifecycleEvent)
+	events   chan SessionLifecycleEvent
+	done     chan struct{}
+	log      *levelLogger
+
+	mu     sync.Mutex
+	closed bool
+}
+
+// newLifecycleNotifier starts delivering events to callback
+func newLifecycleNotifier(callback func(event SessionLifecycleEvent), log *levelLogger) *lifecycleNotifier {
+	n := &lifecycleNotifier{
+		callback: callback,
+		events:   make(chan SessionLifecycleEvent, lifecycleQueueSize),
+		done:     make(chan struct{}),
+		log:      log,
+	}
+	go n.run()
+	return n
+}
+
+func (n *lifecycleNotifier) run() {
+	defer close(n.done)
+	for event := range n.events {
+		n.deliver(event)
+	}
+}
+
+// deliver invokes the callback, recovering from its panics
+func (n *lifecycleNotifier) deliver(event SessionLifecycleEvent) {
+	defer func() {
+		if r := recover(); r != nil {
+			n.log.Error("OnSessionLifecycle panicked on %s event of session %s: %v", event.Kind, event.SessionID, r)
+		}
+	}()
+	n.callback(event)
+}
+
+// notify queues an event without blocking, dropping it if the queue is full
+func (n *lifecycleNotifier) notify(event SessionLifecycleEvent) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if n.closed {
+		return
+	}
+	select {
+	case n.events <- event:
+	default:
+		n.log.Debug("Session lifecycle queue full, %s event of session %s dropped", event.Kind, event.SessionID)
+	}
+}
+
+// close stops accepting events and waits a bounded time for queued ones to be
+// delivered
+func (n *lifecycleNotifier) close() {
+	n.mu.Lock()
+	if n.closed {
+		n.mu.Unlock()
+		return
+	}
+	n.closed = true
+	close(n.events)
+	n.mu.Unlock()
+
+	select {
+	case <-n.done:
+	case <-time.After(lifecycleDrainTimeout):
+		n.log.Warning("Timed out delivering session lifecycle events")
+	}
+}
+
+// emitLifecycle reports a session transition to Config.OnSessionLifecycle.
+// Every transition of the manager goes through here.
+func (sm *SessionManager) emitLifecycle(kind string, sessionID string, details map[string]string) {
+	if sm.lifecycle == nil {
+		return
+	}
+	sm.lifecycle.notify(SessionLifecycleEvent{
+		Kind:      kind,
+		SessionID: sessionID,
+		Timestamp: time.Now(),
+		Details:   details,
+	})
+}
+
+// closeLifecycle delivers pending lifecycle events and stops the notifier
+func (sm *SessionManager) closeLifecycle() {
+	if sm.lifecycle != nil {
+		sm.lifecycle.close()
+	}
+}
@@ -0,0 +1,145 @@
+package agnost
+
+import (
+	"fmt"
+	"io"
+	"sync"
+	"testing"
+	"time"
+)
+
+// lifecycleRecorder records the lifecycle events delivered to it
+type lifecycleRecorder struct {
+	mu     sync.Mutex
+	events []SessionLifecycleEvent
+}
+
+func (r *lifecycleRecorder) record(event SessionLifecycleEvent) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.events = append(r.events, event)
+}
+
+// kinds returns the kinds of the events recorded so far, in order
+func (r *lifecycleRecorder) kinds() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var kinds []string
+	for _, event := range r.events {
+		kinds = append(kinds, event.Kind)
+	}
+	return kinds
+}
+
+func TestSessionTransitionsAreReportedInOrder(t *testing.T) {
+	collector := newSessionCollector(t, 0)
+	var recorder lifecycleRecorder
+	sm := newTestSessionManager(collector.URL, func(config *AgnostConfig) {
+		config.SessionTTL = 30 * time.Millisecond
+		config.OnSessionLifecycle = recorder.record
+	})
+	info := &SessionInfo{SessionKey: "client:1", ClientName: "test"}
+
+	first, err := sm.GetOrCreateSession(info)
+	if err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(40 * time.Millisecond)
+	second, err := sm.GetOrCreateSession(info)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sm.Disconnect(info.SessionKey)
+	sm.closeLifecycle()
+
+	want := []string{
+		SessionLifecycleCreated,
+		SessionLifecycleExpired, SessionLifecycleEnded, SessionLifecycleCreated,
+		SessionLifecycleEvicted, SessionLifecycleEnded,
+	}
+	if got := recorder.kinds(); fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Fatalf("got transitions %v, want %v", got, want)
+	}
+	for i, event := range recorder.events {
+		wantID := first
+		if i >= 3 {
+			wantID = second
+		}
+		if event.SessionID != wantID {
+			t.Errorf("%s event is for session %s, want %s", event.Kind, event.SessionID, wantID)
+		}
+		if event.Timestamp.IsZero() {
+			t.Errorf("%s event has no timestamp", event.Kind)
+		}
+	}
+	if got := recorder.events[0].Details["session_key"]; got != info.SessionKey {
+		t.Errorf("created event has session key %q, want %q", got, info.SessionKey)
+	}
+}
+
+func TestIdentityChangesAreReported(t *testing.T) {
+	var recorder lifecycleRecorder
+	_, a, collector := newTrackedServer(t, func(config *AgnostConfig) {
+		config.OnSessionLifecycle = recorder.record
+	})
+	sm := a.sessionManager
+	sessionID := collector.Sessions()[0].SessionID
+
+	a.Identify(UserIdentity{"user_id": "alice"})
+	a.Identify(UserIdentity{"user_id": "bob"})
+	// A call of the identified user doesn't change it
+	sm.RecordIdentity(sessionID, UserIdentity{"user_id": "bob"})
+	sm.closeLifecycle()
+
+	want := []string{SessionLifecycleCreated, SessionLifecycleIdentityChanged, SessionLifecycleIdentityChanged}
+	if got := recorder.kinds(); fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Fatalf("got transitions %v, want %v", got, want)
+	}
+	if got := recorder.events[1].Details; got["user_id"] != "alice" || got["previous_user_id"] != "" {
+		t.Errorf("first identification has details %v", got)
+	}
+	if got := recorder.events[2].Details; got["user_id"] != "bob" || got["previous_user_id"] != "alice" {
+		t.Errorf("identity change has details %v, want bob replacing alice", got)
+	}
+	if recorder.events[2].SessionID != sessionID {
+		t.Errorf("identity change is for session %s, want %s", recorder.events[2].SessionID, sessionID)
+	}
+}
+
+func TestPanickingLifecycleCallbackKeepsReceivingEvents(t *testing.T) {
+	var recorder lifecycleRecorder
+	n := newLifecycleNotifier(func(event SessionLifecycleEvent) {
+		recorder.record(event)
+		panic("callback failed")
+	}, newLevelLogger(NewLogger(io.Discard), "debug"))
+
+	n.notify(SessionLifecycleEvent{Kind: SessionLifecycleCreated})
+	n.notify(SessionLifecycleEvent{Kind: SessionLifecycleEnded})
+	n.close()
+	if got := recorder.kinds(); len(got) != 2 {
+		t.Errorf("delivered %v, want both events despite the panics", got)
+	}
+}
+
+func TestSlowLifecycleCallbackDropsEventsInsteadOfBlocking(t *testing.T) {
+	release := make(chan struct{})
+	n := newLifecycleNotifier(func(event SessionLifecycleEvent) { <-release }, newLevelLogger(NewLogger(io.Discard), "debug"))
+
+	done := make(chan struct{})
+	go func() {
+		for range lifecycleQueueSize + 10 {
+			n.notify(SessionLifecycleEvent{Kind: SessionLifecycleCreated})
+		}
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("notify blocked on a slow callback")
+	}
+	close(release)
+	n.close()
+
+	// Events after close are ignored
+	n.notify(SessionLifecycleEvent{Kind: SessionLifecycleEnded})
+}
@@ -2,9 +2,12 @@ package agnost
 
 import (
 	"fmt"
+	"io"
 	"log"
+	"log/slog"
 	"os"
 	"strings"
+	"sync/atomic"
 )
 
 // LogLevel represents logging levels
@@ -17,85 +20,218 @@ const (
 	LogLevelError
 )
 
-// Logger provides structured logging for the SDK
+// LogSink receives the SDK's log messages, such as to forward them to zap or
+// another logging library. The SDK calls it only for messages at or above
+// Config.LogLevel. Implementations must be safe for concurrent use.
+type LogSink interface {
+	Debug(format string, args ...any)
+	Info(format string, args ...any)
+	Warning(format string, args ...any)
+	Error(format string, args ...any)
+}
+
+// Logger provides structured logging for the SDK, and is its default LogSink
 type Logger struct {
 	level  LogLevel
 	logger *log.Logger
+	out    io.Writer
 }
 
-var defaultLogger = &Logger{
-	level:  LogLevelInfo,
-	logger: log.New(os.Stderr, "[agnost] ", log.LstdFlags),
+// NewLogger creates a logger writing to w
+func NewLogger(w io.Writer) *Logger {
+	return &Logger{
+		level:  LogLevelInfo,
+		logger: log.New(w, "[agnost] ", log.LstdFlags),
+		out:    w,
+	}
 }
 
-// SetLogLevel sets the global log level
-func SetLogLevel(level string) {
-	defaultLogger.SetLevel(level)
+// StderrOnly creates a logger writing to stderr, which is always safe for stdio servers
+func StderrOnly() *Logger {
+	return NewLogger(os.Stderr)
 }
 
-// SetLevel sets the log level for this logger
+// Output returns the writer this logger writes to
+func (l *Logger) Output() io.Writer {
+	return l.out
+}
+
+// SetLevel sets the log level for this logger, for its use outside the SDK;
+// the SDK filters its messages by Config.LogLevel instead
 func (l *Logger) SetLevel(level string) {
-	switch strings.ToLower(level) {
-	case "debug":
-		l.level = LogLevelDebug
-	case "info":
-		l.level = LogLevelInfo
-	case "warning", "warn":
-		l.level = LogLevelWarning
-	case "error":
-		l.level = LogLevelError
-	default:
-		l.level = LogLevelInfo
-	}
+	l.level = parseLogLevel(level)
 }
 
 // Debug logs a debug message
 func (l *Logger) Debug(format string, args ...any) {
 	if l.level <= LogLevelDebug {
-		l.logger.Printf("[DEBUG] "+format, args...)
+		l.print(LogLevelDebug, format, args...)
 	}
 }
 
 // Info logs an info message
 func (l *Logger) Info(format string, args ...any) {
 	if l.level <= LogLevelInfo {
-		l.logger.Printf("[INFO] "+format, args...)
+		l.print(LogLevelInfo, format, args...)
 	}
 }
 
 // Warning logs a warning message
 func (l *Logger) Warning(format string, args ...any) {
 	if l.level <= LogLevelWarning {
-		l.logger.Printf("[WARNING] "+format, args...)
+		l.print(LogLevelWarning, format, args...)
 	}
 }
 
 // Error logs an error message
 func (l *Logger) Error(format string, args ...any) {
 	if l.level <= LogLevelError {
-		l.logger.Printf("[ERROR] "+format, args...)
+		l.print(LogLevelError, format, args...)
+	}
+}
+
+// print writes a message tagged with level, whatever the logger's level
+func (l *Logger) print(level LogLevel, format string, args ...any) {
+	l.logger.Printf("["+level.String()+"] "+format, args...)
+}
+
+// String returns the tag of the level in log lines, such as "INFO"
+func (level LogLevel) String() string {
+	switch level {
+	case LogLevelDebug:
+		return "DEBUG"
+	case LogLevelInfo:
+		return "INFO"
+	case LogLevelWarning:
+		return "WARNING"
+	case LogLevelError:
+		return "ERROR"
+	}
+	return fmt.Sprintf("LogLevel(%d)", int(level))
+}
+
+// parseLogLevel returns the level named by level, such as Config.LogLevel;
+// unknown names are LogLevelInfo
+func parseLogLevel(level string) LogLevel {
+	switch strings.ToLower(level) {
+	case "debug":
+		return LogLevelDebug
+	case "warning", "warn":
+		return LogLevelWarning
+	case "error":
+		return LogLevelError
+	}
+	return LogLevelInfo
+}
+
+// levelLogger hands the messages at or above its level to a LogSink. Each
+// analytics client logs through one of its own, so clients can use different
+// loggers and levels. A nil levelLogger logs through the package logger.
+type levelLogger struct {
+	level LogLevel
+	sink  LogSink
+	attrs []slog.Attr // fields added to every message, see with
+
+	// recovered counts the panics guard logs here, if set
+	recovered *atomic.Int64
+}
+
+// newLevelLogger returns a logger passing the messages at or above level to
+// sink, or to a stderr logger if sink is nil
+func newLevelLogger(sink LogSink, level string) *levelLogger {
+	if sink == nil {
+		sink = StderrOnly()
+	}
+	return &levelLogger{level: parseLogLevel(level), sink: sink}
+}
+
+// packageLogger receives the messages not tied to an analytics client, such
+// as recovered panics and those of the package-level logging functions. The
+// global analytics client replaces it with its own logger on Initialize.
+var packageLogger atomic.Pointer[levelLogger]
+
+func init() {
+	packageLogger.Store(newLevelLogger(nil, "info"))
+}
+
+// SetLogLevel sets the level of the package logger, which logs the messages
+// not tied to an analytics client
+func SetLogLevel(level string) {
+	packageLogger.Store(newLevelLogger(packageLogger.Load().sink, level))
+}
+
+// enabled reports whether messages at level are logged, so hot paths can skip
+// building the ones that aren't
+func (l *levelLogger) enabled(level LogLevel) bool {
+	if l == nil {
+		l = packageLogger.Load()
+	}
+	return level >= l.level
+}
+
+func (l *levelLogger) Debug(format string, args ...any) {
+	l.log(LogLevelDebug, format, args...)
+}
+
+func (l *levelLogger) Info(format string, args ...any) {
+	l.log(LogLevelInfo, format, args...)
+}
+
+func (l *levelLogger) Warning(format string, args ...any) {
+	l.log(LogLevelWarning, format, args...)
+}
+
+func (l *levelLogger) Error(format string, args ...any) {
+	l.log(LogLevelError, format, args...)
+}
+
+// log hands a message at or above the logger's level to the sink
+func (l *levelLogger) log(level LogLevel, format string, args ...any) {
+	if l == nil {
+		l = packageLogger.Load()
+	}
+	if level < l.level {
+		return
+	}
+	if structured, ok := l.sink.(structuredLogger); ok {
+		structured.logAttrs(level, l.attrs, format, args...)
+		return
+	}
+	if std, ok := l.sink.(*Logger); ok {
+		std.print(level, format, args...)
+		return
+	}
+	switch level {
+	case LogLevelDebug:
+		l.sink.Debug(format, args...)
+	case LogLevelInfo:
+		l.sink.Info(format, args...)
+	case LogLevelWarning:
+		l.sink.Warning(format, args...)
+	default:
+		l.sink.Error(format, args...)
 	}
 }
 
-// Global logging functions
+// Global logging functions, logging through the package logger
 func Debug(format string, args ...any) {
-	defaultLogger.Debug(format, args...)
+	packageLogger.Load().Debug(format, args...)
 }
 
 func Info(format string, args ...any) {
-	defaultLogger.Info(format, args...)
+	packageLogger.Load().Info(format, args...)
 }
 
 func Warning(format string, args ...any) {
-	defaultLogger.Warning(format, args...)
+	packageLogger.Load().Warning(format, args...)
 }
 
 func Error(format string, args ...any) {
-	defaultLogger.Error(format, args...)
+	packageLogger.Load().Error(format, args...)
 }
 
 func Errorf(format string, args ...any) error {
 	msg := fmt.Sprintf(format, args...)
-	defaultLogger.Error(msg)
+	packageLogger.Load().Error(msg)
 	return fmt.Errorf(msg)
 }
@@ -0,0 +1,71 @@
+package agnost
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// syncBuffer is a buffer loggers can write to concurrently
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+// capturePackageLogger sends the package logger's messages to the returned
+// buffer for the rest of the test
+func capturePackageLogger(t *testing.T) *syncBuffer {
+	var buf syncBuffer
+	previous := packageLogger.Swap(newLevelLogger(NewLogger(&buf), "debug"))
+	t.Cleanup(func() { packageLogger.Store(previous) })
+	return &buf
+}
+
+func TestCallMessagesGoToTheTrackingClientsLogger(t *testing.T) {
+	global := capturePackageLogger(t)
+	var client syncBuffer
+	log := newLevelLogger(NewLogger(&client), "debug")
+
+	s := server.NewMCPServer("test", "1.0.0", server.WithToolHandlerMiddleware(ToolMiddleware()))
+	s.AddTool(mcp.NewTool("echo"), func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		SetEventAttribute(ctx, "session_id", "shadowed")
+		WithEventMetadata(ctx, map[string]any{"bad": func() {}})
+		return mcp.NewToolResultText("ok"), nil
+	})
+	trackerFor(s).setSink(nil, func(call *ToolCall) error { panic("callback") }, nil, nil, nil, log)
+	callTool(t, s, "echo")
+
+	for _, want := range []string{"Event attribute 'session_id' rejected", "Event metadata rejected", "Recovered from panic in analytics callback"} {
+		if !strings.Contains(client.String(), want) {
+			t.Errorf("client log lacks %q:\n%s", want, client.String())
+		}
+	}
+	if global.String() != "" {
+		t.Errorf("call messages went to the package logger:\n%s", global.String())
+	}
+}
+
+func TestMessagesOutsideCallsGoToThePackageLogger(t *testing.T) {
+	global := capturePackageLogger(t)
+	WithEventMetadata(context.Background(), map[string]any{"bad": func() {}})
+	if !strings.Contains(global.String(), "Event metadata rejected") {
+		t.Errorf("package log lacks the rejection:\n%s", global.String())
+	}
+}
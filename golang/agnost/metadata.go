@@ -0,0 +1,92 @@
+package agnost
+
+import (
+	"context"
+	"encoding/json"
+	"maps"
+	"strings"
+)
+
+type eventMetadataKey struct{}
+
+// WithEventMetadata returns a context carrying metadata, such as a tenant ID or
+// an upstream request ID, for the events of the tracked calls made with it,
+// merged over the metadata ctx already carries. Keys set later override earlier
+// ones. Middleware in front of a tracked handler passes the returned context
+// on; inside a tracked handler the metadata is also attached to the running
+// call's event. The metadata is copied, as JSON, so the caller can keep
+// modifying its map; values that can't be serialized are rejected with a
+// warning.
+func WithEventMetadata(ctx context.Context, metadata map[string]any) context.Context {
+	state := callStateFromContext(ctx)
+	var log *levelLogger
+	if state != nil {
+		log = state.log
+	}
+	copied, ok := copyMetadata(metadata, log)
+	if !ok || len(copied) == 0 {
+		return ctx
+	}
+
+	if state != nil {
+		state.mu.Lock()
+		if state.finished {
+			log.Warning("Event metadata ignored: set after the tool call returned")
+		} else {
+			state.metadata = mergeMetadata(state.metadata, copied)
+		}
+		state.mu.Unlock()
+	}
+
+	return context.WithValue(ctx, eventMetadataKey{}, mergeMetadata(metadataFromContext(ctx), copied))
+}
+
+// metadataFromContext returns the metadata ctx carries, which callers must not
+// modify
+func metadataFromContext(ctx context.Context) map[string]any {
+	if ctx == nil {
+		return nil
+	}
+	metadata, _ := ctx.Value(eventMetadataKey{}).(map[string]any)
+	return metadata
+}
+
+// mergeMetadata returns a new map holding base overridden by overrides
+func mergeMetadata(base map[string]any, overrides map[string]any) map[string]any {
+	merged := make(map[string]any, len(base)+len(overrides))
+	maps.Copy(merged, base)
+	maps.Copy(merged, overrides)
+	return merged
+}
+
+// copyMetadata deep-copies metadata with a JSON round trip, so that the copy
+// shares nothing with the caller's values, warning to log about values it
+// can't copy
+func copyMetadata(metadata map[string]any, log *levelLogger) (map[string]any, bool) {
+	if len(metadata) == 0 {
+		return nil, true
+	}
+	data, err := json.Marshal(metadata)
+	if err != nil {
+		log.Warning("Event metadata rejected: %v", err)
+		return nil, false
+	}
+	decoder := json.NewDecoder(strings.NewReader(string(data)))
+	decoder.UseNumber()
+	var copied map[string]any
+	if err := decoder.Decode(&copied); err != nil {
+		log.Warning("Event metadata rejected: %v", err)
+		return nil, false
+	}
+	return copied, true
+}
+
+// metadataSnapshot returns the metadata of the call
+func (s *callState) metadataSnapshot() map[string]any {
+	if s == nil {
+		return nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.metadata
+}
@@ -0,0 +1,151 @@
+package agnost
+
+import (
+	"bufio"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// maxMetricsTools bounds the tools with metrics of their own; calls of further
+// tools are counted under metricsOtherTool
+const maxMetricsTools = 1000
+
+// metricsOtherTool is the tool label of the calls beyond maxMetricsTools
+const metricsOtherTool = "_other"
+
+// toolMetrics counts the calls of a tool and bins their latencies on the
+// delivery latency histogram's bounds
+type toolMetrics struct {
+	succeeded atomic.Int64
+	failed    atomic.Int64
+	latency   [len(latencyBounds) + 1]atomic.Int64
+	latencyMs atomic.Int64 // sum of the latencies
+}
+
+// callMetrics holds the tool call metrics served by MetricsHandler. Calls are
+// recorded with atomics only, once the tool was seen.
+type callMetrics struct {
+	tools sync.Map // tool name -> *toolMetrics
+	count atomic.Int64
+}
+
+// record counts a tool call
+func (m *callMetrics) record(tool string, success bool, latencyMs int64) {
+	metrics := m.tool(tool)
+	if success {
+		metrics.succeeded.Add(1)
+	} else {
+		metrics.failed.Add(1)
+	}
+	metrics.latency[latencyBin(time.Duration(latencyMs)*time.Millisecond)].Add(1)
+	metrics.latencyMs.Add(latencyMs)
+}
+
+// tool returns the metrics of a tool, creating them on first sight
+func (m *callMetrics) tool(name string) *toolMetrics {
+	if metrics, ok := m.tools.Load(name); ok {
+		return metrics.(*toolMetrics)
+	}
+	if m.count.Load() >= maxMetricsTools {
+		name = metricsOtherTool
+	}
+	metrics, loaded := m.tools.LoadOrStore(name, &toolMetrics{})
+	if !loaded {
+		m.count.Add(1)
+	}
+	return metrics.(*toolMetrics)
+}
+
+// MetricsHandler returns an http.Handler serving the SDK's metrics in the
+// Prometheus text format: tool calls by tool and outcome, tool latency, queue
+// depth and dropped events by reason
+func (a *AgnostAnalytics) MetricsHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		out := bufio.NewWriter(w)
+		a.writeMetrics(out)
+		out.Flush()
+	})
+}
+
+// writeMetrics writes the metrics in the Prometheus text format
+func (a *AgnostAnalytics) writeMetrics(out *bufio.Writer) {
+	var names []string
+	a.metrics.tools.Range(func(name, _ any) bool {
+		names = append(names, name.(string))
+		return true
+	})
+	slices.Sort(names)
+	tools := make([]*toolMetrics, len(names))
+	for i, name := range names {
+		metrics, _ := a.metrics.tools.Load(name)
+		tools[i] = metrics.(*toolMetrics)
+	}
+
+	out.WriteString("# HELP agnost_tool_calls_total Tracked tool calls, by tool and outcome.\n")
+	out.WriteString("# TYPE agnost_tool_calls_total counter\n")
+	for i, name := range names {
+		label := `tool="` + escapeLabel(name) + `"`
+		writeSample(out, "agnost_tool_calls_total", label+`,success="true"`, tools[i].succeeded.Load())
+		writeSample(out, "agnost_tool_calls_total", label+`,success="false"`, tools[i].failed.Load())
+	}
+
+	out.WriteString("# HELP agnost_tool_latency_seconds Latency of tracked tool calls, by tool.\n")
+	out.WriteString("# TYPE agnost_tool_latency_seconds histogram\n")
+	for i, name := range names {
+		label := `tool="` + escapeLabel(name) + `"`
+		var cumulative int64
+		for bin := range tools[i].latency {
+			cumulative += tools[i].latency[bin].Load()
+			le := "+Inf"
+			if bin < len(latencyBounds) {
+				le = strconv.FormatFloat(latencyBounds[bin].Seconds(), 'g', -1, 64)
+			}
+			writeSample(out, "agnost_tool_latency_seconds_bucket", label+`,le="`+le+`"`, cumulative)
+		}
+		out.WriteString("agnost_tool_latency_seconds_sum{" + label + "} ")
+		out.WriteString(strconv.FormatFloat(float64(tools[i].latencyMs.Load())/1000, 'g', -1, 64))
+		out.WriteString("\n")
+		writeSample(out, "agnost_tool_latency_seconds_count", label, cumulative)
+	}
+
+	var queued int
+	a.mu.RLock()
+	if a.eventProcessor != nil {
+		queued, _, _ = a.eventProcessor.QueueStats()
+	}
+	a.mu.RUnlock()
+	out.WriteString("# HELP agnost_queue_depth Events waiting in the queue.\n")
+	out.WriteString("# TYPE agnost_queue_depth gauge\n")
+	writeSample(out, "agnost_queue_depth", "", int64(queued))
+
+	drops := a.drops.snapshot()
+	out.WriteString("# HELP agnost_events_dropped_total Events that left the pipeline undelivered, by reason.\n")
+	out.WriteString("# TYPE agnost_events_dropped_total counter\n")
+	for _, reason := range DropReasons {
+		writeSample(out, "agnost_events_dropped_total", `reason="`+string(reason)+`"`, drops[reason])
+	}
+}
+
+// writeSample writes a sample line, with labels if any
+func writeSample(out *bufio.Writer, name string, labels string, value int64) {
+	out.WriteString(name)
+	if labels != "" {
+		out.WriteString("{" + labels + "}")
+	}
+	out.WriteString(" ")
+	out.WriteString(strconv.FormatInt(value, 10))
+	out.WriteString("\n")
+}
+
+// labelEscaper escapes label values for the Prometheus text format
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func escapeLabel(value string) string {
+	return labelEscaper.Replace(value)
+}
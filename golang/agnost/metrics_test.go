@@ -0,0 +1,112 @@
+package agnost
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// scrapeMetrics serves a metrics request, returning the samples by series
+func scrapeMetrics(t *testing.T, a *AgnostAnalytics) map[string]string {
+	t.Helper()
+	recorder := httptest.NewRecorder()
+	a.MetricsHandler().ServeHTTP(recorder, httptest.NewRequest("GET", "/metrics", nil))
+	if got := recorder.Header().Get("Content-Type"); !strings.HasPrefix(got, "text/plain; version=0.0.4") {
+		t.Errorf("metrics served as %q, want the Prometheus text format", got)
+	}
+	samples := map[string]string{}
+	for _, line := range strings.Split(strings.TrimSpace(recorder.Body.String()), "\n") {
+		if strings.HasPrefix(line, "#") {
+			continue
+		}
+		series, value, ok := strings.Cut(line, " ")
+		if !ok {
+			t.Fatalf("malformed sample line %q", line)
+		}
+		samples[series] = value
+	}
+	return samples
+}
+
+func TestMetricsCountToolCallsByOutcome(t *testing.T) {
+	s, a, _ := newTrackedServer(t, nil)
+	addEchoTool(s)
+	s.AddTool(mcp.NewTool("fail"), func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		return mcp.NewToolResultError("failed"), nil
+	})
+	callTool(t, s, "echo")
+	callTool(t, s, "echo")
+	callTool(t, s, "fail")
+
+	samples := scrapeMetrics(t, a)
+	for series, want := range map[string]string{
+		`agnost_tool_calls_total{tool="echo",success="true"}`:       "2",
+		`agnost_tool_calls_total{tool="echo",success="false"}`:      "0",
+		`agnost_tool_calls_total{tool="fail",success="false"}`:      "1",
+		`agnost_tool_latency_seconds_count{tool="echo"}`:            "2",
+		`agnost_tool_latency_seconds_bucket{tool="echo",le="+Inf"}`: "2",
+		`agnost_tool_latency_seconds_bucket{tool="fail",le="+Inf"}`: "1",
+		`agnost_queue_depth`: "0",
+		`agnost_events_dropped_total{reason="` + string(DropQueueFull) + `"}`: "0",
+	} {
+		if got := samples[series]; got != want {
+			t.Errorf("%s = %q, want %s", series, got, want)
+		}
+	}
+	for _, reason := range DropReasons {
+		if _, ok := samples[`agnost_events_dropped_total{reason="`+string(reason)+`"}`]; !ok {
+			t.Errorf("no dropped events sample for %s", reason)
+		}
+	}
+}
+
+func TestMetricsLatencyHistogramIsCumulative(t *testing.T) {
+	a := NewAgnostAnalytics()
+	a.metrics.record("search", true, 30)
+	a.metrics.record("search", true, 300)
+
+	samples := scrapeMetrics(t, a)
+	for le, want := range map[string]string{"0.025": "0", "0.05": "1", "0.25": "1", "0.5": "2", "+Inf": "2"} {
+		series := `agnost_tool_latency_seconds_bucket{tool="search",le="` + le + `"}`
+		if got := samples[series]; got != want {
+			t.Errorf("%s = %q, want %s", series, got, want)
+		}
+	}
+	if got := samples[`agnost_tool_latency_seconds_sum{tool="search"}`]; got != "0.33" {
+		t.Errorf("latency sum = %q, want 0.33", got)
+	}
+}
+
+func TestMetricsBoundTheToolLabels(t *testing.T) {
+	a := NewAgnostAnalytics()
+	for i := range maxMetricsTools + 5 {
+		a.metrics.record(fmt.Sprintf("tool_%d", i), true, 1)
+	}
+	a.metrics.record("tool_0", false, 1)
+
+	samples := scrapeMetrics(t, a)
+	if got := samples[`agnost_tool_calls_total{tool="`+metricsOtherTool+`",success="true"}`]; got != "5" {
+		t.Errorf("calls beyond %d tools counted as %q, want 5", maxMetricsTools, got)
+	}
+	if got := samples[`agnost_tool_calls_total{tool="tool_0",success="false"}`]; got != "1" {
+		t.Errorf("a known tool's calls counted as %q past the bound, want 1", got)
+	}
+	if _, ok := samples[fmt.Sprintf(`agnost_tool_calls_total{tool="tool_%d",success="true"}`, maxMetricsTools)]; ok {
+		t.Error("a tool past the bound got a label of its own")
+	}
+}
+
+func TestMetricLabelsAreEscaped(t *testing.T) {
+	var buf strings.Builder
+	out := bufio.NewWriter(&buf)
+	writeSample(out, "agnost_tool_calls_total", `tool="`+escapeLabel("say \"hi\"\\\n")+`"`, 1)
+	out.Flush()
+	if want := `agnost_tool_calls_total{tool="say \"hi\"\\\n"} 1` + "\n"; buf.String() != want {
+		t.Errorf("wrote %q, want %q", buf.String(), want)
+	}
+}
@@ -0,0 +1,95 @@
+package agnost
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// addNestingTool adds a tool calling the named tool of s from its handler,
+// with the context the handler was given
+func addNestingTool(s *server.MCPServer, name string, calls string) {
+	s.AddTool(mcp.NewTool(name), func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		message, _ := json.Marshal(map[string]any{"jsonrpc": "2.0", "id": 2, "method": "tools/call",
+			"params": map[string]any{"name": calls, "arguments": map[string]any{}}})
+		s.HandleMessage(ctx, message)
+		return mcp.NewToolResultText("ok"), nil
+	})
+}
+
+// toolEvents returns the tool events of the collector by tool name
+func toolEvents(t *testing.T, collector *eventCollector, want int) map[string]EventData {
+	t.Helper()
+	if !waitUntil(func() bool { return len(collector.Events("tool")) >= want }) {
+		t.Fatalf("got %d tool events, want %d", len(collector.Events("tool")), want)
+	}
+	events := make(map[string]EventData)
+	for _, event := range collector.Events("tool") {
+		events[event.PrimitiveName] = event
+	}
+	return events
+}
+
+func TestNestedCallsLinkToTheirParentEvent(t *testing.T) {
+	s, _, collector := newTrackedServer(t, nil)
+	addEchoTool(s)
+	addNestingTool(s, "middle", "echo")
+	addNestingTool(s, "outer", "middle")
+
+	callTool(t, s, "outer")
+	events := toolEvents(t, collector, 3)
+	outer, middle, inner := events["outer"], events["middle"], events["echo"]
+	if outer.EventID == "" || middle.EventID == "" || inner.EventID == "" {
+		t.Fatal("events were recorded without an event ID")
+	}
+	if outer.ParentEventID != "" {
+		t.Errorf("top-level call has parent %q", outer.ParentEventID)
+	}
+	if middle.ParentEventID != outer.EventID {
+		t.Errorf("middle call has parent %q, want %q", middle.ParentEventID, outer.EventID)
+	}
+	if inner.ParentEventID != middle.EventID {
+		t.Errorf("inner call has parent %q, want %q", inner.ParentEventID, middle.EventID)
+	}
+}
+
+func TestCallsDeeperThanMaxCallDepthHaveNoParent(t *testing.T) {
+	s, _, collector := newTrackedServer(t, func(config *AgnostConfig) {
+		config.MaxCallDepth = 2
+	})
+	addEchoTool(s)
+	addNestingTool(s, "middle", "echo")
+	addNestingTool(s, "outer", "middle")
+
+	callTool(t, s, "outer")
+	events := toolEvents(t, collector, 3)
+	if got, want := events["middle"].ParentEventID, events["outer"].EventID; got != want {
+		t.Errorf("call at the max depth has parent %q, want %q", got, want)
+	}
+	if got := events["echo"].ParentEventID; got != "" {
+		t.Errorf("call past the max depth has parent %q", got)
+	}
+}
+
+func TestSequentialCallsAreNotNested(t *testing.T) {
+	s, _, collector := newTrackedServer(t, nil)
+	addEchoTool(s)
+
+	callTool(t, s, "echo")
+	callTool(t, s, "echo")
+	events := collector.Events("tool")
+	if len(events) != 2 {
+		t.Fatalf("got %d tool events, want 2", len(events))
+	}
+	if events[0].EventID == events[1].EventID {
+		t.Error("calls share an event ID")
+	}
+	for _, event := range events {
+		if event.ParentEventID != "" {
+			t.Errorf("sequential call has parent %q", event.ParentEventID)
+		}
+	}
+}
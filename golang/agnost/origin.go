@@ -0,0 +1,59 @@
+package agnost
+
+import "sync"
+
+// ToolOriginNative is the origin of tools without a label: the server's own
+const ToolOriginNative = "native"
+
+// toolOrigins holds the origin labels set with SetToolOrigin
+type toolOrigins struct {
+	mu     sync.RWMutex
+	labels map[string]string // tool name -> origin
+}
+
+func newToolOrigins() *toolOrigins {
+	return &toolOrigins{labels: make(map[string]string)}
+}
+
+// set labels the named tool, or removes its label for an empty origin
+func (o *toolOrigins) set(name string, origin string) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if origin == "" {
+		delete(o.labels, name)
+		return
+	}
+	o.labels[name] = origin
+}
+
+// origin returns the origin of the named tool: its SetToolOrigin label, else
+// its label in Config.ToolOrigins by name or pattern, else ToolOriginNative
+func (o *toolOrigins) origin(config *AgnostConfig, name string) string {
+	if o != nil {
+		o.mu.RLock()
+		origin, ok := o.labels[name]
+		o.mu.RUnlock()
+		if ok {
+			return origin
+		}
+	}
+	if origin, ok := lookupPattern(config.ToolOrigins, name); ok && origin != "" {
+		return origin
+	}
+	return ToolOriginNative
+}
+
+// SetToolOrigin labels the origin of a tool of the server tracked by the
+// global analytics client, see AgnostAnalytics.SetToolOrigin
+func SetToolOrigin(name string, origin string) {
+	globalClient.SetToolOrigin(name, origin)
+}
+
+// SetToolOrigin labels where the named tool comes from, such as the upstream
+// server a proxied tool is mounted from, overriding Config.ToolOrigins. The
+// origin is sent on the tool's events and in the session's tool inventory. An
+// empty origin removes the label. Call it before Track so the first sessions
+// carry the label.
+func (a *AgnostAnalytics) SetToolOrigin(name string, origin string) {
+	a.origins.set(name, origin)
+}
@@ -0,0 +1,71 @@
+package agnost
+
+import (
+	"maps"
+	"testing"
+
+	"github.com/mark3labs/mcp-go/server"
+)
+
+func TestToolOriginPrecedence(t *testing.T) {
+	config := DefaultConfig()
+	config.ToolOrigins = map[string]string{"github_*": "github", "search": "docs"}
+	origins := newToolOrigins()
+	origins.set("search", "proxy")
+
+	for name, want := range map[string]string{"github_issues": "github", "search": "proxy", "echo": ToolOriginNative} {
+		if got := origins.origin(config, name); got != want {
+			t.Errorf("origin(%q) = %q, want %q", name, got, want)
+		}
+	}
+	origins.set("search", "")
+	if got := origins.origin(config, "search"); got != "docs" {
+		t.Errorf("unlabeled tool has origin %q, want its configured one", got)
+	}
+	var unset *toolOrigins
+	if got := unset.origin(config, "github_pulls"); got != "github" {
+		t.Errorf("origin without labels = %q, want the configured one", got)
+	}
+}
+
+func TestEventsAndSessionsCarryToolOrigins(t *testing.T) {
+	s := server.NewMCPServer("test", "1.0.0", server.WithToolHandlerMiddleware(ToolMiddleware()))
+	addEchoTool(s)
+	addCachedTool(s, "github_issues", nil)
+	addCachedTool(s, "lookup", nil)
+	a := NewAgnostAnalytics()
+	a.SetToolOrigin("lookup", "upstream")
+	collector := trackServer(t, s, a, func(config *AgnostConfig) {
+		config.ToolOrigins = map[string]string{"github_*": "github"}
+	})
+
+	want := map[string]string{"github_issues": "github", "lookup": "upstream"}
+	if got := collector.Sessions()[0].ToolOrigins; !maps.Equal(got, want) {
+		t.Errorf("session lists tool origins %v, want %v", got, want)
+	}
+	for _, name := range []string{"echo", "github_issues", "lookup"} {
+		callTool(t, s, name)
+	}
+	want["echo"] = ToolOriginNative
+	for _, event := range collector.Events("tool") {
+		if event.Origin != want[event.PrimitiveName] {
+			t.Errorf("%s event has origin %q, want %q", event.PrimitiveName, event.Origin, want[event.PrimitiveName])
+		}
+	}
+	if got := len(collector.Events("tool")); got != 3 {
+		t.Errorf("got %d tool events, want 3", got)
+	}
+}
+
+func TestSetToolOriginAppliesToLaterCalls(t *testing.T) {
+	s, a, collector := newTrackedServer(t, nil)
+	addEchoTool(s)
+	callTool(t, s, "echo")
+	a.SetToolOrigin("echo", "mounted")
+	callTool(t, s, "echo")
+
+	events := collector.Events("tool")
+	if len(events) != 2 || events[0].Origin != ToolOriginNative || events[1].Origin != "mounted" {
+		t.Errorf("got events %+v, want a native call then a mounted one", events)
+	}
+}
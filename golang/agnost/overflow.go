@@ -0,0 +1,211 @@
+package agnost
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// defaultOverflowMaxBytes is used when Config.OverflowMaxBytes is not set
+const defaultOverflowMaxBytes = 10 << 20
+
+// overflowDrainChunk is how many events the worker takes from the overflow
+// file at a time, so a large file isn't rewritten for every event
+const overflowDrainChunk = 100
+
+// overflowHeaderBytes is the size of the big-endian length prefix of every
+// overflow record
+const overflowHeaderBytes = 4
+
+// eventOverflow keeps the events that found the queue full on disk, as
+// length-prefixed spoolRecords, until the worker has room for them again, even
+// after a restart. The file is capped at maxBytes by dropping the oldest
+// events.
+type eventOverflow struct {
+	path     string
+	runID    string
+	maxBytes int64
+	log      *levelLogger
+
+	mu       sync.Mutex
+	repaired bool // the file was checked for a partial record
+}
+
+// newEventOverflow creates the overflow file of an organization in dir
+func newEventOverflow(dir string, orgID string, maxBytes int64, log *levelLogger) *eventOverflow {
+	if maxBytes <= 0 {
+		maxBytes = defaultOverflowMaxBytes
+	}
+	return &eventOverflow{
+		path:     filepath.Join(dir, orgFileName(orgID, ".overflow")),
+		runID:    generateUUID(),
+		maxBytes: maxBytes,
+		log:      log,
+	}
+}
+
+// write appends an event to the overflow file, first dropping as many of the
+// oldest events as it takes to stay within maxBytes, and returns how many it
+// dropped
+func (o *eventOverflow) write(event *EventData) (int, error) {
+	payload, err := json.Marshal(spoolRecord{RunID: o.runID, Event: event})
+	if err != nil {
+		return 0, fmt.Errorf("failed to marshal overflow event: %v", err)
+	}
+	record := make([]byte, overflowHeaderBytes, overflowHeaderBytes+len(payload))
+	binary.BigEndian.PutUint32(record, uint32(len(payload)))
+	record = append(record, payload...)
+	if int64(len(record)) > o.maxBytes {
+		return 0, fmt.Errorf("event of %d bytes exceeds the overflow cap (%d bytes)", len(record), o.maxBytes)
+	}
+
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.repairLocked()
+
+	info, err := os.Stat(o.path)
+	if err == nil && info.Size()+int64(len(record)) > o.maxBytes {
+		records := o.readLocked()
+		size := int64(len(record))
+		for _, r := range records {
+			size += int64(len(r))
+		}
+		dropped := 0
+		for ; size > o.maxBytes; dropped++ {
+			size -= int64(len(records[dropped]))
+		}
+		if err := o.rewriteLocked(append(records[dropped:], record)); err != nil {
+			return 0, fmt.Errorf("failed to truncate overflow file: %v", err)
+		}
+		return dropped, nil
+	}
+
+	if err := os.MkdirAll(filepath.Dir(o.path), 0o700); err != nil {
+		return 0, fmt.Errorf("failed to create overflow directory: %v", err)
+	}
+	file, err := os.OpenFile(o.path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o600)
+	if err != nil {
+		return 0, fmt.Errorf("failed to open overflow file: %v", err)
+	}
+	if _, err := file.Write(record); err != nil {
+		file.Close()
+		return 0, fmt.Errorf("failed to write overflow file: %v", err)
+	}
+	return 0, file.Close()
+}
+
+// take removes up to n of the oldest events from the overflow file and returns
+// them, stamped as replayed if a previous process wrote them
+func (o *eventOverflow) take(n int) []*EventData {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.repairLocked()
+
+	records := o.readLocked()
+	if len(records) == 0 {
+		return nil
+	}
+
+	taken := min(n, len(records))
+	events := make([]*EventData, 0, taken)
+	for _, r := range records[:taken] {
+		var record spoolRecord
+		if err := json.Unmarshal(r[overflowHeaderBytes:], &record); err != nil || record.Event == nil {
+			o.log.Warning("Dropping corrupt overflow event: %v", err)
+			continue
+		}
+		if record.RunID != o.runID {
+			record.Event.DeliveryMode = DeliveryModeReplayed
+		}
+		events = append(events, record.Event)
+	}
+	if err := o.rewriteLocked(records[taken:]); err != nil {
+		o.log.Warning("Failed to update overflow file: %v", err)
+	}
+	return events
+}
+
+// size returns the size of the overflow file in bytes
+func (o *eventOverflow) size() int64 {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	info, err := os.Stat(o.path)
+	if err != nil {
+		return 0
+	}
+	return info.Size()
+}
+
+// repairLocked cuts off the partial record a crash during a write may have
+// left at the end of the file, once, so new records aren't appended after it
+func (o *eventOverflow) repairLocked() {
+	if o.repaired {
+		return
+	}
+	o.repaired = true
+
+	data, err := os.ReadFile(o.path)
+	if err != nil {
+		return
+	}
+	records := decodeOverflowRecords(data)
+	size := 0
+	for _, r := range records {
+		size += len(r)
+	}
+	if size == len(data) {
+		return
+	}
+	o.log.Warning("Skipping %d bytes of partially written overflow records", len(data)-size)
+	if err := o.rewriteLocked(records); err != nil {
+		o.log.Warning("Failed to repair overflow file: %v", err)
+	}
+}
+
+// readLocked returns the records of the overflow file, length prefix included
+func (o *eventOverflow) readLocked() [][]byte {
+	data, err := os.ReadFile(o.path)
+	if err != nil {
+		return nil
+	}
+	return decodeOverflowRecords(data)
+}
+
+// decodeOverflowRecords splits overflow file data into records. A record cut
+// short ends the data; its bytes are left out.
+func decodeOverflowRecords(data []byte) [][]byte {
+	var records [][]byte
+	for len(data) >= overflowHeaderBytes {
+		length := int64(binary.BigEndian.Uint32(data))
+		if length == 0 || length > int64(len(data)-overflowHeaderBytes) {
+			break
+		}
+		end := overflowHeaderBytes + int(length)
+		records = append(records, data[:end:end])
+		data = data[end:]
+	}
+	return records
+}
+
+// rewriteLocked atomically replaces the overflow file with the given records
+func (o *eventOverflow) rewriteLocked(records [][]byte) error {
+	if len(records) == 0 {
+		if err := os.Remove(o.path); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return nil
+	}
+
+	if err := os.MkdirAll(filepath.Dir(o.path), 0o700); err != nil {
+		return err
+	}
+	tmp := o.path + ".tmp"
+	if err := os.WriteFile(tmp, bytes.Join(records, nil), 0o600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, o.path)
+}
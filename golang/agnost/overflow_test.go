@@ -0,0 +1,114 @@
+package agnost
+
+import (
+	"encoding/binary"
+	"io"
+	"os"
+	"slices"
+	"testing"
+)
+
+func newTestOverflow(dir string) *eventOverflow {
+	return newEventOverflow(dir, "org", 0, newLevelLogger(NewLogger(io.Discard), "debug"))
+}
+
+// appendOverflowBytes appends raw bytes to the overflow file, as a crash or a
+// corrupted disk would leave them
+func appendOverflowBytes(t *testing.T, o *eventOverflow, data []byte) {
+	t.Helper()
+	file, err := os.OpenFile(o.path, os.O_WRONLY|os.O_APPEND, 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	if _, err := file.Write(data); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// overflowRecord frames a payload as an overflow record
+func overflowRecord(payload string) []byte {
+	record := binary.BigEndian.AppendUint32(nil, uint32(len(payload)))
+	return append(record, payload...)
+}
+
+func takeNames(o *eventOverflow) (names []string, modes []string) {
+	for _, event := range o.take(overflowDrainChunk) {
+		names = append(names, event.PrimitiveName)
+		modes = append(modes, event.DeliveryMode)
+	}
+	return names, modes
+}
+
+func TestOverflowReplaySkipsAPartialTrailingRecord(t *testing.T) {
+	dir := t.TempDir()
+	previous := newTestOverflow(dir)
+	for _, name := range []string{"a", "b"} {
+		if _, err := previous.write(&EventData{PrimitiveName: name}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// A record whose write was cut short
+	partial := overflowRecord(`{"run_id":"x","event":{"primitive_name":"lost"}}`)
+	appendOverflowBytes(t, previous, partial[:len(partial)-10])
+
+	current := newTestOverflow(dir)
+	if _, err := current.write(&EventData{PrimitiveName: "c"}); err != nil {
+		t.Fatal(err)
+	}
+	names, modes := takeNames(current)
+	if !slices.Equal(names, []string{"a", "b", "c"}) {
+		t.Fatalf("replayed %v, want [a b c] without the partial record", names)
+	}
+	if !slices.Equal(modes, []string{DeliveryModeReplayed, DeliveryModeReplayed, ""}) {
+		t.Errorf("got delivery modes %v, want the previous run's events replayed", modes)
+	}
+	if got := current.size(); got != 0 {
+		t.Errorf("overflow holds %d bytes after replaying everything", got)
+	}
+
+	previous = newTestOverflow(dir)
+	previous.write(&EventData{PrimitiveName: "d"})
+	appendOverflowBytes(t, previous, []byte{0})
+	if names, _ := takeNames(newTestOverflow(dir)); !slices.Equal(names, []string{"d"}) {
+		t.Errorf("replayed %v before a stray header byte, want [d]", names)
+	}
+}
+
+func TestOverflowReplayDropsCorruptRecords(t *testing.T) {
+	o := newTestOverflow(t.TempDir())
+	o.write(&EventData{PrimitiveName: "a"})
+	appendOverflowBytes(t, o, overflowRecord("{not json"))
+	appendOverflowBytes(t, o, overflowRecord(`{"run_id":"x"}`))
+	o.write(&EventData{PrimitiveName: "b"})
+
+	if names, _ := takeNames(o); !slices.Equal(names, []string{"a", "b"}) {
+		t.Errorf("replayed %v, want the two valid events", names)
+	}
+	if got := o.size(); got != 0 {
+		t.Errorf("overflow kept %d bytes of corrupt records", got)
+	}
+}
+
+func TestOverflowIsReplayedOnStart(t *testing.T) {
+	dir := t.TempDir()
+	previous := newTestOverflow(dir)
+	previous.write(&EventData{SessionID: "s", PrimitiveType: "tool", PrimitiveName: "echo"})
+	appendOverflowBytes(t, previous, overflowRecord(`{"run_id":"x"}`)[:8])
+
+	collector := newEventCollector(t)
+	config := DefaultConfig()
+	config.Logger = NewLogger(io.Discard)
+	config.OverflowDir = dir
+	ep := NewEventProcessor(collector.URL, "org", config)
+	defer ep.Shutdown()
+	if !waitUntil(func() bool { return len(collector.Events("tool")) == 1 }) {
+		t.Fatal("overflowed event wasn't replayed on start")
+	}
+	if got := collector.Events("tool")[0].DeliveryMode; got != DeliveryModeReplayed {
+		t.Errorf("replayed event has delivery mode %q", got)
+	}
+	if !waitUntil(func() bool { return ep.overflowBytes() == 0 }) {
+		t.Errorf("overflow holds %d bytes after the replay", ep.overflowBytes())
+	}
+}
@@ -0,0 +1,97 @@
+//go:build !race
+
+package agnost
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// overheadBudget is the most tracking may add to a tool call with capture
+// disabled, in nanoseconds, and overheadAllocs the most allocations
+const (
+	overheadBudget = 100_000
+	overheadAllocs = 20
+)
+
+// measureRuns is the number of calls the overhead is averaged over
+const measureRuns = 2000
+
+// measure returns the average time and allocations of call, a tool call
+func measure(t *testing.T, call func()) (ns int64, allocs int64) {
+	call()
+	start := time.Now()
+	for range measureRuns {
+		call()
+	}
+	ns = time.Since(start).Nanoseconds() / measureRuns
+	return ns, int64(testing.AllocsPerRun(measureRuns, call))
+}
+
+// measureCalls measures calls of the echo tool of s
+func measureCalls(t *testing.T, s *server.MCPServer) (ns int64, allocs int64) {
+	return measure(t, func() {
+		if _, ok := s.HandleMessage(context.Background(), echoCall).(mcp.JSONRPCResponse); !ok {
+			t.Fatal("tool call failed")
+		}
+	})
+}
+
+// measureExchange measures posting an event to a local collector, the HTTP
+// exchange a synchronous delivery makes
+func measureExchange(t *testing.T) (ns int64, allocs int64) {
+	collector := newSessionCollector(t, 0)
+	body, _ := json.Marshal(&EventData{SessionID: "s", PrimitiveType: "tool", PrimitiveName: "echo"})
+	return measure(t, func() {
+		resp, err := http.Post(collector.URL+"/api/v1/capture-event", "application/json", bytes.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+	})
+}
+
+// TestTrackingOverheadBudget checks that tracking adds at most overheadBudget
+// to a tool call with capture disabled, relative to the same call untracked
+// so that the machine's speed cancels out. Queued calls are also held to
+// overheadAllocs; synchronous ones are measured without the HTTP exchange
+// with the collector, whose round trip they wait for. The race detector
+// slows calls down too much to measure them.
+func TestTrackingOverheadBudget(t *testing.T) {
+	if testing.Short() {
+		t.Skip("measures tool calls")
+	}
+	untrackedNs, untrackedAllocs := measureCalls(t, benchmarkServer(t, nil))
+
+	t.Run("queued", func(t *testing.T) {
+		ns, allocs := measureCalls(t, benchmarkServer(t, withoutCapture))
+		t.Logf("tracking adds %dns and %d allocations per call", ns-untrackedNs, allocs-untrackedAllocs)
+		if overhead := ns - untrackedNs; overhead > overheadBudget {
+			t.Errorf("tracking adds %dns per call, over the %dns budget", overhead, overheadBudget)
+		}
+		if added := allocs - untrackedAllocs; added > overheadAllocs {
+			t.Errorf("tracking adds %d allocations per call, over the budget of %d", added, overheadAllocs)
+		}
+	})
+
+	t.Run("sync", func(t *testing.T) {
+		exchangeNs, _ := measureExchange(t)
+		ns, _ := measureCalls(t, benchmarkServer(t, func(config *AgnostConfig) {
+			withoutCapture(config)
+			config.EnableRequestQueuing = false
+		}))
+		t.Logf("tracking adds %dns per call, %dns of them the HTTP exchange", ns-untrackedNs, exchangeNs)
+		if overhead := ns - untrackedNs - exchangeNs; overhead > overheadBudget {
+			t.Errorf("tracking adds %dns per call besides the HTTP exchange, over the %dns budget", overhead, overheadBudget)
+		}
+	})
+}
@@ -0,0 +1,47 @@
+package agnost
+
+import (
+	"path"
+	"strings"
+)
+
+// isPattern reports whether s contains glob metacharacters
+func isPattern(s string) bool {
+	return strings.ContainsAny(s, "*?[")
+}
+
+// matchPattern reports whether name matches the glob pattern (as in path.Match).
+// Malformed patterns never match.
+func matchPattern(pattern string, name string) bool {
+	if !isPattern(pattern) {
+		return pattern == name
+	}
+	matched, err := path.Match(pattern, name)
+	return err == nil && matched
+}
+
+// lookupPattern finds the value for name in a map keyed by names or glob
+// patterns. Exact names win; otherwise the longest matching pattern wins, with
+// ties broken lexicographically so the result is deterministic.
+func lookupPattern[T any](m map[string]T, name string) (T, bool) {
+	if v, ok := m[name]; ok {
+		return v, true
+	}
+
+	var best string
+	var found bool
+	for pattern := range m {
+		if !isPattern(pattern) || !matchPattern(pattern, name) {
+			continue
+		}
+		if !found || len(pattern) > len(best) || (len(pattern) == len(best) && pattern < best) {
+			best, found = pattern, true
+		}
+	}
+
+	if !found {
+		var zero T
+		return zero, false
+	}
+	return m[best], true
+}
@@ -0,0 +1,172 @@
+package agnost
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// PromptGet describes a completed prompts/get request
+type PromptGet struct {
+	PromptName string
+
+	// EventID identifies the request's event; ParentEventID is the event of
+	// the tracked call it was nested in, if any
+	EventID       string
+	ParentEventID string
+
+	// SessionID is the session pinned at request start, or empty if none was pinned
+	SessionID string
+
+	Arguments map[string]string
+	Result    *mcp.GetPromptResult
+	Success   bool
+	StartTime time.Time
+	ExecTime  int64 // milliseconds
+
+	// Tags and Attributes are the ones the handler set with SetTag and
+	// SetEventAttribute, Metadata the one attached with WithEventMetadata
+	Tags       map[string]string
+	Attributes map[string]any
+	Metadata   map[string]any
+}
+
+// PromptCallback is called with every completed prompt request
+type PromptCallback func(get *PromptGet)
+
+// promptPatcher is implemented by adapters that can track prompt requests
+// besides tool calls
+type promptPatcher interface {
+	PatchPrompts(pin SessionPinFunc, callback PromptCallback) error
+	ExtractPrompts() []string
+}
+
+// PatchPrompts tracks the requests of the server's prompts whose handlers
+// were wrapped with PromptHandler
+func (a *MCPGoAdapter) PatchPrompts(pin SessionPinFunc, callback PromptCallback) error {
+	if a.server == nil {
+		return fmt.Errorf("server is nil")
+	}
+	trackerFor(a.server).setPromptSink(pin, callback)
+	return nil
+}
+
+// ExtractPrompts returns the names of the server's prompts, sorted
+func (a *MCPGoAdapter) ExtractPrompts() []string {
+	if a.server == nil {
+		return nil
+	}
+	var names []string
+	listAll(a.server, string(mcp.MethodPromptsList), func(result *mcp.ListPromptsResult) mcp.Cursor {
+		for _, prompt := range result.Prompts {
+			names = append(names, prompt.Name)
+		}
+		return result.NextCursor
+	})
+	slices.Sort(names)
+	return names
+}
+
+// PromptHandler wraps the handler of a prompt to track its requests once its
+// server is tracked, mcp-go having no prompt middleware:
+//
+//	s.AddPrompt(prompt, agnost.PromptHandler(prompt, handler))
+func PromptHandler(prompt mcp.Prompt, handler server.PromptHandlerFunc) server.PromptHandlerFunc {
+	name := prompt.Name
+	return func(ctx context.Context, request mcp.GetPromptRequest) (*mcp.GetPromptResult, error) {
+		t := trackerFromContext(ctx)
+		if t == nil || !t.active() {
+			return handler(ctx, request)
+		}
+		pin, callback := t.promptSink()
+		if callback == nil {
+			return handler(ctx, request)
+		}
+		return wrapPromptHandler(name, handler, pin, callback, t.logger())(ctx, request)
+	}
+}
+
+// wrapPromptHandler wraps a prompt handler, reporting every request to the
+// callback. Like tool calls, a request fails if the handler returns an error;
+// it also fails if the handler returns no messages.
+func wrapPromptHandler(name string, handler server.PromptHandlerFunc, pin SessionPinFunc, callback PromptCallback, log *levelLogger) server.PromptHandlerFunc {
+	return func(ctx context.Context, request mcp.GetPromptRequest) (*mcp.GetPromptResult, error) {
+		startTime := time.Now()
+
+		// Per-request state handlers can read and update
+		ctx, state := withCallState(ctx, "prompt", name)
+		state.log = log
+
+		// Pin the session for the duration of the request
+		pinned, release := pinCall(ctx, pin, log)
+		defer guard(log, "session release", release)
+
+		report := func(result *mcp.GetPromptResult, err error) {
+			attributes := state.finish()
+			sessionID := pinned()
+			guard(log, "prompt callback", func() {
+				callback(&PromptGet{
+					PromptName:    name,
+					EventID:       state.eventID,
+					ParentEventID: state.parentEventID,
+					SessionID:     sessionID,
+					Arguments:     request.Params.Arguments,
+					Result:        result,
+					Success:       err == nil && result != nil && len(result.Messages) > 0,
+					StartTime:     startTime,
+					ExecTime:      time.Since(startTime).Milliseconds(),
+					Tags:          state.tagsSnapshot(),
+					Attributes:    attributes,
+					Metadata:      state.metadataSnapshot(),
+				})
+			})
+		}
+
+		// Record requests whose handler panics on the way out; the panic propagates
+		returned := false
+		defer func() {
+			if !returned {
+				report(nil, errHandlerPanicked)
+			}
+		}()
+
+		result, err := handler(ctx, request)
+		returned = true
+		report(result, err)
+		return result, err
+	}
+}
+
+// promptCallback records a prompt request as a "prompt" event named after the
+// prompt, with its arguments as input and the returned messages as output
+func (a *AgnostAnalytics) promptCallback(get *PromptGet) {
+	var args, result any
+	if get.Arguments != nil {
+		args = get.Arguments
+	}
+	if get.Result != nil {
+		result = get.Result
+	}
+	err := a.recordEvent(&eventRecord{
+		eventID:       get.EventID,
+		parentEventID: get.ParentEventID,
+		sessionID:     get.SessionID,
+		primitiveType: "prompt",
+		primitiveName: get.PromptName,
+		args:          args,
+		latency:       get.ExecTime,
+		success:       get.Success,
+		result:        result,
+		tags:          get.Tags,
+		attributes:    get.Attributes,
+		metadata:      get.Metadata,
+	})
+	if err != nil && !errors.Is(err, errShuttingDown) {
+		a.logger().Warning("Failed to record event for prompt '%s': %v", get.PromptName, err)
+	}
+}
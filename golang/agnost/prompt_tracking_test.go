@@ -0,0 +1,92 @@
+package agnost
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+func TestPromptHandlerReportsRequests(t *testing.T) {
+	s := server.NewMCPServer("test", "1.0.0")
+	greet := mcp.NewPrompt("greet")
+	s.AddPrompt(greet, PromptHandler(greet, func(ctx context.Context, request mcp.GetPromptRequest) (*mcp.GetPromptResult, error) {
+		SetTag(ctx, "lang", "en")
+		return mcp.NewGetPromptResult("greeting", []mcp.PromptMessage{
+			mcp.NewPromptMessage(mcp.RoleUser, mcp.NewTextContent("hello "+request.Params.Arguments["name"])),
+		}), nil
+	}))
+	empty := mcp.NewPrompt("empty")
+	s.AddPrompt(empty, PromptHandler(empty, func(ctx context.Context, request mcp.GetPromptRequest) (*mcp.GetPromptResult, error) {
+		return mcp.NewGetPromptResult("nothing", nil), nil
+	}))
+
+	var gets []*PromptGet
+	if err := NewMCPGoAdapter(s).PatchPrompts(nil, func(get *PromptGet) { gets = append(gets, get) }); err != nil {
+		t.Fatal(err)
+	}
+	handleRequest(t, s, "prompts/get", map[string]any{"name": "greet", "arguments": map[string]any{"name": "ada"}})
+	handleRequest(t, s, "prompts/get", map[string]any{"name": "empty"})
+
+	if len(gets) != 2 {
+		t.Fatalf("reported %d prompt requests, want 2", len(gets))
+	}
+	if get := gets[0]; get.PromptName != "greet" || !get.Success || get.Arguments["name"] != "ada" || get.Tags["lang"] != "en" {
+		t.Errorf("greet reported as %+v", get)
+	}
+	if gets[1].Success {
+		t.Error("a prompt returning no messages was reported as successful")
+	}
+}
+
+func TestPromptHandlerReportsPanics(t *testing.T) {
+	var got *PromptGet
+	handler := wrapPromptHandler("broken", func(ctx context.Context, request mcp.GetPromptRequest) (*mcp.GetPromptResult, error) {
+		panic("broken")
+	}, nil, func(get *PromptGet) { got = get }, nil)
+
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Error("the handler's panic didn't propagate")
+			}
+		}()
+		handler(context.Background(), mcp.GetPromptRequest{})
+	}()
+	if got == nil || got.Success {
+		t.Errorf("panicking request reported as %+v, want a failure", got)
+	}
+}
+
+func TestPromptHandlerRunsUntrackedOnOtherServers(t *testing.T) {
+	tracked := server.NewMCPServer("tracked", "1.0.0")
+	reported := 0
+	if err := NewMCPGoAdapter(tracked).PatchPrompts(nil, func(get *PromptGet) { reported++ }); err != nil {
+		t.Fatal(err)
+	}
+	// The same wrapped handler, registered on a server that isn't tracked
+	other := server.NewMCPServer("other", "1.0.0")
+	prompt := mcp.NewPrompt("greet")
+	other.AddPrompt(prompt, PromptHandler(prompt, func(ctx context.Context, request mcp.GetPromptRequest) (*mcp.GetPromptResult, error) {
+		return mcp.NewGetPromptResult("greeting", nil), nil
+	}))
+	handleRequest(t, other, "prompts/get", map[string]any{"name": "greet"})
+	if reported != 0 {
+		t.Errorf("reported %d requests of an untracked server", reported)
+	}
+}
+
+func TestExtractPromptsListsThroughTheServer(t *testing.T) {
+	s := server.NewMCPServer("test", "1.0.0")
+	for _, name := range []string{"summarize", "greet"} {
+		s.AddPrompt(mcp.NewPrompt(name), func(ctx context.Context, request mcp.GetPromptRequest) (*mcp.GetPromptResult, error) {
+			return nil, errors.New("unused")
+		})
+	}
+	if got, want := NewMCPGoAdapter(s).ExtractPrompts(), []string{"greet", "summarize"}; !slices.Equal(got, want) {
+		t.Errorf("ExtractPrompts() = %v, want %v", got, want)
+	}
+}
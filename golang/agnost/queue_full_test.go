@@ -0,0 +1,147 @@
+package agnost
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// newFullQueue returns a processor blocking full-queue events for up to timeout,
+// with its queue of one full and its worker stuck sending until release is called
+func newFullQueue(t *testing.T, timeout time.Duration) (*EventProcessor, func()) {
+	unblock := make(chan struct{})
+	release := sync.OnceFunc(func() { close(unblock) })
+	blocked := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-unblock
+	}))
+	t.Cleanup(blocked.Close)
+
+	config := DefaultConfig()
+	config.Logger = NewLogger(io.Discard)
+	config.QueueSize = 1
+	config.BatchSize = 1
+	config.MaxRetries = 0
+	config.QueueFullPolicy = QueueFullBlock
+	config.QueueFullTimeout = timeout
+	ep := NewEventProcessor(blocked.URL, "org", config)
+	ep.drops = newDropCounter()
+	t.Cleanup(ep.Shutdown)
+	t.Cleanup(release)
+
+	ep.QueueEvent(&EventData{SessionID: "s", PrimitiveType: "tool", PrimitiveName: "first"})
+	if !waitUntil(func() bool { return len(ep.queue) == 0 }) {
+		t.Fatal("the worker didn't take the first event")
+	}
+	ep.QueueEvent(&EventData{SessionID: "s", PrimitiveType: "tool", PrimitiveName: "second"})
+	return ep, release
+}
+
+func TestBlockingQueueWaitsForRoom(t *testing.T) {
+	ep, release := newFullQueue(t, 2*time.Second)
+	time.AfterFunc(50*time.Millisecond, release)
+
+	start := time.Now()
+	ep.QueueEvent(&EventData{SessionID: "s", PrimitiveType: "tool", PrimitiveName: "third"})
+	if elapsed := time.Since(start); elapsed < 40*time.Millisecond {
+		t.Errorf("event was queued after %v, before there was room", elapsed)
+	}
+	if drops := ep.drops.snapshot(); len(drops) != 0 {
+		t.Errorf("got drops %v, want none", drops)
+	}
+}
+
+func TestBlockingQueueDropsAfterTheTimeout(t *testing.T) {
+	ep, _ := newFullQueue(t, 20*time.Millisecond)
+
+	start := time.Now()
+	ep.QueueEvent(&EventData{SessionID: "s", PrimitiveType: "tool", PrimitiveName: "third"})
+	if elapsed := time.Since(start); elapsed < 20*time.Millisecond {
+		t.Errorf("event was dropped after %v, before the timeout", elapsed)
+	}
+	if got := ep.drops.snapshot()[DropQueueFull]; got != 1 {
+		t.Errorf("counted %d drops of a full queue, want 1", got)
+	}
+}
+
+func TestBlockedEventIsDroppedOnShutdown(t *testing.T) {
+	ep, _ := newFullQueue(t, 5*time.Second)
+	time.AfterFunc(20*time.Millisecond, ep.cancel)
+
+	start := time.Now()
+	ep.QueueEvent(&EventData{SessionID: "s", PrimitiveType: "tool", PrimitiveName: "third"})
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("event waited %v for room after shutting down", elapsed)
+	}
+	if got := ep.drops.snapshot()[DropShutdown]; got != 1 {
+		t.Errorf("got drops %v, want 1 shutdown", ep.drops.snapshot())
+	}
+}
+
+func TestQueueFullTimeoutFollowsThePolicy(t *testing.T) {
+	config := DefaultConfig()
+	config.QueueFullTimeout = time.Second
+	if got := config.queueFullTimeout(); got != 0 {
+		t.Errorf("default policy waits %v, want 0", got)
+	}
+	config.QueueFullPolicy = QueueFullBlock
+	if got := config.queueFullTimeout(); got != time.Second {
+		t.Errorf("block policy waits %v, want the configured 1s", got)
+	}
+	config.QueueFullTimeout = 0
+	if got := config.queueFullTimeout(); got != defaultQueueFullTimeout {
+		t.Errorf("block policy without a timeout waits %v, want %v", got, defaultQueueFullTimeout)
+	}
+
+	config.QueueFullPolicy = "wait"
+	config.Logger = NewLogger(io.Discard)
+	capturePackageLogger(t)
+	s := server.NewMCPServer("test", "1.0.0")
+	if err := NewAgnostAnalytics().TrackMCP(s, "org", config); err == nil || !strings.Contains(err.Error(), "QueueFullPolicy") {
+		t.Errorf("unknown policy gave error %v", err)
+	}
+}
+
+func TestBlockingOnAFullQueueDoesntHoldTheLock(t *testing.T) {
+	held, received, release := newHeldCollector(t)
+	_, a, _ := newTrackedServer(t, func(config *AgnostConfig) {
+		config.SessionEndpoint = config.Endpoint
+		config.Endpoint = held.URL
+		config.EnableRequestQueuing = true
+		config.QueueSize = 1
+		config.BatchSize = 1
+		config.QueueFullPolicy = QueueFullBlock
+		config.QueueFullTimeout = 5 * time.Second
+	})
+
+	// The worker holds the first event and the queue the second
+	a.RecordEvent("tool", "first", nil, 1, true, nil)
+	<-received
+	a.RecordEvent("tool", "second", nil, 1, true, nil)
+
+	if err := a.RecordEvent("tool", "optional", nil, 1, true, nil, BestEffort()); err != ErrBackpressure {
+		t.Errorf("best-effort event on a congested pipeline got %v, want ErrBackpressure", err)
+	}
+	if drops := a.Stats().Drops; drops[DropBackpressure] != 1 || drops[DropQueueFull] != 0 {
+		t.Errorf("got drops %v, want one backpressure drop", drops)
+	}
+
+	go a.RecordEvent("tool", "third", nil, 1, true, nil)
+	identified := make(chan struct{})
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		a.Identify(UserIdentity{"user_id": "alice"})
+		close(identified)
+	}()
+	select {
+	case <-identified:
+	case <-time.After(time.Second):
+		t.Fatal("Identify waited for room in the queue")
+	}
+	release()
+}
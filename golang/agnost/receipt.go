@@ -0,0 +1,116 @@
+package agnost
+
+import (
+	"context"
+	"errors"
+	"sync"
+)
+
+// Event is a custom analytics event recorded with Submit
+type Event struct {
+	PrimitiveType string
+	PrimitiveName string
+	Args          any
+	Latency       int64 // milliseconds
+	Success       bool
+	Result        any
+}
+
+// Receipt resolves once a submitted event reaches a terminal state: delivered,
+// spooled for a later retry, or dropped. It holds only the outcome, never the
+// event. A nil Receipt reports a resolved, successful delivery, so callers not
+// interested in the outcome can ignore it.
+type Receipt struct {
+	once sync.Once
+	done chan struct{}
+	err  error
+}
+
+// resolvedDone is the Done channel of nil receipts
+var resolvedDone = func() chan struct{} {
+	done := make(chan struct{})
+	close(done)
+	return done
+}()
+
+func newReceipt() *Receipt {
+	return &Receipt{done: make(chan struct{})}
+}
+
+// Done returns a channel closed once the event reached a terminal state
+func (r *Receipt) Done() <-chan struct{} {
+	if r == nil {
+		return resolvedDone
+	}
+	return r.done
+}
+
+// Err returns why the event was not delivered, once Done is closed. It is nil
+// while the event is pending and for delivered or spooled events.
+func (r *Receipt) Err() error {
+	if r == nil {
+		return nil
+	}
+	select {
+	case <-r.done:
+		return r.err
+	default:
+		return nil
+	}
+}
+
+// resolve records the event's outcome; only the first outcome counts
+func (r *Receipt) resolve(err error) {
+	if r == nil {
+		return
+	}
+	r.once.Do(func() {
+		r.err = err
+		close(r.done)
+	})
+}
+
+// Submit records a custom event with the global analytics client, see
+// AgnostAnalytics.Submit
+func Submit(ctx context.Context, event Event, opts ...RecordOption) *Receipt {
+	return globalClient.Submit(ctx, event, opts...)
+}
+
+// Submit records a custom event like RecordEvent, without waiting for its
+// delivery, and returns a Receipt resolved with the delivery's outcome. Events
+// that can't be recorded, for instance with the BestEffort option while the
+// pipeline is congested or once Shutdown started, resolve immediately with the
+// reason. Without request queuing the event is sent before Submit returns,
+// bounded by ctx like with RecordEventContext.
+func (a *AgnostAnalytics) Submit(ctx context.Context, event Event, opts ...RecordOption) *Receipt {
+	var options recordOptions
+	for _, opt := range opts {
+		opt(&options)
+	}
+
+	receipt := newReceipt()
+	if options.bestEffort && !a.PipelineHealthy() {
+		a.drops.count(DropBackpressure)
+		receipt.resolve(ErrBackpressure)
+		return receipt
+	}
+
+	err := a.recordEvent(&eventRecord{
+		ctx:           ctx,
+		primitiveType: event.PrimitiveType,
+		primitiveName: event.PrimitiveName,
+		args:          event.Args,
+		latency:       event.Latency,
+		success:       event.Success,
+		result:        event.Result,
+		tags:          options.tags,
+		receipt:       receipt,
+	})
+	if err != nil {
+		if !errors.Is(err, errShuttingDown) {
+			a.logger().Debug("Failed to submit event %s/%s: %v", event.PrimitiveType, event.PrimitiveName, err)
+		}
+		receipt.resolve(err)
+	}
+	return receipt
+}
@@ -0,0 +1,107 @@
+package agnost
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+)
+
+// awaitReceipt waits for a receipt to resolve and returns its error
+func awaitReceipt(t *testing.T, receipt *Receipt) error {
+	t.Helper()
+	select {
+	case <-receipt.Done():
+		return receipt.Err()
+	case <-time.After(2 * time.Second):
+		t.Fatal("receipt didn't resolve")
+		return nil
+	}
+}
+
+func TestReceiptResolvesOnce(t *testing.T) {
+	var unset *Receipt
+	if err := awaitReceipt(t, unset); err != nil {
+		t.Errorf("nil receipt reports %v", err)
+	}
+
+	r := newReceipt()
+	if err := r.Err(); err != nil {
+		t.Errorf("pending receipt reports %v", err)
+	}
+	failure := errors.New("failed")
+	r.resolve(failure)
+	r.resolve(nil)
+	if err := awaitReceipt(t, r); err != failure {
+		t.Errorf("receipt reports %v, want the first outcome", err)
+	}
+}
+
+var submitted = Event{PrimitiveType: "custom", PrimitiveName: "job_done", Latency: 5, Success: true}
+
+func TestSubmitResolvesOnceDelivered(t *testing.T) {
+	for _, queued := range []bool{false, true} {
+		_, a, collector := newTrackedServer(t, func(config *AgnostConfig) {
+			config.EnableRequestQueuing = queued
+			config.BatchSize = 1
+		})
+		if err := awaitReceipt(t, a.Submit(context.Background(), submitted)); err != nil {
+			t.Errorf("queued %v: delivered event reports %v", queued, err)
+		}
+		if got := len(collector.Events("custom")); got != 1 {
+			t.Errorf("queued %v: got %d custom events, want 1", queued, got)
+		}
+	}
+}
+
+func TestSubmitResolvesAfterRetries(t *testing.T) {
+	events := newFlakyCollector(t, func(attempt int64, w http.ResponseWriter) bool {
+		if attempt > 2 {
+			return false
+		}
+		w.WriteHeader(http.StatusServiceUnavailable)
+		return true
+	})
+	_, a, _ := newTrackedServer(t, func(config *AgnostConfig) {
+		config.SessionEndpoint = config.Endpoint
+		config.Endpoint = events.URL
+		config.MaxRetries = 2
+		config.RetryDelay = time.Millisecond
+	})
+
+	if err := awaitReceipt(t, a.Submit(context.Background(), submitted)); err != nil {
+		t.Errorf("event delivered on retry reports %v", err)
+	}
+}
+
+func TestSubmitReportsWhyTheEventWasNotDelivered(t *testing.T) {
+	events := newFlakyCollector(t, func(attempt int64, w http.ResponseWriter) bool {
+		w.WriteHeader(http.StatusBadRequest)
+		return true
+	})
+	_, a, _ := newTrackedServer(t, func(config *AgnostConfig) {
+		config.SessionEndpoint = config.Endpoint
+		config.Endpoint = events.URL
+	})
+	if err := awaitReceipt(t, a.Submit(context.Background(), submitted)); err == nil {
+		t.Error("rejected event reports no error")
+	}
+
+	a.Shutdown()
+	if err := awaitReceipt(t, a.Submit(context.Background(), submitted)); !errors.Is(err, errShuttingDown) {
+		t.Errorf("event submitted after Shutdown reports %v", err)
+	}
+}
+
+func TestSubmitResolvesSampledOutEvents(t *testing.T) {
+	_, a, collector := newTrackedServer(t, func(config *AgnostConfig) {
+		config.SampleRate = 1e-12
+	})
+	if err := awaitReceipt(t, a.Submit(context.Background(), submitted)); err != nil {
+		t.Errorf("sampled out event reports %v", err)
+	}
+	if got := len(collector.Events("custom")); got != 0 {
+		t.Errorf("sent %d sampled out events", got)
+	}
+}
@@ -0,0 +1,82 @@
+package agnost
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+// newHeldCollector returns a collector holding every request until release is
+// called or the test ends, signalling each one it holds on received
+func newHeldCollector(t *testing.T) (collector *httptest.Server, received chan struct{}, release func()) {
+	received = make(chan struct{}, 16)
+	released := make(chan struct{})
+	release = sync.OnceFunc(func() { close(released) })
+	collector = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received <- struct{}{}
+		<-released
+	}))
+	t.Cleanup(collector.Close)
+	t.Cleanup(release)
+	return collector, received, release
+}
+
+func TestSynchronousSendsDontHoldTheLock(t *testing.T) {
+	held, received, release := newHeldCollector(t)
+	_, a, _ := newTrackedServer(t, func(config *AgnostConfig) {
+		config.SessionEndpoint = config.Endpoint
+		config.Endpoint = held.URL
+	})
+
+	recorded := make(chan error, 1)
+	go func() { recorded <- a.RecordEvent("tool", "echo", nil, 1, true, nil) }()
+	<-received
+
+	// Identify takes the lock for writing
+	identified := make(chan struct{})
+	go func() {
+		a.Identify(UserIdentity{"user_id": "alice"})
+		close(identified)
+	}()
+	select {
+	case <-identified:
+	case <-time.After(time.Second):
+		t.Fatal("Identify waited for an event send")
+	}
+
+	release()
+	if err := <-recorded; err != nil {
+		t.Errorf("recording the event failed: %v", err)
+	}
+}
+
+func TestShutdownWaitsForTheEventsBeingRecorded(t *testing.T) {
+	held, received, release := newHeldCollector(t)
+	_, a, _ := newTrackedServer(t, func(config *AgnostConfig) {
+		config.SessionEndpoint = config.Endpoint
+		config.Endpoint = held.URL
+	})
+
+	recorded := make(chan error, 1)
+	go func() { recorded <- a.RecordEvent("tool", "echo", nil, 1, true, nil) }()
+	<-received
+
+	shutDown := make(chan struct{})
+	go func() {
+		a.Shutdown()
+		close(shutDown)
+	}()
+	select {
+	case <-shutDown:
+		t.Fatal("Shutdown returned while an event was being sent")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	release()
+	<-shutDown
+	if err := <-recorded; err != nil {
+		t.Errorf("recording the event failed: %v", err)
+	}
+}
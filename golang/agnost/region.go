@@ -0,0 +1,55 @@
+package agnost
+
+import (
+	"cmp"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// defaultEndpoint is the Agnost Analytics API endpoint of the default region
+const defaultEndpoint = "https://api.agnost.ai"
+
+// regionEndpoints maps the regions that can be set in Config.Region to their
+// API endpoint
+var regionEndpoints = map[string]string{
+	"us": defaultEndpoint,
+	"eu": "https://api.eu.agnost.ai",
+}
+
+// normalizeRegion returns a region name in the form used by regionEndpoints
+func normalizeRegion(region string) string {
+	return strings.ToLower(strings.TrimSpace(region))
+}
+
+// resolveEndpoint returns the endpoint events are sent to. An explicit
+// Config.Endpoint wins over Config.Region; the default endpoint, as set by
+// DefaultConfig, counts as not set so that DefaultConfig can be combined with
+// a region. Without either, events go to the default endpoint.
+func resolveEndpoint(config *AgnostConfig, log *levelLogger) (string, error) {
+	region := normalizeRegion(config.Region)
+	if region == "" {
+		return cmp.Or(config.Endpoint, defaultEndpoint), nil
+	}
+
+	regionEndpoint, ok := regionEndpoints[region]
+	if !ok {
+		known := make([]string, 0, len(regionEndpoints))
+		for name := range regionEndpoints {
+			known = append(known, name)
+		}
+		slices.Sort(known)
+		return "", fmt.Errorf("unknown region %q, must be one of: %s", config.Region, strings.Join(known, ", "))
+	}
+
+	endpoint := strings.TrimRight(config.Endpoint, "/")
+	switch endpoint {
+	case "", defaultEndpoint:
+		return regionEndpoint, nil
+	case regionEndpoint:
+		return config.Endpoint, nil
+	default:
+		log.Warning("Endpoint %s is not the endpoint of region %q (%s); sending to the explicit endpoint", config.Endpoint, region, regionEndpoint)
+		return config.Endpoint, nil
+	}
+}
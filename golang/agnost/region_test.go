@@ -0,0 +1,50 @@
+package agnost
+
+import (
+	"io"
+	"testing"
+
+	"github.com/mark3labs/mcp-go/server"
+)
+
+func TestResolveEndpoint(t *testing.T) {
+	tests := []struct {
+		region, endpoint, want string
+	}{
+		{"", "", defaultEndpoint},
+		{"", "https://collector.example", "https://collector.example"},
+		{"eu", "", regionEndpoints["eu"]},
+		{" EU ", defaultEndpoint, regionEndpoints["eu"]},
+		{"eu", regionEndpoints["eu"] + "/", regionEndpoints["eu"] + "/"},
+		{"eu", "https://collector.example", "https://collector.example"},
+	}
+	log := newLevelLogger(NewLogger(io.Discard), "error")
+	for _, tt := range tests {
+		config := &AgnostConfig{Region: tt.region, Endpoint: tt.endpoint}
+		got, err := resolveEndpoint(config, log)
+		if err != nil || got != tt.want {
+			t.Errorf("region %q, endpoint %q: got %q, %v, want %q", tt.region, tt.endpoint, got, err, tt.want)
+		}
+	}
+
+	if _, err := resolveEndpoint(&AgnostConfig{Region: "mars"}, log); err == nil {
+		t.Error("accepted an unknown region")
+	}
+}
+
+func TestRegionEndpointSharesTheCapabilityProbe(t *testing.T) {
+	config := DefaultConfig()
+	config.Logger = NewLogger(io.Discard)
+	config.SkipValidation = true
+	config.Region = "eu"
+	config.SessionEndpoint = regionEndpoints["eu"]
+
+	a := NewAgnostAnalytics()
+	if err := a.Initialize(server.NewMCPServer("test", "1.0.0"), "org", config); err != nil {
+		t.Fatal(err)
+	}
+	defer a.Shutdown()
+	if a.sessionManager.capabilities != a.eventProcessor.capabilities {
+		t.Error("sessions and events probe the region's collector separately")
+	}
+}
@@ -0,0 +1,248 @@
+package agnost
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// ResourceRead describes a completed resources/read request
+type ResourceRead struct {
+	// Name is the URI of the static resource read, or the URI template of the
+	// resource template that served the read
+	Name string
+
+	// URI is the URI the client requested
+	URI string
+
+	// EventID identifies the read's event; ParentEventID is the event of the
+	// tracked call it was nested in, if any
+	EventID       string
+	ParentEventID string
+
+	// SessionID is the session pinned at read start, or empty if none was pinned
+	SessionID string
+
+	Contents  []mcp.ResourceContents
+	Success   bool
+	StartTime time.Time
+	ExecTime  int64 // milliseconds
+
+	// Tags and Attributes are the ones the handler set with SetTag and
+	// SetEventAttribute, Metadata the one attached with WithEventMetadata
+	Tags       map[string]string
+	Attributes map[string]any
+	Metadata   map[string]any
+}
+
+// ResourceCallback is called with every completed resource read
+type ResourceCallback func(read *ResourceRead)
+
+// resourcePatcher is implemented by adapters that can track resource reads
+// besides tool calls
+type resourcePatcher interface {
+	PatchResources(pin SessionPinFunc, callback ResourceCallback) error
+	ExtractResources() []string
+}
+
+// resourceContent describes one item of a resource read's contents, recorded
+// as the read's output in place of the contents themselves
+type resourceContent struct {
+	URI      string `json:"uri"`
+	MIMEType string `json:"mime_type,omitempty"`
+	Type     string `json:"type"` // "text" or "blob"
+	Size     int    `json:"size"` // bytes of text, or of base64 data for blobs
+}
+
+// resourceContentsSummary describes the contents of a resource read
+func resourceContentsSummary(contents []mcp.ResourceContents) []resourceContent {
+	summary := make([]resourceContent, 0, len(contents))
+	for _, content := range contents {
+		switch c := content.(type) {
+		case mcp.TextResourceContents:
+			summary = append(summary, resourceContent{URI: c.URI, MIMEType: c.MIMEType, Type: "text", Size: len(c.Text)})
+		case *mcp.TextResourceContents:
+			summary = append(summary, resourceContent{URI: c.URI, MIMEType: c.MIMEType, Type: "text", Size: len(c.Text)})
+		case mcp.BlobResourceContents:
+			summary = append(summary, resourceContent{URI: c.URI, MIMEType: c.MIMEType, Type: "blob", Size: len(c.Blob)})
+		case *mcp.BlobResourceContents:
+			summary = append(summary, resourceContent{URI: c.URI, MIMEType: c.MIMEType, Type: "blob", Size: len(c.Blob)})
+		}
+	}
+	return summary
+}
+
+// PatchResources tracks the reads of the server's resources whose handlers
+// were wrapped with ResourceMiddleware or ResourceTemplateHandler
+func (a *MCPGoAdapter) PatchResources(pin SessionPinFunc, callback ResourceCallback) error {
+	if a.server == nil {
+		return fmt.Errorf("server is nil")
+	}
+	trackerFor(a.server).setResourceSink(pin, callback)
+	return nil
+}
+
+// ExtractResources returns the URIs of the server's resources and the URI
+// templates of its resource templates, sorted
+func (a *MCPGoAdapter) ExtractResources() []string {
+	if a.server == nil {
+		return nil
+	}
+	var names []string
+	listAll(a.server, string(mcp.MethodResourcesList), func(result *mcp.ListResourcesResult) mcp.Cursor {
+		for _, resource := range result.Resources {
+			names = append(names, resource.URI)
+		}
+		return result.NextCursor
+	})
+	listAll(a.server, string(mcp.MethodResourcesTemplatesList), func(result *mcp.ListResourceTemplatesResult) mcp.Cursor {
+		for _, template := range result.ResourceTemplates {
+			names = append(names, templateName(&template))
+		}
+		return result.NextCursor
+	})
+	slices.Sort(names)
+	return names
+}
+
+// templateName names a resource template after its URI template
+func templateName(template *mcp.ResourceTemplate) string {
+	if template.URITemplate != nil && template.URITemplate.Template != nil {
+		return template.URITemplate.Raw()
+	}
+	return template.Name
+}
+
+// ResourceMiddleware returns a resource handler middleware tracking the reads
+// of the static resources of the tracked server it runs on, to pass to
+// server.WithResourceHandlerMiddleware when creating the server:
+//
+//	s := server.NewMCPServer("my-server", "1.0.0", server.WithResourceHandlerMiddleware(agnost.ResourceMiddleware()))
+//
+// mcp-go doesn't run it for resource templates; wrap their handlers with
+// ResourceTemplateHandler.
+func ResourceMiddleware() server.ResourceHandlerMiddleware {
+	return resourceMiddleware
+}
+
+func resourceMiddleware(next server.ResourceHandlerFunc) server.ResourceHandlerFunc {
+	return func(ctx context.Context, request mcp.ReadResourceRequest) ([]mcp.ResourceContents, error) {
+		return trackResourceRead(ctx, request, request.Params.URI, next)
+	}
+}
+
+// ResourceTemplateHandler wraps the handler of a resource template to track
+// its reads once its server is tracked:
+//
+//	s.AddResourceTemplate(template, agnost.ResourceTemplateHandler(template, handler))
+func ResourceTemplateHandler(template mcp.ResourceTemplate, handler server.ResourceTemplateHandlerFunc) server.ResourceTemplateHandlerFunc {
+	name := templateName(&template)
+	plain := server.ResourceHandlerFunc(handler)
+	return func(ctx context.Context, request mcp.ReadResourceRequest) ([]mcp.ResourceContents, error) {
+		return trackResourceRead(ctx, request, name, plain)
+	}
+}
+
+// trackResourceRead runs handler, reporting the read of the named resource if
+// the server in ctx is tracked
+func trackResourceRead(ctx context.Context, request mcp.ReadResourceRequest, name string, handler server.ResourceHandlerFunc) ([]mcp.ResourceContents, error) {
+	t := trackerFromContext(ctx)
+	if t == nil || !t.active() {
+		return handler(ctx, request)
+	}
+	pin, callback := t.resourceSink()
+	if callback == nil {
+		return handler(ctx, request)
+	}
+	return wrapResourceHandler(name, handler, pin, callback, t.logger())(ctx, request)
+}
+
+// wrapResourceHandler wraps the handler of a resource or resource template,
+// reporting every read to the callback
+func wrapResourceHandler(name string, handler server.ResourceHandlerFunc, pin SessionPinFunc, callback ResourceCallback, log *levelLogger) server.ResourceHandlerFunc {
+	return func(ctx context.Context, request mcp.ReadResourceRequest) ([]mcp.ResourceContents, error) {
+		startTime := time.Now()
+
+		// Per-read state handlers can read and update
+		ctx, state := withCallState(ctx, "resource", name)
+		state.log = log
+
+		// Pin the session for the duration of the read
+		pinned, release := pinCall(ctx, pin, log)
+		defer guard(log, "session release", release)
+
+		report := func(contents []mcp.ResourceContents, err error) {
+			attributes := state.finish()
+			sessionID := pinned()
+			guard(log, "resource callback", func() {
+				callback(&ResourceRead{
+					Name:          name,
+					URI:           request.Params.URI,
+					EventID:       state.eventID,
+					ParentEventID: state.parentEventID,
+					SessionID:     sessionID,
+					Contents:      contents,
+					Success:       err == nil,
+					StartTime:     startTime,
+					ExecTime:      time.Since(startTime).Milliseconds(),
+					Tags:          state.tagsSnapshot(),
+					Attributes:    attributes,
+					Metadata:      state.metadataSnapshot(),
+				})
+			})
+		}
+
+		// Record reads whose handler panics on the way out; the panic propagates
+		returned := false
+		defer func() {
+			if !returned {
+				report(nil, errHandlerPanicked)
+			}
+		}()
+
+		contents, err := handler(ctx, request)
+		returned = true
+		report(contents, err)
+		return contents, err
+	}
+}
+
+// resourceCallback records a resource read as a "resource" event named after
+// the resource, with the requested URI as input and a description of the
+// contents as output. The URIs of the contents are scrubbed like the input.
+func (a *AgnostAnalytics) resourceCallback(read *ResourceRead) {
+	var result any
+	if read.Success {
+		summary := resourceContentsSummary(read.Contents)
+		a.mu.RLock()
+		if a.config != nil {
+			for i := range summary {
+				summary[i].URI, _ = scrubResourceURI(summary[i].URI, a.config.ResourceQueryAllowlist)
+			}
+		}
+		a.mu.RUnlock()
+		result = summary
+	}
+	err := a.recordEvent(&eventRecord{
+		eventID:       read.EventID,
+		parentEventID: read.ParentEventID,
+		sessionID:     read.SessionID,
+		primitiveType: "resource",
+		primitiveName: read.Name,
+		args:          read.URI,
+		latency:       read.ExecTime,
+		success:       read.Success,
+		result:        result,
+		tags:          read.Tags,
+		attributes:    read.Attributes,
+		metadata:      read.Metadata,
+	})
+	if err != nil && !errors.Is(err, errShuttingDown) {
+		a.logger().Warning("Failed to record event for resource '%s': %v", read.Name, err)
+	}
+}
@@ -0,0 +1,115 @@
+package agnost
+
+import (
+	"context"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// resourceServer returns a server with a static resource behind the SDK's
+// middleware and a wrapped resource template
+func resourceServer() *server.MCPServer {
+	s := server.NewMCPServer("test", "1.0.0", server.WithResourceHandlerMiddleware(ResourceMiddleware()))
+	s.AddResource(mcp.NewResource("config://app", "config"), func(ctx context.Context, request mcp.ReadResourceRequest) ([]mcp.ResourceContents, error) {
+		return []mcp.ResourceContents{mcp.TextResourceContents{URI: request.Params.URI, Text: "{}"}}, nil
+	})
+	template := mcp.NewResourceTemplate("file://{path}", "files")
+	s.AddResourceTemplate(template, ResourceTemplateHandler(template, func(ctx context.Context, request mcp.ReadResourceRequest) ([]mcp.ResourceContents, error) {
+		return []mcp.ResourceContents{mcp.TextResourceContents{URI: request.Params.URI, Text: "hello"}}, nil
+	}))
+	return s
+}
+
+func TestResourceReadsAreTrackedWithoutReflection(t *testing.T) {
+	s := resourceServer()
+	var mu sync.Mutex
+	var reads []*ResourceRead
+	err := NewMCPGoAdapter(s).PatchResources(nil, func(read *ResourceRead) {
+		mu.Lock()
+		reads = append(reads, read)
+		mu.Unlock()
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	handleRequest(t, s, "resources/read", map[string]any{"uri": "config://app"})
+	handleRequest(t, s, "resources/read", map[string]any{"uri": "file://notes.txt"})
+
+	if len(reads) != 2 {
+		t.Fatalf("reported %d reads, want 2", len(reads))
+	}
+	if reads[0].Name != "config://app" || !reads[0].Success {
+		t.Errorf("static resource read reported as %+v", reads[0])
+	}
+	if reads[1].Name != "file://{path}" || reads[1].URI != "file://notes.txt" {
+		t.Errorf("template read reported as %s of %s, want file://{path} of file://notes.txt", reads[1].Name, reads[1].URI)
+	}
+}
+
+func TestResourceHandlersRunUntrackedBeforeTrack(t *testing.T) {
+	s := resourceServer()
+	handleRequest(t, s, "resources/read", map[string]any{"uri": "file://notes.txt"})
+	if _, tracked := toolTrackers.Load(s); tracked {
+		t.Error("reading a resource of an untracked server created its tracker")
+	}
+}
+
+func TestExtractResourcesListsThroughTheServer(t *testing.T) {
+	s := resourceServer()
+	// A page limit makes the listing take several requests
+	server.WithPaginationLimit(1)(s)
+	s.AddResource(mcp.NewResource("config://db", "db"), func(ctx context.Context, request mcp.ReadResourceRequest) ([]mcp.ResourceContents, error) {
+		return nil, nil
+	})
+
+	want := []string{"config://app", "config://db", "file://{path}"}
+	if got := NewMCPGoAdapter(s).ExtractResources(); !slices.Equal(got, want) {
+		t.Errorf("ExtractResources() = %v, want %v", got, want)
+	}
+	if got := NewMCPGoAdapter(server.NewMCPServer("empty", "1.0.0")).ExtractResources(); len(got) != 0 {
+		t.Errorf("ExtractResources() of a server without resources = %v", got)
+	}
+}
+
+func TestInventoryListingsAreNotCountedAsAdvertised(t *testing.T) {
+	collector := newSessionCollector(t, 0)
+	a := NewAgnostAnalytics()
+	s := server.NewMCPServer("test", "1.0.0", server.WithHooks(a.Hooks()))
+	s.AddResource(mcp.NewResource("config://app", "config"), func(ctx context.Context, request mcp.ReadResourceRequest) ([]mcp.ResourceContents, error) {
+		return nil, nil
+	})
+	adapter := NewMCPGoAdapter(s)
+	a.serverAdapter = adapter
+	a.sessionManager = newTestSessionManager(collector.URL, nil)
+	a.sessionManager.adapter = adapter
+	a.initialized = true
+
+	// The listings happen while the session is created, so counting them
+	// would also wait for the session being created
+	created := make(chan error, 1)
+	var sessionID string
+	go func() {
+		var err error
+		sessionID, err = a.sessionManager.GetOrCreateSession(adapter.GetSessionInfo())
+		created <- err
+	}()
+	select {
+	case err := <-created:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("session creation deadlocked on its own inventory listing")
+	}
+	var summary SessionSummary
+	a.sessionManager.byID[sessionID].resources.summarize(&summary)
+	if summary.ResourcesAdvertised != 0 {
+		t.Errorf("advertised %d resources before any client listed them", summary.ResourcesAdvertised)
+	}
+}
@@ -0,0 +1,158 @@
+package agnost
+
+import (
+	"context"
+	"sync"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// maxTrackedResources caps the distinct resource URIs remembered per session;
+// reads of further URIs are counted as overflow
+const maxTrackedResources = 256
+
+// resourceUsage tracks how many resources a session was offered and which of
+// them it read
+type resourceUsage struct {
+	mu sync.Mutex
+
+	// listedResources and listedTemplates are the sizes of the latest resource
+	// and template listings, summed over their pages
+	listedResources int
+	listedTemplates int
+
+	read     map[string]struct{} // distinct URIs read, at most maxTrackedResources
+	overflow int64               // reads of URIs beyond the cap
+}
+
+// listed records a page of a resource or template listing. The first page
+// replaces the previous listing.
+func (u *resourceUsage) listed(count *int, items int, firstPage bool) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	if firstPage {
+		*count = 0
+	}
+	*count += items
+}
+
+// readURI records a read of uri, counting each URI once
+func (u *resourceUsage) readURI(uri string) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	if _, seen := u.read[uri]; seen {
+		return
+	}
+	if len(u.read) >= maxTrackedResources {
+		u.overflow++
+		return
+	}
+	if u.read == nil {
+		u.read = make(map[string]struct{})
+	}
+	u.read[uri] = struct{}{}
+}
+
+// summarize adds the resource usage to a session summary
+func (u *resourceUsage) summarize(summary *SessionSummary) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	summary.ResourcesAdvertised = u.listedResources + u.listedTemplates
+	summary.ResourcesReadDistinct = len(u.read)
+	summary.ResourcesReadOverflow = u.overflow
+}
+
+// RecordResourcesListed records a page of the resource listing sent in the
+// session with the given ID
+func (sm *SessionManager) RecordResourcesListed(sessionID string, resources int, firstPage bool) {
+	sm.mu.RLock()
+	entry, exists := sm.byID[sessionID]
+	sm.mu.RUnlock()
+	if exists {
+		entry.resources.listed(&entry.resources.listedResources, resources, firstPage)
+	}
+}
+
+// RecordResourceTemplatesListed records a page of the resource template
+// listing sent in the session with the given ID
+func (sm *SessionManager) RecordResourceTemplatesListed(sessionID string, templates int, firstPage bool) {
+	sm.mu.RLock()
+	entry, exists := sm.byID[sessionID]
+	sm.mu.RUnlock()
+	if exists {
+		entry.resources.listed(&entry.resources.listedTemplates, templates, firstPage)
+	}
+}
+
+// RecordResourceRead records that the session with the given ID read a resource
+func (sm *SessionManager) RecordResourceRead(sessionID string, uri string) {
+	sm.mu.RLock()
+	entry, exists := sm.byID[sessionID]
+	sm.mu.RUnlock()
+	if exists {
+		entry.resources.readURI(uri)
+	}
+}
+
+// HookResources registers hooks tracking resource usage with the global
+// analytics client, see AgnostAnalytics.HookResources
+func HookResources(hooks *server.Hooks) {
+	globalClient.HookResources(hooks)
+}
+
+// HookResources registers hooks that track, per session, how many resources
+// and resource templates the server listed and how many distinct resources
+// were read, reported in the session-end summary. mcp-go only accepts hooks
+// when the server is created, so pass the same hooks to server.WithHooks:
+//
+//	hooks := &server.Hooks{}
+//	agnost.HookResources(hooks)
+//	s := server.NewMCPServer("my-server", "1.0.0", server.WithHooks(hooks))
+func (a *AgnostAnalytics) HookResources(hooks *server.Hooks) {
+	hooks.AddAfterListResources(func(ctx context.Context, id any, message *mcp.ListResourcesRequest, result *mcp.ListResourcesResult) {
+		guard(a.logger(), "resource listing hook", func() {
+			if isInventory(ctx) {
+				return
+			}
+			if sessionID, ok := a.currentSessionID(); ok && result != nil {
+				a.sessionManager.RecordResourcesListed(sessionID, len(result.Resources), message.Params.Cursor == "")
+			}
+		})
+	})
+	hooks.AddAfterListResourceTemplates(func(ctx context.Context, id any, message *mcp.ListResourceTemplatesRequest, result *mcp.ListResourceTemplatesResult) {
+		guard(a.logger(), "resource template listing hook", func() {
+			if isInventory(ctx) {
+				return
+			}
+			if sessionID, ok := a.currentSessionID(); ok && result != nil {
+				a.sessionManager.RecordResourceTemplatesListed(sessionID, len(result.ResourceTemplates), message.Params.Cursor == "")
+			}
+		})
+	})
+	hooks.AddAfterReadResource(func(ctx context.Context, id any, message *mcp.ReadResourceRequest, result *mcp.ReadResourceResult) {
+		guard(a.logger(), "resource read hook", func() {
+			if sessionID, ok := a.currentSessionID(); ok {
+				a.sessionManager.RecordResourceRead(sessionID, message.Params.URI)
+			}
+		})
+	})
+}
+
+// currentSessionID returns the ID of the current session, or false when the
+// SDK is not tracking
+func (a *AgnostAnalytics) currentSessionID() (string, bool) {
+	if a.closing.Load() {
+		return "", false
+	}
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	if !a.initialized {
+		return "", false
+	}
+	sessionID, err := a.sessionManager.GetOrCreateSession(a.serverAdapter.GetSessionInfo())
+	if err != nil {
+		return "", false
+	}
+	return sessionID, true
+}
@@ -0,0 +1,98 @@
+package agnost
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"testing"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+func TestResourceUsageSummarizesTheLatestListingAndDistinctReads(t *testing.T) {
+	var u resourceUsage
+	u.listed(&u.listedResources, 3, true)
+	u.listed(&u.listedResources, 2, false)
+	u.listed(&u.listedTemplates, 1, true)
+	for _, uri := range []string{"a", "b", "a"} {
+		u.readURI(uri)
+	}
+
+	var summary SessionSummary
+	u.summarize(&summary)
+	if summary.ResourcesAdvertised != 6 || summary.ResourcesReadDistinct != 2 || summary.ResourcesReadOverflow != 0 {
+		t.Errorf("got %d advertised, %d read and %d overflow, want 6, 2 and 0",
+			summary.ResourcesAdvertised, summary.ResourcesReadDistinct, summary.ResourcesReadOverflow)
+	}
+
+	// A new listing replaces the previous one
+	u.listed(&u.listedResources, 1, true)
+	u.summarize(&summary)
+	if summary.ResourcesAdvertised != 2 {
+		t.Errorf("advertised %d resources after a new listing, want 2", summary.ResourcesAdvertised)
+	}
+}
+
+func TestResourceUsageCountsReadsPastTheCapAsOverflow(t *testing.T) {
+	var u resourceUsage
+	for i := range maxTrackedResources + 3 {
+		u.readURI(fmt.Sprint("file://", i))
+	}
+	u.readURI("file://0")
+
+	var summary SessionSummary
+	u.summarize(&summary)
+	if summary.ResourcesReadDistinct != maxTrackedResources || summary.ResourcesReadOverflow != 3 {
+		t.Errorf("got %d read and %d overflow, want %d and 3",
+			summary.ResourcesReadDistinct, summary.ResourcesReadOverflow, maxTrackedResources)
+	}
+}
+
+func TestSessionEndReportsAdvertisedAndReadResources(t *testing.T) {
+	collector := newEventCollector(t)
+	config := DefaultConfig()
+	config.Endpoint = collector.URL
+	config.Logger = NewLogger(io.Discard)
+	config.EnableRequestQueuing = false
+
+	a := NewAgnostAnalytics()
+	s := server.NewMCPServer("test", "1.0.0", server.WithHooks(a.Hooks()), server.WithPaginationLimit(2))
+	for _, uri := range []string{"config://app", "config://db", "config://cache"} {
+		s.AddResource(mcp.NewResource(uri, uri), func(ctx context.Context, request mcp.ReadResourceRequest) ([]mcp.ResourceContents, error) {
+			return []mcp.ResourceContents{mcp.TextResourceContents{URI: request.Params.URI, Text: "{}"}}, nil
+		})
+	}
+	s.AddResourceTemplate(mcp.NewResourceTemplate("file://{path}", "files"), func(ctx context.Context, request mcp.ReadResourceRequest) ([]mcp.ResourceContents, error) {
+		return []mcp.ResourceContents{mcp.TextResourceContents{URI: request.Params.URI, Text: "hello"}}, nil
+	})
+	if err := a.TrackMCP(s, "org", config); err != nil {
+		t.Fatal(err)
+	}
+
+	// List both pages of resources and the templates
+	var page struct {
+		Result mcp.ListResourcesResult `json:"result"`
+	}
+	response := s.HandleMessage(context.Background(), []byte(`{"jsonrpc":"2.0","id":1,"method":"resources/list"}`))
+	encoded, _ := json.Marshal(response)
+	json.Unmarshal(encoded, &page)
+	handleRequest(t, s, "resources/list", map[string]any{"cursor": string(page.Result.NextCursor)})
+	handleRequest(t, s, "resources/templates/list", nil)
+	for _, uri := range []string{"config://app", "file://notes.txt", "config://app"} {
+		handleRequest(t, s, "resources/read", map[string]any{"uri": uri})
+	}
+	a.Shutdown()
+
+	ends := collector.Ends()
+	if len(ends) != 1 {
+		t.Fatalf("got %d session ends, want 1", len(ends))
+	}
+	if got := ends[0].ResourcesAdvertised; got != 4 {
+		t.Errorf("advertised %d resources, want the 3 resources and 1 template", got)
+	}
+	if got := ends[0].ResourcesReadDistinct; got != 2 {
+		t.Errorf("read %d distinct resources, want 2", got)
+	}
+}
@@ -0,0 +1,254 @@
+package agnost
+
+import (
+	"sync"
+	"time"
+)
+
+const (
+	// rollupBucketWidth is the time span covered by each delivery bucket
+	rollupBucketWidth = time.Minute
+
+	// rollupBuckets is the number of buckets kept, covering the last 15 minutes
+	rollupBuckets = 15
+)
+
+// latencyBounds are the upper bounds of the delivery latency histogram bins;
+// a final bin holds everything slower
+var latencyBounds = [...]time.Duration{
+	10 * time.Millisecond,
+	25 * time.Millisecond,
+	50 * time.Millisecond,
+	100 * time.Millisecond,
+	250 * time.Millisecond,
+	500 * time.Millisecond,
+	time.Second,
+	2500 * time.Millisecond,
+	5 * time.Second,
+	10 * time.Second,
+	30 * time.Second,
+	time.Minute,
+	5 * time.Minute,
+}
+
+// deliveryOutcome is the terminal state of an event
+type deliveryOutcome int
+
+const (
+	outcomeDelivered deliveryOutcome = iota
+	outcomeFailed
+	outcomeDropped
+)
+
+// BucketStats contains the delivery outcomes of events that reached a terminal
+// state during one bucket
+type BucketStats struct {
+	// Start is the beginning of the bucket's time span. Buckets follow the
+	// monotonic clock, so after the machine slept Start lags the wall clock
+	// by the time asleep.
+	Start time.Time
+
+	// Sent counts events delivered, Failed events whose delivery failed and
+	// Dropped events discarded before delivery was attempted
+	Sent    int64
+	Failed  int64
+	Dropped int64
+}
+
+// deliveryBucket is one slot of the delivery rollup ring
+type deliveryBucket struct {
+	index   int64 // bucket number since the Unix epoch, identifies stale slots
+	sent    int64
+	failed  int64
+	dropped int64
+
+	// onTime counts events delivered within the delivery target, and latency
+	// bins delivered events by time from record to delivery
+	onTime  int64
+	latency [len(latencyBounds) + 1]int64
+}
+
+// deliveryRollup keeps fixed-size time buckets of event delivery outcomes.
+// Slots are reused as time moves on, so rotation never allocates. Buckets
+// follow the clock's steady time, so a wall-clock jump, such as waking from
+// sleep, neither expires every bucket nor inflates delivery latencies.
+type deliveryRollup struct {
+	mu      sync.Mutex
+	buckets [rollupBuckets]deliveryBucket
+	target  time.Duration // delivery latency counted as on time
+	clock   *clock
+}
+
+func newDeliveryRollup(c *clock) *deliveryRollup {
+	return &deliveryRollup{
+		target: defaultDeliveryTarget,
+		clock:  c,
+	}
+}
+
+// setTarget sets the delivery latency counted as on time, keeping the default
+// when target is not positive
+func (r *deliveryRollup) setTarget(target time.Duration) {
+	if target <= 0 {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.target = target
+}
+
+// bucketIndex returns the bucket number of t
+func bucketIndex(t time.Time) int64 {
+	return t.UnixNano() / int64(rollupBucketWidth)
+}
+
+// record counts the terminal outcome of an event. Its delivery latency is
+// measured from enqueuedMonotonic, the clock's monotonic time when the event
+// was recorded, or from enqueuedAt (unix milliseconds) for events recorded by
+// a previous process; a zero value means unknown.
+func (r *deliveryRollup) record(outcome deliveryOutcome, enqueuedAt int64, enqueuedMonotonic time.Duration) {
+	if r == nil {
+		return
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := r.clock.steady()
+	index := bucketIndex(now)
+	bucket := &r.buckets[index%rollupBuckets]
+	if bucket.index != index {
+		*bucket = deliveryBucket{index: index}
+	}
+
+	switch outcome {
+	case outcomeDelivered:
+		bucket.sent++
+		var latency time.Duration
+		if enqueuedMonotonic > 0 {
+			latency = max(r.clock.monotonic()-enqueuedMonotonic, 0)
+		} else if enqueuedAt > 0 {
+			latency = max(r.clock.wall().Sub(time.UnixMilli(enqueuedAt)), 0)
+		}
+		if latency <= r.target {
+			bucket.onTime++
+		}
+		bucket.latency[latencyBin(latency)]++
+	case outcomeFailed:
+		bucket.failed++
+	case outcomeDropped:
+		bucket.dropped++
+	}
+}
+
+// recent returns the buckets of the last 15 minutes, oldest first. Buckets
+// without any delivery are included so the result always spans the window.
+func (r *deliveryRollup) recent() []BucketStats {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	current := bucketIndex(r.clock.steady())
+	stats := make([]BucketStats, 0, rollupBuckets)
+	for index := current - rollupBuckets + 1; index <= current; index++ {
+		entry := BucketStats{
+			Start: time.Unix(0, index*int64(rollupBucketWidth)),
+		}
+		if bucket := r.buckets[index%rollupBuckets]; bucket.index == index {
+			entry.Sent = bucket.sent
+			entry.Failed = bucket.failed
+			entry.Dropped = bucket.dropped
+		}
+		stats = append(stats, entry)
+	}
+	return stats
+}
+
+// windowLocked returns the bucket numbers overlapping the last d, capped to
+// the buckets kept
+func (r *deliveryRollup) windowLocked(d time.Duration) (oldest int64, current int64) {
+	now := r.clock.steady()
+	current = bucketIndex(now)
+	oldest = bucketIndex(now.Add(-d))
+	if oldest <= current-rollupBuckets {
+		oldest = current - rollupBuckets + 1
+	}
+	return oldest, current
+}
+
+// deliveredWithin reports whether any event was delivered in the buckets
+// overlapping the last d
+func (r *deliveryRollup) deliveredWithin(d time.Duration) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	oldest, current := r.windowLocked(d)
+	for index := oldest; index <= current; index++ {
+		if bucket := r.buckets[index%rollupBuckets]; bucket.index == index && bucket.sent > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// report summarizes the delivery outcomes of the buckets overlapping the last window
+func (r *deliveryRollup) report(window time.Duration) DeliveryReport {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	report := DeliveryReport{
+		Window: window,
+		Target: r.target,
+	}
+	var latency [len(latencyBounds) + 1]int64
+	oldest, current := r.windowLocked(window)
+	for index := oldest; index <= current; index++ {
+		bucket := &r.buckets[index%rollupBuckets]
+		if bucket.index != index {
+			continue
+		}
+		report.Delivered += bucket.sent
+		report.Failed += bucket.failed
+		report.Dropped += bucket.dropped
+		report.OnTime += bucket.onTime
+		for i, count := range bucket.latency {
+			latency[i] += count
+		}
+	}
+
+	if total := report.Delivered + report.Failed + report.Dropped; total > 0 {
+		report.SuccessRatio = float64(report.OnTime) / float64(total)
+	}
+	report.P50 = latencyPercentile(&latency, report.Delivered, 0.50)
+	report.P90 = latencyPercentile(&latency, report.Delivered, 0.90)
+	report.P99 = latencyPercentile(&latency, report.Delivered, 0.99)
+	return report
+}
+
+// latencyBin returns the histogram bin of a latency
+func latencyBin(latency time.Duration) int {
+	for i, bound := range latencyBounds {
+		if latency <= bound {
+			return i
+		}
+	}
+	return len(latencyBounds)
+}
+
+// latencyPercentile returns the upper bound of the histogram bin holding the
+// pth fraction of count samples. Samples beyond the last bound report it.
+func latencyPercentile(bins *[len(latencyBounds) + 1]int64, count int64, p float64) time.Duration {
+	if count == 0 {
+		return 0
+	}
+	rank := int64(p*float64(count) + 0.5)
+	rank = max(rank, 1)
+
+	var seen int64
+	for i, binCount := range bins {
+		seen += binCount
+		if seen >= rank {
+			return latencyBounds[min(i, len(latencyBounds)-1)]
+		}
+	}
+	return latencyBounds[len(latencyBounds)-1]
+}
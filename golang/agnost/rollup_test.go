@@ -0,0 +1,113 @@
+package agnost
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+// manualClock is a clock whose wall and monotonic times only move when told
+type manualClock struct {
+	mu        sync.Mutex
+	now       time.Time
+	elapsed   time.Duration
+	wallDrift time.Duration
+}
+
+// newManualClock returns a clock starting at start and the controls moving it
+func newManualClock(start time.Time) (*clock, *manualClock) {
+	m := &manualClock{now: start}
+	return newClock(m.wall, m.monotonic), m
+}
+
+func (m *manualClock) wall() time.Time {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.now.Add(m.elapsed + m.wallDrift)
+}
+
+func (m *manualClock) monotonic() time.Duration {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.elapsed
+}
+
+// advance moves both clocks on by d
+func (m *manualClock) advance(d time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.elapsed += d
+}
+
+// jump moves the wall clock alone by d, as setting the system time does
+func (m *manualClock) jump(d time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.wallDrift += d
+}
+
+func TestDeliveryRollupBucketsOutcomesByMinute(t *testing.T) {
+	c, m := newManualClock(time.Date(2026, 1, 1, 12, 0, 30, 0, time.UTC))
+	r := newDeliveryRollup(c)
+
+	r.record(outcomeDelivered, 0, c.monotonic())
+	r.record(outcomeDelivered, 0, c.monotonic())
+	m.advance(3 * time.Minute)
+	r.record(outcomeFailed, 0, 0)
+	r.record(outcomeDropped, 0, 0)
+
+	stats := r.recent()
+	if len(stats) != rollupBuckets {
+		t.Fatalf("got %d buckets, want %d", len(stats), rollupBuckets)
+	}
+	current, earlier := stats[rollupBuckets-1], stats[rollupBuckets-4]
+	if current.Sent != 0 || current.Failed != 1 || current.Dropped != 1 {
+		t.Errorf("current bucket is %+v, want 1 failed and 1 dropped", current)
+	}
+	if earlier.Sent != 2 || earlier.Failed != 0 {
+		t.Errorf("bucket of 3 minutes ago is %+v, want 2 sent", earlier)
+	}
+	if !current.Start.Equal(time.Date(2026, 1, 1, 12, 3, 0, 0, time.UTC)) {
+		t.Errorf("current bucket starts at %v, want 12:03", current.Start)
+	}
+	for i := 1; i < len(stats); i++ {
+		if got := stats[i].Start.Sub(stats[i-1].Start); got != rollupBucketWidth {
+			t.Fatalf("buckets %d and %d are %v apart, want %v", i-1, i, got, rollupBucketWidth)
+		}
+	}
+
+	if r.deliveredWithin(time.Minute) {
+		t.Error("healthy within a minute of deliveries 3 minutes ago")
+	}
+	if !r.deliveredWithin(5 * time.Minute) {
+		t.Error("unhealthy within 5 minutes of deliveries 3 minutes ago")
+	}
+
+	// Buckets older than the ring expire
+	m.advance(rollupBuckets * time.Minute)
+	for _, bucket := range r.recent() {
+		if bucket.Sent != 0 || bucket.Failed != 0 || bucket.Dropped != 0 {
+			t.Fatalf("expired bucket %+v still counts outcomes", bucket)
+		}
+	}
+	if r.deliveredWithin(time.Hour) {
+		t.Error("healthy after every delivery expired")
+	}
+}
+
+func TestHealthyWithinFollowsTheClientsDeliveries(t *testing.T) {
+	c, m := newManualClock(time.Date(2026, 1, 1, 12, 0, 30, 0, time.UTC))
+	a := NewAgnostAnalytics()
+	a.deliveries = newDeliveryRollup(c)
+	if a.HealthyWithin(time.Minute) {
+		t.Error("healthy without any delivery")
+	}
+	a.deliveries.record(outcomeDelivered, 0, c.monotonic())
+	if !a.HealthyWithin(time.Minute) || a.RecentDeliveryStats()[rollupBuckets-1].Sent != 1 {
+		t.Error("the delivery isn't reported")
+	}
+	m.advance(10 * time.Minute)
+	if a.HealthyWithin(time.Minute) {
+		t.Error("healthy 10 minutes after the last delivery")
+	}
+}
@@ -0,0 +1,116 @@
+package agnost
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+)
+
+// PayloadSchemaVersion is the version of the payload schemas returned by
+// PayloadSchemas, recorded as schema_version in each document. It is bumped
+// with breaking changes to the payloads, not with added optional fields.
+const PayloadSchemaVersion = 1
+
+// payloadTypes lists the payloads the SDK sends, keyed like PayloadSchemas
+var payloadTypes = map[string]reflect.Type{
+	"event":          reflect.TypeOf(EventData{}),
+	"events":         reflect.TypeOf([]EventData{}),
+	"event_chunk":    reflect.TypeOf(EventChunk{}),
+	"session":        reflect.TypeOf(SessionData{}),
+	"sessions":       reflect.TypeOf([]SessionData{}),
+	"session_update": reflect.TypeOf(SessionUpdateData{}),
+	"session_end":    reflect.TypeOf(SessionEndData{}),
+}
+
+// PayloadSchemas returns a JSON Schema (draft 2020-12) document for every
+// payload the SDK sends, keyed by payload: event, events (the batch of
+// events), event_chunk, session, sessions (the batch of sessions),
+// session_update and session_end (the session summary). The schemas are generated from the Go types and their
+// JSON tags, so they can't drift from what the SDK serializes; fields tagged
+// omitempty are optional.
+func PayloadSchemas() map[string]json.RawMessage {
+	schemas := make(map[string]json.RawMessage, len(payloadTypes))
+	for name, t := range payloadTypes {
+		schema := typeSchema(t)
+		schema["$schema"] = "https://json-schema.org/draft/2020-12/schema"
+		schema["title"] = name
+		schema["schema_version"] = PayloadSchemaVersion
+		data, err := json.Marshal(schema)
+		if err != nil {
+			// The schemas are built from plain maps, which always marshal
+			panic(err)
+		}
+		schemas[name] = data
+	}
+	return schemas
+}
+
+// typeSchema returns the JSON Schema of the JSON encoding of values of type t
+func typeSchema(t reflect.Type) map[string]any {
+	switch t.Kind() {
+	case reflect.Pointer:
+		return typeSchema(t.Elem())
+	case reflect.String:
+		return map[string]any{"type": "string"}
+	case reflect.Bool:
+		return map[string]any{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]any{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]any{"type": "number"}
+	case reflect.Slice, reflect.Array:
+		return map[string]any{"type": "array", "items": typeSchema(t.Elem())}
+	case reflect.Map:
+		return map[string]any{"type": "object", "additionalProperties": typeSchema(t.Elem())}
+	case reflect.Struct:
+		properties := make(map[string]any)
+		required := []string{}
+		addStructFields(t, properties, &required)
+		return map[string]any{
+			"type":       "object",
+			"properties": properties,
+			"required":   required,
+		}
+	default:
+		// Interfaces hold any JSON value
+		return map[string]any{}
+	}
+}
+
+// addStructFields adds the serialized fields of struct type t to a schema's
+// properties, inlining embedded structs like encoding/json does
+func addStructFields(t reflect.Type, properties map[string]any, required *[]string) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag := field.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, options, _ := strings.Cut(tag, ",")
+		if field.Anonymous && name == "" && field.Type.Kind() == reflect.Struct {
+			addStructFields(field.Type, properties, required)
+			continue
+		}
+		if !field.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+
+		schema := typeSchema(field.Type)
+		omitEmpty := strings.Contains(options, "omitempty")
+		switch field.Type.Kind() {
+		case reflect.Pointer, reflect.Slice, reflect.Map:
+			// Nil values are serialized as null unless omitted
+			if !omitEmpty {
+				schema = map[string]any{"anyOf": []any{schema, map[string]any{"type": "null"}}}
+			}
+		}
+		properties[name] = schema
+		if !omitEmpty {
+			*required = append(*required, name)
+		}
+	}
+}
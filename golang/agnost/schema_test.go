@@ -0,0 +1,96 @@
+package agnost
+
+import (
+	"encoding/json"
+	"reflect"
+	"slices"
+	"testing"
+)
+
+// decodedSchema is the part of a JSON Schema object the tests inspect
+type decodedSchema struct {
+	Schema        string                     `json:"$schema"`
+	Title         string                     `json:"title"`
+	SchemaVersion int                        `json:"schema_version"`
+	Type          string                     `json:"type"`
+	Properties    map[string]json.RawMessage `json:"properties"`
+	Required      []string                   `json:"required"`
+	Items         *decodedSchema             `json:"items"`
+}
+
+func TestPayloadSchemasDescribeEveryPayload(t *testing.T) {
+	schemas := PayloadSchemas()
+	for name := range payloadTypes {
+		var schema decodedSchema
+		if err := json.Unmarshal(schemas[name], &schema); err != nil {
+			t.Errorf("%s: schema isn't valid JSON: %v", name, err)
+			continue
+		}
+		if schema.Title != name || schema.SchemaVersion != PayloadSchemaVersion || schema.Schema == "" {
+			t.Errorf("%s: got title %q and version %d", name, schema.Title, schema.SchemaVersion)
+		}
+	}
+	if len(schemas) != len(payloadTypes) {
+		t.Errorf("got %d schemas, want %d", len(schemas), len(payloadTypes))
+	}
+
+	var events decodedSchema
+	json.Unmarshal(schemas["events"], &events)
+	if events.Type != "array" || events.Items == nil || events.Items.Properties["primitive_name"] == nil {
+		t.Errorf("events schema isn't an array of events: %s", schemas["events"])
+	}
+}
+
+func TestSchemasFollowTheJSONTags(t *testing.T) {
+	type embedded struct {
+		Inner string `json:"inner"`
+	}
+	type payload struct {
+		embedded
+		Name     string            `json:"name"`
+		Optional int               `json:"optional,omitempty"`
+		Tags     map[string]string `json:"tags"`
+		Skipped  string            `json:"-"`
+		Untagged bool
+		Any      any `json:"any,omitempty"`
+		hidden   string
+	}
+	data, _ := json.Marshal(typeSchema(reflect.TypeOf(payload{})))
+	var schema struct {
+		decodedSchema
+		Properties map[string]map[string]any `json:"properties"`
+	}
+	json.Unmarshal(data, &schema)
+
+	var names []string
+	for name := range schema.Properties {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	if want := []string{"Untagged", "any", "inner", "name", "optional", "tags"}; !slices.Equal(names, want) {
+		t.Errorf("got properties %v, want %v", names, want)
+	}
+	slices.Sort(schema.Required)
+	if want := []string{"Untagged", "inner", "name", "tags"}; !slices.Equal(schema.Required, want) {
+		t.Errorf("got required %v, want %v", schema.Required, want)
+	}
+	if _, nullable := schema.Properties["tags"]["anyOf"]; !nullable {
+		t.Errorf("required map isn't nullable: %v", schema.Properties["tags"])
+	}
+	if got := schema.Properties["optional"]["type"]; got != "integer" {
+		t.Errorf("int field has type %v", got)
+	}
+	if len(schema.Properties["any"]) != 0 {
+		t.Errorf("interface field is constrained: %v", schema.Properties["any"])
+	}
+}
+
+func TestSessionEndSchemaInlinesTheSummary(t *testing.T) {
+	var schema decodedSchema
+	json.Unmarshal(PayloadSchemas()["session_end"], &schema)
+	for _, name := range []string{"session_id", "ended_at", "resources_advertised", "cache_hits"} {
+		if schema.Properties[name] == nil {
+			t.Errorf("session_end schema lacks %s", name)
+		}
+	}
+}
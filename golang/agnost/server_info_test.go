@@ -0,0 +1,63 @@
+package agnost
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestInstructionsHashIsStable(t *testing.T) {
+	config := DefaultConfig()
+	config.ServerInstructions = "foo"
+	// The hex SHA-256 of "foo", so the hash matches across processes and SDKs
+	const want = "2c26b46b68ffc68ff99b453c1d30413413422d706483bfa0f98a5e886266e7ae"
+	for range 3 {
+		if got := describeServer(nil, config).instructionsHash; got != want {
+			t.Fatalf("got instructions hash %q, want %q", got, want)
+		}
+	}
+
+	config.ServerInstructions = "foo "
+	if got := describeServer(nil, config).instructionsHash; got == want {
+		t.Error("different instructions hashed the same")
+	}
+	config.ServerInstructions = ""
+	if got := describeServer(nil, config); got.instructionsHash != "" || got.instructionsPreview != "" {
+		t.Errorf("server without instructions described as %+v", got)
+	}
+}
+
+func TestServerDescriptionPrefersTheConfig(t *testing.T) {
+	info := &ServerInfo{Name: "adapter", Version: "0.1.0", Instructions: "adapter instructions"}
+	config := DefaultConfig()
+	config.ServerName = "configured"
+	config.ServerInstructions = "configured instructions"
+
+	got := describeServer(info, config)
+	if got.name != "configured" || got.version != "0.1.0" {
+		t.Errorf("described the server as %q %q, want the configured name and the adapter's version", got.name, got.version)
+	}
+	if want := describeServer(nil, config).instructionsHash; got.instructionsHash != want {
+		t.Error("instructions hash isn't the configured instructions'")
+	}
+	if got.instructionsPreview != "" {
+		t.Errorf("captured preview %q without CaptureInstructionsPreview", got.instructionsPreview)
+	}
+
+	config.CaptureInstructionsPreview = true
+	config.ServerInstructions = strings.Repeat("é", 150)
+	if preview := describeServer(nil, config).instructionsPreview; len(preview) > instructionsPreviewBytes || !strings.HasPrefix(config.ServerInstructions, preview) {
+		t.Errorf("got preview of %d bytes, want a prefix of at most %d", len(preview), instructionsPreviewBytes)
+	}
+}
+
+func TestSessionsRecordTheConfiguredServerInfo(t *testing.T) {
+	_, _, collector := newTrackedServer(t, func(config *AgnostConfig) {
+		config.ServerName = "weather"
+		config.ServerVersion = "2.3.0"
+		config.ServerInstructions = "foo"
+	})
+	session := collector.Sessions()[0]
+	if session.ServerName != "weather" || session.ServerVersion != "2.3.0" || session.InstructionsHash == "" {
+		t.Errorf("session registered with server %q %q and instructions hash %q", session.ServerName, session.ServerVersion, session.InstructionsHash)
+	}
+}
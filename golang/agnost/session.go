@@ -2,25 +2,210 @@ package agnost
 
 import (
 	"bytes"
+	"cmp"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"io"
+	"log/slog"
+	"maps"
 	"net/http"
-	"os"
-	"strings"
+	"slices"
+	"strconv"
 	"sync"
+	"sync/atomic"
+	"time"
 )
 
+// sessionEntry is a cached session along with its activity counters
+type sessionEntry struct {
+	id        string
+	info      SessionInfo
+	createdAt time.Time
+
+	// data is the payload the session was registered with, kept to re-register
+	// it if the collector forgets the session; nil for resumed sessions until
+	// needed. Guarded by SessionManager.mu.
+	data *SessionData
+
+	// refs counts in-flight calls pinning this session; guarded by SessionManager.mu
+	refs    int
+	evicted bool
+	ended   bool // its session end was sent; guarded by SessionManager.mu
+
+	// adoptedBy is the client session that took the session over, see
+	// SessionManager.AdoptSession; guarded by SessionManager.mu
+	adoptedBy *SessionInfo
+
+	// userID is the user_id the session was last identified as, if any
+	userID string
+
+	// identity is the identity set with Identify; guarded by SessionManager.mu
+	identity UserIdentity
+
+	eventCount   atomic.Int64
+	firstEventAt atomic.Int64 // unix milliseconds, 0 until the first event
+	lastEventAt  atomic.Int64 // unix milliseconds, 0 until the first event
+
+	maxConcurrentCalls atomic.Int64
+
+	// firstAction is one more than the milliseconds from the session's
+	// creation to the first event other than the SDK's own, 0 until then. It
+	// is measured on the monotonic clock, so a wall-clock jump in between
+	// doesn't distort it.
+	firstAction atomic.Int64
+
+	cacheHits   atomic.Int64
+	cacheMisses atomic.Int64
+
+	denials denialCounter
+
+	resources resourceUsage
+}
+
+// storeMax raises v to n if n is larger
+func storeMax(v *atomic.Int64, n int64) {
+	for {
+		cur := v.Load()
+		if n <= cur || v.CompareAndSwap(cur, n) {
+			return
+		}
+	}
+}
+
+// recordEvent updates the activity counters for an event observed at t
+func (e *sessionEntry) recordEvent(t time.Time, concurrentCalls int64) {
+	ms := t.UnixMilli()
+	e.firstEventAt.CompareAndSwap(0, ms)
+	storeMax(&e.lastEventAt, ms)
+	storeMax(&e.maxConcurrentCalls, concurrentCalls)
+	e.eventCount.Add(1)
+}
+
+// recordAction records an event other than the SDK's own. For the session's
+// first one it returns the milliseconds since the session was created.
+func (e *sessionEntry) recordAction(t time.Time) (int64, bool) {
+	ms := max(t.Sub(e.createdAt).Milliseconds(), 0)
+	if !e.firstAction.CompareAndSwap(0, ms+1) {
+		return 0, false
+	}
+	return ms, true
+}
+
+// summary computes the activity summary of the session as of now
+func (e *sessionEntry) summary(now time.Time) SessionSummary {
+	count := e.eventCount.Load()
+	summary := SessionSummary{
+		SessionID:    e.id,
+		FirstEventAt: e.firstEventAt.Load(),
+		LastEventAt:  e.lastEventAt.Load(),
+		EventCount:   count,
+
+		MaxConcurrentCalls: e.maxConcurrentCalls.Load(),
+
+		CacheHits:   e.cacheHits.Load(),
+		CacheMisses: e.cacheMisses.Load(),
+	}
+	summary.CacheHitRatio = cacheHitRatio(summary.CacheHits, summary.CacheMisses)
+	summary.Denials = e.denials.snapshot()
+	if firstAction := e.firstAction.Load(); firstAction != 0 {
+		timeToFirst := firstAction - 1
+		summary.HadActivity = true
+		summary.TimeToFirstEventMs = &timeToFirst
+	}
+
+	// Rate is computed over the whole life of the session; sessions that
+	// never saw an event (or have no measurable lifetime) report zero
+	if lifetime := now.Sub(e.createdAt); count > 0 && lifetime > 0 {
+		summary.EventsPerMinute = float64(count) / lifetime.Minutes()
+	}
+	e.resources.summarize(&summary)
+
+	return summary
+}
+
 // SessionManager manages analytics sessions
 type SessionManager struct {
 	endpoint   string
 	orgID      string
 	httpClient *http.Client
 	config     *AgnostConfig
+	log        *levelLogger
+	apiKey     string
+	auth       *authMonitor
 	adapter    ServerAdapter
+	origins    *toolOrigins // labels set with SetToolOrigin, nil if none can be
 
 	mu       sync.RWMutex
-	sessions map[string]string // sessionKey -> sessionID
+	sessions map[string]*sessionEntry    // sessionKey -> session
+	byID     map[string]*sessionEntry    // sessionID -> session, including evicted but pinned ones
+	creating map[string]*sessionCreation // sessionKey -> session being created
+
+	identityFailures atomic.Int64
+
+	// updates tracks the session updates being sent in the background
+	updates sync.WaitGroup
+
+	// queuedIdentity is the identity of the next session created; guarded by mu
+	queuedIdentity UserIdentity
+
+	// identities caches the identities of tool calls, nil when disabled
+	identities *identityCache
+
+	// installation identifies this installation in derived identities; see installationID
+	installationOnce sync.Once
+	installation     string
+
+	state   *stateStore     // nil unless session resumption is enabled
+	batcher *sessionBatcher // nil unless session batching is enabled
+
+	capabilities *capabilityProbe
+	datagram     *datagramExporter // nil unless sessions are sent as datagrams
+
+	server serverDescription // recorded in every session
+
+	lifecycle *lifecycleNotifier // nil unless Config.OnSessionLifecycle is set
+}
+
+// defaultMaxToolsInSession is used when Config.MaxToolsInSession is not set
+const defaultMaxToolsInSession = 1000
+
+// instructionsPreviewBytes is the length of the preview recorded with
+// Config.CaptureInstructionsPreview
+const instructionsPreviewBytes = 200
+
+// serverDescription is the server metadata recorded in sessions
+type serverDescription struct {
+	name                string
+	version             string
+	instructionsHash    string
+	instructionsPreview string
+}
+
+// describeServer builds the session's server metadata from the config, or
+// from what the adapter reports for fields the config leaves unset
+func describeServer(info *ServerInfo, config *AgnostConfig) serverDescription {
+	if info == nil {
+		info = &ServerInfo{}
+	}
+	description := serverDescription{
+		name:    cmp.Or(config.ServerName, info.Name),
+		version: cmp.Or(config.ServerVersion, info.Version),
+	}
+
+	instructions := cmp.Or(config.ServerInstructions, info.Instructions)
+	if instructions == "" {
+		return description
+	}
+
+	hash := sha256.Sum256([]byte(instructions))
+	description.instructionsHash = hex.EncodeToString(hash[:])
+	if config.CaptureInstructionsPreview {
+		description.instructionsPreview = instructions[:runeBoundary(instructions, instructionsPreviewBytes)]
+	}
+	return description
 }
 
 // NewSessionManager creates a new session manager
@@ -31,14 +216,56 @@ func NewSessionManager(
 	config *AgnostConfig,
 	adapter ServerAdapter,
 ) *SessionManager {
-	return &SessionManager{
+	log := config.logger()
+	sm := &SessionManager{
 		endpoint:   endpoint,
 		orgID:      orgID,
 		httpClient: httpClient,
 		config:     config,
+		log:        log,
+		apiKey:     config.apiKey(),
+		auth:       &authMonitor{log: log},
 		adapter:    adapter,
-		sessions:   make(map[string]string),
+		sessions:   make(map[string]*sessionEntry),
+		byID:       make(map[string]*sessionEntry),
+		creating:   make(map[string]*sessionCreation),
+
+		capabilities: newCapabilityProbe(endpoint, orgID, config, httpClient),
+		identities:   newIdentityCache(config.IdentifyCacheTTL, config.IdentifyCacheSize),
+	}
+
+	var serverInfo *ServerInfo
+	if describer, ok := adapter.(serverDescriber); ok {
+		serverInfo = describer.GetServerInfo()
 	}
+	sm.server = describeServer(serverInfo, config)
+
+	if config.OnSessionLifecycle != nil {
+		sm.lifecycle = newLifecycleNotifier(config.OnSessionLifecycle, log)
+	}
+
+	if config.SessionBatchWindow > 0 {
+		sm.batcher = newSessionBatcher(sm, config.SessionBatchWindow, config.SessionBatchSize)
+	}
+
+	if config.SessionResumeWindow > 0 {
+		state, err := newStateStore(config.StateDir, orgID, log)
+		if err != nil {
+			sm.log.Warning("Session resumption disabled: %v", err)
+		} else {
+			sm.state = state
+		}
+	}
+
+	return sm
+}
+
+// sessionCreation is a session being created, which concurrent calls for the
+// same key wait for instead of creating one of their own
+type sessionCreation struct {
+	done chan struct{}
+	id   string
+	err  error
 }
 
 // GetOrCreateSession gets or creates a session for the given session info
@@ -52,112 +279,788 @@ func (sm *SessionManager) GetOrCreateSession(sessionInfo *SessionInfo) (string,
 
 	// Check if session exists
 	sm.mu.RLock()
-	sessionID, exists := sm.sessions[sessionInfo.SessionKey]
+	entry, exists := sm.sessions[sessionInfo.SessionKey]
 	sm.mu.RUnlock()
+	if exists && !sm.expired(entry) {
+		sm.log.Debug("Using existing session: %s", entry.id)
+		return entry.id, nil
+	}
 
-	if exists {
-		Debug("Using existing session: %s", sessionID)
-		return sessionID, nil
+	// Wait for another call creating it, or create it
+	sm.mu.Lock()
+	entry, exists = sm.sessions[sessionInfo.SessionKey]
+	expired := exists && sm.expired(entry)
+	if exists && !expired {
+		sm.mu.Unlock()
+		return entry.id, nil
+	}
+	if creation, pending := sm.creating[sessionInfo.SessionKey]; pending {
+		sm.mu.Unlock()
+		<-creation.done
+		return creation.id, creation.err
 	}
+	creation := &sessionCreation{done: make(chan struct{})}
+	sm.creating[sessionInfo.SessionKey] = creation
+	sm.mu.Unlock()
 
-	// Create new session
-	sessionID, err := sm.createSession(sessionInfo)
-	if err != nil {
-		return "", err
+	defer func() {
+		sm.mu.Lock()
+		if sm.creating[sessionInfo.SessionKey] == creation {
+			delete(sm.creating, sessionInfo.SessionKey)
+		}
+		sm.mu.Unlock()
+		close(creation.done)
+	}()
+	creation.id, creation.err = sm.createEntry(sessionInfo, entry, expired)
+	return creation.id, creation.err
+}
+
+// createEntry creates and caches the session of sessionInfo, replacing
+// previous if it expired
+func (sm *SessionManager) createEntry(sessionInfo *SessionInfo, previous *sessionEntry, expired bool) (string, error) {
+	if expired {
+		sm.expire(previous)
+	}
+
+	// Resume the previous process's session, or create a new one. An expired
+	// session is replaced, never resumed.
+	var sessionData *SessionData
+	var sessionID string
+	var resumed bool
+	if !expired {
+		sessionID, resumed = sm.resumeSession(sessionInfo)
+	}
+	if !resumed {
+		var err error
+		sessionData, err = sm.createSession(sessionInfo)
+		if err != nil {
+			return "", err
+		}
+		sessionID = sessionData.SessionID
+	}
+	if sm.state != nil {
+		sm.state.saveSession(sessionID, sessionInfo, time.Now())
 	}
 
 	// Store session
+	entry := &sessionEntry{
+		id:        sessionID,
+		info:      *sessionInfo,
+		createdAt: time.Now(),
+		data:      sessionData,
+	}
+	if sessionData != nil {
+		entry.userID = identityUserID(sessionData.UserData)
+	}
 	sm.mu.Lock()
-	sm.sessions[sessionInfo.SessionKey] = sessionID
+	sm.sessions[sessionInfo.SessionKey] = entry
+	sm.byID[sessionID] = entry
 	sm.mu.Unlock()
 
-	Info("Created new session: %s (key: %s)", sessionID, sessionInfo.SessionKey)
+	details := map[string]string{
+		"session_key": sessionInfo.SessionKey,
+		"client":      sessionInfo.ClientName,
+	}
+	if resumed {
+		sm.log.with(sessionAttrs(sessionID, sessionInfo.SessionKey)...).Info("Resumed session: %s (key: %s)", sessionID, sessionInfo.SessionKey)
+		sm.emitLifecycle(SessionLifecycleResumed, sessionID, details)
+	} else {
+		sm.log.with(sessionAttrs(sessionID, sessionInfo.SessionKey)...).Info("Created new session: %s (key: %s)", sessionID, sessionInfo.SessionKey)
+		sm.emitLifecycle(SessionLifecycleCreated, sessionID, details)
+	}
 	return sessionID, nil
 }
 
+// expired reports whether entry outlived Config.SessionTTL. createdAt never
+// changes, so no lock is needed.
+func (sm *SessionManager) expired(entry *sessionEntry) bool {
+	return sm.config.SessionTTL > 0 && time.Since(entry.createdAt) >= sm.config.SessionTTL
+}
+
+// expire removes an expired session from the cache under every key it is
+// cached under and ends it. Like Evict, it keeps sessions
+// pinned by in-flight calls reachable by ID until the last pin is released.
+// A session another call expired first is left alone.
+func (sm *SessionManager) expire(entry *sessionEntry) {
+	sm.mu.Lock()
+	var keys []string
+	for key, other := range sm.sessions {
+		if other == entry {
+			keys = append(keys, key)
+			delete(sm.sessions, key)
+		}
+	}
+	if len(keys) > 0 {
+		if entry.refs > 0 {
+			entry.evicted = true
+		} else {
+			delete(sm.byID, entry.id)
+		}
+	}
+	sm.mu.Unlock()
+
+	if len(keys) == 0 {
+		return
+	}
+	sm.identities.invalidateSession(entry.id)
+	sm.log.with(sessionAttrs(entry.id, entry.info.SessionKey)...).Info("Session expired: %s (key: %s)", entry.id, entry.info.SessionKey)
+	sm.emitLifecycle(SessionLifecycleExpired, entry.id, map[string]string{
+		"session_key": entry.info.SessionKey,
+		"age":         time.Since(entry.createdAt).Round(time.Second).String(),
+	})
+	sm.endSession(context.Background(), entry, time.Now())
+}
+
+// resumeSession reuses the session persisted by a previous process if it is
+// still within the resume window, notifying the backend of the resumption
+func (sm *SessionManager) resumeSession(sessionInfo *SessionInfo) (string, bool) {
+	if sm.state == nil {
+		return "", false
+	}
+
+	sessionID, ok := sm.state.resumableSession(sessionInfo, sm.config.SessionResumeWindow, time.Now())
+	if !ok {
+		return "", false
+	}
+
+	sm.sendSessionUpdate(&SessionUpdateData{
+		SessionID: sessionID,
+		Kind:      SessionUpdateResumed,
+		UpdatedAt: time.Now().UnixMilli(),
+	})
+	return sessionID, true
+}
+
+// sendSessionUpdate reports a change to an existing session
+func (sm *SessionManager) sendSessionUpdate(update *SessionUpdateData) {
+	status, body, err := sm.post("/api/v1/capture-session-update", update)
+	if err != nil {
+		sm.log.Warning("Failed to update session %s: %v", update.SessionID, err)
+		return
+	}
+	if status < 200 || status >= 300 {
+		sm.log.Warning("Session update failed with status %d: %s", status, string(body))
+		return
+	}
+	sm.log.with(slog.String("session_id", update.SessionID)).Debug("Session updated: %s (%s)", update.SessionID, update.Kind)
+}
+
+// PinSession gets or creates the session for the given session info and holds a
+// reference to it, so that the session stays cached until release is called even
+// if it is evicted in the meantime
+func (sm *SessionManager) PinSession(sessionInfo *SessionInfo) (string, func(), error) {
+	sessionID, err := sm.GetOrCreateSession(sessionInfo)
+	if err != nil {
+		return "", func() {}, err
+	}
+
+	sm.mu.Lock()
+	entry, exists := sm.byID[sessionID]
+	if exists {
+		entry.refs++
+	}
+	sm.mu.Unlock()
+
+	if !exists {
+		// Evicted between creation and pinning; the ID is still valid to report against
+		return sessionID, func() {}, nil
+	}
+
+	return sessionID, sync.OnceFunc(func() {
+		sm.mu.Lock()
+		defer sm.mu.Unlock()
+		entry.refs--
+		if entry.refs == 0 && entry.evicted {
+			delete(sm.byID, entry.id)
+		}
+	}), nil
+}
+
+// sessionPin is a session pinned by an in-flight call, see pinSession
+type sessionPin struct {
+	sm   *SessionManager
+	done chan struct{} // closed once id is set
+	id   string        // empty if the session couldn't be created
+
+	// Guarded by sm.mu
+	entry    *sessionEntry // the entry holding a reference, if any
+	resolved bool
+	released bool
+	waiting  []func(sessionID string)
+
+	releaseOnce sync.Once
+}
+
+// pinSession pins the session for the given session info like PinSession,
+// without waiting for it: a session that isn't cached yet is created in the
+// background and pinned once it is
+func (sm *SessionManager) pinSession(sessionInfo *SessionInfo) *sessionPin {
+	pin := &sessionPin{sm: sm, done: make(chan struct{})}
+
+	sm.mu.Lock()
+	var entry *sessionEntry
+	if sessionInfo != nil {
+		entry = sm.sessions[sessionInfo.SessionKey]
+	}
+	if entry != nil && !sm.expired(entry) {
+		waiting := pin.resolveLocked(entry.id)
+		sm.mu.Unlock()
+		pin.finish(waiting)
+		return pin
+	}
+	sm.mu.Unlock()
+
+	go func() {
+		var sessionID string
+		defer func() {
+			sm.mu.Lock()
+			waiting := pin.resolveLocked(sessionID)
+			sm.mu.Unlock()
+			pin.finish(waiting)
+		}()
+		guard(sm.log, "session creation", func() {
+			var err error
+			if sessionID, err = sm.GetOrCreateSession(sessionInfo); err != nil {
+				sm.log.Warning("Failed to pin session: %v", err)
+			}
+		})
+	}()
+	return pin
+}
+
+// resolveLocked sets the pinned session, taking a reference to it unless the
+// pin was released, and returns the functions waiting for it; the caller
+// holds sm.mu
+func (p *sessionPin) resolveLocked(sessionID string) []func(string) {
+	p.id = sessionID
+	p.resolved = true
+	if entry, ok := p.sm.byID[sessionID]; ok && !p.released {
+		entry.refs++
+		p.entry = entry
+	}
+	waiting := p.waiting
+	p.waiting = nil
+	return waiting
+}
+
+// finish runs the functions waiting for the session, then unblocks sessionID
+func (p *sessionPin) finish(waiting []func(string)) {
+	for _, f := range waiting {
+		guard(p.sm.log, "session pin callback", func() { f(p.id) })
+	}
+	close(p.done)
+}
+
+// sessionID returns the ID of the pinned session, waiting for it to be created
+func (p *sessionPin) sessionID() string {
+	<-p.done
+	return p.id
+}
+
+// then calls f with the ID of the pinned session once it is known, right away
+// if it is. Pending functions run before sessionID returns.
+func (p *sessionPin) then(f func(sessionID string)) {
+	p.sm.mu.Lock()
+	if !p.resolved {
+		p.waiting = append(p.waiting, f)
+		p.sm.mu.Unlock()
+		return
+	}
+	p.sm.mu.Unlock()
+	f(p.id)
+}
+
+// release drops the pin's reference to the session
+func (p *sessionPin) release() {
+	p.releaseOnce.Do(func() {
+		p.sm.mu.Lock()
+		defer p.sm.mu.Unlock()
+		p.released = true
+		if entry := p.entry; entry != nil {
+			p.entry = nil
+			entry.refs--
+			if entry.refs == 0 && entry.evicted {
+				delete(p.sm.byID, entry.id)
+			}
+		}
+	})
+}
+
+// AdoptSession keys the session cached under fallbackKey to sessionInfo as
+// well, unless sessionInfo already has a session or another client adopted
+// it first. The first client of a server thereby continues the session
+// created when tracking started instead of opening a second one. Clients with
+// tools of their own get a session of their own, since the session was
+// registered with the server's tools only.
+func (sm *SessionManager) AdoptSession(sessionInfo *SessionInfo, fallbackKey string) {
+	if len(sessionInfo.SessionTools) > 0 {
+		return
+	}
+
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+
+	if _, exists := sm.sessions[sessionInfo.SessionKey]; exists {
+		return
+	}
+	entry, exists := sm.sessions[fallbackKey]
+	if !exists || entry.adoptedBy != nil {
+		return
+	}
+	adoptedBy := *sessionInfo
+	entry.adoptedBy = &adoptedBy
+	sm.sessions[sessionInfo.SessionKey] = entry
+	sm.log.Debug("Session %s adopted by client session (key: %s)", entry.id, sessionInfo.SessionKey)
+}
+
+// Evict removes the session with the given key from the cache. Sessions pinned by
+// in-flight calls stay reachable by ID until the last pin is released.
+func (sm *SessionManager) Evict(sessionKey string) {
+	sm.evict(sessionKey)
+}
+
+// Disconnect evicts the session of a client that disconnected and ends it,
+// unless the session is still cached under another key
+func (sm *SessionManager) Disconnect(sessionKey string) {
+	if entry := sm.evict(sessionKey); entry != nil {
+		sm.endSession(context.Background(), entry, time.Now())
+	}
+}
+
+// evict is Evict, returning the evicted session if it left the cache
+// altogether
+func (sm *SessionManager) evict(sessionKey string) *sessionEntry {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+
+	entry, exists := sm.sessions[sessionKey]
+	if !exists {
+		return nil
+	}
+
+	delete(sm.sessions, sessionKey)
+	sm.emitLifecycle(SessionLifecycleEvicted, entry.id, map[string]string{"session_key": sessionKey})
+
+	// An adopted session stays cached under its other key
+	for _, other := range sm.sessions {
+		if other == entry {
+			return nil
+		}
+	}
+	if entry.refs > 0 {
+		entry.evicted = true
+	} else {
+		delete(sm.byID, entry.id)
+	}
+	sm.identities.invalidateSession(entry.id)
+	return entry
+}
+
 // createSession creates a new session via API
-func (sm *SessionManager) createSession(sessionInfo *SessionInfo) (string, error) {
-	// Extract tools from server
+func (sm *SessionManager) createSession(sessionInfo *SessionInfo) (*SessionData, error) {
+	sessionData := sm.newSessionData(generateSessionID(), sessionInfo)
+	sm.mu.Lock()
+	if sm.queuedIdentity != nil {
+		sessionData.UserData, sm.queuedIdentity = sm.queuedIdentity, nil
+	}
+	sm.mu.Unlock()
+
+	// Register in the background when batching; events use the local ID, which
+	// the collector can't replace then
+	if sm.batcher != nil {
+		sm.batcher.add(sessionData)
+		sm.log.Debug("Queued session registration: %s", sessionData.SessionID)
+		return sessionData, nil
+	}
+
+	// Send request
+	status, body, err := sm.post("/api/v1/capture-session", sessionData)
+	if err != nil {
+		sm.log.Error("failed to create session: %v", err)
+		return nil, fmt.Errorf("failed to create session: %v", err)
+	}
+
+	// Check status code
+	if status != http.StatusOK && status != http.StatusCreated {
+		sm.log.Warning("Session creation failed with status %d: %s", status, string(body))
+		// Return session ID anyway - we'll continue tracking events with it
+		sm.log.Debug("Using session ID %s despite creation failure", sessionData.SessionID)
+		return sessionData, nil
+	}
+
+	// Prefer the ID the collector assigned, as it may deduplicate or rewrite
+	// session IDs; without one in the response, the generated ID stays
+	if assigned := assignedSessionID(body); assigned != "" && assigned != sessionData.SessionID {
+		sm.log.Debug("Collector assigned session ID %s in place of %s", assigned, sessionData.SessionID)
+		sessionData.SessionID = assigned
+	}
+
+	sm.log.with(slog.String("session_id", sessionData.SessionID)).Info("Session created successfully: %s", sessionData.SessionID)
+	return sessionData, nil
+}
+
+// assignedSessionID returns the session ID of a session creation response, or
+// empty if the body is missing, malformed or has none
+func assignedSessionID(body []byte) string {
+	var response SessionResponse
+	if err := json.Unmarshal(body, &response); err != nil {
+		return ""
+	}
+	return response.SessionID
+}
+
+// resendSession re-registers a cached session the collector reported as unknown.
+// It returns false if the session is no longer cached or couldn't be registered.
+func (sm *SessionManager) resendSession(sessionID string) bool {
+	sm.mu.RLock()
+	entry, exists := sm.byID[sessionID]
+	var sessionData *SessionData
+	if exists {
+		sessionData = entry.data
+	}
+	sm.mu.RUnlock()
+
+	if !exists {
+		sm.log.Debug("Collector forgot session %s, which is no longer cached", sessionID)
+		return false
+	}
+
+	// Resumed sessions were registered by a previous process; rebuild their payload
+	if sessionData == nil {
+		sessionData = sm.newSessionData(sessionID, &entry.info)
+		sm.mu.Lock()
+		if entry.data == nil {
+			entry.data = sessionData
+		}
+		sm.mu.Unlock()
+	}
+
+	sm.log.Info("Collector forgot session %s, re-sending it", sessionID)
+	status, body, err := sm.post("/api/v1/capture-session", sessionData)
+	if err != nil {
+		sm.log.Warning("Failed to re-send session %s: %v", sessionID, err)
+		return false
+	}
+	if status != http.StatusOK && status != http.StatusCreated {
+		sm.log.Warning("Session re-send failed with status %d: %s", status, string(body))
+		return false
+	}
+	sm.emitLifecycle(SessionLifecycleReregistered, sessionID, nil)
+	return true
+}
+
+// newSessionData builds the registration payload of a session
+func (sm *SessionManager) newSessionData(sessionID string, sessionInfo *SessionInfo) *SessionData {
+	// Extract tools from server, capping the list sent on huge servers
 	var tools []string
+	var hashes map[string]string
 	if sm.adapter != nil {
 		tools = sm.adapter.ExtractTools()
 	}
+	if hasher, ok := sm.adapter.(toolHasher); ok {
+		hashes = hasher.ToolHashes()
+	}
 
-	// Get user identity if identify function is provided
-	var user UserIdentity
-	if sm.config.Identify != nil {
-		// Get environment variables
-		env := make(map[string]string)
-		for _, e := range os.Environ() {
-			pair := strings.SplitN(e, "=", 2)
-			if len(pair) == 2 {
-				env[pair[0]] = pair[1]
+	// Tools of the client session shadow the server's tools of the same name
+	var sessionTools []string
+	if len(sessionInfo.SessionTools) > 0 {
+		sessionTools = slices.Sorted(maps.Keys(sessionInfo.SessionTools))
+		tools = slices.DeleteFunc(tools, func(name string) bool {
+			_, shadowed := sessionInfo.SessionTools[name]
+			return shadowed
+		})
+		tools = append(tools, sessionTools...)
+		hashes = maps.Clone(hashes)
+		if hashes == nil {
+			hashes = make(map[string]string, len(sessionTools))
+		}
+		maps.Copy(hashes, sessionInfo.SessionTools)
+	}
+	toolCount := len(tools)
+	maxTools := sm.config.MaxToolsInSession
+	if maxTools <= 0 {
+		maxTools = defaultMaxToolsInSession
+	}
+	if toolCount > maxTools {
+		slices.Sort(tools)
+		tools = slices.Clip(tools[:maxTools])
+	}
+	var resources []string
+	if patcher, ok := sm.adapter.(resourcePatcher); ok {
+		resources = patcher.ExtractResources()
+		for i, name := range resources {
+			resources[i] = sm.config.captureName("resource", name)
+		}
+	}
+	var prompts []string
+	if patcher, ok := sm.adapter.(promptPatcher); ok {
+		prompts = patcher.ExtractPrompts()
+	}
+	resourceCount := len(resources)
+	if resourceCount > maxTrackedResources {
+		resources = slices.Clip(resources[:maxTrackedResources])
+	}
+	var toolHashes, toolOrigins map[string]string
+	for _, name := range tools {
+		if hash := hashes[name]; hash != "" {
+			if toolHashes == nil {
+				toolHashes = make(map[string]string, len(tools))
 			}
+			toolHashes[name] = hash
+		}
+		if origin := sm.origins.origin(sm.config, name); origin != ToolOriginNative {
+			if toolOrigins == nil {
+				toolOrigins = make(map[string]string)
+			}
+			toolOrigins[name] = origin
 		}
-
-		// TODO: Pass actual request when available in HTTP transport
-		user = sm.config.Identify(nil, env)
 	}
 
-	// Generate session ID
-	sessionID := generateSessionID()
+	// Get user identity if identify function is provided
+	var user UserIdentity
+	if identify := sm.config.identifyFunc(); identify != nil {
+		var err error
+		user, err = identify(nil, environ())
+		if err != nil {
+			sm.identityFailures.Add(1)
+			sm.log.Warning("Failed to identify user, creating session without identity: %v", err)
+			user = nil
+		}
+	} else if sm.config.DeriveAnonymousIdentity {
+		user = deriveIdentity(sm.installationID(), sessionInfo.ClientName)
+	}
 
 	// Prepare session data (matching Python SDK format)
-	sessionData := SessionData{
+	return &SessionData{
 		SessionID:      sessionID,
 		ClientConfig:   sessionInfo.ClientName,
-		ConnectionType: "",
+		ConnectionType: sm.config.ConnectionType,
 		IP:             "",
+		Region:         normalizeRegion(sm.config.Region),
 		UserData:       user,
 		Tools:          tools,
+		ToolCount:      toolCount,
+		SessionTools:   sessionTools,
+		ToolHashes:     toolHashes,
+		ToolOrigins:    toolOrigins,
+		Resources:      resources,
+		ResourceCount:  resourceCount,
+		Prompts:        prompts,
+
+		ServerName:          sm.server.name,
+		ServerVersion:       sm.server.version,
+		InstructionsHash:    sm.server.instructionsHash,
+		InstructionsPreview: sm.server.instructionsPreview,
+	}
+}
+
+// post sends a JSON payload to the given API path and returns the response status and body
+func (sm *SessionManager) post(path string, payload any) (int, []byte, error) {
+	return sm.postContext(context.Background(), path, payload)
+}
+
+// postContext is post, aborting the request once ctx is done
+func (sm *SessionManager) postContext(ctx context.Context, path string, payload any) (int, []byte, error) {
+	// Datagrams are fire-and-forget, so there is never a response to report
+	if sm.datagram != nil {
+		sm.datagram.send(path, payload)
+		return http.StatusOK, nil, nil
 	}
 
 	// Marshal to JSON
-	jsonData, err := json.Marshal(sessionData)
+	jsonData, err := json.Marshal(payload)
 	if err != nil {
-		return "", Errorf("failed to marshal session data: %v", err)
+		return 0, nil, fmt.Errorf("failed to marshal payload: %v", err)
 	}
 
 	// Create HTTP request
-	url := fmt.Sprintf("%s/api/v1/capture-session", sm.endpoint)
-	req, err := http.NewRequest("POST", url, bytes.NewBuffer(jsonData))
+	url := fmt.Sprintf("%s%s", sm.endpoint, path)
+	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewBuffer(jsonData))
 	if err != nil {
-		return "", Errorf("failed to create session request: %v", err)
+		return 0, nil, fmt.Errorf("failed to create request: %v", err)
 	}
 
+	addHeaders(req, sm.config.Headers)
 	req.Header.Set("Content-Type", "application/json")
 	req.Header.Set("X-Org-id", sm.orgID)
+	authorize(req, sm.apiKey)
 
 	// Send request
-	Debug("Creating session at %s with payload: %s", url, string(jsonData))
-	resp, err := sm.httpClient.Do(req)
+	sm.log.Debug("Sending request to %s with payload: %s", url, string(jsonData))
+	resp, err := doRequest(sm.httpClient, req, sm.config.requestTimeout())
 	if err != nil {
-		return "", Errorf("failed to create session: %v", err)
+		return 0, nil, err
 	}
 	defer resp.Body.Close()
+	sm.auth.observe(resp.StatusCode)
 
 	// Read response
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return "", Errorf("failed to read session response: %v", err)
+		return resp.StatusCode, nil, fmt.Errorf("failed to read response: %v", err)
 	}
 
-	// Check status code
-	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusCreated {
-		Warning("Session creation failed with status %d: %s", resp.StatusCode, string(body))
-		// Return session ID anyway - we'll continue tracking events with it
-		Debug("Using session ID %s despite creation failure", sessionID)
-		return sessionID, nil
+	return resp.StatusCode, body, nil
+}
+
+// RecordActivity records that an event was observed for the session with the given ID,
+// along with the number of tool calls in flight when it started
+func (sm *SessionManager) RecordActivity(sessionID string, at time.Time, concurrentCalls int64) {
+	sm.mu.RLock()
+	entry, exists := sm.byID[sessionID]
+	sm.mu.RUnlock()
+
+	if exists {
+		entry.recordEvent(at, concurrentCalls)
 	}
+}
 
-	Info("Session created successfully: %s", sessionID)
-	// Return the session ID we generated
-	return sessionID, nil
+// RecordAction records an event other than the SDK's own for the session with
+// the given ID. For the session's first one it returns the milliseconds since
+// the session was created.
+func (sm *SessionManager) RecordAction(sessionID string, at time.Time) (int64, bool) {
+	sm.mu.RLock()
+	entry, exists := sm.byID[sessionID]
+	sm.mu.RUnlock()
+	if !exists {
+		return 0, false
+	}
+	return entry.recordAction(at)
+}
+
+// RecordCacheOutcome counts a tool call of the session marked as a cache hit
+// or miss
+func (sm *SessionManager) RecordCacheOutcome(sessionID string, outcome string) {
+	sm.mu.RLock()
+	entry, exists := sm.byID[sessionID]
+	sm.mu.RUnlock()
+	if !exists {
+		return
+	}
+	switch outcome {
+	case CacheHit:
+		entry.cacheHits.Add(1)
+	case CacheMiss:
+		entry.cacheMisses.Add(1)
+	}
+}
+
+// Summaries returns the activity summaries of all cached sessions
+func (sm *SessionManager) Summaries() []SessionSummary {
+	now := time.Now()
+
+	sm.mu.RLock()
+	defer sm.mu.RUnlock()
+
+	summaries := make([]SessionSummary, 0, len(sm.byID))
+	for _, entry := range sm.byID {
+		summaries = append(summaries, entry.summary(now))
+	}
+	return summaries
+}
+
+// SessionCount returns the number of cached sessions, including evicted ones
+// still pinned by in-flight calls
+func (sm *SessionManager) SessionCount() int {
+	sm.mu.RLock()
+	defer sm.mu.RUnlock()
+	return len(sm.byID)
+}
+
+// IdentityFailures returns the number of times the identify function failed
+func (sm *SessionManager) IdentityFailures() int64 {
+	return sm.identityFailures.Load()
+}
+
+// IdentityCacheStats returns the hits and misses of the identity cache
+func (sm *SessionManager) IdentityCacheStats() (hits int64, misses int64) {
+	if sm.identities == nil {
+		return 0, 0
+	}
+	return sm.identities.hits.Load(), sm.identities.misses.Load()
+}
+
+// EndSessions sends a session-end payload with the activity summary of every cached session
+func (sm *SessionManager) EndSessions() {
+	sm.endSessions(context.Background())
+}
+
+// endSessions is EndSessions, skipping the session ends not sent by the time
+// ctx is done
+func (sm *SessionManager) endSessions(ctx context.Context) {
+	// Register pending sessions and send pending updates before ending them
+	if sm.batcher != nil {
+		sm.batcher.flush()
+	}
+	updated := make(chan struct{})
+	go func() {
+		sm.updates.Wait()
+		close(updated)
+	}()
+	select {
+	case <-updated:
+	case <-ctx.Done():
+	}
+
+	sm.mu.RLock()
+	entries := make([]*sessionEntry, 0, len(sm.byID))
+	for _, entry := range sm.byID {
+		entries = append(entries, entry)
+	}
+	sm.mu.RUnlock()
+
+	now := time.Now()
+	for _, entry := range entries {
+		// Refresh the resume window from the moment the process stops
+		if sm.state != nil {
+			sm.state.saveSession(entry.id, &entry.info, now)
+		}
+		sm.endSession(ctx, entry, now)
+	}
+}
+
+// endSession sends the session-end payload of entry, unless ctx is done or it
+// was ended before, such as an expired session still pinned at shutdown
+func (sm *SessionManager) endSession(ctx context.Context, entry *sessionEntry, now time.Time) {
+	sm.mu.Lock()
+	ended := entry.ended
+	entry.ended = true
+	sm.mu.Unlock()
+	if ended {
+		return
+	}
+
+	endData := SessionEndData{
+		SessionSummary: entry.summary(now),
+		EndedAt:        now.UnixMilli(),
+	}
+	sm.emitLifecycle(SessionLifecycleEnded, entry.id, map[string]string{
+		"event_count": strconv.FormatInt(endData.EventCount, 10),
+	})
+
+	if ctx.Err() != nil {
+		sm.log.Debug("Session end of %s skipped: %v", entry.id, ctx.Err())
+		return
+	}
+	status, body, err := sm.postContext(ctx, "/api/v1/capture-session-end", endData)
+	if err != nil {
+		sm.log.Warning("Failed to end session %s: %v", entry.id, err)
+		return
+	}
+	if status < 200 || status >= 300 {
+		sm.log.Warning("Session end failed with status %d: %s", status, string(body))
+		return
+	}
+
+	sm.log.with(slog.String("session_id", entry.id), slog.Int64("event_count", endData.EventCount)).
+		Debug("Session ended: %s (%d events)", entry.id, endData.EventCount)
 }
 
 // Clear clears all cached sessions
 func (sm *SessionManager) Clear() {
 	sm.mu.Lock()
 	defer sm.mu.Unlock()
-	sm.sessions = make(map[string]string)
+	sm.sessions = make(map[string]*sessionEntry)
+	sm.byID = make(map[string]*sessionEntry)
+	sm.identities.clear()
 }
@@ -0,0 +1,103 @@
+package agnost
+
+import (
+	"net/http"
+	"sync"
+	"time"
+)
+
+// defaultSessionBatchSize is used when Config.SessionBatchSize is not set
+const defaultSessionBatchSize = 50
+
+// sessionBatcher collects session registrations and sends them together, so a
+// burst of reconnecting clients produces a few requests instead of hundreds
+type sessionBatcher struct {
+	sm     *SessionManager
+	window time.Duration
+	size   int
+
+	mu      sync.Mutex
+	pending []*SessionData
+	timer   *time.Timer
+}
+
+func newSessionBatcher(sm *SessionManager, window time.Duration, size int) *sessionBatcher {
+	if size <= 0 {
+		size = defaultSessionBatchSize
+	}
+	return &sessionBatcher{
+		sm:     sm,
+		window: window,
+		size:   size,
+	}
+}
+
+// add queues a session registration, sending the batch once it is full or the
+// window since the first pending registration elapses
+func (b *sessionBatcher) add(session *SessionData) {
+	b.mu.Lock()
+	b.pending = append(b.pending, session)
+	if len(b.pending) < b.size {
+		if b.timer == nil {
+			b.timer = time.AfterFunc(b.window, b.flush)
+		}
+		b.mu.Unlock()
+		return
+	}
+	batch := b.take()
+	b.mu.Unlock()
+
+	go b.send(batch)
+}
+
+// take removes and returns the pending registrations; callers hold b.mu
+func (b *sessionBatcher) take() []*SessionData {
+	if b.timer != nil {
+		b.timer.Stop()
+		b.timer = nil
+	}
+	batch := b.pending
+	b.pending = nil
+	return batch
+}
+
+// flush sends all pending registrations
+func (b *sessionBatcher) flush() {
+	b.mu.Lock()
+	batch := b.take()
+	b.mu.Unlock()
+
+	b.send(batch)
+}
+
+// send registers a batch of sessions, falling back to one request per session
+// when the collector doesn't support batch registration
+func (b *sessionBatcher) send(batch []*SessionData) {
+	if len(batch) == 0 {
+		return
+	}
+
+	if len(batch) > 1 && b.sm.capabilities.supports(capabilitySessionBatch) {
+		status, body, err := b.sm.post("/api/v1/capture-sessions", batch)
+		if err == nil && status >= 200 && status < 300 {
+			b.sm.log.Debug("Registered batch of %d sessions", len(batch))
+			return
+		}
+		if err != nil {
+			b.sm.log.Warning("Session batch registration failed, sending individually: %v", err)
+		} else {
+			b.sm.log.Warning("Session batch registration failed with status %d, sending individually: %s", status, string(body))
+		}
+	}
+
+	for _, session := range batch {
+		status, body, err := b.sm.post("/api/v1/capture-session", session)
+		if err != nil {
+			b.sm.log.Warning("Failed to create session %s: %v", session.SessionID, err)
+			continue
+		}
+		if status != http.StatusOK && status != http.StatusCreated {
+			b.sm.log.Warning("Session creation failed with status %d: %s", status, string(body))
+		}
+	}
+}
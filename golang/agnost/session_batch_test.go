@@ -0,0 +1,126 @@
+package agnost
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+)
+
+// batchCollector records session registrations, as the sizes of the batches
+// and single registrations it received
+type batchCollector struct {
+	*httptest.Server
+
+	mu      sync.Mutex
+	batches []int    // sizes of the batches registered
+	singles []string // IDs of the sessions registered one at a time
+}
+
+// newBatchCollector returns a collector advertising batch registration if
+// batched, answering batches with batchStatus
+func newBatchCollector(t *testing.T, batched bool, batchStatus int) *batchCollector {
+	c := &batchCollector{}
+	c.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		switch r.URL.Path {
+		case "/api/v1/capabilities":
+			if batched {
+				json.NewEncoder(w).Encode(capabilitiesResponse{Capabilities: []string{capabilitySessionBatch}})
+			}
+		case "/api/v1/capture-sessions":
+			if batchStatus != http.StatusOK {
+				w.WriteHeader(batchStatus)
+				return
+			}
+			var batch []SessionData
+			json.NewDecoder(r.Body).Decode(&batch)
+			c.batches = append(c.batches, len(batch))
+		case "/api/v1/capture-session":
+			var session SessionData
+			json.NewDecoder(r.Body).Decode(&session)
+			c.singles = append(c.singles, session.SessionID)
+		}
+	}))
+	t.Cleanup(c.Close)
+	return c
+}
+
+// registered returns the batch sizes and the number of single registrations
+func (c *batchCollector) registered() ([]int, int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return slices.Clone(c.batches), len(c.singles)
+}
+
+// createSessions creates n sessions on a manager batching registrations
+func createSessions(t *testing.T, endpoint string, n int, window time.Duration, size int) *SessionManager {
+	sm := newTestSessionManager(endpoint, func(config *AgnostConfig) {
+		config.SessionBatchWindow = window
+		config.SessionBatchSize = size
+	})
+	for i := range n {
+		if _, err := sm.GetOrCreateSession(&SessionInfo{SessionKey: fmt.Sprintf("client:%d", i), ClientName: "test"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return sm
+}
+
+func TestSessionRegistrationsAreBatchedWithinTheWindow(t *testing.T) {
+	collector := newBatchCollector(t, true, http.StatusOK)
+	createSessions(t, collector.URL, 3, 50*time.Millisecond, 0)
+	if batches, _ := collector.registered(); len(batches) != 0 {
+		t.Fatalf("sent batches %v before the window elapsed", batches)
+	}
+	if !waitUntil(func() bool { batches, _ := collector.registered(); return len(batches) == 1 }) {
+		t.Fatal("the batch wasn't sent once the window elapsed")
+	}
+	if batches, singles := collector.registered(); batches[0] != 3 || singles != 0 {
+		t.Errorf("registered batches %v and %d single sessions, want one batch of 3", batches, singles)
+	}
+}
+
+func TestFullSessionBatchesAreSentEarly(t *testing.T) {
+	collector := newBatchCollector(t, true, http.StatusOK)
+	createSessions(t, collector.URL, 5, time.Hour, 2)
+	if !waitUntil(func() bool { batches, _ := collector.registered(); return len(batches) == 2 }) {
+		t.Fatal("full batches weren't sent before the window elapsed")
+	}
+	if batches, _ := collector.registered(); !slices.Equal(batches, []int{2, 2}) {
+		t.Errorf("registered batches %v, want two of 2 with the fifth pending", batches)
+	}
+}
+
+func TestSessionBatchesFallBackToSingleRegistrations(t *testing.T) {
+	for _, tt := range []struct {
+		name        string
+		batched     bool
+		batchStatus int
+	}{
+		{"collector without batches", false, http.StatusOK},
+		{"failing batch", true, http.StatusInternalServerError},
+		{"batch route missing", true, http.StatusNotFound},
+	} {
+		collector := newBatchCollector(t, tt.batched, tt.batchStatus)
+		sm := createSessions(t, collector.URL, 3, time.Hour, 0)
+		sm.batcher.flush()
+		if batches, singles := collector.registered(); len(batches) != 0 || singles != 3 {
+			t.Errorf("%s: registered batches %v and %d single sessions, want the 3 sessions one at a time", tt.name, batches, singles)
+		}
+	}
+}
+
+func TestEndingSessionsRegistersThePendingOnes(t *testing.T) {
+	collector := newBatchCollector(t, true, http.StatusOK)
+	sm := createSessions(t, collector.URL, 2, time.Hour, 0)
+	sm.EndSessions()
+	if batches, _ := collector.registered(); !slices.Equal(batches, []int{2}) {
+		t.Errorf("registered batches %v on ending the sessions, want the pending one of 2", batches)
+	}
+}
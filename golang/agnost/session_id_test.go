@@ -0,0 +1,47 @@
+package agnost
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// newAssigningCollector returns a collector answering session creations with
+// the given body
+func newAssigningCollector(t *testing.T, body string) *httptest.Server {
+	collector := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/api/v1/capture-session" {
+			w.Write([]byte(body))
+		}
+	}))
+	t.Cleanup(collector.Close)
+	return collector
+}
+
+func TestSessionsAdoptTheCollectorAssignedID(t *testing.T) {
+	collector := newAssigningCollector(t, `{"session_id":"collector-1"}`)
+	sm := newTestSessionManager(collector.URL, nil)
+	info := &SessionInfo{SessionKey: "client:1", ClientName: "test"}
+
+	id, err := sm.GetOrCreateSession(info)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id != "collector-1" {
+		t.Errorf("got session ID %q, want the one the collector assigned", id)
+	}
+	if again, _ := sm.GetOrCreateSession(info); again != id {
+		t.Errorf("the session is known as %q, then %q", id, again)
+	}
+}
+
+func TestSessionsKeepTheirIDWithoutAnAssignedOne(t *testing.T) {
+	for _, body := range []string{"", "not json", `{}`, `{"session_id":""}`} {
+		collector := newAssigningCollector(t, body)
+		sm := newTestSessionManager(collector.URL, nil)
+		id, err := sm.GetOrCreateSession(&SessionInfo{SessionKey: "client:1", ClientName: "test"})
+		if err != nil || id == "" {
+			t.Errorf("body %q: got session ID %q, %v, want the generated one", body, id, err)
+		}
+	}
+}
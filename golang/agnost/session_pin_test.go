@@ -0,0 +1,177 @@
+package agnost
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// WrapToolHandler keeps the signature it had before session pinning
+var _ func(string, server.ToolHandlerFunc, AnalyticsCallback) server.ToolHandlerFunc = WrapToolHandler
+
+func TestPinnedSessionOutlivesEviction(t *testing.T) {
+	collector := newSessionCollector(t, 0)
+	sm := newTestSessionManager(collector.URL, nil)
+	info := &SessionInfo{SessionKey: "client:1", ClientName: "test"}
+
+	sessionID, release, err := sm.PinSession(info)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sm.Evict(info.SessionKey)
+	if got := sm.SessionCount(); got != 1 {
+		t.Fatalf("evicting a pinned session left %d cached, want 1", got)
+	}
+	if _, first := sm.RecordAction(sessionID, time.Now()); !first {
+		t.Error("the evicted session's first action wasn't recorded")
+	}
+
+	release()
+	release()
+	if got := sm.SessionCount(); got != 0 {
+		t.Errorf("releasing the last pin left %d sessions cached, want 0", got)
+	}
+}
+
+func TestPinningDoesNotWaitForSessionCreation(t *testing.T) {
+	collector := newSessionCollector(t, 200*time.Millisecond)
+	sm := newTestSessionManager(collector.URL, nil)
+
+	start := time.Now()
+	pin := sm.pinSession(&SessionInfo{SessionKey: "client:1", ClientName: "test"})
+	defer pin.release()
+	if elapsed := time.Since(start); elapsed > 100*time.Millisecond {
+		t.Fatalf("pinning took %s, waiting for the session to be registered", elapsed)
+	}
+
+	var identified string
+	pin.then(func(sessionID string) { identified = sessionID })
+	sessionID := pin.sessionID()
+	if sessionID == "" {
+		t.Fatal("the pinned session wasn't created")
+	}
+	if identified != sessionID {
+		t.Errorf("then got session %q before sessionID returned, want %q", identified, sessionID)
+	}
+
+	// Once cached, the session is pinned right away
+	again := sm.pinSession(&SessionInfo{SessionKey: "client:1", ClientName: "test"})
+	defer again.release()
+	select {
+	case <-again.done:
+	default:
+		t.Error("pinning a cached session didn't resolve it right away")
+	}
+	if got := collector.created.Load(); got != 1 {
+		t.Errorf("registered %d sessions, want 1", got)
+	}
+}
+
+func TestReleasedPinDoesNotHoldLateSession(t *testing.T) {
+	collector := newSessionCollector(t, 50*time.Millisecond)
+	sm := newTestSessionManager(collector.URL, nil)
+	info := &SessionInfo{SessionKey: "client:1", ClientName: "test"}
+
+	pin := sm.pinSession(info)
+	pin.release()
+	sessionID := pin.sessionID()
+
+	sm.Evict(info.SessionKey)
+	if _, first := sm.RecordAction(sessionID, time.Now()); first {
+		t.Error("a pin released before its session was created kept it cached")
+	}
+}
+
+func TestWrapToolHandlerWithPinReportsPinnedSession(t *testing.T) {
+	released := false
+	pin := func(ctx context.Context) (func() string, func()) {
+		return func() string { return "session-1" }, func() { released = true }
+	}
+	var got *ToolCall
+	handler := WrapToolHandlerWithPin("echo", func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		if released {
+			t.Error("session released before the handler returned")
+		}
+		return mcp.NewToolResultText("ok"), nil
+	}, pin, func(call *ToolCall) error {
+		got = call
+		if released {
+			t.Error("session released before the call was reported")
+		}
+		return nil
+	})
+
+	if _, err := handler(context.Background(), mcp.CallToolRequest{}); err != nil {
+		t.Fatal(err)
+	}
+	if got == nil || got.SessionID != "session-1" {
+		t.Fatalf("reported call %+v, want it in session-1", got)
+	}
+	if !released {
+		t.Error("pinned session wasn't released")
+	}
+}
+
+func TestWrapToolHandlerSurvivesPanickingPin(t *testing.T) {
+	capturePackageLogger(t)
+	pin := func(ctx context.Context) (func() string, func()) { panic("pin") }
+	var got *ToolCall
+	handler := WrapToolHandlerWithPin("echo", func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		return mcp.NewToolResultText("ok"), nil
+	}, pin, func(call *ToolCall) error {
+		got = call
+		return nil
+	})
+
+	if _, err := handler(context.Background(), mcp.CallToolRequest{}); err != nil {
+		t.Fatal(err)
+	}
+	if got == nil || got.SessionID != "" || !got.Success {
+		t.Errorf("reported call %+v, want a successful call without session", got)
+	}
+}
+
+func TestSlowCallReportsTheSessionItStartedIn(t *testing.T) {
+	s, _, collector := newTrackedServer(t, func(config *AgnostConfig) {
+		config.SessionTTL = 50 * time.Millisecond
+	})
+	started, release := make(chan struct{}), make(chan struct{})
+	s.AddTool(mcp.NewTool("slow"), func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		close(started)
+		<-release
+		return mcp.NewToolResultText("done"), nil
+	})
+	addEchoTool(s)
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		callTool(t, s, "slow")
+	}()
+	<-started
+	time.Sleep(60 * time.Millisecond)
+	callTool(t, s, "echo")
+	close(release)
+	<-done
+
+	sessions := collector.Sessions()
+	if len(sessions) != 2 {
+		t.Fatalf("registered %d sessions, want the expired one and its replacement", len(sessions))
+	}
+	events := collector.Events("tool")
+	if len(events) != 2 {
+		t.Fatalf("got %d tool events, want 2", len(events))
+	}
+	for _, event := range events {
+		want := sessions[1].SessionID
+		if event.PrimitiveName == "slow" {
+			want = sessions[0].SessionID
+		}
+		if event.SessionID != want {
+			t.Errorf("%s was reported in session %s, want %s", event.PrimitiveName, event.SessionID, want)
+		}
+	}
+}
@@ -0,0 +1,111 @@
+package agnost
+
+import (
+	"cmp"
+	"fmt"
+	"slices"
+	"time"
+)
+
+// SessionSnapshot is a copy of the state of a cached session, for operational
+// tooling. Of the session's identity it only carries the user ID.
+type SessionSnapshot struct {
+	SessionID string
+
+	// Key is the key the session is cached under, such as the client session
+	// it belongs to, and ClientName the client's name
+	Key        string
+	ClientName string
+
+	// UserID is the user_id of the identity the session was registered with,
+	// empty if it wasn't identified or was resumed from a previous process
+	UserID string
+
+	CreatedAt   time.Time
+	LastEventAt time.Time // zero until the session's first event
+	EventCount  int64
+}
+
+// identityUserID returns the user_id of an identity, or "" if it has none
+func identityUserID(user UserIdentity) string {
+	switch id := user["user_id"].(type) {
+	case nil:
+		return ""
+	case string:
+		return id
+	default:
+		return fmt.Sprint(id)
+	}
+}
+
+// snapshotLocked copies the session's state, keyed under key; the caller
+// holds SessionManager.mu
+func (e *sessionEntry) snapshotLocked(key string) SessionSnapshot {
+	snapshot := SessionSnapshot{
+		SessionID:  e.id,
+		Key:        key,
+		ClientName: e.info.ClientName,
+		UserID:     e.userID,
+		CreatedAt:  e.createdAt,
+		EventCount: e.eventCount.Load(),
+	}
+	if e.adoptedBy != nil && e.adoptedBy.SessionKey == key {
+		snapshot.ClientName = e.adoptedBy.ClientName
+	}
+	if lastEvent := e.lastEventAt.Load(); lastEvent != 0 {
+		snapshot.LastEventAt = time.UnixMilli(lastEvent)
+	}
+	return snapshot
+}
+
+// Snapshots returns a snapshot of every cached session, oldest first. A
+// session adopted by a client session is listed once, under the client's key
+// while that client is connected.
+func (sm *SessionManager) Snapshots() []SessionSnapshot {
+	sm.mu.RLock()
+	defer sm.mu.RUnlock()
+
+	snapshots := make([]SessionSnapshot, 0, len(sm.sessions))
+	for key, entry := range sm.sessions {
+		if entry.adoptedBy != nil && entry.adoptedBy.SessionKey != key && sm.sessions[entry.adoptedBy.SessionKey] == entry {
+			continue
+		}
+		snapshots = append(snapshots, entry.snapshotLocked(key))
+	}
+	slices.SortFunc(snapshots, func(a, b SessionSnapshot) int {
+		return cmp.Or(a.CreatedAt.Compare(b.CreatedAt), cmp.Compare(a.Key, b.Key))
+	})
+	return snapshots
+}
+
+// Snapshot returns a snapshot of the session cached under key
+func (sm *SessionManager) Snapshot(key string) (SessionSnapshot, bool) {
+	sm.mu.RLock()
+	defer sm.mu.RUnlock()
+
+	entry, exists := sm.sessions[key]
+	if !exists {
+		return SessionSnapshot{}, false
+	}
+	return entry.snapshotLocked(key), true
+}
+
+// Sessions returns a snapshot of every active session, oldest first
+func (a *AgnostAnalytics) Sessions() []SessionSnapshot {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	if a.sessionManager == nil {
+		return nil
+	}
+	return a.sessionManager.Snapshots()
+}
+
+// Session returns a snapshot of the active session with the given key
+func (a *AgnostAnalytics) Session(key string) (SessionSnapshot, bool) {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	if a.sessionManager == nil {
+		return SessionSnapshot{}, false
+	}
+	return a.sessionManager.Snapshot(key)
+}
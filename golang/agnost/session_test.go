@@ -0,0 +1,159 @@
+package agnost
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// sessionCollector answers session registrations after delay, so that
+// concurrent creations overlap, and counts them
+type sessionCollector struct {
+	*httptest.Server
+	created atomic.Int64
+	ended   atomic.Int64
+}
+
+func newSessionCollector(t testing.TB, delay time.Duration) *sessionCollector {
+	c := &sessionCollector{}
+	c.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/v1/capture-session":
+			c.created.Add(1)
+			time.Sleep(delay)
+			var session SessionData
+			json.NewDecoder(r.Body).Decode(&session)
+			json.NewEncoder(w).Encode(SessionResponse{SessionID: session.SessionID})
+		case "/api/v1/capture-session-end":
+			c.ended.Add(1)
+		}
+	}))
+	t.Cleanup(c.Close)
+	return c
+}
+
+func newTestSessionManager(endpoint string, configure func(*AgnostConfig)) *SessionManager {
+	config := DefaultConfig()
+	config.Logger = NewLogger(io.Discard)
+	if configure != nil {
+		configure(config)
+	}
+	return NewSessionManager(endpoint, "org", http.DefaultClient, config, nil)
+}
+
+func TestGetOrCreateSessionCollapsesConcurrentCreations(t *testing.T) {
+	collector := newSessionCollector(t, 20*time.Millisecond)
+	sm := newTestSessionManager(collector.URL, nil)
+	info := &SessionInfo{SessionKey: "client:1", ClientName: "test"}
+
+	var wg sync.WaitGroup
+	ids := make([]string, 20)
+	for i := range ids {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			id, err := sm.GetOrCreateSession(info)
+			if err != nil {
+				t.Errorf("GetOrCreateSession: %v", err)
+			}
+			ids[i] = id
+		}()
+	}
+	wg.Wait()
+
+	if got := collector.created.Load(); got != 1 {
+		t.Errorf("registered %d sessions, want 1", got)
+	}
+	for _, id := range ids {
+		if id != ids[0] {
+			t.Fatalf("got session IDs %q and %q for one key", ids[0], id)
+		}
+	}
+	if got := sm.SessionCount(); got != 1 {
+		t.Errorf("cached %d sessions, want 1", got)
+	}
+}
+
+func TestGetOrCreateSessionReplacesExpiredSessionOnce(t *testing.T) {
+	collector := newSessionCollector(t, 20*time.Millisecond)
+	sm := newTestSessionManager(collector.URL, func(config *AgnostConfig) {
+		config.SessionTTL = 50 * time.Millisecond
+	})
+	info := &SessionInfo{SessionKey: "client:1", ClientName: "test"}
+
+	first, err := sm.GetOrCreateSession(info)
+	if err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(60 * time.Millisecond)
+
+	var wg sync.WaitGroup
+	for range 10 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if id, _ := sm.GetOrCreateSession(info); id == first {
+				t.Errorf("expired session %s was reused", first)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if got := collector.created.Load(); got != 2 {
+		t.Errorf("registered %d sessions, want 2", got)
+	}
+	if got := collector.ended.Load(); got != 1 {
+		t.Errorf("ended %d sessions, want the expired one", got)
+	}
+	if got := sm.SessionCount(); got != 1 {
+		t.Errorf("cached %d sessions, want 1", got)
+	}
+}
+
+func TestGetOrCreateSessionRetriesAfterFailedCreation(t *testing.T) {
+	sm := newTestSessionManager("http://127.0.0.1:1", func(config *AgnostConfig) {
+		config.RequestTimeout = 100 * time.Millisecond
+	})
+	info := &SessionInfo{SessionKey: "client:1", ClientName: "test"}
+	if _, err := sm.GetOrCreateSession(info); err == nil {
+		t.Fatal("created a session against an unreachable endpoint")
+	}
+
+	collector := newSessionCollector(t, 20*time.Millisecond)
+	sm.endpoint = collector.URL
+	if _, err := sm.GetOrCreateSession(info); err != nil {
+		t.Fatalf("failed creation wasn't retried: %v", err)
+	}
+}
+
+func TestEndSessionsSkipsExpiredPinnedSessions(t *testing.T) {
+	collector := newSessionCollector(t, 20*time.Millisecond)
+	sm := newTestSessionManager(collector.URL, func(config *AgnostConfig) {
+		config.SessionTTL = 20 * time.Millisecond
+	})
+	info := &SessionInfo{SessionKey: "client:1", ClientName: "test"}
+
+	_, release, err := sm.PinSession(info)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer release()
+	time.Sleep(30 * time.Millisecond)
+	if _, err := sm.GetOrCreateSession(info); err != nil {
+		t.Fatal(err)
+	}
+	if got := collector.ended.Load(); got != 1 {
+		t.Fatalf("ended %d sessions on expiry, want 1", got)
+	}
+
+	// The expired session is still pinned, so still cached by ID, but was ended
+	sm.EndSessions()
+	if got := collector.ended.Load(); got != 2 {
+		t.Errorf("ended %d sessions in total, want the expired one and its replacement", got)
+	}
+}
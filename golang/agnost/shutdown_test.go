@@ -0,0 +1,162 @@
+package agnost
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/http/httputil"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+func TestCallsFinishingDuringShutdownAreDroppedQuietly(t *testing.T) {
+	var log syncBuffer
+	s, a, collector := newTrackedServer(t, func(config *AgnostConfig) {
+		config.Logger = NewLogger(&log)
+	})
+	started, release := make(chan struct{}), make(chan struct{})
+	s.AddTool(mcp.NewTool("slow"), func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		close(started)
+		<-release
+		return mcp.NewToolResultText("ok"), nil
+	})
+
+	called := make(chan string)
+	go func() { called <- callToolError(t, s, "slow") }()
+	<-started
+	shutDown := make(chan struct{})
+	go func() {
+		a.Shutdown()
+		close(shutDown)
+	}()
+	if !waitUntil(a.closing.Load) {
+		t.Fatal("Shutdown didn't start")
+	}
+	close(release)
+
+	if message := <-called; message != "" {
+		t.Errorf("call finishing during shutdown failed with %q", message)
+	}
+	<-shutDown
+	if got := len(collector.Events("tool")); got != 0 {
+		t.Errorf("sent %d events after shutdown started", got)
+	}
+	if got := a.Stats().Drops[DropShutdown]; got != 1 {
+		t.Errorf("counted %d shutdown drops, want 1", got)
+	}
+	if strings.Contains(log.String(), "WARNING") {
+		t.Errorf("dropping during shutdown logged warnings:\n%s", log.String())
+	}
+}
+
+func TestRecordEventAfterShutdownReturnsNoError(t *testing.T) {
+	_, a, _ := newTrackedServer(t, nil)
+	a.Shutdown()
+
+	if err := a.RecordEvent("tool", "late", nil, 1, true, nil); err != nil {
+		t.Errorf("RecordEvent after Shutdown returned %v", err)
+	}
+	if got := a.Stats().Drops[DropShutdown]; got != 1 {
+		t.Errorf("counted %d shutdown drops, want 1", got)
+	}
+}
+
+func TestInitializeAfterShutdownRecordsAgain(t *testing.T) {
+	s, a, collector := newTrackedServer(t, nil)
+	a.Shutdown()
+	if err := a.Initialize(s, "org", a.config); err != nil {
+		t.Fatal(err)
+	}
+	if a.closing.Load() {
+		t.Fatal("client is still closing after Initialize")
+	}
+
+	if err := a.RecordEvent("tool", "echo", nil, 1, true, nil); err != nil {
+		t.Fatal(err)
+	}
+	if got := len(collector.Events("tool")); got != 1 {
+		t.Errorf("got %d tool events after re-initializing, want 1", got)
+	}
+}
+
+func TestShutdownContextAbandonsSendsAtItsDeadline(t *testing.T) {
+	held, received, _ := newHeldCollector(t)
+	_, a, _ := newTrackedServer(t, func(config *AgnostConfig) {
+		config.SessionEndpoint = config.Endpoint
+		config.Endpoint = held.URL
+		config.EnableRequestQueuing = true
+		config.BatchSize = 1
+	})
+	for range 3 {
+		if err := a.RecordEvent("tool", "echo", nil, 1, true, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	<-received
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	err := a.ShutdownContext(ctx)
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("ShutdownContext took %v past a 100ms deadline", elapsed)
+	}
+	if !errors.Is(err, ErrEventsUndelivered) {
+		t.Errorf("ShutdownContext returned %v, want ErrEventsUndelivered", err)
+	}
+	if a.IsInitialized() {
+		t.Error("client is still initialized after its shutdown deadline")
+	}
+}
+
+func TestShutdownContextBoundsEndingSessions(t *testing.T) {
+	hung := make(chan struct{})
+	_, a, _ := newTrackedServer(t, func(config *AgnostConfig) {
+		// Session ends hang, anything else reaches the collector
+		target, _ := url.Parse(config.Endpoint)
+		proxy := httputil.NewSingleHostReverseProxy(target)
+		sessions := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path == "/api/v1/capture-session-end" {
+				<-hung
+				return
+			}
+			proxy.ServeHTTP(w, r)
+		}))
+		t.Cleanup(sessions.Close)
+		t.Cleanup(func() { close(hung) })
+		config.SessionEndpoint = sessions.URL
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if err := a.ShutdownContext(ctx); err != nil {
+		t.Errorf("ShutdownContext returned %v without undelivered events", err)
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("ShutdownContext took %v past a 100ms deadline ending sessions", elapsed)
+	}
+}
+
+func TestShutdownContextDeliversEverythingInTime(t *testing.T) {
+	_, a, collector := newTrackedServer(t, func(config *AgnostConfig) {
+		config.EnableRequestQueuing = true
+	})
+	for range 3 {
+		a.RecordEvent("tool", "echo", nil, 1, true, nil)
+	}
+	if err := a.ShutdownContext(context.Background()); err != nil {
+		t.Fatalf("ShutdownContext returned %v", err)
+	}
+	if got := len(collector.Events("tool")); got != 3 || len(collector.Ends()) != 1 {
+		t.Errorf("collector got %d events and %d session ends, want 3 and 1", got, len(collector.Ends()))
+	}
+	if err := a.ShutdownContext(context.Background()); err != nil {
+		t.Errorf("shutting down twice returned %v", err)
+	}
+}
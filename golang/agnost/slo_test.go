@@ -0,0 +1,100 @@
+package agnost
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLookupPattern(t *testing.T) {
+	budgets := map[string]int{
+		"search":        1,
+		"search_*":      2,
+		"search_web_*":  3,
+		"fetch?":        4,
+		"[":             5,
+		"db_*_read":     6,
+		"db_*_rea[a-z]": 7,
+		"a*c":           8,
+		"ab*":           9,
+	}
+	tests := []struct {
+		name string
+		want int
+		ok   bool
+	}{
+		{"search", 1, true},
+		{"search_docs", 2, true},
+		{"search_web_images", 3, true},
+		{"fetch1", 4, true},
+		{"fetch12", 0, false},
+		{"[", 5, true},
+		{"db_users_read", 7, true},
+		{"db_users_ready", 0, false},
+		{"abc", 8, true},
+		{"other", 0, false},
+	}
+	for _, tt := range tests {
+		got, ok := lookupPattern(budgets, tt.name)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("lookupPattern(%q) = %d, %v, want %d, %v", tt.name, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestEventsAreCheckedAgainstTheToolSLO(t *testing.T) {
+	_, a, collector := newTrackedServer(t, func(config *AgnostConfig) {
+		config.ToolSLOs = map[string]time.Duration{
+			"search": 100 * time.Millisecond,
+			"slow_*": time.Second,
+			"off":    0,
+		}
+	})
+
+	yes, no := true, false
+	tests := []struct {
+		primitiveType, name string
+		latency             int64
+		slo                 int64
+		breached            *bool
+	}{
+		{"tool", "search", 99, 100, &no},
+		{"tool", "search", 100, 100, &no},
+		{"tool", "search", 101, 100, &yes},
+		{"tool", "slow_report", 1001, 1000, &yes},
+		{"tool", "off", 5000, 0, nil},
+		{"tool", "unlisted", 5000, 0, nil},
+		{"prompt", "search", 5000, 0, nil},
+	}
+	for _, tt := range tests {
+		if err := a.RecordEvent(tt.primitiveType, tt.name, nil, tt.latency, true, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var events []EventData
+	for _, primitiveType := range []string{"tool", "prompt"} {
+		events = append(events, collector.Events(primitiveType)...)
+	}
+	if len(events) != len(tests) {
+		t.Fatalf("got %d events, want %d", len(events), len(tests))
+	}
+	for i, tt := range tests {
+		event := events[i]
+		if event.SLOMs != tt.slo || (event.SLOBreached == nil) != (tt.breached == nil) ||
+			(tt.breached != nil && *event.SLOBreached != *tt.breached) {
+			t.Errorf("%s %s at %dms: got SLO %dms, breached %v, want %dms, %v",
+				tt.primitiveType, tt.name, tt.latency, event.SLOMs, ptrString(event.SLOBreached), tt.slo, ptrString(tt.breached))
+		}
+	}
+}
+
+// ptrString formats an optional bool
+func ptrString(b *bool) string {
+	if b == nil {
+		return "unset"
+	}
+	if *b {
+		return "true"
+	}
+	return "false"
+}
@@ -0,0 +1,118 @@
+package agnost
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"slices"
+	"time"
+)
+
+// SlogLogger is a LogSink writing structured records to a slog.Handler
+type SlogLogger struct {
+	handler slog.Handler
+}
+
+// NewSlogLogger creates a logger writing records to h
+func NewSlogLogger(h slog.Handler) *SlogLogger {
+	return &SlogLogger{handler: h}
+}
+
+// Debug logs a debug message
+func (l *SlogLogger) Debug(format string, args ...any) {
+	l.logAttrs(LogLevelDebug, nil, format, args...)
+}
+
+// Info logs an info message
+func (l *SlogLogger) Info(format string, args ...any) {
+	l.logAttrs(LogLevelInfo, nil, format, args...)
+}
+
+// Warning logs a warning message, at slog.LevelWarn
+func (l *SlogLogger) Warning(format string, args ...any) {
+	l.logAttrs(LogLevelWarning, nil, format, args...)
+}
+
+// Error logs an error message
+func (l *SlogLogger) Error(format string, args ...any) {
+	l.logAttrs(LogLevelError, nil, format, args...)
+}
+
+// logAttrs writes a record of the message with attrs to the handler
+func (l *SlogLogger) logAttrs(level LogLevel, attrs []slog.Attr, format string, args ...any) {
+	ctx := context.Background()
+	recordLevel := slogLevel(level)
+	if !l.handler.Enabled(ctx, recordLevel) {
+		return
+	}
+	record := slog.NewRecord(time.Now(), recordLevel, fmt.Sprintf(format, args...), 0)
+	record.AddAttrs(attrs...)
+	_ = l.handler.Handle(ctx, record)
+}
+
+// slogLevel returns the slog level of a LogLevel
+func slogLevel(level LogLevel) slog.Level {
+	switch level {
+	case LogLevelDebug:
+		return slog.LevelDebug
+	case LogLevelInfo:
+		return slog.LevelInfo
+	case LogLevelWarning:
+		return slog.LevelWarn
+	}
+	return slog.LevelError
+}
+
+// structuredLogger is implemented by loggers taking fields with a message
+type structuredLogger interface {
+	logAttrs(level LogLevel, attrs []slog.Attr, format string, args ...any)
+}
+
+// with returns a logger adding attrs to its messages if the sink takes fields
+func (l *levelLogger) with(attrs ...slog.Attr) *levelLogger {
+	if l == nil {
+		l = packageLogger.Load()
+	}
+	if _, ok := l.sink.(structuredLogger); !ok {
+		return l
+	}
+	return &levelLogger{
+		level:     l.level,
+		sink:      l.sink,
+		attrs:     append(slices.Clip(l.attrs), attrs...),
+		recovered: l.recovered,
+	}
+}
+
+// forEvent returns a logger adding the fields of event to its messages
+func (l *levelLogger) forEvent(event *EventData) *levelLogger {
+	if l == nil {
+		l = packageLogger.Load()
+	}
+	if _, ok := l.sink.(structuredLogger); !ok {
+		return l
+	}
+	return l.with(primitiveAttrs(event.PrimitiveType, event.PrimitiveName, event.SessionID, event.Latency)...)
+}
+
+// primitiveAttrs returns the log fields of a call of a primitive
+func primitiveAttrs(primitiveType string, name string, sessionID string, latency int64) []slog.Attr {
+	nameKey := "primitive_name"
+	switch primitiveType {
+	case "tool", "resource", "prompt":
+		nameKey = primitiveType
+	}
+	attrs := []slog.Attr{
+		slog.String("primitive_type", primitiveType),
+		slog.String(nameKey, name),
+	}
+	if sessionID != "" {
+		attrs = append(attrs, slog.String("session_id", sessionID))
+	}
+	return append(attrs, slog.Int64("latency_ms", latency))
+}
+
+// sessionAttrs returns the log fields of a session
+func sessionAttrs(sessionID string, sessionKey string) []slog.Attr {
+	return []slog.Attr{slog.String("session_id", sessionID), slog.String("session_key", sessionKey)}
+}
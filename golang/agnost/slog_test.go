@@ -0,0 +1,110 @@
+package agnost
+
+import (
+	"encoding/json"
+	"io"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+// records decodes the records a slog JSON handler wrote to out
+func slogRecords(t *testing.T, out *syncBuffer) []map[string]any {
+	t.Helper()
+	var records []map[string]any
+	for _, line := range strings.Split(strings.TrimSpace(out.String()), "\n") {
+		if line == "" {
+			continue
+		}
+		var record map[string]any
+		if err := json.Unmarshal([]byte(line), &record); err != nil {
+			t.Fatalf("invalid record %q: %v", line, err)
+		}
+		records = append(records, record)
+	}
+	return records
+}
+
+func newJSONSlogLogger(level slog.Level) (*SlogLogger, *syncBuffer) {
+	var out syncBuffer
+	return NewSlogLogger(slog.NewJSONHandler(&out, &slog.HandlerOptions{Level: level})), &out
+}
+
+func TestSlogLoggerWritesRecordsAtTheirLevel(t *testing.T) {
+	logger, out := newJSONSlogLogger(slog.LevelDebug)
+	logger.Debug("debug %d", 1)
+	logger.Info("info %s", "two")
+	logger.Warning("warning")
+	logger.Error("error")
+
+	want := [][2]string{{"DEBUG", "debug 1"}, {"INFO", "info two"}, {"WARN", "warning"}, {"ERROR", "error"}}
+	records := slogRecords(t, out)
+	if len(records) != len(want) {
+		t.Fatalf("wrote %d records, want %d", len(records), len(want))
+	}
+	for i, record := range records {
+		if record["level"] != want[i][0] || record["msg"] != want[i][1] {
+			t.Errorf("record %d is %v %q, want %v %q", i, record["level"], record["msg"], want[i][0], want[i][1])
+		}
+	}
+}
+
+func TestSlogLoggerHonoursTheHandlersLevel(t *testing.T) {
+	logger, out := newJSONSlogLogger(slog.LevelWarn)
+	logger.Debug("debug")
+	logger.Info("info")
+	logger.Warning("warning")
+	if records := slogRecords(t, out); len(records) != 1 || records[0]["msg"] != "warning" {
+		t.Errorf("wrote %v, want only the warning", records)
+	}
+
+	// LogLevel filters messages before they reach the handler
+	logger, out = newJSONSlogLogger(slog.LevelDebug)
+	newLevelLogger(logger, "error").Warning("warning")
+	if records := slogRecords(t, out); len(records) != 0 {
+		t.Errorf("wrote %v under LogLevel error", records)
+	}
+}
+
+func TestSlogRecordsCarryTheEventsFields(t *testing.T) {
+	logger, out := newJSONSlogLogger(slog.LevelDebug)
+	log := newLevelLogger(logger, "debug")
+	log.forEvent(&EventData{PrimitiveType: "tool", PrimitiveName: "echo", SessionID: "s1", Latency: 12}).Warning("failed")
+	log.with(sessionAttrs("s2", "client:1")...).Info("session")
+
+	records := slogRecords(t, out)
+	if len(records) != 2 {
+		t.Fatalf("wrote %d records, want 2", len(records))
+	}
+	event := records[0]
+	if event["primitive_type"] != "tool" || event["tool"] != "echo" || event["session_id"] != "s1" || event["latency_ms"] != 12.0 {
+		t.Errorf("event record is %v", event)
+	}
+	if session := records[1]; session["session_id"] != "s2" || session["session_key"] != "client:1" || session["tool"] != nil {
+		t.Errorf("session record is %v, without the event's fields", session)
+	}
+}
+
+func TestUnstructuredSinksIgnoreFields(t *testing.T) {
+	log := newLevelLogger(NewLogger(io.Discard), "debug")
+	if log.with(slog.String("session_id", "s1")) != log {
+		t.Error("with copied a logger whose sink doesn't take fields")
+	}
+}
+
+func TestTrackedCallsLogStructuredRecords(t *testing.T) {
+	logger, out := newJSONSlogLogger(slog.LevelDebug)
+	s, _, _ := newTrackedServer(t, func(config *AgnostConfig) {
+		config.Logger = logger
+		config.LogLevel = "debug"
+	})
+	addEchoTool(s)
+	callTool(t, s, "echo")
+
+	for _, record := range slogRecords(t, out) {
+		if record["tool"] == "echo" {
+			return
+		}
+	}
+	t.Error("no record of the call carries its tool field")
+}
@@ -0,0 +1,173 @@
+package agnost
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// defaultSpoolMaxBytes is used when Config.SpoolMaxBytes is not set
+const defaultSpoolMaxBytes = 10 << 20
+
+// Delivery modes recorded in EventData.DeliveryMode
+const (
+	// DeliveryModeLive events were sent by the pipeline that recorded them
+	DeliveryModeLive = "live"
+
+	// DeliveryModeSpooled events were spooled after a failed delivery and sent
+	// later by the same process
+	DeliveryModeSpooled = "spooled"
+
+	// DeliveryModeReplayed events were spooled by a previous process and sent
+	// after a restart
+	DeliveryModeReplayed = "replayed"
+)
+
+// spoolRecord is one event in the spool file
+type spoolRecord struct {
+	// RunID identifies the process that spooled the event, to tell spooled
+	// events from replayed ones
+	RunID string     `json:"run_id"`
+	Event *EventData `json:"event"`
+}
+
+// eventSpool keeps events that could not be delivered on disk, one JSON record
+// per line, so they can be sent once the collector is reachable again, even
+// after a restart
+type eventSpool struct {
+	path     string
+	runID    string
+	maxBytes int64
+	log      *levelLogger
+
+	mu sync.Mutex
+}
+
+// newEventSpool creates the spool of an organization in dir
+func newEventSpool(dir string, orgID string, maxBytes int64, log *levelLogger) *eventSpool {
+	if maxBytes <= 0 {
+		maxBytes = defaultSpoolMaxBytes
+	}
+	return &eventSpool{
+		path:     filepath.Join(dir, orgFileName(orgID, ".spool.jsonl")),
+		runID:    generateUUID(),
+		maxBytes: maxBytes,
+		log:      log,
+	}
+}
+
+// write appends an event to the spool
+func (s *eventSpool) write(event *EventData) error {
+	line, err := json.Marshal(spoolRecord{RunID: s.runID, Event: event})
+	if err != nil {
+		return fmt.Errorf("failed to marshal spooled event: %v", err)
+	}
+	line = append(line, '\n')
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if info, err := os.Stat(s.path); err == nil && info.Size()+int64(len(line)) > s.maxBytes {
+		return fmt.Errorf("spool is full (%d bytes)", s.maxBytes)
+	}
+	if err := os.MkdirAll(filepath.Dir(s.path), 0o700); err != nil {
+		return fmt.Errorf("failed to create spool directory: %v", err)
+	}
+
+	file, err := os.OpenFile(s.path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o600)
+	if err != nil {
+		return fmt.Errorf("failed to open spool: %v", err)
+	}
+	if _, err := file.Write(line); err != nil {
+		file.Close()
+		return fmt.Errorf("failed to write spool: %v", err)
+	}
+	return file.Close()
+}
+
+// size returns the size of the spool file in bytes
+func (s *eventSpool) size() int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	info, err := os.Stat(s.path)
+	if err != nil {
+		return 0
+	}
+	return info.Size()
+}
+
+// drain sends the spooled events in order, stamping their delivery mode. It
+// stops at the first failure and keeps that event and the ones after it.
+func (s *eventSpool) drain(send func(event *EventData) error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	data, err := os.ReadFile(s.path)
+	if err != nil || len(data) == 0 {
+		return
+	}
+
+	var remaining [][]byte
+	sent, dropped := 0, 0
+	for _, line := range bytes.Split(data, []byte{'\n'}) {
+		if len(line) == 0 {
+			continue
+		}
+		if len(remaining) > 0 {
+			remaining = append(remaining, line)
+			continue
+		}
+
+		var record spoolRecord
+		if err := json.Unmarshal(line, &record); err != nil || record.Event == nil {
+			s.log.Warning("Dropping corrupt spooled event: %v", err)
+			dropped++
+			continue
+		}
+		record.Event.DeliveryMode = DeliveryModeReplayed
+		if record.RunID == s.runID {
+			record.Event.DeliveryMode = DeliveryModeSpooled
+		}
+
+		if err := send(record.Event); err != nil {
+			s.log.Debug("Spooled event not delivered, will retry: %v", err)
+			remaining = append(remaining, line)
+			continue
+		}
+		sent++
+	}
+
+	if sent == 0 && dropped == 0 {
+		return
+	}
+	if sent > 0 {
+		s.log.Info("Delivered %d spooled events", sent)
+	}
+	if err := s.rewriteLocked(remaining); err != nil {
+		s.log.Warning("Failed to update spool: %v", err)
+	}
+}
+
+// rewriteLocked atomically replaces the spool with the given lines
+func (s *eventSpool) rewriteLocked(lines [][]byte) error {
+	if len(lines) == 0 {
+		if err := os.Remove(s.path); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return nil
+	}
+
+	var buf bytes.Buffer
+	for _, line := range lines {
+		buf.Write(line)
+		buf.WriteByte('\n')
+	}
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, buf.Bytes(), 0o600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, s.path)
+}
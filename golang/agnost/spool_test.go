@@ -0,0 +1,127 @@
+package agnost
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+)
+
+func newTestSpool(dir string, maxBytes int64) *eventSpool {
+	return newEventSpool(dir, "org", maxBytes, newLevelLogger(NewLogger(io.Discard), "debug"))
+}
+
+// drainNames drains the spool, returning the names and delivery modes of the
+// events sent, and failing to send the event named fail
+func drainNames(s *eventSpool, fail string) (names []string, modes []string) {
+	s.drain(func(event *EventData) error {
+		if event.PrimitiveName == fail {
+			return errors.New("unreachable")
+		}
+		names = append(names, event.PrimitiveName)
+		modes = append(modes, event.DeliveryMode)
+		return nil
+	})
+	return names, modes
+}
+
+func TestSpoolDrainStampsDeliveryModes(t *testing.T) {
+	dir := t.TempDir()
+	previous := newTestSpool(dir, 0)
+	current := newTestSpool(dir, 0)
+	previous.write(&EventData{PrimitiveName: "before-restart"})
+	current.write(&EventData{PrimitiveName: "after-restart"})
+
+	names, modes := drainNames(current, "")
+	if len(names) != 2 || names[0] != "before-restart" || names[1] != "after-restart" {
+		t.Fatalf("drained %v, want the events in spool order", names)
+	}
+	if modes[0] != DeliveryModeReplayed || modes[1] != DeliveryModeSpooled {
+		t.Errorf("got delivery modes %v, want %s and %s", modes, DeliveryModeReplayed, DeliveryModeSpooled)
+	}
+	if _, err := os.Stat(current.path); !os.IsNotExist(err) {
+		t.Errorf("drained spool wasn't removed: %v", err)
+	}
+}
+
+func TestSpoolDrainKeepsEventsFromTheFirstFailure(t *testing.T) {
+	s := newTestSpool(t.TempDir(), 0)
+	for _, name := range []string{"a", "b", "c"} {
+		s.write(&EventData{PrimitiveName: name})
+	}
+
+	if names, _ := drainNames(s, "b"); len(names) != 1 || names[0] != "a" {
+		t.Fatalf("drained %v before the failure, want [a]", names)
+	}
+	if names, _ := drainNames(s, ""); len(names) != 2 || names[0] != "b" || names[1] != "c" {
+		t.Errorf("drained %v on retry, want [b c]", names)
+	}
+	if got := s.size(); got != 0 {
+		t.Errorf("spool holds %d bytes after delivering everything", got)
+	}
+}
+
+func TestSpoolDrainDropsCorruptRecords(t *testing.T) {
+	s := newTestSpool(t.TempDir(), 0)
+	s.write(&EventData{PrimitiveName: "a"})
+	file, _ := os.OpenFile(s.path, os.O_WRONLY|os.O_APPEND, 0o600)
+	file.WriteString("{not json\n{\"run_id\":\"x\"}\n")
+	file.Close()
+	s.write(&EventData{PrimitiveName: "b"})
+
+	if names, _ := drainNames(s, ""); len(names) != 2 {
+		t.Errorf("drained %v, want the two valid events", names)
+	}
+	if got := s.size(); got != 0 {
+		t.Errorf("spool kept %d bytes of corrupt records", got)
+	}
+}
+
+func TestSpoolIsBounded(t *testing.T) {
+	s := newTestSpool(t.TempDir(), 200)
+	if err := s.write(&EventData{PrimitiveName: "a"}); err != nil {
+		t.Fatal(err)
+	}
+	size := s.size()
+	if err := s.write(&EventData{PrimitiveName: "b", Input: string(make([]byte, 200))}); err == nil {
+		t.Error("wrote past the spool's limit")
+	}
+	if got := s.size(); got != size {
+		t.Errorf("spool grew to %d bytes on a rejected write", got)
+	}
+}
+
+func TestUndeliveredEventsAreReplayedAfterRestart(t *testing.T) {
+	dir := t.TempDir()
+	newConfig := func() *AgnostConfig {
+		config := DefaultConfig()
+		config.Logger = NewLogger(io.Discard)
+		config.MaxRetries = 0
+		config.SpoolDir = dir
+		return config
+	}
+
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer failing.Close()
+	ep := NewEventProcessor(failing.URL, "org", newConfig())
+	ep.QueueEvent(&EventData{SessionID: "s", PrimitiveType: "tool", PrimitiveName: "echo"})
+	ep.Flush()
+	if _, _, spoolBytes := ep.QueueStats(); spoolBytes == 0 {
+		t.Fatal("undelivered event wasn't spooled")
+	}
+	ep.Shutdown()
+
+	collector := newEventCollector(t)
+	ep = NewEventProcessor(collector.URL, "org", newConfig())
+	defer ep.Shutdown()
+	if !waitUntil(func() bool { return len(collector.Events("tool")) == 1 }) {
+		t.Fatal("spooled event wasn't replayed on start")
+	}
+	if got := collector.Events("tool")[0].DeliveryMode; got != DeliveryModeReplayed {
+		t.Errorf("replayed event has delivery mode %q", got)
+	}
+}
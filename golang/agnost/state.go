@@ -0,0 +1,149 @@
+package agnost
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// persistedSession is the last session recorded in the state file
+type persistedSession struct {
+	SessionID  string `json:"session_id"`
+	SessionKey string `json:"session_key"`
+	ClientName string `json:"client_name"`
+	SavedAt    int64  `json:"saved_at"` // unix milliseconds
+}
+
+// persistedState is the SDK state kept across process restarts
+type persistedState struct {
+	InstallationID string            `json:"installation_id"`
+	LastSession    *persistedSession `json:"last_session,omitempty"`
+}
+
+// stateStore reads and writes the SDK state file for an organization
+type stateStore struct {
+	path string
+	log  *levelLogger
+	mu   sync.Mutex
+}
+
+// newStateStore creates a state store in dir, defaulting to the user cache directory
+func newStateStore(dir string, orgID string, log *levelLogger) (*stateStore, error) {
+	if dir == "" {
+		cacheDir, err := os.UserCacheDir()
+		if err != nil {
+			return nil, fmt.Errorf("failed to locate state directory: %v", err)
+		}
+		dir = filepath.Join(cacheDir, "agnost")
+	}
+	return &stateStore{
+		path: filepath.Join(dir, orgFileName(orgID, ".json")),
+		log:  log,
+	}, nil
+}
+
+// orgFileName names an organization's file with the hash of its ID, so no ID
+// can point the file outside its directory
+func orgFileName(orgID string, ext string) string {
+	hash := sha256.Sum256([]byte(orgID))
+	return hex.EncodeToString(hash[:16]) + ext
+}
+
+// load reads the state file. Missing or corrupt files yield an empty state.
+func (st *stateStore) load() persistedState {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	return st.loadLocked()
+}
+
+func (st *stateStore) loadLocked() persistedState {
+	var state persistedState
+	data, err := os.ReadFile(st.path)
+	if err != nil {
+		return state
+	}
+	if err := json.Unmarshal(data, &state); err != nil {
+		st.log.Warning("Ignoring corrupt state file %s: %v", st.path, err)
+		return persistedState{}
+	}
+	return state
+}
+
+// update applies fn to the current state and writes the result atomically
+func (st *stateStore) update(fn func(state *persistedState)) error {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
+	state := st.loadLocked()
+	if state.InstallationID == "" {
+		state.InstallationID = generateUUID()
+	}
+	fn(&state)
+
+	data, err := json.Marshal(state)
+	if err != nil {
+		return fmt.Errorf("failed to marshal state: %v", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(st.path), 0o700); err != nil {
+		return fmt.Errorf("failed to create state directory: %v", err)
+	}
+
+	tmp := st.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return fmt.Errorf("failed to write state: %v", err)
+	}
+	if err := os.Rename(tmp, st.path); err != nil {
+		return fmt.Errorf("failed to write state: %v", err)
+	}
+	return nil
+}
+
+// installationID returns the installation ID, persisting a new one if the
+// state file has none yet
+func (st *stateStore) installationID() (string, error) {
+	if id := st.load().InstallationID; id != "" {
+		return id, nil
+	}
+	var id string
+	err := st.update(func(state *persistedState) {
+		id = state.InstallationID
+	})
+	return id, err
+}
+
+// resumableSession returns the persisted session ID if it matches the session
+// info and was saved within the window
+func (st *stateStore) resumableSession(sessionInfo *SessionInfo, window time.Duration, now time.Time) (string, bool) {
+	last := st.load().LastSession
+	if last == nil || last.SessionID == "" {
+		return "", false
+	}
+	if last.SessionKey != sessionInfo.SessionKey || last.ClientName != sessionInfo.ClientName {
+		return "", false
+	}
+	savedAt := time.UnixMilli(last.SavedAt)
+	if savedAt.After(now) || now.Sub(savedAt) > window {
+		return "", false
+	}
+	return last.SessionID, true
+}
+
+// saveSession records the session as the last one seen
+func (st *stateStore) saveSession(sessionID string, sessionInfo *SessionInfo, now time.Time) {
+	err := st.update(func(state *persistedState) {
+		state.LastSession = &persistedSession{
+			SessionID:  sessionID,
+			SessionKey: sessionInfo.SessionKey,
+			ClientName: sessionInfo.ClientName,
+			SavedAt:    now.UnixMilli(),
+		}
+	})
+	if err != nil {
+		st.log.Warning("Failed to persist session state: %v", err)
+	}
+}
@@ -0,0 +1,80 @@
+package agnost
+
+import (
+	"io"
+	"net/http"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestPersistedSessionsResumeOnlyWhenValid(t *testing.T) {
+	info := &SessionInfo{SessionKey: "client:1", ClientName: "test"}
+	tests := []struct {
+		name   string
+		orgID  string
+		spoil  func(t *testing.T, st *stateStore, sessionID string)
+		resume bool
+	}{
+		{name: "valid", orgID: "org", resume: true},
+		{
+			name:  "truncated file",
+			orgID: "org",
+			spoil: func(t *testing.T, st *stateStore, sessionID string) {
+				data, err := os.ReadFile(st.path)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if err := os.WriteFile(st.path, data[:len(data)/2], 0o600); err != nil {
+					t.Fatal(err)
+				}
+			},
+		},
+		{
+			name:  "expired entry",
+			orgID: "org",
+			spoil: func(t *testing.T, st *stateStore, sessionID string) {
+				st.saveSession(sessionID, info, time.Now().Add(-2*time.Hour))
+			},
+		},
+		{name: "different org", orgID: "other-org"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			collector := newSessionCollector(t, 0)
+			dir := t.TempDir()
+			configure := func(config *AgnostConfig) {
+				config.StateDir = dir
+				config.SessionResumeWindow = time.Hour
+			}
+			previous := newTestSessionManager(collector.URL, configure)
+			first, err := previous.GetOrCreateSession(info)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.spoil != nil {
+				tt.spoil(t, previous.state, first)
+			}
+
+			config := DefaultConfig()
+			config.Logger = NewLogger(io.Discard)
+			configure(config)
+			restarted := NewSessionManager(collector.URL, tt.orgID, http.DefaultClient, config, nil)
+			second, err := restarted.GetOrCreateSession(info)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if resumed := second == first; resumed != tt.resume {
+				t.Errorf("restart got session %s after %s, want resumed %v", second, first, tt.resume)
+			}
+			want := int64(2)
+			if tt.resume {
+				want = 1
+			}
+			if created := collector.created.Load(); created != want {
+				t.Errorf("registered %d sessions, want %d", created, want)
+			}
+		})
+	}
+}
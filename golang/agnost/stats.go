@@ -0,0 +1,192 @@
+package agnost
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Stats is a point-in-time snapshot of the SDK's internal state
+type Stats struct {
+	// Sessions contains the activity summary of every cached session
+	Sessions []SessionSummary
+
+	// TruncationRatios is the fraction of recent outputs truncated, per tool
+	TruncationRatios map[string]float64
+
+	// Tools contains call outcome counters, per tool
+	Tools map[string]ToolStats
+
+	// Delivery reports the SDK's delivery reliability over Config.DeliveryReportWindow
+	Delivery DeliveryReport
+
+	// QueuedEvents is the number of events waiting in the queue, QueuedBytes
+	// approximates the memory their payloads hold and SpoolBytes is the size
+	// of the spool file
+	QueuedEvents int
+	QueuedBytes  int64
+	SpoolBytes   int64
+
+	// OverflowBytes is the size of the overflow file, see Config.OverflowDir
+	OverflowBytes int64
+
+	// EventsQueued, EventsSent and EventsFailed count the events queued,
+	// recorded by the collector and failed for good since startup, and
+	// EventsRetried the send attempts repeated after a failure. Dropped events
+	// are counted in Drops.
+	EventsQueued  int64
+	EventsSent    int64
+	EventsRetried int64
+	EventsFailed  int64
+
+	// LastSendError is the error of the latest failed send attempt and
+	// LastSendErrorAt when it failed, empty until an attempt fails
+	LastSendError   string
+	LastSendErrorAt time.Time
+
+	// PatchDuration is how long wrapping the server's tools took at Track
+	PatchDuration time.Duration
+
+	// Drops counts the events that left the pipeline undelivered, per reason;
+	// reasons that dropped nothing are omitted
+	Drops map[DropReason]int64
+
+	// InFlightCalls is the number of tracked tool calls currently executing
+	InFlightCalls int64
+
+	// InternalErrors is the number of panics recovered from the SDK and the
+	// hooks it calls, which never reach tool calls
+	InternalErrors int64
+
+	// IdentityFailures is the number of times the identify function failed
+	IdentityFailures int64
+
+	// IdentityCacheHits and IdentityCacheMisses count identity cache lookups
+	IdentityCacheHits   int64
+	IdentityCacheMisses int64
+
+	// BackoffSkips counts the sends failed locally, without dialing, while the
+	// endpoint cooled down after a transport error
+	BackoffSkips int64
+
+	// ClockJumps counts the wall-clock jumps of a minute or more noticed
+	// between the event worker's ticks, such as after the machine slept
+	ClockJumps int64
+
+	// DatagramsSent and DatagramsDropped count datagrams sent to a udp://
+	// endpoint and datagrams dropped for exceeding the size limit
+	DatagramsSent    int64
+	DatagramsDropped int64
+}
+
+// pipelineCounters counts the events through the event processor since
+// startup; they only ever grow and are read without locks
+type pipelineCounters struct {
+	queued  atomic.Int64
+	sent    atomic.Int64
+	retried atomic.Int64
+	failed  atomic.Int64
+
+	lastError atomic.Pointer[sendFailure]
+}
+
+// sendFailure is a failed send attempt
+type sendFailure struct {
+	err string
+	at  time.Time
+}
+
+// recordError remembers the error of a failed send attempt
+func (c *pipelineCounters) recordError(err error) {
+	c.lastError.Store(&sendFailure{err: err.Error(), at: time.Now()})
+}
+
+// addTo copies the counters into a Stats snapshot
+func (c *pipelineCounters) addTo(stats *Stats) {
+	stats.EventsQueued = c.queued.Load()
+	stats.EventsSent = c.sent.Load()
+	stats.EventsRetried = c.retried.Load()
+	stats.EventsFailed = c.failed.Load()
+	if failure := c.lastError.Load(); failure != nil {
+		stats.LastSendError = failure.err
+		stats.LastSendErrorAt = failure.at
+	}
+}
+
+// ToolStats contains call outcome counters for a single tool
+type ToolStats struct {
+	Calls            int64
+	Failures         int64
+	ValidationErrors int64
+	SLOBreaches      int64
+
+	// Denials counts the calls marked with MarkDenied
+	Denials int64
+
+	// CacheHits and CacheMisses count the calls marked with MarkCacheHit and
+	// MarkCacheMiss
+	CacheHits   int64
+	CacheMisses int64
+}
+
+// CacheHitRatio returns the fraction of hits among the tool's calls marked as
+// a cache hit or miss, 0 if none were
+func (s ToolStats) CacheHitRatio() float64 {
+	return cacheHitRatio(s.CacheHits, s.CacheMisses)
+}
+
+// toolStatsTracker accumulates per-tool call outcome counters
+type toolStatsTracker struct {
+	mu    sync.Mutex
+	tools map[string]*ToolStats
+}
+
+func newToolStatsTracker() *toolStatsTracker {
+	return &toolStatsTracker{
+		tools: make(map[string]*ToolStats),
+	}
+}
+
+// record counts the outcome of a tool call event
+func (t *toolStatsTracker) record(event *EventData) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	stats, exists := t.tools[event.PrimitiveName]
+	if !exists {
+		stats = &ToolStats{}
+		t.tools[event.PrimitiveName] = stats
+	}
+
+	stats.Calls++
+	if !event.Success {
+		stats.Failures++
+	}
+	switch event.ErrorType {
+	case ErrorTypeValidation:
+		stats.ValidationErrors++
+	case ErrorTypeDenied:
+		stats.Denials++
+	}
+	if event.SLOBreached != nil && *event.SLOBreached {
+		stats.SLOBreaches++
+	}
+	switch outcome, _ := cacheOutcome(event.Attributes); outcome {
+	case CacheHit:
+		stats.CacheHits++
+	case CacheMiss:
+		stats.CacheMisses++
+	}
+}
+
+// snapshot returns a copy of the counters of every tool
+func (t *toolStatsTracker) snapshot() map[string]ToolStats {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	snapshot := make(map[string]ToolStats, len(t.tools))
+	for name, stats := range t.tools {
+		snapshot[name] = *stats
+	}
+	return snapshot
+}
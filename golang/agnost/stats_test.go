@@ -0,0 +1,75 @@
+package agnost
+
+import (
+	"context"
+	"io"
+	"testing"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// trackedServer returns a server whose calls are reported to callback by a,
+// with a's logger and counters, and whose "wait" tool blocks until release is
+// closed
+func trackedServer(a *AgnostAnalytics, callback ToolCallback, started chan<- struct{}, release <-chan struct{}) *server.MCPServer {
+	log := newLevelLogger(NewLogger(io.Discard), "error")
+	log.recovered = &a.internalErrors
+	a.log.Store(log)
+
+	s := server.NewMCPServer("test", "1.0.0", server.WithToolHandlerMiddleware(ToolMiddleware()))
+	s.AddTool(mcp.NewTool("wait"), func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		started <- struct{}{}
+		<-release
+		return mcp.NewToolResultText("ok"), nil
+	})
+	trackerFor(s).setSink(nil, callback, nil, nil, nil, log)
+	a.serverAdapter = NewMCPGoAdapter(s)
+	return s
+}
+
+func TestStatsCountEachClientsCalls(t *testing.T) {
+	busy, idle := NewAgnostAnalytics(), NewAgnostAnalytics()
+	started, release := make(chan struct{}), make(chan struct{})
+	report := func(call *ToolCall) error { return nil }
+	s := trackedServer(busy, report, started, release)
+	trackedServer(idle, report, started, release)
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		callTool(t, s, "wait")
+	}()
+	<-started
+	if got := busy.Stats().InFlightCalls; got != 1 {
+		t.Errorf("busy client has %d calls in flight, want 1", got)
+	}
+	if got := idle.Stats().InFlightCalls; got != 0 {
+		t.Errorf("idle client has %d calls in flight, want 0", got)
+	}
+	close(release)
+	<-done
+	if got := busy.Stats().InFlightCalls; got != 0 {
+		t.Errorf("busy client has %d calls in flight after they returned", got)
+	}
+}
+
+func TestStatsCountEachClientsInternalErrors(t *testing.T) {
+	failing, healthy := NewAgnostAnalytics(), NewAgnostAnalytics()
+	started, release := make(chan struct{}, 1), make(chan struct{})
+	close(release)
+	s := trackedServer(failing, func(call *ToolCall) error { panic("callback") }, started, release)
+	trackedServer(healthy, func(call *ToolCall) error { return nil }, started, release)
+
+	before := InternalErrors()
+	callTool(t, s, "wait")
+	if got := failing.Stats().InternalErrors; got != 1 {
+		t.Errorf("failing client recovered %d panics, want 1", got)
+	}
+	if got := healthy.Stats().InternalErrors; got != 0 {
+		t.Errorf("healthy client recovered %d panics, want 0", got)
+	}
+	if got := InternalErrors() - before; got != 1 {
+		t.Errorf("process-wide count grew by %d, want 1", got)
+	}
+}
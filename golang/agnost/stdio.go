@@ -0,0 +1,51 @@
+package agnost
+
+import (
+	"fmt"
+	"io"
+	"os"
+)
+
+// Connection types that can be declared in Config.ConnectionType
+const (
+	ConnectionTypeStdio          = "stdio"
+	ConnectionTypeSSE            = "sse"
+	ConnectionTypeStreamableHTTP = "streamable-http"
+)
+
+// outputReporter is implemented by components that can report the writer they write to
+type outputReporter interface {
+	Output() io.Writer
+}
+
+// checkStdioSafety returns an error if the configuration would write to stdout
+// while the server uses the stdio transport, which corrupts the MCP protocol stream
+func checkStdioSafety(config *AgnostConfig) error {
+	if config.ConnectionType != ConnectionTypeStdio {
+		return nil
+	}
+
+	if config.Logger != nil && writesToStdout(config.Logger) {
+		return fmt.Errorf("logger writes to stdout, which corrupts the stdio transport; use agnost.StderrOnly()")
+	}
+
+	return nil
+}
+
+// writesToStdout makes a best-effort guess at whether w ends up writing to stdout
+func writesToStdout(w any) bool {
+	for depth := 0; depth < 8 && w != nil; depth++ {
+		if w == os.Stdout {
+			return true
+		}
+		if f, ok := w.(interface{ Fd() uintptr }); ok && f.Fd() == os.Stdout.Fd() {
+			return true
+		}
+		reporter, ok := w.(outputReporter)
+		if !ok {
+			return false
+		}
+		w = reporter.Output()
+	}
+	return false
+}
@@ -0,0 +1,85 @@
+package agnost
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// reportingWriter is a writer reporting the writer it forwards to
+type reportingWriter struct {
+	io.Writer
+}
+
+func (w reportingWriter) Output() io.Writer {
+	return w.Writer
+}
+
+// stdoutDescriptor is a writer on the stdout descriptor, such as a duplicate
+// of os.Stdout
+type stdoutDescriptor struct {
+	io.Writer
+}
+
+func (stdoutDescriptor) Fd() uintptr {
+	return os.Stdout.Fd()
+}
+
+func TestStdioSafetyRefusesLoggersWritingToStdout(t *testing.T) {
+	tests := []struct {
+		name   string
+		logger LogSink
+		unsafe bool
+	}{
+		{"default", nil, false},
+		{"StderrOnly", StderrOnly(), false},
+		{"buffer", NewLogger(&bytes.Buffer{}), false},
+		{"stdout", NewLogger(os.Stdout), true},
+		{"stdout's descriptor", NewLogger(stdoutDescriptor{io.Discard}), true},
+		{"wrapped stdout", NewLogger(reportingWriter{reportingWriter{os.Stdout}}), true},
+	}
+	for _, tt := range tests {
+		config := DefaultConfig()
+		config.Logger = tt.logger
+		config.ConnectionType = ConnectionTypeStdio
+		if err := checkStdioSafety(config); (err != nil) != tt.unsafe {
+			t.Errorf("%s logger over stdio: got %v, want unsafe %v", tt.name, err, tt.unsafe)
+		}
+		for _, connection := range []string{"", ConnectionTypeSSE, ConnectionTypeStreamableHTTP} {
+			config.ConnectionType = connection
+			if err := checkStdioSafety(config); err != nil {
+				t.Errorf("%s logger over %q: %v", tt.name, connection, err)
+			}
+		}
+	}
+}
+
+func TestTrackingAStdioServerFailsFastOnStdoutLogging(t *testing.T) {
+	collector := newEventCollector(t)
+	config := DefaultConfig()
+	config.Endpoint = collector.URL
+	config.ConnectionType = ConnectionTypeStdio
+	config.Logger = NewLogger(os.Stdout)
+
+	a := NewAgnostAnalytics()
+	s := server.NewMCPServer("test", "1.0.0")
+	err := a.TrackMCP(s, "org", config)
+	if err == nil || !strings.Contains(err.Error(), "StderrOnly") {
+		t.Fatalf("tracking with a stdout logger returned %v, want an error pointing at StderrOnly", err)
+	}
+	if a.IsInitialized() || len(collector.Sessions()) != 0 {
+		t.Error("the client started tracking despite the error")
+	}
+
+	config.Logger = StderrOnly()
+	config.LogLevel = "error"
+	config.EnableRequestQueuing = false
+	if err := a.TrackMCP(s, "org", config); err != nil {
+		t.Fatalf("tracking with StderrOnly failed: %v", err)
+	}
+	t.Cleanup(a.Shutdown)
+}
@@ -0,0 +1,146 @@
+package agnost
+
+import (
+	"container/list"
+	"context"
+	"strings"
+	"sync"
+)
+
+// defaultMaxTagValuesPerKey is used when Config.MaxTagValuesPerKey is not set
+const defaultMaxTagValuesPerKey = 100
+
+// maxTrackedTagKeys bounds how many tag keys the cardinality guard remembers;
+// the least recently used key is forgotten beyond it
+const maxTrackedTagKeys = 1000
+
+// HighCardinalityTagValue replaces new values of a tag key that has exceeded
+// Config.MaxTagValuesPerKey distinct values
+const HighCardinalityTagValue = "<high-cardinality>"
+
+// SetTag attaches a tag to the event of the tracked tool call running in ctx.
+// Keys and values are trimmed of surrounding whitespace and empty keys are
+// ignored. It is a no-op outside a tracked tool handler.
+func SetTag(ctx context.Context, key string, value string) {
+	state := callStateFromContext(ctx)
+	if state == nil {
+		return
+	}
+	state.mu.Lock()
+	defer state.mu.Unlock()
+	if state.tags == nil {
+		state.tags = make(map[string]string)
+	}
+	state.tags[key] = value
+}
+
+// WithTags attaches tags to a custom event recorded with RecordEvent
+func WithTags(tags map[string]string) RecordOption {
+	return func(o *recordOptions) {
+		o.tags = tags
+	}
+}
+
+// tagKeyValues is the set of values observed for one tag key
+type tagKeyValues struct {
+	key    string
+	values map[string]struct{}
+	warned bool
+}
+
+// tagGuard bounds the cardinality of tag values reported to the backend. It
+// remembers up to a fixed number of distinct values per key, in an LRU of keys,
+// and replaces new values of keys at capacity with HighCardinalityTagValue.
+type tagGuard struct {
+	maxValues int
+	exempt    []string // keys or glob patterns exempt from the limit
+	log       *levelLogger
+
+	mu   sync.Mutex
+	keys map[string]*list.Element // of *tagKeyValues
+	lru  *list.List               // most recently used key first
+}
+
+func newTagGuard(maxValues int, exempt []string, log *levelLogger) *tagGuard {
+	if maxValues <= 0 {
+		maxValues = defaultMaxTagValuesPerKey
+	}
+	return &tagGuard{
+		maxValues: maxValues,
+		exempt:    exempt,
+		log:       log,
+		keys:      make(map[string]*list.Element),
+		lru:       list.New(),
+	}
+}
+
+// apply returns the normalized tags with high-cardinality values replaced
+func (g *tagGuard) apply(tags map[string]string) map[string]string {
+	if len(tags) == 0 {
+		return nil
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	result := make(map[string]string, len(tags))
+	for key, value := range tags {
+		key = strings.TrimSpace(key)
+		if key == "" {
+			continue
+		}
+		result[key] = g.admit(key, strings.TrimSpace(value))
+	}
+	if len(result) == 0 {
+		return nil
+	}
+	return result
+}
+
+// admit returns value if the key may report it, or HighCardinalityTagValue
+func (g *tagGuard) admit(key string, value string) string {
+	if g.isExempt(key) {
+		return value
+	}
+
+	element, exists := g.keys[key]
+	if exists {
+		g.lru.MoveToFront(element)
+	} else {
+		element = g.lru.PushFront(&tagKeyValues{
+			key:    key,
+			values: make(map[string]struct{}),
+		})
+		g.keys[key] = element
+		if g.lru.Len() > maxTrackedTagKeys {
+			oldest := g.lru.Back()
+			g.lru.Remove(oldest)
+			delete(g.keys, oldest.Value.(*tagKeyValues).key)
+		}
+	}
+
+	entry := element.Value.(*tagKeyValues)
+	if _, seen := entry.values[value]; seen {
+		return value
+	}
+	if len(entry.values) < g.maxValues {
+		entry.values[value] = struct{}{}
+		return value
+	}
+
+	if !entry.warned {
+		entry.warned = true
+		g.log.Warning("Tag key '%s' exceeded %d distinct values; new values are reported as %q", key, g.maxValues, HighCardinalityTagValue)
+	}
+	return HighCardinalityTagValue
+}
+
+// isExempt reports whether the key is exempt from the cardinality limit
+func (g *tagGuard) isExempt(key string) bool {
+	for _, pattern := range g.exempt {
+		if matchPattern(pattern, key) {
+			return true
+		}
+	}
+	return false
+}
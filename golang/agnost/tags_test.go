@@ -0,0 +1,101 @@
+package agnost
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+func TestTagGuardReplacesValuesPastTheLimit(t *testing.T) {
+	var buf syncBuffer
+	g := newTagGuard(2, nil, newLevelLogger(NewLogger(&buf), "debug"))
+
+	for _, value := range []string{"a", "b", "a"} {
+		if got := g.apply(map[string]string{"user": value})["user"]; got != value {
+			t.Errorf("value %q within the limit reported as %q", value, got)
+		}
+	}
+	for range 2 {
+		if got := g.apply(map[string]string{"user": "c"})["user"]; got != HighCardinalityTagValue {
+			t.Errorf("value past the limit reported as %q", got)
+		}
+	}
+	if got := strings.Count(buf.String(), "exceeded 2 distinct values"); got != 1 {
+		t.Errorf("warned %d times about the key, want once:\n%s", got, buf.String())
+	}
+
+	// Other keys have limits of their own
+	if got := g.apply(map[string]string{"region": "c"})["region"]; got != "c" {
+		t.Errorf("value of another key reported as %q", got)
+	}
+}
+
+func TestTagGuardNormalizesTags(t *testing.T) {
+	g := newTagGuard(0, nil, newLevelLogger(nil, "error"))
+	got := g.apply(map[string]string{" env ": " prod ", "  ": "empty key"})
+	if len(got) != 1 || got["env"] != "prod" {
+		t.Errorf("got tags %v, want env=prod", got)
+	}
+	if got := g.apply(map[string]string{"": "x"}); got != nil {
+		t.Errorf("got tags %v for empty keys only, want none", got)
+	}
+	if g.maxValues != defaultMaxTagValuesPerKey {
+		t.Errorf("limit defaulted to %d, want %d", g.maxValues, defaultMaxTagValuesPerKey)
+	}
+}
+
+func TestTagGuardExemptKeys(t *testing.T) {
+	g := newTagGuard(1, []string{"trace", "req_*"}, newLevelLogger(nil, "error"))
+	for i := range 3 {
+		value := fmt.Sprint(i)
+		tags := g.apply(map[string]string{"trace": value, "req_id": value, "user": value})
+		if tags["trace"] != value || tags["req_id"] != value {
+			t.Errorf("exempt keys reported as %v", tags)
+		}
+		if i > 0 && tags["user"] != HighCardinalityTagValue {
+			t.Errorf("key that isn't exempt reported %q past the limit", tags["user"])
+		}
+	}
+}
+
+func TestTagGuardForgetsTheLeastRecentlyUsedKey(t *testing.T) {
+	g := newTagGuard(1, nil, newLevelLogger(nil, "error"))
+	g.apply(map[string]string{"first": "a"})
+	for i := range maxTrackedTagKeys {
+		g.apply(map[string]string{fmt.Sprint("key", i): "a"})
+	}
+	if got := len(g.keys); got != maxTrackedTagKeys {
+		t.Errorf("remembered %d keys, want %d", got, maxTrackedTagKeys)
+	}
+	// The forgotten key starts over with a fresh set of values
+	if got := g.apply(map[string]string{"first": "b"})["first"]; got != "b" {
+		t.Errorf("value of a forgotten key reported as %q", got)
+	}
+}
+
+func TestSetTagTagsTheCallsEvent(t *testing.T) {
+	s, _, collector := newTrackedServer(t, func(config *AgnostConfig) {
+		config.MaxTagValuesPerKey = 1
+	})
+	s.AddTool(mcp.NewTool("tagged"), func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		SetTag(ctx, "plan", fmt.Sprint("plan-", len(collector.Events("tool"))))
+		return mcp.NewToolResultText("ok"), nil
+	})
+	SetTag(context.Background(), "outside", "ignored")
+
+	callTool(t, s, "tagged")
+	callTool(t, s, "tagged")
+	events := collector.Events("tool")
+	if len(events) != 2 {
+		t.Fatalf("got %d tool events, want 2", len(events))
+	}
+	if got := events[0].Tags["plan"]; got != "plan-0" {
+		t.Errorf("first call tagged %q, want plan-0", got)
+	}
+	if got := events[1].Tags["plan"]; got != HighCardinalityTagValue {
+		t.Errorf("second call tagged %q past the limit", got)
+	}
+}
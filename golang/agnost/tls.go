@@ -0,0 +1,78 @@
+package agnost
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+)
+
+// TLSConfig configures TLS to the collector, such as trusting a private CA or
+// presenting a client certificate for mutual TLS
+type TLSConfig struct {
+	// CAFile is a PEM bundle of the CAs trusted to sign the collector's
+	// certificate, in place of the system roots
+	CAFile string
+
+	// CAPEM is a PEM bundle of CAs like CAFile, trusted along with it
+	CAPEM []byte
+
+	// CertFile and KeyFile are the PEM files of the client certificate and
+	// its key, presented to the collector
+	CertFile string
+	KeyFile  string
+
+	// CertPEM and KeyPEM are the client certificate and key as PEM, in place
+	// of CertFile and KeyFile
+	CertPEM []byte
+	KeyPEM  []byte
+
+	// InsecureSkipVerify accepts any certificate the collector presents.
+	// Only for development: it leaves the connection open to interception.
+	InsecureSkipVerify bool
+}
+
+// build loads the CAs and client certificate into a tls.Config, warning
+// through log if verification is disabled
+func (c *TLSConfig) build(log *levelLogger) (*tls.Config, error) {
+	config := &tls.Config{MinVersion: tls.VersionTLS12}
+
+	if c.CAFile != "" || len(c.CAPEM) > 0 {
+		pool := x509.NewCertPool()
+		if c.CAFile != "" {
+			bundle, err := os.ReadFile(c.CAFile)
+			if err != nil {
+				return nil, fmt.Errorf("failed to read TLS CA file: %v", err)
+			}
+			if !pool.AppendCertsFromPEM(bundle) {
+				return nil, fmt.Errorf("no certificates found in TLS CA file %s", c.CAFile)
+			}
+		}
+		if len(c.CAPEM) > 0 && !pool.AppendCertsFromPEM(c.CAPEM) {
+			return nil, errors.New("no certificates found in TLS CAPEM")
+		}
+		config.RootCAs = pool
+	}
+
+	var cert tls.Certificate
+	var err error
+	switch {
+	case len(c.CertPEM) > 0 || len(c.KeyPEM) > 0:
+		cert, err = tls.X509KeyPair(c.CertPEM, c.KeyPEM)
+	case c.CertFile != "" || c.KeyFile != "":
+		cert, err = tls.LoadX509KeyPair(c.CertFile, c.KeyFile)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load TLS client certificate: %v", err)
+	}
+	if len(cert.Certificate) > 0 {
+		config.Certificates = []tls.Certificate{cert}
+	}
+
+	if c.InsecureSkipVerify {
+		log.Warning("TLS certificate verification of the collector is disabled; use InsecureSkipVerify only in development")
+		config.InsecureSkipVerify = true
+	}
+	return config, nil
+}
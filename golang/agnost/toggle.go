@@ -0,0 +1,66 @@
+package agnost
+
+import "context"
+
+// Disable turns the global analytics client's tracking off, see
+// AgnostAnalytics.Disable
+func Disable() {
+	defaultClient.Disable()
+}
+
+// Enable turns the global analytics client's tracking back on
+func Enable() {
+	defaultClient.Enable()
+}
+
+// Disable turns tracking off without restarting, as a kill switch while the
+// analytics backend misbehaves. Tracked handlers then run as if they weren't
+// wrapped, beyond checking the switch; calls already running are still
+// recorded. Events queued before are still delivered, and custom events
+// recorded with RecordEvent, Submit or Capture aren't affected. Safe to call
+// at any time, also before Track.
+func (a *AgnostAnalytics) Disable() {
+	if !a.disabled.Swap(true) {
+		a.logger().Info("Analytics tracking disabled")
+	}
+}
+
+// Enable turns tracking back on after Disable
+func (a *AgnostAnalytics) Enable() {
+	if a.disabled.Swap(false) {
+		a.logger().Info("Analytics tracking enabled")
+	}
+}
+
+// Enabled reports whether tracking is on, which it is unless Disable was called
+func (a *AgnostAnalytics) Enabled() bool {
+	return !a.disabled.Load()
+}
+
+// trackingSwitch is implemented by adapters that can bypass tracking while it
+// is disabled
+type trackingSwitch interface {
+	// SetTrackingSwitch makes the handlers the adapter patches skip tracking
+	// while enabled returns false
+	SetTrackingSwitch(enabled func() bool)
+}
+
+// SetTrackingSwitch makes the patched tool, prompt and resource handlers call
+// the original handler directly while enabled returns false
+func (a *MCPGoAdapter) SetTrackingSwitch(enabled func() bool) {
+	a.enabled = enabled
+}
+
+// switchable returns a handler running tracked while enabled returns true and
+// plain otherwise, or tracked itself if enabled is nil
+func switchable[Req any, Res any](enabled func() bool, tracked func(context.Context, Req) (Res, error), plain func(context.Context, Req) (Res, error)) func(context.Context, Req) (Res, error) {
+	if enabled == nil {
+		return tracked
+	}
+	return func(ctx context.Context, request Req) (Res, error) {
+		if !enabled() {
+			return plain(ctx, request)
+		}
+		return tracked(ctx, request)
+	}
+}
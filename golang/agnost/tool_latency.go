@@ -0,0 +1,98 @@
+package agnost
+
+import (
+	"cmp"
+	"slices"
+	"sync"
+	"time"
+)
+
+const (
+	// maxLatencyTools caps the tools whose latency is sketched between two
+	// reports; calls of further tools are only counted
+	maxLatencyTools = 100
+
+	// latencyReportTools is how many of the most called tools a report lists
+	latencyReportTools = 10
+)
+
+// latencySketch approximates a latency distribution in fixed memory, binning
+// latencies on the same bounds as delivery latencies. Percentiles are rounded
+// up to a bin bound.
+type latencySketch struct {
+	count int64
+	bins  [len(latencyBounds) + 1]int64
+}
+
+func (s *latencySketch) add(latency time.Duration) {
+	s.count++
+	s.bins[latencyBin(latency)]++
+}
+
+func (s *latencySketch) percentile(p float64) time.Duration {
+	return latencyPercentile(&s.bins, s.count, p)
+}
+
+// toolLatencyTracker sketches the latency of tool calls per tool between two
+// delivery reports
+type toolLatencyTracker struct {
+	mu        sync.Mutex
+	tools     map[string]*latencySketch
+	untracked int64 // calls of tools beyond maxLatencyTools
+}
+
+func newToolLatencyTracker() *toolLatencyTracker {
+	return &toolLatencyTracker{
+		tools: make(map[string]*latencySketch),
+	}
+}
+
+// record adds a tool call's latency, in milliseconds
+func (t *toolLatencyTracker) record(tool string, latency int64) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	sketch, exists := t.tools[tool]
+	if !exists {
+		if len(t.tools) >= maxLatencyTools {
+			t.untracked++
+			return
+		}
+		sketch = &latencySketch{}
+		t.tools[tool] = sketch
+	}
+	sketch.add(time.Duration(latency) * time.Millisecond)
+}
+
+// addStats adds the call count and p50 and p95 latency of the most called
+// tools since the previous report to a report's attributes, then starts over.
+// Calls of the tools left out are counted in unlisted_tool_calls.
+func (t *toolLatencyTracker) addStats(attributes map[string]any) {
+	t.mu.Lock()
+	tools, untracked := t.tools, t.untracked
+	t.tools, t.untracked = make(map[string]*latencySketch, len(tools)), 0
+	t.mu.Unlock()
+
+	names := make([]string, 0, len(tools))
+	for name := range tools {
+		names = append(names, name)
+	}
+	slices.SortFunc(names, func(a, b string) int {
+		return cmp.Or(cmp.Compare(tools[b].count, tools[a].count), cmp.Compare(a, b))
+	})
+
+	unlisted := untracked
+	for i, name := range names {
+		sketch := tools[name]
+		if i >= latencyReportTools {
+			unlisted += sketch.count
+			continue
+		}
+		attributes["tool_calls_"+name] = sketch.count
+		attributes["tool_p50_ms_"+name] = sketch.percentile(0.50).Milliseconds()
+		attributes["tool_p95_ms_"+name] = sketch.percentile(0.95).Milliseconds()
+	}
+	if unlisted > 0 {
+		attributes["unlisted_tool_calls"] = unlisted
+	}
+}
@@ -0,0 +1,116 @@
+package agnost
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+// checkPercentile checks that a sketched percentile is no lower than the exact
+// one and no higher than the bound of the bin holding it
+func checkPercentile(t *testing.T, name string, got int64, exact time.Duration) {
+	t.Helper()
+	bound := latencyBounds[min(latencyBin(exact), len(latencyBounds)-1)]
+	if got < exact.Milliseconds() || got > bound.Milliseconds() {
+		t.Errorf("%s is %dms, want between the exact %v and its bin's bound %v", name, got, exact, bound)
+	}
+}
+
+func TestToolLatencyPercentilesOfAKnownDistribution(t *testing.T) {
+	tracker := newToolLatencyTracker()
+	// 1ms to 200ms, once each: p50 is 100ms and p95 190ms
+	for latency := int64(1); latency <= 200; latency++ {
+		tracker.record("search", latency)
+	}
+	// A fast tool with a slow tail: p50 is 5ms, p95 2s
+	for i := range 100 {
+		latency := int64(5)
+		if i >= 90 {
+			latency = 2000
+		}
+		tracker.record("lookup", latency)
+	}
+
+	attributes := map[string]any{}
+	tracker.addStats(attributes)
+	if got := attributes["tool_calls_search"]; got != int64(200) {
+		t.Errorf("counted %v calls of search, want 200", got)
+	}
+	checkPercentile(t, "search p50", attributes["tool_p50_ms_search"].(int64), 100*time.Millisecond)
+	checkPercentile(t, "search p95", attributes["tool_p95_ms_search"].(int64), 190*time.Millisecond)
+	checkPercentile(t, "lookup p50", attributes["tool_p50_ms_lookup"].(int64), 5*time.Millisecond)
+	checkPercentile(t, "lookup p95", attributes["tool_p95_ms_lookup"].(int64), 2*time.Second)
+
+	// Each report starts over
+	attributes = map[string]any{}
+	tracker.addStats(attributes)
+	if len(attributes) != 0 {
+		t.Errorf("second report has %v without calls in between", attributes)
+	}
+}
+
+func TestLatencyPercentileOfAnEmptyOrSlowSketch(t *testing.T) {
+	var s latencySketch
+	if got := s.percentile(0.95); got != 0 {
+		t.Errorf("empty sketch has p95 %v, want 0", got)
+	}
+	s.add(time.Hour)
+	if got, last := s.percentile(0.95), latencyBounds[len(latencyBounds)-1]; got != last {
+		t.Errorf("latency beyond the last bound has p95 %v, want %v", got, last)
+	}
+}
+
+func TestToolLatencyReportsListTheMostCalledTools(t *testing.T) {
+	tracker := newToolLatencyTracker()
+	for i := range maxLatencyTools + 5 {
+		for range i + 1 {
+			tracker.record(fmt.Sprintf("tool%03d", i), 10)
+		}
+	}
+
+	attributes := map[string]any{}
+	tracker.addStats(attributes)
+	listed := 0
+	for i := range maxLatencyTools {
+		if _, ok := attributes[fmt.Sprintf("tool_calls_tool%03d", i)]; ok {
+			listed++
+			if i < maxLatencyTools-latencyReportTools {
+				t.Errorf("tool%03d is listed, but isn't among the most called", i)
+			}
+		}
+	}
+	if listed != latencyReportTools {
+		t.Errorf("listed %d tools, want %d", listed, latencyReportTools)
+	}
+	// Calls of the tools beyond the cap are only counted
+	var total, listedCalls int64
+	for i := range maxLatencyTools + 5 {
+		total += int64(i + 1)
+	}
+	for i := maxLatencyTools - latencyReportTools; i < maxLatencyTools; i++ {
+		listedCalls += int64(i + 1)
+	}
+	if got := attributes["unlisted_tool_calls"]; got != total-listedCalls {
+		t.Errorf("counted %v unlisted calls, want %d", got, total-listedCalls)
+	}
+}
+
+func TestDeliveryReportsCarryToolLatency(t *testing.T) {
+	s, _, collector := newTrackedServer(t, func(config *AgnostConfig) {
+		config.DeliveryReportInterval = 20 * time.Millisecond
+		config.CollectToolLatency = true
+	})
+	addEchoTool(s)
+	callTool(t, s, "echo")
+
+	if !waitUntil(func() bool {
+		for _, report := range collector.Events("sdk") {
+			if report.Attributes["tool_calls_echo"] == float64(1) && report.Attributes["tool_p95_ms_echo"] != nil {
+				return true
+			}
+		}
+		return false
+	}) {
+		t.Error("no delivery report has the latency of the echo call")
+	}
+}
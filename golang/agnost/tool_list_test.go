@@ -0,0 +1,77 @@
+package agnost
+
+import (
+	"context"
+	"encoding/json"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+func TestSessionToolListsAreCapped(t *testing.T) {
+	for _, tt := range []struct {
+		max  int
+		want []string
+	}{
+		{0, []string{"a", "b", "c", "d", "e"}},
+		{3, []string{"a", "b", "c"}},
+		{5, []string{"a", "b", "c", "d", "e"}},
+	} {
+		s := server.NewMCPServer("test", "1.0.0", server.WithToolHandlerMiddleware(ToolMiddleware()))
+		for _, name := range []string{"e", "c", "a", "d", "b"} {
+			addCachedTool(s, name, nil)
+		}
+		collector := trackServer(t, s, NewAgnostAnalytics(), func(config *AgnostConfig) {
+			config.MaxToolsInSession = tt.max
+		})
+
+		session := collector.Sessions()[0]
+		tools := slices.Sorted(slices.Values(session.Tools))
+		if !slices.Equal(tools, tt.want) || session.ToolCount != 5 {
+			t.Errorf("max %d: session lists %v of %d tools, want %v of 5", tt.max, session.Tools, session.ToolCount, tt.want)
+		}
+	}
+}
+
+func TestToolSchemasAreCapturedOnTheFirstCall(t *testing.T) {
+	s, _, collector := newTrackedServer(t, func(config *AgnostConfig) {
+		config.CaptureToolSchemas = true
+	})
+	handler := func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		return mcp.NewToolResultText("ok"), nil
+	}
+	s.AddTool(mcp.NewTool("weather", mcp.WithString("city")), handler)
+	s.AddTool(mcp.NewToolWithRawSchema("raw", "", json.RawMessage(`{"type":"object","properties":{"q":{"type":"string"}}}`)), handler)
+	for _, name := range []string{"weather", "weather", "raw"} {
+		callTool(t, s, name)
+	}
+
+	events := collector.Events("tool")
+	if len(events) != 3 {
+		t.Fatalf("got %d events, want 3", len(events))
+	}
+	var schema map[string]any
+	if err := json.Unmarshal([]byte(events[0].ToolSchema), &schema); err != nil || !strings.Contains(events[0].ToolSchema, `"city"`) {
+		t.Errorf("first weather event carries schema %q, want its input schema", events[0].ToolSchema)
+	}
+	if events[1].ToolSchema != "" {
+		t.Errorf("second weather event carries schema %q, want none", events[1].ToolSchema)
+	}
+	if want := `{"type":"object","properties":{"q":{"type":"string"}}}`; events[2].ToolSchema != want {
+		t.Errorf("raw schema tool's event carries %q, want its raw schema", events[2].ToolSchema)
+	}
+}
+
+func TestToolSchemasAreOffByDefault(t *testing.T) {
+	s, _, collector := newTrackedServer(t, nil)
+	s.AddTool(mcp.NewTool("weather", mcp.WithString("city")), func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		return mcp.NewToolResultText("ok"), nil
+	})
+	callTool(t, s, "weather")
+	if schema := collector.Events("tool")[0].ToolSchema; schema != "" {
+		t.Errorf("event carries schema %q without CaptureToolSchemas", schema)
+	}
+}
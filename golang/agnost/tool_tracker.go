@@ -0,0 +1,303 @@
+package agnost
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// toolTracker tracks the tool calls of one server, and the prompt requests
+// and resource reads of the handlers wrapped with PromptHandler,
+// ResourceMiddleware and ResourceTemplateHandler. There is a single tracker
+// per server, so tracking a server again, such as after Shutdown, redirects
+// its calls to the new analytics client instead of wrapping them twice.
+type toolTracker struct {
+	server *server.MCPServer
+
+	mu       sync.RWMutex
+	pin      SessionPinFunc
+	callback ToolCallback
+	tracked  func(name string) bool // nil tracks every tool
+	tracer   Tracer                 // nil leaves calls untraced
+	enabled  func() bool            // nil tracks calls regardless
+	log      *levelLogger           // nil logs through the package logger
+
+	// The sinks of prompt requests and resource reads, nil until tracked
+	promptPin        SessionPinFunc
+	promptCallback   PromptCallback
+	resourcePin      SessionPinFunc
+	resourceCallback ResourceCallback
+
+	hashes map[string]string // tool name -> definition hash, computed on first sight
+
+	inFlight atomic.Int64 // tracked calls currently executing
+
+	wrapped map[string]bool // tools wrapped in place
+}
+
+// toolTrackers holds the tracker of every tracked server
+var toolTrackers sync.Map // *server.MCPServer -> *toolTracker
+
+// trackerFromContext returns the tracker of the server handling the request
+// in ctx, or nil if the server isn't tracked
+func trackerFromContext(ctx context.Context) *toolTracker {
+	t, tracked := toolTrackers.Load(server.ServerFromContext(ctx))
+	if !tracked {
+		return nil
+	}
+	return t.(*toolTracker)
+}
+
+// trackerFor returns the tracker of s, creating it on first use
+func trackerFor(s *server.MCPServer) *toolTracker {
+	t, _ := toolTrackers.LoadOrStore(s, &toolTracker{
+		server:  s,
+		hashes:  make(map[string]string),
+		wrapped: make(map[string]bool),
+	})
+	return t.(*toolTracker)
+}
+
+// setSink points the tracker's calls at the given pin function and callback,
+// tracking only the tools tracked accepts if it is set, tracing them with
+// tracer if it is set and only while enabled returns true if it is set, and
+// logging to log
+func (t *toolTracker) setSink(pin SessionPinFunc, callback ToolCallback, tracked func(name string) bool, tracer Tracer, enabled func() bool, log *levelLogger) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.pin = pin
+	t.callback = callback
+	t.tracked = tracked
+	t.tracer = tracer
+	t.enabled = enabled
+	t.log = log
+}
+
+// logger returns the logger of the client tracking the server
+func (t *toolTracker) logger() *levelLogger {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	return t.log
+}
+
+// setPromptSink points the tracker's prompt requests at the given pin function
+// and callback
+func (t *toolTracker) setPromptSink(pin SessionPinFunc, callback PromptCallback) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.promptPin = pin
+	t.promptCallback = callback
+}
+
+// setResourceSink points the tracker's resource reads at the given pin
+// function and callback
+func (t *toolTracker) setResourceSink(pin SessionPinFunc, callback ResourceCallback) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.resourcePin = pin
+	t.resourceCallback = callback
+}
+
+func (t *toolTracker) promptSink() (SessionPinFunc, PromptCallback) {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	return t.promptPin, t.promptCallback
+}
+
+func (t *toolTracker) resourceSink() (SessionPinFunc, ResourceCallback) {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	return t.resourcePin, t.resourceCallback
+}
+
+func (t *toolTracker) sink() (SessionPinFunc, ToolCallback) {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	return t.pin, t.callback
+}
+
+// spanStarter returns the current tracer's Start, or nil if calls aren't traced
+func (t *toolTracker) spanStarter() spanStarter {
+	t.mu.RLock()
+	tracer := t.tracer
+	t.mu.RUnlock()
+	if tracer == nil {
+		return nil
+	}
+	return tracer.Start
+}
+
+// tracks reports whether calls of the named tool are tracked
+func (t *toolTracker) tracks(name string) bool {
+	t.mu.RLock()
+	tracked := t.tracked
+	t.mu.RUnlock()
+	return tracked == nil || tracked(name)
+}
+
+// active reports whether tracking is enabled
+func (t *toolTracker) active() bool {
+	t.mu.RLock()
+	enabled := t.enabled
+	t.mu.RUnlock()
+	return enabled == nil || enabled()
+}
+
+// hash returns the definition hash of the named tool. Hashes are computed the
+// first time a tool is seen, so a tool replaced under the same name keeps the
+// hash of its first definition.
+func (t *toolTracker) hash(name string, tool *mcp.Tool) string {
+	t.mu.RLock()
+	hash, ok := t.hashes[name]
+	t.mu.RUnlock()
+	if ok {
+		return hash
+	}
+
+	hash = toolHash(tool, t.logger())
+	t.mu.Lock()
+	t.hashes[name] = hash
+	t.mu.Unlock()
+	return hash
+}
+
+// pinSession, report and traceCall forward to the current sink, for handlers
+// wrapped in place
+func (t *toolTracker) pinSession(ctx context.Context) (func() string, func()) {
+	pin, _ := t.sink()
+	if pin == nil {
+		return func() string { return "" }, func() {}
+	}
+	return pin(ctx)
+}
+
+func (t *toolTracker) report(call *ToolCall) error {
+	_, callback := t.sink()
+	if callback == nil {
+		return nil
+	}
+	return callback(call)
+}
+
+func (t *toolTracker) traceCall(ctx context.Context, primitiveType string, name string) (context.Context, Span) {
+	start := t.spanStarter()
+	if start == nil {
+		return ctx, nil
+	}
+	return start(ctx, primitiveType, name)
+}
+
+// ToolMiddleware returns a tool handler middleware tracking the calls of the
+// tracked server it runs on, to pass to server.WithToolHandlerMiddleware when
+// creating the server:
+//
+//	s := server.NewMCPServer("my-server", "1.0.0", server.WithToolHandlerMiddleware(agnost.ToolMiddleware()))
+//
+// Without it, Track only wraps the tools the server has at that point, as a
+// best effort. Calls it tracks aren't tracked a second time by those wrappers.
+func ToolMiddleware() server.ToolHandlerMiddleware {
+	return toolMiddleware
+}
+
+// toolMiddlewareKey marks the context of a call ToolMiddleware tracks with
+// the tracker tracking it
+type toolMiddlewareKey struct{}
+
+func toolMiddleware(next server.ToolHandlerFunc) server.ToolHandlerFunc {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		t := trackerFromContext(ctx)
+		if t == nil {
+			return next(ctx, request)
+		}
+		return t.handle(context.WithValue(ctx, toolMiddlewareKey{}, t), request, next)
+	}
+}
+
+// trackedByMiddleware reports whether ToolMiddleware already tracks the call
+// in ctx for t, returning ctx unmarked for the handler so its nested calls
+// are tracked
+func (t *toolTracker) trackedByMiddleware(ctx context.Context) (context.Context, bool) {
+	if marked, _ := ctx.Value(toolMiddlewareKey{}).(*toolTracker); marked != t {
+		return ctx, false
+	}
+	return context.WithValue(ctx, toolMiddlewareKey{}, (*toolTracker)(nil)), true
+}
+
+// handle tracks a call of next if its tool is tracked, looking up the tool's
+// definition on each call
+func (t *toolTracker) handle(ctx context.Context, request mcp.CallToolRequest, next server.ToolHandlerFunc) (*mcp.CallToolResult, error) {
+	name := request.Params.Name
+	if !t.active() || !t.tracks(name) {
+		return next(ctx, request)
+	}
+	var tool *mcp.Tool
+	var hash string
+	if serverTool, sessionScoped := t.lookup(ctx, name); serverTool != nil {
+		tool = &serverTool.Tool
+		// Sessions may define the same name differently, so session tools
+		// aren't hashed once per name
+		if sessionScoped {
+			hash = toolHash(tool, t.logger())
+		} else {
+			hash = t.hash(name, tool)
+		}
+	}
+	pin, callback := t.sink()
+	return wrapToolHandler(name, tool, hash, next, pin, callback, t.spanStarter(), t.logger(), &t.inFlight)(ctx, request)
+}
+
+// lookup returns the tool a call runs, or nil if it's gone. Like mcp-go, it
+// prefers the tools of the client session, reporting whether the tool is one.
+func (t *toolTracker) lookup(ctx context.Context, name string) (tool *server.ServerTool, sessionScoped bool) {
+	if session, ok := server.ClientSessionFromContext(ctx).(server.SessionWithTools); ok {
+		if tool, ok := session.GetSessionTools()[name]; ok {
+			return &tool, true
+		}
+	}
+	return t.server.GetTool(name), false
+}
+
+// wrapInPlace wraps the handlers of the server's current tracked tools,
+// skipping the ones it wrapped before, and returns how many it wrapped. It is
+// a best effort for servers created without ToolMiddleware: tools added later
+// stay untracked, and re-adding the tools notifies clients of a list change.
+func (t *toolTracker) wrapInPlace() int {
+	tools := t.server.ListTools()
+	wrappedTools := make([]server.ServerTool, 0, len(tools))
+
+	for name, toolPtr := range tools {
+		if !t.tracks(name) {
+			continue
+		}
+		t.mu.Lock()
+		done := toolPtr == nil || t.wrapped[name]
+		t.wrapped[name] = true
+		t.mu.Unlock()
+		if done {
+			continue
+		}
+		tool := &toolPtr.Tool
+		hash := t.hash(name, tool)
+		plain := toolPtr.Handler
+		wrappedTools = append(wrappedTools, server.ServerTool{
+			Tool: toolPtr.Tool,
+			Handler: func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+				ctx, marked := t.trackedByMiddleware(ctx)
+				if marked || !t.active() {
+					return plain(ctx, request)
+				}
+				return wrapToolHandler(name, tool, hash, plain, t.pinSession, t.report, t.traceCall, t.logger(), &t.inFlight)(ctx, request)
+			},
+		})
+		t.logger().Debug("Wrapped tool: %s", name)
+	}
+
+	// AddTools keeps the tools that are already wrapped
+	if len(wrappedTools) > 0 {
+		t.server.AddTools(wrappedTools...)
+	}
+	return len(wrappedTools)
+}
@@ -0,0 +1,164 @@
+package agnost
+
+import (
+	"context"
+	"encoding/json"
+	"slices"
+	"sync/atomic"
+	"testing"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// handleRequest sends a request to the server through its message handling,
+// so its middleware and hooks run, and fails the test unless it succeeds
+func handleRequest(t *testing.T, s *server.MCPServer, method string, params map[string]any) {
+	t.Helper()
+	handleRequestContext(t, s, context.Background(), method, params)
+}
+
+// handleRequestContext is handleRequest with the given context
+func handleRequestContext(t *testing.T, s *server.MCPServer, ctx context.Context, method string, params map[string]any) {
+	t.Helper()
+	message, _ := json.Marshal(map[string]any{"jsonrpc": "2.0", "id": 1, "method": method, "params": params})
+	response := s.HandleMessage(ctx, message)
+	if _, ok := response.(mcp.JSONRPCResponse); !ok {
+		t.Fatalf("%s: got %#v", method, response)
+	}
+}
+
+// callTool calls the named tool through the server's request handling
+func callTool(t *testing.T, s *server.MCPServer, name string) {
+	t.Helper()
+	handleRequest(t, s, "tools/call", map[string]any{"name": name, "arguments": map[string]any{}})
+}
+
+func addEchoTool(s *server.MCPServer) {
+	s.AddTool(mcp.NewTool("echo"), func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		return mcp.NewToolResultText("ok"), nil
+	})
+}
+
+// countCalls points the server's tracker at a callback counting the calls it reports
+func countCalls(s *server.MCPServer) *atomic.Int64 {
+	var calls atomic.Int64
+	trackerFor(s).setSink(nil, func(call *ToolCall) error {
+		calls.Add(1)
+		return nil
+	}, nil, nil, nil, nil)
+	return &calls
+}
+
+func TestToolMiddlewareTracksToolsAddedAnyTime(t *testing.T) {
+	s := server.NewMCPServer("test", "1.0.0", server.WithToolHandlerMiddleware(ToolMiddleware()))
+	calls := countCalls(s)
+	addEchoTool(s)
+
+	callTool(t, s, "echo")
+	if got := calls.Load(); got != 1 {
+		t.Errorf("reported %d calls, want 1", got)
+	}
+}
+
+func TestToolMiddlewareIgnoresUntrackedServers(t *testing.T) {
+	s := server.NewMCPServer("test", "1.0.0", server.WithToolHandlerMiddleware(ToolMiddleware()))
+	addEchoTool(s)
+	callTool(t, s, "echo")
+	if _, tracked := toolTrackers.Load(s); tracked {
+		t.Error("calling a tool of an untracked server created its tracker")
+	}
+}
+
+func TestToolMiddlewareAndWrappedHandlersReportCallsOnce(t *testing.T) {
+	s := server.NewMCPServer("test", "1.0.0", server.WithToolHandlerMiddleware(ToolMiddleware()))
+	addEchoTool(s)
+	calls := countCalls(s)
+	trackerFor(s).wrapInPlace()
+	callTool(t, s, "echo")
+	if got := calls.Load(); got != 1 {
+		t.Errorf("reported %d calls with the handler wrapped too, want 1", got)
+	}
+}
+
+func TestTrackWithoutToolMiddlewareWrapsTheExistingTools(t *testing.T) {
+	s := server.NewMCPServer("test", "1.0.0")
+	addEchoTool(s)
+	collector := trackServer(t, s, NewAgnostAnalytics(), nil)
+	addCachedTool(s, "late", nil)
+
+	callTool(t, s, "echo")
+	callTool(t, s, "late")
+	events := collector.Events("tool")
+	if len(events) != 1 || events[0].PrimitiveName != "echo" {
+		t.Errorf("got events %+v, want only the tool present at Track", events)
+	}
+}
+
+func TestToolFiltersMatchNamesAndPatterns(t *testing.T) {
+	tests := []struct {
+		include, exclude []string
+		tracked          []string
+	}{
+		{nil, nil, []string{"search", "search_debug", "fetch"}},
+		{[]string{"search_*"}, nil, []string{"search_debug"}},
+		{[]string{"search", "fetch"}, nil, []string{"search", "fetch"}},
+		{nil, []string{"*_debug"}, []string{"search", "fetch"}},
+		// Exclusions take precedence
+		{[]string{"search*"}, []string{"search_debug"}, []string{"search"}},
+		{[]string{"fetch"}, []string{"*"}, nil},
+	}
+	for _, tt := range tests {
+		config := DefaultConfig()
+		config.IncludeTools = tt.include
+		config.ExcludeTools = tt.exclude
+		var tracked []string
+		for _, name := range []string{"search", "search_debug", "fetch"} {
+			if config.tracksTool(name) {
+				tracked = append(tracked, name)
+			}
+		}
+		if !slices.Equal(tracked, tt.tracked) {
+			t.Errorf("include %v, exclude %v: tracked %v, want %v", tt.include, tt.exclude, tracked, tt.tracked)
+		}
+		if got := config.filtersTools(); got != (tt.include != nil || tt.exclude != nil) {
+			t.Errorf("include %v, exclude %v: filtersTools is %v", tt.include, tt.exclude, got)
+		}
+	}
+}
+
+func TestUntrackedToolsRecordNothing(t *testing.T) {
+	for _, middleware := range []bool{true, false} {
+		var opts []server.ServerOption
+		if middleware {
+			opts = append(opts, server.WithToolHandlerMiddleware(ToolMiddleware()))
+		}
+		s := server.NewMCPServer("test", "1.0.0", opts...)
+		var untrackedCalls atomic.Int64
+		for _, name := range []string{"search", "search_debug", "fetch"} {
+			addCachedTool(s, name, func(ctx context.Context) {
+				if name != "search" {
+					untrackedCalls.Add(1)
+				}
+			})
+		}
+		collector := trackServer(t, s, NewAgnostAnalytics(), func(config *AgnostConfig) {
+			config.IncludeTools = []string{"search*"}
+			config.ExcludeTools = []string{"*_debug"}
+		})
+
+		for _, name := range []string{"search", "search_debug", "fetch"} {
+			callTool(t, s, name)
+		}
+		events := collector.Events("tool")
+		if len(events) != 1 || events[0].PrimitiveName != "search" {
+			t.Errorf("middleware %v: got events %+v, want only search", middleware, events)
+		}
+		if got := untrackedCalls.Load(); got != 2 {
+			t.Errorf("middleware %v: untracked tools handled %d calls, want 2", middleware, got)
+		}
+		if tools := collector.Sessions()[0].Tools; len(tools) != 3 {
+			t.Errorf("middleware %v: session lists tools %v, want all three", middleware, tools)
+		}
+	}
+}
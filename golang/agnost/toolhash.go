@@ -0,0 +1,58 @@
+package agnost
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// toolHashLength is the number of hex characters kept of a tool hash
+const toolHashLength = 12
+
+// toolHash returns a short, stable hash of a tool's definition: its
+// description and input schema, serialized as canonical JSON so the hash is
+// the same in every process that registers the same tool
+func toolHash(tool *mcp.Tool, log *levelLogger) string {
+	schema := tool.RawInputSchema
+	if len(schema) == 0 {
+		var err error
+		if schema, err = json.Marshal(tool.InputSchema); err != nil {
+			log.Debug("Failed to serialize schema of tool '%s': %v", tool.Name, err)
+			return ""
+		}
+	}
+
+	definition, err := canonicalJSON(map[string]any{
+		"description":  tool.Description,
+		"input_schema": json.RawMessage(schema),
+	})
+	if err != nil {
+		log.Debug("Failed to hash tool '%s': %v", tool.Name, err)
+		return ""
+	}
+
+	hash := sha256.Sum256(definition)
+	return hex.EncodeToString(hash[:])[:toolHashLength]
+}
+
+// canonicalJSON serializes v with object keys sorted and insignificant
+// whitespace removed, whatever order and formatting raw JSON within v had
+func canonicalJSON(v any) ([]byte, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+
+	// Decoding into generic values and encoding again sorts object keys;
+	// numbers are kept verbatim
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var generic any
+	if err := decoder.Decode(&generic); err != nil {
+		return nil, err
+	}
+	return json.Marshal(generic)
+}
@@ -0,0 +1,73 @@
+package agnost
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"testing"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+func TestToolHashIgnoresSchemaFormatting(t *testing.T) {
+	log := newLevelLogger(NewLogger(io.Discard), "debug")
+	compact := mcp.NewToolWithRawSchema("search", "Search the docs",
+		json.RawMessage(`{"type":"object","properties":{"query":{"type":"string"},"limit":{"type":"number"}}}`))
+	reordered := mcp.NewToolWithRawSchema("find", "Search the docs", json.RawMessage(`{
+		"properties": {"limit": {"type": "number"}, "query": {"type": "string"}},
+		"type": "object"
+	}`))
+
+	hash := toolHash(&compact, log)
+	if len(hash) != toolHashLength {
+		t.Fatalf("got hash %q, want %d hex characters", hash, toolHashLength)
+	}
+	if got := toolHash(&reordered, log); got != hash {
+		t.Errorf("reformatted schema hashed to %q, want %q", got, hash)
+	}
+
+	described := compact
+	described.Description = "Search the documentation"
+	if toolHash(&described, log) == hash {
+		t.Error("description change kept the hash")
+	}
+	schema := mcp.NewToolWithRawSchema("search", "Search the docs",
+		json.RawMessage(`{"type":"object","properties":{"query":{"type":"string"}}}`))
+	if toolHash(&schema, log) == hash {
+		t.Error("schema change kept the hash")
+	}
+}
+
+func TestToolHashOfStructuredSchemaMatchesItsRawForm(t *testing.T) {
+	log := newLevelLogger(NewLogger(io.Discard), "debug")
+	structured := mcp.NewTool("echo", mcp.WithDescription("Echo text"), mcp.WithString("text"))
+	schema, _ := json.Marshal(structured.InputSchema)
+	raw := mcp.NewToolWithRawSchema("echo", "Echo text", schema)
+	if got, want := toolHash(&structured, log), toolHash(&raw, log); got != want {
+		t.Errorf("structured schema hashed to %q, raw form to %q", got, want)
+	}
+}
+
+func TestEventsCarryTheToolHash(t *testing.T) {
+	s, _, collector := newTrackedServer(t, nil)
+	addEchoTool(s)
+	tool := mcp.NewTool("echo")
+	want := toolHash(&tool, newLevelLogger(NewLogger(io.Discard), "debug"))
+
+	callTool(t, s, "echo")
+	// A tool replaced under the same name keeps its first hash
+	s.AddTool(mcp.NewTool("echo", mcp.WithDescription("Replaced")), func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		return mcp.NewToolResultText("replaced"), nil
+	})
+	callTool(t, s, "echo")
+
+	events := collector.Events("tool")
+	if len(events) != 2 {
+		t.Fatalf("got %d tool events, want 2", len(events))
+	}
+	for _, event := range events {
+		if event.ToolHash != want {
+			t.Errorf("event has tool hash %q, want %q", event.ToolHash, want)
+		}
+	}
+}
@@ -0,0 +1,78 @@
+package agnost
+
+import (
+	"context"
+)
+
+// Tracer starts a tracing span, such as an OpenTelemetry span, for every
+// tracked tool call; the agnostotel module provides one for OpenTelemetry.
+// Set it with Config.Tracer.
+type Tracer interface {
+	// Start starts the span of a call from the context the call arrived with
+	// and returns the context its handler runs with
+	Start(ctx context.Context, primitiveType string, name string) (context.Context, Span)
+}
+
+// Span is the tracing span of a tracked call
+type Span interface {
+	// IDs returns the span's trace and span IDs, recorded in the call's event
+	// so the backend can link the two, or empty strings if it has none
+	IDs() (traceID string, spanID string)
+
+	// End ends the span with the outcome of the call
+	End(outcome SpanOutcome)
+}
+
+// SpanOutcome is the outcome of a tracked call, for its span
+type SpanOutcome struct {
+	SessionID string
+	Success   bool
+	LatencyMs int64
+	Err       error // the handler's error, nil if it returned none
+}
+
+// spanStarter starts the span of a call, returning a nil Span when the call
+// isn't traced
+type spanStarter func(ctx context.Context, primitiveType string, name string) (context.Context, Span)
+
+// startSpan starts the span of a call with start, if set, recovering from its
+// panics
+func startSpan(start spanStarter, ctx context.Context, primitiveType string, name string, log *levelLogger) (context.Context, Span) {
+	if start == nil {
+		return ctx, nil
+	}
+	spanCtx, span := ctx, Span(nil)
+	guard(log, "Tracer.Start", func() {
+		spanCtx, span = start(ctx, primitiveType, name)
+	})
+	if spanCtx == nil {
+		spanCtx = ctx
+	}
+	return spanCtx, span
+}
+
+// spanIDs returns the trace and span IDs of span, if any
+func spanIDs(span Span, log *levelLogger) (traceID string, spanID string) {
+	if span == nil {
+		return "", ""
+	}
+	guard(log, "Span.IDs", func() {
+		traceID, spanID = span.IDs()
+	})
+	return traceID, spanID
+}
+
+// endSpan ends span, if any, recovering from its panics
+func endSpan(span Span, outcome SpanOutcome, log *levelLogger) {
+	if span == nil {
+		return
+	}
+	guard(log, "Span.End", func() { span.End(outcome) })
+}
+
+// tracerSetter is implemented by adapters that can trace tool calls
+type tracerSetter interface {
+	// SetTracer makes PatchServer start a span with tracer for every tracked
+	// tool call
+	SetTracer(tracer Tracer)
+}
@@ -0,0 +1,96 @@
+package agnost
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"time"
+)
+
+// newHTTPClient returns the client that sends every request to the
+// collector, set up with Config.TLS
+func newHTTPClient(config *AgnostConfig) (*http.Client, error) {
+	if config.HTTPClient != nil {
+		if config.TLS != nil {
+			return nil, errors.New("TLS can't be applied to HTTPClient; configure TLS on its transport instead")
+		}
+		return config.HTTPClient, nil
+	}
+
+	transport := config.Transport
+	if config.TLS != nil {
+		tlsConfig, err := config.TLS.build(config.logger())
+		if err != nil {
+			return nil, err
+		}
+		base, ok := transport.(*http.Transport)
+		if transport == nil {
+			base, ok = http.DefaultTransport.(*http.Transport)
+		}
+		if !ok {
+			return nil, errors.New("TLS can only be applied to an *http.Transport; configure TLS on Transport instead")
+		}
+		clone := base.Clone()
+		clone.TLSClientConfig = tlsConfig
+		transport = clone
+	}
+	return &http.Client{Transport: transport}, nil
+}
+
+// httpClient returns the client newHTTPClient sets up, or a client with
+// Config.Transport alone if Config.TLS can't be applied, logging why
+func (c *AgnostConfig) httpClient() *http.Client {
+	client, err := newHTTPClient(c)
+	if err != nil {
+		c.logger().Error("Failed to set up the HTTP client: %v", err)
+		return &http.Client{Transport: c.Transport}
+	}
+	return client
+}
+
+// doRequest sends a request to the collector with client, bounded by timeout
+// through the request's context rather than the client's Timeout, so a client
+// set with Config.HTTPClient is used as is. The timeout covers reading the
+// response body, until it is closed.
+func doRequest(client *http.Client, req *http.Request, timeout time.Duration) (*http.Response, error) {
+	if timeout <= 0 {
+		return client.Do(req)
+	}
+	ctx, cancel := context.WithTimeout(req.Context(), timeout)
+	resp, err := client.Do(req.WithContext(ctx))
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	resp.Body = &cancelOnClose{ReadCloser: resp.Body, cancel: cancel}
+	return resp, nil
+}
+
+// sleepContext waits for d, or returns ctx's error if it is done first
+func sleepContext(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return ctx.Err()
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// cancelOnClose releases the context of a request once its response body is
+// closed
+type cancelOnClose struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (b *cancelOnClose) Close() error {
+	err := b.ReadCloser.Close()
+	b.cancel()
+	return err
+}
@@ -0,0 +1,128 @@
+package agnost
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// countingTransport counts the requests it sends through the default transport
+type countingTransport struct {
+	requests atomic.Int64
+}
+
+func (c *countingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	c.requests.Add(1)
+	return http.DefaultTransport.RoundTrip(req)
+}
+
+func TestRequestsGoThroughTheConfiguredClient(t *testing.T) {
+	for _, useClient := range []bool{false, true} {
+		transport := &countingTransport{}
+		s, a, collector := newTrackedServer(t, func(config *AgnostConfig) {
+			if useClient {
+				config.HTTPClient = &http.Client{Transport: transport}
+			} else {
+				config.Transport = transport
+			}
+		})
+		addEchoTool(s)
+		callTool(t, s, "echo")
+		a.Shutdown()
+
+		// Session creation, the event and the session end
+		if got := transport.requests.Load(); got < 3 {
+			t.Errorf("client %v: sent %d requests through the transport, want at least 3", useClient, got)
+		}
+		if len(collector.Events("tool")) != 1 || len(collector.Ends()) != 1 {
+			t.Errorf("client %v: the collector didn't get the event and session end", useClient)
+		}
+	}
+}
+
+func TestHTTPClientIsUsedAsIs(t *testing.T) {
+	config := DefaultConfig()
+	config.HTTPClient = &http.Client{Timeout: time.Minute}
+	config.Transport = &countingTransport{}
+	client, err := newHTTPClient(config)
+	if err != nil || client != config.HTTPClient || client.Timeout != time.Minute {
+		t.Errorf("got client %+v, %v, want HTTPClient unchanged", client, err)
+	}
+}
+
+func TestRequestTimeoutBoundsTheResponseBody(t *testing.T) {
+	slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		w.(http.Flusher).Flush()
+		select {
+		case <-r.Context().Done():
+		case <-time.After(2 * time.Second):
+		}
+	}))
+	t.Cleanup(slow.Close)
+
+	req, _ := http.NewRequestWithContext(context.Background(), http.MethodGet, slow.URL, nil)
+	resp, err := doRequest(http.DefaultClient, req, 50*time.Millisecond)
+	if err != nil {
+		t.Fatalf("headers within the timeout failed: %v", err)
+	}
+	defer resp.Body.Close()
+	start := time.Now()
+	if _, err := io.ReadAll(resp.Body); err == nil {
+		t.Error("reading a body past the timeout succeeded")
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("body read took %v, past the timeout", elapsed)
+	}
+}
+
+func TestRequestTimeoutBoundsAnUnansweredRequest(t *testing.T) {
+	hung := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+	}))
+	t.Cleanup(hung.Close)
+
+	req, _ := http.NewRequestWithContext(context.Background(), http.MethodGet, hung.URL, nil)
+	if _, err := doRequest(&http.Client{}, req, 20*time.Millisecond); err == nil {
+		t.Error("unanswered request succeeded")
+	}
+}
+
+func TestEventProcessorsSendThroughTheirClient(t *testing.T) {
+	collector := newEventCollector(t)
+	config := DefaultConfig()
+	config.Logger = NewLogger(io.Discard)
+	config.Transport = &countingTransport{}
+	ep := NewEventProcessor(collector.URL, "org", config)
+	t.Cleanup(ep.Shutdown)
+	if err := ep.sendEvent(context.Background(), &EventData{SessionID: "s1", PrimitiveType: "tool", PrimitiveName: "echo"}); err != nil {
+		t.Fatal(err)
+	}
+	if got := config.Transport.(*countingTransport).requests.Load(); got == 0 {
+		t.Error("NewEventProcessor didn't send through Config.Transport")
+	}
+
+	injected := &countingTransport{}
+	ep = NewEventProcessorWithClient(collector.URL, "org", &http.Client{Transport: injected}, config)
+	t.Cleanup(ep.Shutdown)
+	if err := ep.sendEvent(context.Background(), &EventData{SessionID: "s1", PrimitiveType: "tool", PrimitiveName: "echo"}); err != nil {
+		t.Fatal(err)
+	}
+	if injected.requests.Load() == 0 {
+		t.Error("NewEventProcessorWithClient didn't send through the given client")
+	}
+}
+
+func TestConfigClientFallsBackWithoutTLS(t *testing.T) {
+	config := DefaultConfig()
+	config.Logger = NewLogger(io.Discard)
+	config.HTTPClient = &http.Client{}
+	config.TLS = &TLSConfig{}
+	if client := config.httpClient(); client == nil || client == config.HTTPClient {
+		t.Errorf("got client %+v, want a fallback for TLS on HTTPClient", client)
+	}
+}
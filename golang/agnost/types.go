@@ -1,8 +1,15 @@
 package agnost
 
 import (
+	"context"
+	"math/rand/v2"
 	"net/http"
+	"os"
+	"regexp"
+	"slices"
 	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
 )
 
 // UserIdentity represents user identification information as a flexible map
@@ -12,24 +19,91 @@ type UserIdentity map[string]interface{}
 // IdentifyFunc is a function that extracts user identity from request and environment
 type IdentifyFunc func(req *http.Request, env map[string]string) UserIdentity
 
+// IdentifyErrFunc is like IdentifyFunc but can report that identification failed
+type IdentifyErrFunc func(req *http.Request, env map[string]string) (UserIdentity, error)
+
 // AgnostConfig represents configuration for Agnost Analytics
 type AgnostConfig struct {
-	// Endpoint is the URL of the Agnost Analytics API
+	// Endpoint is the URL of the Agnost Analytics API. A "udp://host:port"
+	// endpoint sends events as fire-and-forget datagrams.
 	Endpoint string
 
+	// Region selects the endpoint of a data residency region ("us", "eu") when
+	// Endpoint is not set, and is recorded in sessions. An explicit Endpoint
+	// takes precedence.
+	Region string
+
+	// APIKey authenticates the SDK to the collector, sent as a bearer token
+	// with every request (default: the AGNOST_API_KEY environment variable)
+	APIKey string
+
+	// Headers are added to every request to the collector, such as the ones an
+	// ingress requires. The headers the SDK sets itself take precedence.
+	Headers map[string]string
+
+	// SessionEndpoint is the URL session payloads are sent to (default: Endpoint).
+	// Useful to keep sessions on HTTP while events use a datagram endpoint.
+	SessionEndpoint string
+
+	// DatagramMaxBytes caps the size of each datagram sent to a udp:// endpoint
+	// (default: 1400). Event payloads are dropped to fit; larger datagrams are dropped.
+	DatagramMaxBytes int
+
 	// DisableInput disables tracking of input arguments
 	DisableInput bool
 
 	// DisableOutput disables tracking of output results
 	DisableOutput bool
 
+	// DisableErrorDetails leaves the error message and error type name out of
+	// the events of failed tool calls
+	DisableErrorDetails bool
+
+	// PayloadPublicKey is a base64 X25519 public key, such as a NaCl box key,
+	// that captured inputs and outputs are encrypted to before they leave the
+	// process, so only the holder of the private key can read them; see
+	// GeneratePayloadKey and OpenPayload. Other event fields stay in plaintext.
+	// Payloads that fail to encrypt are dropped, never sent in plaintext.
+	PayloadPublicKey string
+
+	// RedactKeys lists keys, matched case-insensitively at any depth, whose
+	// values are captured as "[REDACTED]" in the inputs of every primitive
+	// type and in outputs (default: DefaultRedactKeys)
+	RedactKeys []string
+
+	// RedactKeyPatterns redacts the values of keys matching any of the
+	// patterns, like RedactKeys
+	RedactKeyPatterns []*regexp.Regexp
+
+	// ResourceQueryAllowlist lists the query parameters kept in captured
+	// resource URIs; all others are stripped, as they often carry tokens
+	ResourceQueryAllowlist []string
+
 	// EnableRequestQueuing enables background event queuing
 	EnableRequestQueuing bool
 
 	// BatchSize is the number of events to batch before sending
 	BatchSize int
 
-	// MaxRetries is the maximum number of retry attempts for failed requests
+	// QueueSize is the number of events buffered for the background worker
+	// before new events are dropped (default: 100)
+	QueueSize int
+
+	// QueueFullPolicy is what happens to an event that finds the queue full:
+	// QueueFullDrop (the default) drops it, QueueFullBlock waits up to
+	// QueueFullTimeout (default: 100ms) for room before dropping it, delaying
+	// the tool call that recorded it
+	QueueFullPolicy  string
+	QueueFullTimeout time.Duration
+
+	// Compression is the encoding of event and batch request bodies:
+	// CompressionNone (the default) or CompressionGzip, which compresses
+	// bodies of 1KB or more
+	Compression string
+
+	// MaxRetries is the maximum number of retry attempts for failed requests.
+	// With 0 every event is sent exactly once and RetryDelay is never waited;
+	// a config not made by DefaultConfig takes AGNOST_MAX_RETRIES for 0.
 	MaxRetries int
 
 	// RetryDelay is the delay between retry attempts
@@ -38,32 +112,475 @@ type AgnostConfig struct {
 	// RequestTimeout is the timeout for HTTP requests
 	RequestTimeout time.Duration
 
+	// HTTPClient, if set, sends every request to the collector, such as a
+	// shared client or one routed through a proxy. Its Timeout is left alone;
+	// RequestTimeout still bounds each request.
+	HTTPClient *http.Client
+
+	// Transport, if set and HTTPClient is not, is the transport of the SDK's
+	// own client, such as a tracing RoundTripper
+	Transport http.RoundTripper
+
+	// Tracer, if set, starts a tracing span for every tracked tool call, such
+	// as an OpenTelemetry span with the agnostotel module. Events record the
+	// span's trace and span IDs.
+	Tracer Tracer
+
+	// TLS configures TLS to the collector, such as a private CA or a client
+	// certificate for mutual TLS. It applies to the SDK's own client, over
+	// Transport if that is an *http.Transport.
+	TLS *TLSConfig
+
+	// SkipValidation skips checking the endpoints, counts, durations and log
+	// level at Initialize, for setups the checks don't anticipate
+	SkipValidation bool
+
+	// FireAndForget sends every event once with a short timeout, see
+	// fireAndForgetTimeout, and drops it on failure: retries are disabled
+	// whatever MaxRetries says and failed events are not spooled
+	FireAndForget bool
+
 	// Identify is a function to extract user identity
 	Identify IdentifyFunc
 
+	// SessionResumeWindow enables reusing the previous session after a process
+	// restart when the same client reconnects within the window (0 = disabled)
+	SessionResumeWindow time.Duration
+
+	// SessionTTL ends a cached session once it is older than the TTL, the next
+	// event of its client starting a fresh session (0 = sessions never expire)
+	SessionTTL time.Duration
+
+	// SessionBatchWindow enables batching session registrations, sending the
+	// sessions created within the window together (0 = register immediately)
+	SessionBatchWindow time.Duration
+
+	// SessionBatchSize sends a session batch early once it holds this many
+	// sessions (default: 50)
+	SessionBatchSize int
+
+	// StateDir is where state kept across restarts is stored
+	// (default: the "agnost" directory in the user cache directory)
+	StateDir string
+
+	// DeriveAnonymousIdentity gives sessions an anonymous identity when no
+	// Identify function is set: its user_id hashes the installation ID, kept
+	// in StateDir, with the client's name, and it is flagged "derived"
+	DeriveAnonymousIdentity bool
+
+	// StrictDelivery makes tool calls wait for their event to be delivered and
+	// fail when it can't be, instead of dropping analytics silently
+	StrictDelivery bool
+
+	// StrictTimeout bounds how long a tool call waits for delivery in strict
+	// mode (default: 5s)
+	StrictTimeout time.Duration
+
+	// SpoolDir enables spooling queued events that fail delivery to a file in
+	// this directory. Spooled events are retried periodically and after a
+	// restart. Spooled events count as delivered under strict delivery.
+	SpoolDir string
+
+	// DeliveryTarget is the record-to-delivery latency within which an event
+	// counts as delivered on time in delivery reports (default: 10s)
+	DeliveryTarget time.Duration
+
+	// DeliveryReportWindow is the period delivery reports cover, at most 15
+	// minutes (default: 5m)
+	DeliveryReportWindow time.Duration
+
+	// DeliveryReportInterval enables periodic delivery reports, recorded as
+	// "sdk"/"delivery_report" events and passed to OnDeliveryReport
+	DeliveryReportInterval time.Duration
+
+	// OnDeliveryReport receives every periodic delivery report
+	OnDeliveryReport func(report DeliveryReport)
+
+	// OnEventSent, OnEventFailed and OnEventDropped receive every event the
+	// collector recorded, every event whose delivery failed for good and every
+	// event the pipeline dropped from its queue, such as for a full queue or
+	// on shutdown. They run on a goroutine of their own, outside the SDK's
+	// locks, and must neither block for long nor modify the event; outcomes
+	// are dropped while they lag.
+	OnEventSent    func(event *EventData)
+	OnEventFailed  func(event *EventData, err error)
+	OnEventDropped func(event *EventData, reason DropReason)
+
+	// OnSessionLifecycle receives every session transition: creation,
+	// resumption, re-registration, eviction and end. It runs on a goroutine of
+	// its own and must not block for long; events are dropped while it lags.
+	OnSessionLifecycle func(event SessionLifecycleEvent)
+
+	// CollectRuntimeStats adds a runtime snapshot (goroutines, heap, queue,
+	// sessions and spool sizes) to the periodic delivery report events
+	CollectRuntimeStats bool
+
+	// CollectCPUTime adds the CPU time the whole process used since the
+	// previous report to the periodic delivery report events. It is not
+	// attributed to tools, and is omitted on platforms other than Unix.
+	CollectCPUTime bool
+
+	// CollectToolLatency adds the call count and approximate p50 and p95
+	// latency of the 10 most called tools since the previous report to the
+	// periodic delivery report events
+	CollectToolLatency bool
+
+	// SpoolMaxBytes caps the size of the spool file; events that don't fit
+	// are dropped (default: 10MB)
+	SpoolMaxBytes int64
+
+	// OverflowDir enables writing the events that find the queue full to a
+	// file in this directory instead of dropping them. The worker sends them
+	// once the queue has room and the collector is reachable, also after a
+	// restart.
+	OverflowDir string
+
+	// OverflowMaxBytes caps the size of the overflow file; the oldest events
+	// are dropped to make room for new ones (default: 10MB)
+	OverflowMaxBytes int64
+
+	// MaxToolsInSession caps the tool names sent with a session; servers with
+	// more tools send the first names in sorted order and the total count
+	// (default: 1000)
+	MaxToolsInSession int
+
+	// CaptureToolSchemas attaches a tool's input schema to the first event of
+	// each tool called, instead of capturing every schema up front
+	CaptureToolSchemas bool
+
+	// IdentifyE is a function to extract user identity that can fail. When an
+	// error is returned the session is created without identity. Takes
+	// precedence over Identify when both are set.
+	IdentifyE IdentifyErrFunc
+
+	// IdentifyPerCall also evaluates the identify function on every tool call
+	IdentifyPerCall bool
+
+	// IdentifyCacheTTL is how long per-call identities are cached (0 = no caching)
+	IdentifyCacheTTL time.Duration
+
+	// IdentifyCacheSize bounds the number of identities cached (default: 1000)
+	IdentifyCacheSize int
+
+	// IdentifyCacheKey returns the key a call's identity is cached under (default: its session ID)
+	IdentifyCacheKey func(req *http.Request) string
+
 	// LogLevel sets the logging level (debug, info, warning, error)
 	LogLevel string
+
+	// Logger receives the client's log messages at or above LogLevel in place
+	// of the SDK's default stderr logger, such as an adapter to zap
+	Logger LogSink
+
+	// ConnectionType declares the transport the server is served over
+	// (ConnectionTypeStdio, ConnectionTypeSSE, ConnectionTypeStreamableHTTP)
+	ConnectionType string
+
+	// MaxInputBytes caps the serialized size of captured input arguments, in
+	// bytes or with SizeLimitsInRunes in runes (0 = 64KB, negative =
+	// unlimited). Larger inputs are replaced with a truncation marker holding
+	// their original size and a preview.
+	MaxInputBytes int
+
+	// MaxOutputBytes caps the serialized size of captured output results like
+	// MaxInputBytes caps inputs
+	MaxOutputBytes int
+
+	// SizeLimitsInRunes measures MaxInputBytes, MaxOutputBytes and chunk sizes
+	// in characters (runes) instead of bytes. Truncation never splits a rune
+	// either way.
+	SizeLimitsInRunes bool
+
+	// ToolOverrides overrides capture settings for individual tools, keyed by tool name
+	ToolOverrides map[string]ToolOverride
+
+	// ToolOrigins labels where tools come from, keyed by tool name or glob
+	// pattern (e.g. "github_*": "github"), so that tools proxied from upstream
+	// servers can be told from the server's own. Labels set with SetToolOrigin
+	// take precedence; unlabeled tools are ToolOriginNative.
+	ToolOrigins map[string]string
+
+	// IncludeTools, when set, limits tracking to the listed tools, and
+	// ExcludeTools leaves the listed tools untracked, taking precedence. Both
+	// list tool names or glob patterns (e.g. "debug_*"). Calls of untracked
+	// tools go straight to their handler and record nothing; sessions still
+	// list every tool.
+	IncludeTools []string
+	ExcludeTools []string
+
+	// ToolSLOs sets latency budgets per tool, keyed by tool name or glob pattern
+	// (e.g. "search_*"). Events exceeding their budget are flagged as breached.
+	ToolSLOs map[string]time.Duration
+
+	// ValidationErrorPatterns are case-insensitive substrings that classify an
+	// error result's text as an argument validation error. Nil uses the defaults;
+	// an empty slice disables pattern matching.
+	ValidationErrorPatterns []string
+
+	// MaxCallDepth caps how many levels of nested tracked tool calls are linked
+	// to their parent event; deeper calls are recorded without a parent (default: 8)
+	MaxCallDepth int
+
+	// MaxTagValuesPerKey caps the distinct values reported per tag key; new
+	// values beyond it are reported as HighCardinalityTagValue (default: 100)
+	MaxTagValuesPerKey int
+
+	// ExemptTagKeys are tag keys or glob patterns not subject to MaxTagValuesPerKey
+	ExemptTagKeys []string
+
+	// TrackCompletions records a sample of completion/complete requests handled
+	// by a WrapCompletionHandler-wrapped handler
+	TrackCompletions bool
+
+	// CompletionSampleRate is the fraction of completion requests recorded
+	// (default: 0.05)
+	CompletionSampleRate float64
+
+	// SampleRate is the fraction of events recorded, drawn per event; the
+	// others are counted as sampled_out drops. Sessions and the SDK's own
+	// events are never sampled. 0 or 1 records every event (default: 1).
+	SampleRate float64
+
+	// CorrelationMetaKey is the _meta field holding the client's correlation ID,
+	// recorded with each call's event (default: "correlationId")
+	CorrelationMetaKey string
+
+	// SessionFromCorrelation groups calls into sessions by correlation ID, so the
+	// client and the analytics agree on session boundaries. Calls without one
+	// use the default session.
+	SessionFromCorrelation bool
+
+	// ServerName and ServerVersion are the server's declared name and version,
+	// recorded in sessions
+	ServerName    string
+	ServerVersion string
+
+	// ServerInstructions are the instructions the server was constructed with;
+	// sessions record only their hash by default
+	ServerInstructions string
+
+	// CaptureInstructionsPreview also records the first 200 bytes of the
+	// server's instructions in sessions
+	CaptureInstructionsPreview bool
+
+	// log is the logger of the client the configuration was given to, set by
+	// Initialize so that the client's components share it
+	log *levelLogger
+
+	// envApplied is set by DefaultConfig, whose fields are explicit once it
+	// applied the environment, so that Initialize doesn't fill them again
+	envApplied bool
+}
+
+// defaultValidationErrorPatterns are used when Config.ValidationErrorPatterns is nil
+var defaultValidationErrorPatterns = []string{
+	"invalid argument",
+	"invalid parameter",
+	"missing required",
+}
+
+// ToolOverride overrides capture settings for a single tool
+type ToolOverride struct {
+	// DisableInput disables tracking of input arguments for the tool
+	DisableInput bool
+
+	// DisableOutput disables tracking of output results for the tool
+	DisableOutput bool
+
+	// CaptureLargePayloads delivers inputs and outputs exceeding MaxInputBytes or
+	// MaxOutputBytes in full as ordered chunks instead of truncating them. Only
+	// used when the collector advertises chunk support; otherwise payloads are truncated.
+	CaptureLargePayloads bool
+}
+
+// defaultMaxPayloadBytes is the MaxInputBytes and MaxOutputBytes of configs
+// leaving them unset, well below the collector's request body limit
+const defaultMaxPayloadBytes = 64 << 10
+
+// defaultQueueSize is the event queue capacity when Config.QueueSize is not set
+const defaultQueueSize = 100
+
+// Policies for events that find the queue full, see Config.QueueFullPolicy
+const (
+	QueueFullDrop  = "drop"
+	QueueFullBlock = "block"
+)
+
+// defaultQueueFullTimeout is how long QueueFullBlock waits when
+// Config.QueueFullTimeout is not set
+const defaultQueueFullTimeout = 100 * time.Millisecond
+
+// fireAndForgetTimeout caps the request timeout under Config.FireAndForget
+const fireAndForgetTimeout = time.Second
+
+// maxRetries returns the number of retries after a failed send
+func (c *AgnostConfig) maxRetries() int {
+	if c.FireAndForget {
+		return 0
+	}
+	return max(c.MaxRetries, 0)
+}
+
+// filtersTools reports whether IncludeTools or ExcludeTools leave any tool
+// untracked
+func (c *AgnostConfig) filtersTools() bool {
+	return len(c.IncludeTools) > 0 || len(c.ExcludeTools) > 0
+}
+
+// tracksTool reports whether calls of the named tool are tracked under
+// IncludeTools and ExcludeTools
+func (c *AgnostConfig) tracksTool(name string) bool {
+	matches := func(pattern string) bool { return matchPattern(pattern, name) }
+	if slices.ContainsFunc(c.ExcludeTools, matches) {
+		return false
+	}
+	return len(c.IncludeTools) == 0 || slices.ContainsFunc(c.IncludeTools, matches)
+}
+
+// sampledOut draws whether an event of the given primitive type is sampled
+// out under SampleRate
+func (c *AgnostConfig) sampledOut(primitiveType string) bool {
+	if c.SampleRate <= 0 || c.SampleRate >= 1 || primitiveType == "sdk" {
+		return false
+	}
+	return rand.Float64() >= c.SampleRate
+}
+
+// queueSize returns the capacity of the event queue
+func (c *AgnostConfig) queueSize() int {
+	if c.QueueSize <= 0 {
+		return defaultQueueSize
+	}
+	return c.QueueSize
+}
+
+// queueFullTimeout returns how long an event waits for room in a full queue,
+// or 0 if it is dropped right away
+func (c *AgnostConfig) queueFullTimeout() time.Duration {
+	if c.QueueFullPolicy != QueueFullBlock {
+		return 0
+	}
+	if c.QueueFullTimeout <= 0 {
+		return defaultQueueFullTimeout
+	}
+	return c.QueueFullTimeout
+}
+
+// logger returns a logger for the components of a client, passing the
+// messages at or above LogLevel to Logger
+func (c *AgnostConfig) logger() *levelLogger {
+	if c.log != nil {
+		return c.log
+	}
+	return newLevelLogger(c.Logger, c.LogLevel)
+}
+
+// apiKey returns the API key sent to the collector, if any
+func (c *AgnostConfig) apiKey() string {
+	if c.APIKey != "" {
+		return c.APIKey
+	}
+	return os.Getenv(apiKeyEnv)
 }
 
-// DefaultConfig returns a default configuration
+// requestTimeout returns the timeout of a single HTTP request
+func (c *AgnostConfig) requestTimeout() time.Duration {
+	if c.FireAndForget && (c.RequestTimeout <= 0 || c.RequestTimeout > fireAndForgetTimeout) {
+		return fireAndForgetTimeout
+	}
+	return c.RequestTimeout
+}
+
+// identifyFunc returns the configured identify function, adapting Identify to
+// the error-returning signature, or nil if neither is set
+func (c *AgnostConfig) identifyFunc() IdentifyErrFunc {
+	if c.IdentifyE != nil {
+		return c.IdentifyE
+	}
+	if c.Identify != nil {
+		identify := c.Identify
+		return func(req *http.Request, env map[string]string) (UserIdentity, error) {
+			return identify(req, env), nil
+		}
+	}
+	return nil
+}
+
+// defaultCorrelationMetaKey is used when Config.CorrelationMetaKey is not set
+const defaultCorrelationMetaKey = "correlationId"
+
+// maxCorrelationIDBytes caps the length of recorded correlation IDs
+const maxCorrelationIDBytes = 128
+
+// correlationID returns the client's correlation ID from a request's _meta as
+// an opaque string capped to maxCorrelationIDBytes, or "" if there is none
+func (c *AgnostConfig) correlationID(meta *mcp.Meta) string {
+	if meta == nil {
+		return ""
+	}
+	key := c.CorrelationMetaKey
+	if key == "" {
+		key = defaultCorrelationMetaKey
+	}
+	value, ok := meta.AdditionalFields[key]
+	if !ok || value == nil {
+		return ""
+	}
+	id := metaString(value)
+	return id[:runeBoundary(id, maxCorrelationIDBytes)]
+}
+
+// inputDisabled reports whether input capture is disabled for the named primitive
+func (c *AgnostConfig) inputDisabled(name string) bool {
+	return c.DisableInput || c.ToolOverrides[name].DisableInput
+}
+
+// outputDisabled reports whether output capture is disabled for the named primitive
+func (c *AgnostConfig) outputDisabled(name string) bool {
+	return c.DisableOutput || c.ToolOverrides[name].DisableOutput
+}
+
+// DefaultConfig returns a default configuration, overridden by the AGNOST_*
+// environment variables that are set: AGNOST_ENDPOINT, AGNOST_REGION,
+// AGNOST_DISABLE_INPUT, AGNOST_DISABLE_OUTPUT, AGNOST_DISABLE_ERROR_DETAILS,
+// AGNOST_LOG_LEVEL, AGNOST_BATCH_SIZE, AGNOST_QUEUE_SIZE, AGNOST_MAX_RETRIES,
+// AGNOST_REQUEST_TIMEOUT (a duration such as "5s"), AGNOST_SAMPLE_RATE and
+// AGNOST_CONNECTION_TYPE. The fields of the returned config are explicit
+// from then on, so setting one to its zero value keeps it there. Initialize
+// fills the fields any other config leaves at their zero value from the same
+// variables, and fails on invalid values of either.
 func DefaultConfig() *AgnostConfig {
-	return &AgnostConfig{
-		Endpoint:             "https://api.agnost.ai",
+	config := &AgnostConfig{
+		Endpoint:             defaultEndpoint,
 		DisableInput:         false,
 		DisableOutput:        false,
 		EnableRequestQueuing: true,
 		BatchSize:            5,
+		QueueSize:            defaultQueueSize,
 		MaxRetries:           3,
 		RetryDelay:           1 * time.Second,
 		RequestTimeout:       5 * time.Second,
 		LogLevel:             "info",
+		RedactKeys:           slices.Clone(DefaultRedactKeys),
+		MaxInputBytes:        defaultMaxPayloadBytes,
+		MaxOutputBytes:       defaultMaxPayloadBytes,
+		SampleRate:           1,
 	}
+
+	// Invalid values are reported by Initialize
+	_ = applyEnv(config, true)
+	config.envApplied = true
+	return config
 }
 
 // SessionInfo represents session information from the server
 type SessionInfo struct {
 	SessionKey string
 	ClientName string
+
+	// SessionTools maps the tools registered for this client session only,
+	// which the server's tool list doesn't include, to their definition hash
+	SessionTools map[string]string
 }
 
 // SessionData represents a session in the analytics system
@@ -72,8 +589,49 @@ type SessionData struct {
 	ClientConfig   string       `json:"client_config"`
 	ConnectionType string       `json:"connection_type"`
 	IP             string       `json:"ip"`
+	Region         string       `json:"region,omitempty"`
 	Tools          []string     `json:"tools,omitempty"`
 	UserData       UserIdentity `json:"user_data,omitempty"`
+
+	// ToolCount is the number of tools the server has, which exceeds
+	// len(Tools) when the list was capped by Config.MaxToolsInSession
+	ToolCount int `json:"tool_count,omitempty"`
+
+	// SessionTools lists the tools registered for the session's client only,
+	// sorted; they are included in Tools as well
+	SessionTools []string `json:"session_tools,omitempty"`
+
+	// ToolHashes maps the listed tools to the hash of their definition, see
+	// EventData.ToolHash
+	ToolHashes map[string]string `json:"tool_hashes,omitempty"`
+
+	// ToolOrigins maps the listed tools that aren't native to their origin,
+	// see Config.ToolOrigins; tools missing from it are native
+	ToolOrigins map[string]string `json:"tool_origins,omitempty"`
+
+	// Resources lists the URIs of the server's resources and the URI templates
+	// of its resource templates, the names of their "resource" events.
+	// ResourceCount exceeds len(Resources) when the list was capped at
+	// maxTrackedResources.
+	Resources     []string `json:"resources,omitempty"`
+	ResourceCount int      `json:"resource_count,omitempty"`
+
+	// Prompts lists the names of the server's prompts, sorted
+	Prompts []string `json:"prompts,omitempty"`
+
+	// The server's declared name, version and instructions. The instructions
+	// are identified by their SHA-256 hash, so sessions can be grouped by variant.
+	ServerName          string `json:"server_name,omitempty"`
+	ServerVersion       string `json:"server_version,omitempty"`
+	InstructionsHash    string `json:"instructions_hash,omitempty"`
+	InstructionsPreview string `json:"instructions_preview,omitempty"`
+}
+
+// ServerInfo describes the MCP server being tracked
+type ServerInfo struct {
+	Name         string
+	Version      string
+	Instructions string
 }
 
 // SessionResponse represents the response from creating a session
@@ -81,8 +639,67 @@ type SessionResponse struct {
 	SessionID string `json:"session_id"`
 }
 
+// Session update kinds recorded in SessionUpdateData.Kind
+const (
+	SessionUpdateResumed = "resumed"
+
+	// SessionUpdateIdentified reports the session's new user, with UserData
+	SessionUpdateIdentified = "identified"
+)
+
+// SessionUpdateData represents a change to an existing session
+type SessionUpdateData struct {
+	SessionID string `json:"session_id"`
+	Kind      string `json:"kind"`
+	UpdatedAt int64  `json:"updated_at"` // unix milliseconds
+
+	UserData UserIdentity `json:"user_data,omitempty"`
+}
+
+// SessionSummary summarizes the activity observed in a session
+type SessionSummary struct {
+	SessionID       string  `json:"session_id"`
+	FirstEventAt    int64   `json:"first_event_at"` // unix milliseconds, 0 if no events
+	LastEventAt     int64   `json:"last_event_at"`  // unix milliseconds, 0 if no events
+	EventCount      int64   `json:"event_count"`
+	EventsPerMinute float64 `json:"events_per_minute"`
+
+	// MaxConcurrentCalls is the highest number of tool calls in flight observed in the session
+	MaxConcurrentCalls int64 `json:"max_concurrent_calls"`
+
+	// ResourcesAdvertised is the number of resources and resource templates in
+	// the latest listings, and ResourcesReadDistinct the number of distinct
+	// resources read; both need HookResources. Distinct reads beyond
+	// maxTrackedResources are counted in ResourcesReadOverflow.
+	ResourcesAdvertised   int   `json:"resources_advertised,omitempty"`
+	ResourcesReadDistinct int   `json:"resources_read_distinct,omitempty"`
+	ResourcesReadOverflow int64 `json:"resources_read_overflow,omitempty"`
+
+	// CacheHits and CacheMisses count the tool calls marked with MarkCacheHit
+	// and MarkCacheMiss; CacheHitRatio is the fraction of hits among them
+	CacheHits     int64   `json:"cache_hits,omitempty"`
+	CacheMisses   int64   `json:"cache_misses,omitempty"`
+	CacheHitRatio float64 `json:"cache_hit_ratio,omitempty"`
+
+	// Denials counts the tool calls marked with MarkDenied, per tool
+	Denials map[string]int64 `json:"denials,omitempty"`
+
+	// HadActivity reports whether the session recorded any event other than
+	// the SDK's own; TimeToFirstEventMs, absent without activity, is the time
+	// from the session's creation to its first such event
+	HadActivity        bool   `json:"had_activity"`
+	TimeToFirstEventMs *int64 `json:"time_to_first_event_ms,omitempty"`
+}
+
+// SessionEndData represents the payload sent when a session ends
+type SessionEndData struct {
+	SessionSummary
+	EndedAt int64 `json:"ended_at"` // unix milliseconds
+}
+
 // EventData represents an analytics event
 type EventData struct {
+	EventID       string `json:"event_id,omitempty"`
 	SessionID     string `json:"session_id"`
 	PrimitiveType string `json:"primitive_type"`
 	PrimitiveName string `json:"primitive_name"`
@@ -90,14 +707,251 @@ type EventData struct {
 	Success       bool   `json:"success"`
 	Input         string `json:"args,omitempty"`
 	Output        string `json:"result,omitempty"`
+	ErrorType     string `json:"error_type,omitempty"`
+
+	// DenialReason is the reason given to MarkDenied, set with ErrorTypeDenied
+	DenialReason string `json:"denial_reason,omitempty"`
+
+	// FailureKind tells how a tool call failed (FailureKindNone,
+	// FailureKindHandlerError, FailureKindToolError); tool events only
+	FailureKind string `json:"failure_kind,omitempty"`
+
+	// ErrorMessage is why a tool call failed: the handler's error, or the text
+	// of its error result; ErrorTypeName is the Go type of the handler's error
+	ErrorMessage  string `json:"error_message,omitempty"`
+	ErrorTypeName string `json:"error_type_name,omitempty"`
+
+	// DeliveryMode tells live events from spooled and replayed ones
+	// (DeliveryModeLive, DeliveryModeSpooled, DeliveryModeReplayed)
+	DeliveryMode string `json:"delivery_mode,omitempty"`
+
+	// EnqueuedAt is when the event was recorded and SentAt when it was last
+	// sent, in unix milliseconds; they differ widely for replayed events
+	EnqueuedAt int64 `json:"enqueued_at,omitempty"`
+	SentAt     int64 `json:"sent_at,omitempty"`
+
+	// ParentEventID is the event of the tracked tool call this call was made from
+	ParentEventID string `json:"parent_event_id,omitempty"`
+
+	// ConcurrentCalls is the number of tool calls of the server in flight when
+	// the call started
+	ConcurrentCalls int64 `json:"concurrent_calls,omitempty"`
+
+	// ProgressToken is the MCP progress token the client sent with the call, as a string
+	ProgressToken string `json:"progress_token,omitempty"`
+
+	// CorrelationID is the client's correlation ID from the call's _meta, see
+	// Config.CorrelationMetaKey
+	CorrelationID string `json:"correlation_id,omitempty"`
+
+	// ResultSummary describes the forms of content a tool result carried
+	ResultSummary *ResultSummary `json:"result_summary,omitempty"`
+
+	// Tags set with SetTag or WithTags, bounded by Config.MaxTagValuesPerKey
+	Tags map[string]string `json:"tags,omitempty"`
+
+	// Attributes set by the handler with SetEventAttribute
+	Attributes map[string]any `json:"attributes,omitempty"`
+
+	// Metadata attached with WithEventMetadata
+	Metadata map[string]any `json:"metadata,omitempty"`
+
+	// UserData is the identity of the call's user, if known
+	UserData UserIdentity `json:"user_data,omitempty"`
+
+	// Trace and span IDs of the call's span under Config.Tracer
+	TraceID string `json:"trace_id,omitempty"`
+	SpanID  string `json:"span_id,omitempty"`
+
+	// ToolHash is a short hash of the tool's description and input schema, to
+	// tell apart tools that kept their name across deployments but changed
+	ToolHash string `json:"tool_hash,omitempty"`
+
+	// ToolSchema is the tool's JSON input schema, sent with the first event of
+	// each tool under Config.CaptureToolSchemas
+	ToolSchema string `json:"tool_schema,omitempty"`
+
+	// Origin labels where the tool comes from, ToolOriginNative unless set
+	// with Config.ToolOrigins or SetToolOrigin; tool events only
+	Origin string `json:"origin,omitempty"`
+
+	// PayloadEncrypted reports that Input, Output and ErrorMessage are sealed to
+	// Config.PayloadPublicKey and base64 encoded
+	PayloadEncrypted bool `json:"payload_encrypted,omitempty"`
+
+	// TimeToFirstEventMs is set on the first event of a session, other than
+	// the SDK's own, to the milliseconds since the session was created
+	TimeToFirstEventMs *int64 `json:"time_to_first_event_ms,omitempty"`
+
+	// Latency SLO of the tool, see Config.ToolSLOs; omitted for tools without one
+	SLOMs       int64 `json:"slo_ms,omitempty"`
+	SLOBreached *bool `json:"slo_breached,omitempty"`
+
+	// Chunked payload delivery, see ToolOverride.CaptureLargePayloads
+	PayloadRef        string `json:"payload_ref,omitempty"`
+	InputChunks       int    `json:"args_chunks,omitempty"`
+	OutputChunks      int    `json:"result_chunks,omitempty"`
+	PayloadIncomplete bool   `json:"payload_incomplete,omitempty"`
+
+	// Oversized payloads pending chunked delivery
+	pendingInput  string
+	pendingOutput string
+
+	// delivered receives the outcome of the event's delivery, if someone waits for it
+	delivered chan error
+
+	// receipt is resolved with the outcome of the event's delivery, if it was submitted
+	receipt *Receipt
+
+	// deferrals counts the flushes that kept the event while the endpoint was unreachable
+	deferrals int
+
+	// enqueuedMonotonic is the monotonic time the event was recorded at, 0 for
+	// events of a previous process
+	enqueuedMonotonic time.Duration
+}
+
+// payloadBytes approximates the memory held by the event's payloads
+func (e *EventData) payloadBytes() int64 {
+	return int64(len(e.Input) + len(e.Output) + len(e.pendingInput) + len(e.pendingOutput))
+}
+
+// resolve reports the terminal outcome of the event's delivery
+func (e *EventData) resolve(err error) {
+	e.receipt.resolve(err)
+	if e.delivered == nil {
+		return
+	}
+	select {
+	case e.delivered <- err:
+	default:
+	}
+}
+
+// ResultSummary describes the content of a tool result. When the result carries
+// structured content, EventData.Output holds that object instead of the whole result.
+type ResultSummary struct {
+	ContentItems  int  `json:"content_items"`
+	HasText       bool `json:"has_text"`
+	HasStructured bool `json:"has_structured"`
+}
+
+// EventChunk is one piece of an oversized event payload
+type EventChunk struct {
+	PayloadRef string `json:"payload_ref"`
+	Field      string `json:"field"` // "args" or "result"
+	Index      int    `json:"index"`
+	Count      int    `json:"count"`
+	Data       string `json:"data"`
 }
 
+// Error types recorded in EventData.ErrorType
+const (
+	ErrorTypeValidation = "validation"
+	ErrorTypeDenied     = "denied"
+)
+
+// Failure kinds recorded in EventData.FailureKind: a Go error returned by the
+// handler, such as a crash or a timeout, or a result with IsError set, a
+// failure the tool reports to the client
+const (
+	FailureKindNone         = "none"
+	FailureKindHandlerError = "handler_error"
+	FailureKindToolError    = "tool_error"
+)
+
 // EventResponse represents the response from recording an event
 type EventResponse struct {
 	Success bool   `json:"success"`
 	EventID string `json:"event_id,omitempty"`
 }
 
+// EventBatchResponse represents the response from recording a batch of events.
+// Events missing from Rejected were recorded.
+type EventBatchResponse struct {
+	Success  bool            `json:"success"`
+	Rejected []RejectedEvent `json:"rejected,omitempty"`
+}
+
+// RejectedEvent identifies an event of a batch the collector did not record,
+// by its index in the batch
+type RejectedEvent struct {
+	Index int    `json:"index"`
+	Error string `json:"error,omitempty"`
+}
+
+// SessionPinFunc pins the session a tool call belongs to when the call starts,
+// without blocking it. sessionID returns the ID to attribute the call's event
+// to, waiting for the session if it is still being created, and release must
+// be called once the event has been recorded.
+type SessionPinFunc func(ctx context.Context) (sessionID func() string, release func())
+
+// ToolCall describes a completed tool call
+type ToolCall struct {
+	ToolName string
+
+	// Context is the context the call's handler ran with; without request
+	// queuing the call's event is sent within it
+	Context context.Context
+
+	// EventID identifies the call's event; ParentEventID is the event of the
+	// tracked call it was nested in, if any, and Depth its nesting level (1 at the top)
+	EventID       string
+	ParentEventID string
+	Depth         int
+
+	// SessionID is the session pinned at call start, or empty if none was pinned
+	SessionID string
+
+	Arguments any
+	Result    any
+	Success   bool
+	StartTime time.Time
+	ExecTime  int64 // milliseconds
+
+	// FailureKind tells a handler error from an error result, both of which
+	// leave Success false, and Err is the error the handler returned, or nil
+	FailureKind string
+	Err         error
+
+	// ValidationError reports whether the handler marked the call with MarkValidationError
+	ValidationError bool
+
+	// Denied reports whether the handler marked the call with MarkDenied, and
+	// DenialReason the reason it gave
+	Denied       bool
+	DenialReason string
+
+	// ConcurrentCalls is the number of tool calls of the server in flight when
+	// the call started, including itself
+	ConcurrentCalls int64
+
+	// ProgressToken is the call's MCP progress token normalized to a string, or empty if none was sent
+	ProgressToken string
+
+	// Meta is the request's _meta, or nil if none was sent
+	Meta *mcp.Meta
+
+	// Tool is the called tool's definition and ToolHash its hash, or nil and
+	// empty if the handler was wrapped without one
+	Tool     *mcp.Tool
+	ToolHash string
+
+	// Tags are the tags the handler set with SetTag
+	Tags map[string]string
+
+	// Attributes are the attributes the handler set with SetEventAttribute
+	Attributes map[string]any
+
+	// Metadata is the metadata attached with WithEventMetadata
+	Metadata map[string]any
+
+	// TraceID and SpanID identify the call's span under Config.Tracer, empty
+	// if it isn't traced
+	TraceID string
+	SpanID  string
+}
+
 // AnalyticsCallback is a callback function for recording tool execution
 type AnalyticsCallback func(
 	toolName string,
@@ -107,3 +961,8 @@ type AnalyticsCallback func(
 	result any,
 	startTime time.Time,
 )
+
+// ToolCallback is called with every completed tool call. A non-nil error
+// means the call's event could not be delivered and, under strict delivery,
+// the call must fail.
+type ToolCallback func(call *ToolCall) error
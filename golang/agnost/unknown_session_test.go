@@ -0,0 +1,123 @@
+package agnost
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"sync"
+	"testing"
+
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// forgetfulCollector answers the first forget events posted with respond, as
+// if it didn't know their session, and records every request in order
+type forgetfulCollector struct {
+	*httptest.Server
+	mu       sync.Mutex
+	requests []string // paths, in order
+	eventIDs []string // IDs of the events posted, in order
+}
+
+func newForgetfulCollector(t *testing.T, forget int, respond func(w http.ResponseWriter)) *forgetfulCollector {
+	c := &forgetfulCollector{}
+	c.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		c.requests = append(c.requests, r.URL.Path)
+		switch r.URL.Path {
+		case "/api/v1/capture-session":
+			var session SessionData
+			json.Unmarshal(body, &session)
+			json.NewEncoder(w).Encode(SessionResponse{SessionID: session.SessionID})
+		case "/api/v1/capture-event":
+			var event EventData
+			json.Unmarshal(body, &event)
+			c.eventIDs = append(c.eventIDs, event.EventID)
+			if len(c.eventIDs) <= forget {
+				respond(w)
+			}
+		}
+	}))
+	t.Cleanup(c.Close)
+	return c
+}
+
+func (c *forgetfulCollector) sequence() ([]string, []string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]string(nil), c.requests...), append([]string(nil), c.eventIDs...)
+}
+
+// trackForgetful tracks a new server against collector, sending events synchronously
+func trackForgetful(t *testing.T, collector *forgetfulCollector, recorder *lifecycleRecorder) *AgnostAnalytics {
+	t.Helper()
+	config := DefaultConfig()
+	config.Endpoint = collector.URL
+	config.Logger = NewLogger(io.Discard)
+	config.EnableRequestQueuing = false
+	config.MaxRetries = 0
+	config.OnSessionLifecycle = recorder.record
+	a := NewAgnostAnalytics()
+	if err := a.TrackMCP(server.NewMCPServer("test", "1.0.0"), "org", config); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(a.Shutdown)
+	if !waitUntil(func() bool { requests, _ := collector.sequence(); return len(requests) > 0 }) {
+		t.Fatal("the session started by Track wasn't registered")
+	}
+	return a
+}
+
+func TestEventsOfAnUnknownSessionAreResentAfterTheSession(t *testing.T) {
+	for name, respond := range map[string]func(w http.ResponseWriter){
+		"header": func(w http.ResponseWriter) {
+			w.Header().Set(unknownSessionHeader, "1")
+			w.WriteHeader(http.StatusConflict)
+		},
+		"error code": func(w http.ResponseWriter) {
+			w.WriteHeader(http.StatusConflict)
+			w.Write([]byte(`{"code":"unknown_session"}`))
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			collector := newForgetfulCollector(t, 1, respond)
+			var recorder lifecycleRecorder
+			a := trackForgetful(t, collector, &recorder)
+
+			if err := a.RecordEvent("tool", "echo", nil, 1, true, nil); err != nil {
+				t.Fatalf("event of a forgotten session failed: %v", err)
+			}
+			requests, eventIDs := collector.sequence()
+			want := []string{"/api/v1/capture-session", "/api/v1/capture-event", "/api/v1/capture-session", "/api/v1/capture-event"}
+			if !slices.Equal(requests, want) {
+				t.Fatalf("collector got %v, want %v", requests, want)
+			}
+			if eventIDs[0] != eventIDs[1] {
+				t.Errorf("resent event has ID %s, want %s", eventIDs[1], eventIDs[0])
+			}
+			a.sessionManager.closeLifecycle()
+			if kinds := recorder.kinds(); len(kinds) != 2 || kinds[1] != SessionLifecycleReregistered {
+				t.Errorf("got transitions %v, want the session reregistered", kinds)
+			}
+		})
+	}
+}
+
+func TestEventsAreResentOnlyOnce(t *testing.T) {
+	collector := newForgetfulCollector(t, 2, func(w http.ResponseWriter) {
+		w.Header().Set(unknownSessionHeader, "1")
+		w.WriteHeader(http.StatusConflict)
+	})
+	a := trackForgetful(t, collector, &lifecycleRecorder{})
+
+	if err := a.RecordEvent("tool", "echo", nil, 1, true, nil); err == nil {
+		t.Error("event the collector keeps rejecting was delivered")
+	}
+	if _, eventIDs := collector.sequence(); len(eventIDs) != 2 {
+		t.Errorf("event was posted %d times, want twice", len(eventIDs))
+	}
+}
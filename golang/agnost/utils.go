@@ -2,12 +2,17 @@ package agnost
 
 import (
 	"crypto/rand"
-	"fmt"
+	"encoding/hex"
 )
 
 func generateSessionID() string {
-	b := make([]byte, 16)
-	_, err := rand.Read(b)
+	return generateUUID()
+}
+
+// generateUUID generates a random (version 4) UUID
+func generateUUID() string {
+	var b [16]byte
+	_, err := rand.Read(b[:])
 	if err != nil {
 		panic(err) // or handle properly
 	}
@@ -17,6 +22,17 @@ func generateSessionID() string {
 	// Set variant (10xxxxxx) at 9th byte
 	b[8] = (b[8] & 0x3f) | 0x80
 
-	return fmt.Sprintf("%08x-%04x-%04x-%04x-%012x",
-		b[0:4], b[4:6], b[6:8], b[8:10], b[10:16])
+	// Hex-encode into the canonical 8-4-4-4-12 layout; this runs on every
+	// tracked call, so avoid fmt
+	var s [36]byte
+	hex.Encode(s[0:8], b[0:4])
+	s[8] = '-'
+	hex.Encode(s[9:13], b[4:6])
+	s[13] = '-'
+	hex.Encode(s[14:18], b[6:8])
+	s[18] = '-'
+	hex.Encode(s[19:23], b[8:10])
+	s[23] = '-'
+	hex.Encode(s[24:36], b[10:16])
+	return string(s[:])
 }
@@ -0,0 +1,61 @@
+package agnost
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// validateConfig checks the fields a typo would otherwise only surface as
+// failed sends, returning an error listing every problem
+func validateConfig(config *AgnostConfig) error {
+	var problems []error
+	check := func(ok bool, format string, args ...any) {
+		if !ok {
+			problems = append(problems, fmt.Errorf(format, args...))
+		}
+	}
+
+	if config.Endpoint != "" {
+		check(validEndpoint(config.Endpoint), "Endpoint %q is not an http, https or udp URL", config.Endpoint)
+	}
+	if config.SessionEndpoint != "" {
+		check(validEndpoint(config.SessionEndpoint), "SessionEndpoint %q is not an http, https or udp URL", config.SessionEndpoint)
+	}
+	check(config.BatchSize >= 0, "BatchSize %d is negative", config.BatchSize)
+	check(config.MaxRetries >= 0, "MaxRetries %d is negative", config.MaxRetries)
+	check(config.RetryDelay >= 0, "RetryDelay %v is negative", config.RetryDelay)
+	check(config.RequestTimeout >= 0, "RequestTimeout %v is negative", config.RequestTimeout)
+	check(config.IdentifyCacheTTL >= 0, "IdentifyCacheTTL %v is negative", config.IdentifyCacheTTL)
+	check(config.IdentifyCacheSize >= 0, "IdentifyCacheSize %d is negative", config.IdentifyCacheSize)
+	check(knownLogLevel(config.LogLevel), "unknown LogLevel %q, use \"debug\", \"info\", \"warning\" or \"error\"", config.LogLevel)
+
+	if len(problems) == 0 {
+		return nil
+	}
+	return fmt.Errorf("invalid configuration (set SkipValidation to bypass):\n%w", errors.Join(problems...))
+}
+
+// validEndpoint reports whether endpoint is an absolute http, https or udp URL
+func validEndpoint(endpoint string) bool {
+	u, err := url.Parse(endpoint)
+	if err != nil || u.Host == "" {
+		return false
+	}
+	switch u.Scheme {
+	case "http", "https", "udp":
+		return true
+	}
+	return false
+}
+
+// knownLogLevel reports whether parseLogLevel knows level; an empty level
+// means "info"
+func knownLogLevel(level string) bool {
+	switch strings.ToLower(level) {
+	case "", "debug", "info", "warning", "warn", "error":
+		return true
+	}
+	return false
+}
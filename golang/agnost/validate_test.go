@@ -0,0 +1,76 @@
+package agnost
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestValidateConfigAcceptsTheDefaults(t *testing.T) {
+	config := DefaultConfig()
+	if err := validateConfig(config); err != nil {
+		t.Errorf("default configuration is invalid: %v", err)
+	}
+	config.Endpoint = "udp://collector:9000"
+	config.SessionEndpoint = "https://collector.example.com"
+	config.LogLevel = "WARN"
+	if err := validateConfig(config); err != nil {
+		t.Errorf("valid configuration is invalid: %v", err)
+	}
+}
+
+func TestValidateConfigListsEveryProblem(t *testing.T) {
+	config := DefaultConfig()
+	config.Endpoint = "collector.example.com"
+	config.SessionEndpoint = "ftp://collector.example.com"
+	config.BatchSize = -1
+	config.MaxRetries = -1
+	config.RetryDelay = -time.Second
+	config.RequestTimeout = -time.Second
+	config.IdentifyCacheTTL = -time.Second
+	config.IdentifyCacheSize = -1
+	config.LogLevel = "verbose"
+
+	err := validateConfig(config)
+	if err == nil {
+		t.Fatal("invalid configuration passed validation")
+	}
+	if joined, ok := errors.Unwrap(err).(interface{ Unwrap() []error }); !ok || len(joined.Unwrap()) != 9 {
+		t.Errorf("got error %v, want 9 problems", err)
+	}
+	for _, field := range []string{"Endpoint", "SessionEndpoint", "BatchSize", "MaxRetries", "RetryDelay",
+		"RequestTimeout", "IdentifyCacheTTL", "IdentifyCacheSize", "LogLevel", "SkipValidation"} {
+		if !strings.Contains(err.Error(), field) {
+			t.Errorf("error doesn't mention %s: %v", field, err)
+		}
+	}
+}
+
+func TestCheckConfigHonorsSkipValidation(t *testing.T) {
+	config := DefaultConfig()
+	config.MaxRetries = -1
+	if err := checkConfig("org", config); err == nil || !strings.Contains(err.Error(), "MaxRetries") {
+		t.Errorf("got error %v, want an invalid MaxRetries", err)
+	}
+	config.SkipValidation = true
+	if err := checkConfig("org", config); err != nil {
+		t.Errorf("skipped validation still failed: %v", err)
+	}
+}
+
+func TestValidEndpoint(t *testing.T) {
+	for endpoint, want := range map[string]bool{
+		"https://api.agnost.ai": true,
+		"http://localhost:8080": true,
+		"udp://127.0.0.1:9000":  true,
+		"api.agnost.ai":         false,
+		"https://":              false,
+		"ws://api.agnost.ai":    false,
+		"://bad":                false,
+	} {
+		if got := validEndpoint(endpoint); got != want {
+			t.Errorf("validEndpoint(%q) = %v, want %v", endpoint, got, want)
+		}
+	}
+}
@@ -0,0 +1,80 @@
+package agnost
+
+import (
+	"context"
+	"testing"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// addResultTool adds a tool returning result, marking the call as a
+// validation error first if mark is set
+func addResultTool(s *server.MCPServer, name string, mark bool, result *mcp.CallToolResult) {
+	s.AddTool(mcp.NewTool(name), func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		if mark {
+			MarkValidationError(ctx)
+		}
+		return result, nil
+	})
+}
+
+func TestValidationErrorsAreClassified(t *testing.T) {
+	s, a, collector := newTrackedServer(t, nil)
+	addResultTool(s, "marked", true, mcp.NewToolResultError("no"))
+	addResultTool(s, "matched", false, mcp.NewToolResultError("Invalid Argument: city"))
+	addResultTool(s, "failed", false, mcp.NewToolResultError("upstream unavailable"))
+	addResultTool(s, "marked_success", true, mcp.NewToolResultText("ok"))
+	for _, name := range []string{"marked", "matched", "failed", "marked_success"} {
+		callTool(t, s, name)
+	}
+
+	want := map[string]string{"marked": ErrorTypeValidation, "matched": ErrorTypeValidation, "failed": "", "marked_success": ""}
+	events := toolEvents(t, collector, len(want))
+	for name, errorType := range want {
+		if got := events[name].ErrorType; got != errorType {
+			t.Errorf("%s: got error type %q, want %q", name, got, errorType)
+		}
+	}
+	if events["failed"].Success || !events["marked_success"].Success {
+		t.Error("classifying validation errors changed the calls' success")
+	}
+
+	tools := a.Stats().Tools
+	if tools["marked"].ValidationErrors != 1 || tools["matched"].ValidationErrors != 1 {
+		t.Errorf("counted validation errors %+v and %+v, want 1 each", tools["marked"], tools["matched"])
+	}
+	if tools["failed"].ValidationErrors != 0 || tools["failed"].Failures != 1 {
+		t.Errorf("counted %+v for a failure that isn't a validation error", tools["failed"])
+	}
+}
+
+func TestValidationErrorPatternsReplaceTheDefaults(t *testing.T) {
+	for _, tt := range []struct {
+		patterns []string
+		want     map[string]string
+	}{
+		{[]string{"SCHEMA"}, map[string]string{"default": "", "custom": ErrorTypeValidation}},
+		// An empty list turns pattern matching off
+		{[]string{}, map[string]string{"default": "", "custom": ""}},
+	} {
+		s, _, collector := newTrackedServer(t, func(config *AgnostConfig) {
+			config.ValidationErrorPatterns = tt.patterns
+		})
+		addResultTool(s, "default", false, mcp.NewToolResultError("missing required argument"))
+		addResultTool(s, "custom", false, mcp.NewToolResultError("schema mismatch"))
+		callTool(t, s, "default")
+		callTool(t, s, "custom")
+
+		events := toolEvents(t, collector, 2)
+		for name, errorType := range tt.want {
+			if got := events[name].ErrorType; got != errorType {
+				t.Errorf("patterns %q, %s: got error type %q, want %q", tt.patterns, name, got, errorType)
+			}
+		}
+	}
+}
+
+func TestMarkValidationErrorOutsideACallIsANoOp(t *testing.T) {
+	MarkValidationError(context.Background())
+}
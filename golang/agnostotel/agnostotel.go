@@ -0,0 +1,84 @@
+// Package agnostotel traces the tool calls tracked by the agnost package with
+// OpenTelemetry:
+//
+//	agnost.Track(s, "your-org-id", &agnost.Config{
+//	    Tracer: agnostotel.Tracer(nil),
+//	})
+//
+// Every tracked tool call gets a server span named after the tool, started
+// from the context the call arrived with, and its event records the span's
+// trace and span IDs.
+package agnostotel
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/agnostai/agnost-go/agnost"
+)
+
+// instrumentationName names the tracer the spans are started with
+const instrumentationName = "github.com/agnostai/agnost-go/agnostotel"
+
+// Span attributes
+const (
+	attrPrimitiveType = attribute.Key("agnost.primitive_type")
+	attrSuccess       = attribute.Key("agnost.success")
+	attrLatencyMs     = attribute.Key("agnost.latency_ms")
+	attrSessionID     = attribute.Key("agnost.session_id")
+)
+
+// Tracer returns an agnost.Tracer that starts the spans of tracked calls with
+// provider, or with the global tracer provider if provider is nil
+func Tracer(provider trace.TracerProvider) agnost.Tracer {
+	if provider == nil {
+		provider = otel.GetTracerProvider()
+	}
+	return &tracer{tracer: provider.Tracer(instrumentationName)}
+}
+
+type tracer struct {
+	tracer trace.Tracer
+}
+
+func (t *tracer) Start(ctx context.Context, primitiveType string, name string) (context.Context, agnost.Span) {
+	ctx, s := t.tracer.Start(ctx, name,
+		trace.WithSpanKind(trace.SpanKindServer),
+		trace.WithAttributes(attrPrimitiveType.String(primitiveType)),
+	)
+	return ctx, &span{span: s}
+}
+
+type span struct {
+	span trace.Span
+}
+
+func (s *span) IDs() (string, string) {
+	spanContext := s.span.SpanContext()
+	if !spanContext.IsValid() {
+		return "", ""
+	}
+	return spanContext.TraceID().String(), spanContext.SpanID().String()
+}
+
+func (s *span) End(outcome agnost.SpanOutcome) {
+	s.span.SetAttributes(
+		attrSuccess.Bool(outcome.Success),
+		attrLatencyMs.Int64(outcome.LatencyMs),
+	)
+	if outcome.SessionID != "" {
+		s.span.SetAttributes(attrSessionID.String(outcome.SessionID))
+	}
+	switch {
+	case outcome.Err != nil:
+		s.span.RecordError(outcome.Err)
+		s.span.SetStatus(codes.Error, outcome.Err.Error())
+	case !outcome.Success:
+		s.span.SetStatus(codes.Error, "tool call failed")
+	}
+	s.span.End()
+}
@@ -0,0 +1,59 @@
+// Command fakecollector runs the agnosttest fake collector as a standalone
+// server, for exercising SDKs running in another process, such as the example
+// servers. It logs a summary of what it received every interval and on exit.
+//
+//	go run ./agnosttest/cmd/fakecollector -addr localhost:8080
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"time"
+
+	"github.com/agnostai/agnost-go/agnosttest"
+)
+
+func main() {
+	addr := flag.String("addr", "localhost:8080", "address to listen on")
+	interval := flag.Duration("interval", 10*time.Second, "how often to log a summary, 0 to log only on exit")
+	flag.Parse()
+
+	collector, err := agnosttest.ListenCollector(*addr)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	defer collector.Close()
+	log.Printf("Fake collector listening on %s", collector.Endpoint())
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt)
+
+	var tick <-chan time.Time
+	if *interval > 0 {
+		ticker := time.NewTicker(*interval)
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+	for {
+		select {
+		case <-tick:
+			summarize(collector)
+		case <-signals:
+			summarize(collector)
+			return
+		}
+	}
+}
+
+// summarize logs the counts of received payloads and any violations
+func summarize(collector *agnosttest.Collector) {
+	log.Printf("%d sessions, %d events, %d session ends",
+		len(collector.Sessions()), len(collector.Events()), len(collector.SessionEnds()))
+	for _, violation := range collector.Violations() {
+		log.Printf("violation: %s", violation)
+	}
+}
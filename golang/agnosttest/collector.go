@@ -0,0 +1,349 @@
+// Package agnosttest provides an in-process fake Agnost collector for testing
+// code that uses the agnost package
+package agnosttest
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/agnostai/agnost-go/agnost"
+)
+
+// assignedSessionPrefix starts the session IDs a collector assigns, see
+// Collector.AssignSessionIDs
+const assignedSessionPrefix = "collector-session-"
+
+// Collector is a fake Agnost Analytics API that records everything it receives
+type Collector struct {
+	server *httptest.Server
+
+	mu             sync.Mutex
+	sessions       []agnost.SessionData
+	events         []agnost.EventData
+	sessionUpdates []agnost.SessionUpdateData
+	sessionEnds    []agnost.SessionEndData
+	orgIDs         map[string]int
+	violations     []string
+	assignIDs      bool // answer session registrations with IDs of its own
+}
+
+// NewCollector starts a fake collector listening on a local address
+func NewCollector() *Collector {
+	c := newCollector()
+	c.server.Start()
+	return c
+}
+
+// ListenCollector starts a fake collector listening on addr, such as
+// "localhost:8080", for SDKs running in another process
+func ListenCollector(addr string) (*Collector, error) {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	c := newCollector()
+	c.server.Listener.Close()
+	c.server.Listener = listener
+	c.server.Start()
+	return c, nil
+}
+
+func newCollector() *Collector {
+	c := &Collector{
+		orgIDs: make(map[string]int),
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/v1/capture-session", func(w http.ResponseWriter, r *http.Request) {
+		var session agnost.SessionData
+		if !c.decode(w, r, &session) {
+			return
+		}
+		if session.SessionID == "" {
+			c.reject(w, "session without session_id")
+			return
+		}
+		c.mu.Lock()
+		// Sessions re-sent with an assigned ID keep it
+		if c.assignIDs && !strings.HasPrefix(session.SessionID, assignedSessionPrefix) {
+			session.SessionID = fmt.Sprintf("%s%d", assignedSessionPrefix, len(c.sessions)+1)
+		}
+		c.sessions = append(c.sessions, session)
+		c.mu.Unlock()
+		writeJSON(w, agnost.SessionResponse{SessionID: session.SessionID})
+	})
+	mux.HandleFunc("/api/v1/capture-event", func(w http.ResponseWriter, r *http.Request) {
+		var event agnost.EventData
+		if !c.decode(w, r, &event) {
+			return
+		}
+		if err := c.checkEvent(event); err != nil {
+			c.reject(w, err.Error())
+			return
+		}
+		c.mu.Lock()
+		c.events = append(c.events, event)
+		c.mu.Unlock()
+		writeJSON(w, agnost.EventResponse{Success: true, EventID: event.EventID})
+	})
+	mux.HandleFunc("/api/v1/capture-events", func(w http.ResponseWriter, r *http.Request) {
+		var events []agnost.EventData
+		if !c.decode(w, r, &events) {
+			return
+		}
+		response := agnost.EventBatchResponse{Success: true}
+		for i, event := range events {
+			if err := c.checkEvent(event); err != nil {
+				c.violate(err.Error())
+				response.Rejected = append(response.Rejected, agnost.RejectedEvent{Index: i, Error: err.Error()})
+				continue
+			}
+			c.mu.Lock()
+			c.events = append(c.events, event)
+			c.mu.Unlock()
+		}
+		writeJSON(w, response)
+	})
+	mux.HandleFunc("/api/v1/capture-session-update", func(w http.ResponseWriter, r *http.Request) {
+		var update agnost.SessionUpdateData
+		if !c.decode(w, r, &update) {
+			return
+		}
+		// Resumed sessions were registered by an earlier process
+		if update.Kind != agnost.SessionUpdateResumed && !c.knowsSession(update.SessionID) {
+			c.violate(fmt.Sprintf("session update for unregistered session %q", update.SessionID))
+		}
+		c.mu.Lock()
+		c.sessionUpdates = append(c.sessionUpdates, update)
+		c.mu.Unlock()
+		w.WriteHeader(http.StatusOK)
+	})
+	mux.HandleFunc("/api/v1/capture-session-end", func(w http.ResponseWriter, r *http.Request) {
+		var end agnost.SessionEndData
+		if !c.decode(w, r, &end) {
+			return
+		}
+		if !c.knowsSession(end.SessionID) {
+			c.violate(fmt.Sprintf("session end for unregistered session %q", end.SessionID))
+		}
+		c.mu.Lock()
+		c.sessionEnds = append(c.sessionEnds, end)
+		c.mu.Unlock()
+		w.WriteHeader(http.StatusOK)
+	})
+
+	c.server = httptest.NewUnstartedServer(mux)
+	return c
+}
+
+// checkEvent validates an event, returning an error for one the collector
+// can't record. Events of sessions that weren't registered first are recorded
+// but reported as a violation.
+func (c *Collector) checkEvent(event agnost.EventData) error {
+	switch {
+	case event.SessionID == "":
+		return fmt.Errorf("event %s/%s without session_id", event.PrimitiveType, event.PrimitiveName)
+	case event.PrimitiveType == "" || event.PrimitiveName == "":
+		return fmt.Errorf("event %q without primitive_type or primitive_name", event.EventID)
+	case event.Latency < 0:
+		return fmt.Errorf("event %s/%s with negative latency %d", event.PrimitiveType, event.PrimitiveName, event.Latency)
+	}
+	if !c.knowsSession(event.SessionID) {
+		c.violate(fmt.Sprintf("event %s/%s for unregistered session %q", event.PrimitiveType, event.PrimitiveName, event.SessionID))
+	}
+	return nil
+}
+
+// knowsSession reports whether the session was registered
+func (c *Collector) knowsSession(sessionID string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, session := range c.sessions {
+		if session.SessionID == sessionID {
+			return true
+		}
+	}
+	return false
+}
+
+// reject answers an invalid request with 400, recording it as a violation
+func (c *Collector) reject(w http.ResponseWriter, violation string) {
+	c.violate(violation)
+	http.Error(w, violation, http.StatusBadRequest)
+}
+
+func (c *Collector) violate(violation string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.violations = append(c.violations, violation)
+}
+
+// decode validates the common request shape and decodes the JSON body into v
+func (c *Collector) decode(w http.ResponseWriter, r *http.Request, v any) bool {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return false
+	}
+	orgID := r.Header.Get("X-Org-id")
+	if orgID == "" {
+		http.Error(w, "missing organization ID", http.StatusUnauthorized)
+		return false
+	}
+	var body io.Reader = r.Body
+	switch r.Header.Get("Content-Encoding") {
+	case "":
+	case "gzip":
+		reader, err := gzip.NewReader(r.Body)
+		if err != nil {
+			http.Error(w, "invalid gzip body", http.StatusBadRequest)
+			return false
+		}
+		defer reader.Close()
+		body = reader
+	default:
+		http.Error(w, "unsupported content encoding", http.StatusUnsupportedMediaType)
+		return false
+	}
+	if err := json.NewDecoder(body).Decode(v); err != nil {
+		http.Error(w, "invalid request body", http.StatusBadRequest)
+		return false
+	}
+
+	c.mu.Lock()
+	c.orgIDs[orgID]++
+	c.mu.Unlock()
+	return true
+}
+
+func writeJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
+
+// AssignSessionIDs makes the collector answer session registrations with a
+// session ID of its own instead of echoing the SDK's, like a backend that
+// deduplicates or rewrites session IDs. Sessions registered in batches keep
+// their IDs.
+func (c *Collector) AssignSessionIDs() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.assignIDs = true
+}
+
+// Endpoint returns the base URL to use as Config.Endpoint
+func (c *Collector) Endpoint() string {
+	return c.server.URL
+}
+
+// Config returns a configuration pointed at the collector that sends events
+// synchronously, so everything recorded is visible as soon as the call returns.
+// It doesn't derive anonymous identities, which would write a state file.
+func (c *Collector) Config() *agnost.Config {
+	config := agnost.DefaultConfig()
+	config.Endpoint = c.Endpoint()
+	config.EnableRequestQueuing = false
+	config.MaxRetries = 0
+	config.RequestTimeout = time.Second
+	config.LogLevel = "error"
+	return config
+}
+
+// Sessions returns a copy of the sessions received so far
+func (c *Collector) Sessions() []agnost.SessionData {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]agnost.SessionData(nil), c.sessions...)
+}
+
+// Events returns a copy of the events received so far
+func (c *Collector) Events() []agnost.EventData {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]agnost.EventData(nil), c.events...)
+}
+
+// SessionUpdates returns a copy of the session updates received so far, such
+// as identified users
+func (c *Collector) SessionUpdates() []agnost.SessionUpdateData {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]agnost.SessionUpdateData(nil), c.sessionUpdates...)
+}
+
+// SessionEnds returns a copy of the session-end payloads received so far
+func (c *Collector) SessionEnds() []agnost.SessionEndData {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]agnost.SessionEndData(nil), c.sessionEnds...)
+}
+
+// Violations returns the protocol violations seen so far: invalid payloads,
+// which were rejected, and events, session updates or session ends of
+// sessions that weren't registered first
+func (c *Collector) Violations() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]string(nil), c.violations...)
+}
+
+// RequestsForOrg returns how many valid requests carried the given organization ID
+func (c *Collector) RequestsForOrg(orgID string) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.orgIDs[orgID]
+}
+
+// WaitForSessions blocks until at least n sessions were registered or the
+// timeout expires, and reports whether the sessions arrived
+func (c *Collector) WaitForSessions(n int, timeout time.Duration) bool {
+	return c.waitFor(func() int { return len(c.sessions) }, n, timeout)
+}
+
+// WaitForEvents blocks until at least n events were received or the timeout expires,
+// and reports whether the events arrived
+func (c *Collector) WaitForEvents(n int, timeout time.Duration) bool {
+	return c.waitFor(func() int { return len(c.events) }, n, timeout)
+}
+
+// waitFor polls count, called with c.mu held, until it reaches n or the
+// timeout expires
+func (c *Collector) waitFor(count func() int, n int, timeout time.Duration) bool {
+	deadline := time.Now().Add(timeout)
+	for {
+		c.mu.Lock()
+		got := count()
+		c.mu.Unlock()
+		if got >= n {
+			return true
+		}
+		if time.Now().After(deadline) {
+			return false
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// Reset discards everything received so far
+func (c *Collector) Reset() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.sessions = nil
+	c.events = nil
+	c.sessionUpdates = nil
+	c.sessionEnds = nil
+	c.orgIDs = make(map[string]int)
+	c.violations = nil
+}
+
+// Close shuts down the collector
+func (c *Collector) Close() {
+	c.server.Close()
+}
@@ -0,0 +1,149 @@
+package agnosttest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/mark3labs/mcp-go/client"
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+
+	"github.com/agnostai/agnost-go/agnost"
+)
+
+// harnessTimeout bounds how long a Harness waits for the SDK's deliveries
+const harnessTimeout = 5 * time.Second
+
+// Harness runs a tracked MCP server end to end: the server is tracked with its
+// own analytics client against a fake collector and driven by an in-process
+// mcp-go client, the way a real client drives it over stdio
+type Harness struct {
+	Collector *Collector
+	Analytics *agnost.AgnostAnalytics
+	Client    *client.Client
+}
+
+// NewHarness tracks s and connects a client to it. A nil config uses Collector.Config; a non-nil one has its
+// Endpoint pointed at the harness's collector. The harness waits for the
+// initial session, so every call is attributed to it.
+func NewHarness(ctx context.Context, s *server.MCPServer, orgID string, config *agnost.Config) (*Harness, error) {
+	return NewHarnessWithCollector(ctx, s, orgID, config, NewCollector())
+}
+
+// NewHarnessWithCollector is NewHarness with a collector set up by the caller,
+// such as one assigning session IDs. The harness closes it.
+func NewHarnessWithCollector(ctx context.Context, s *server.MCPServer, orgID string, config *agnost.Config, collector *Collector) (*Harness, error) {
+	h := &Harness{
+		Collector: collector,
+		Analytics: agnost.NewAgnostAnalytics(),
+	}
+	if config == nil {
+		config = h.Collector.Config()
+	} else {
+		config.Endpoint = h.Collector.Endpoint()
+	}
+
+	if err := h.Analytics.TrackMCP(s, orgID, config); err != nil {
+		h.Close()
+		return nil, fmt.Errorf("failed to track server: %v", err)
+	}
+	if !h.Collector.WaitForSessions(1, harnessTimeout) {
+		h.Close()
+		return nil, errors.New("no session registered")
+	}
+
+	c, err := client.NewInProcessClient(s)
+	if err != nil {
+		h.Close()
+		return nil, fmt.Errorf("failed to create client: %v", err)
+	}
+	h.Client = c
+	if err := c.Start(ctx); err != nil {
+		h.Close()
+		return nil, fmt.Errorf("failed to start client: %v", err)
+	}
+
+	initialize := mcp.InitializeRequest{}
+	initialize.Params.ProtocolVersion = mcp.LATEST_PROTOCOL_VERSION
+	initialize.Params.ClientInfo = mcp.Implementation{Name: "agnosttest", Version: "1.0.0"}
+	if _, err := c.Initialize(ctx, initialize); err != nil {
+		h.Close()
+		return nil, fmt.Errorf("failed to initialize client: %v", err)
+	}
+	return h, nil
+}
+
+// CallTool calls the named tool with the given arguments. Tool errors are
+// returned in the result, like a client sees them.
+func (h *Harness) CallTool(ctx context.Context, name string, args map[string]any) (*mcp.CallToolResult, error) {
+	request := mcp.CallToolRequest{}
+	request.Params.Name = name
+	request.Params.Arguments = args
+	return h.Client.CallTool(ctx, request)
+}
+
+// ToolCall is a tool call a Harness expects the collector to have received
+type ToolCall struct {
+	Name    string
+	Success bool
+
+	// MinLatency is the least latency expected, in milliseconds
+	MinLatency int64
+}
+
+// CheckToolCalls waits for the events of the expected tool calls and compares
+// them with the calls, in order, returning an error listing every mismatch.
+// It also fails on collector violations, more than one session, and tool
+// events other than the expected ones.
+func (h *Harness) CheckToolCalls(want []ToolCall) error {
+	var errs []error
+	if !h.Collector.WaitForEvents(len(want), harnessTimeout) {
+		errs = append(errs, fmt.Errorf("timed out waiting for %d events", len(want)))
+	}
+	for _, violation := range h.Collector.Violations() {
+		errs = append(errs, fmt.Errorf("collector: %s", violation))
+	}
+
+	sessions := h.Collector.Sessions()
+	if len(sessions) != 1 {
+		errs = append(errs, fmt.Errorf("got %d sessions, want 1", len(sessions)))
+	}
+
+	var got []agnost.EventData
+	for _, event := range h.Collector.Events() {
+		if event.PrimitiveType == "tool" {
+			got = append(got, event)
+		}
+	}
+	if len(got) != len(want) {
+		errs = append(errs, fmt.Errorf("got %d tool events, want %d", len(got), len(want)))
+	}
+	for i := 0; i < len(got) && i < len(want); i++ {
+		event, call := got[i], want[i]
+		if event.PrimitiveName != call.Name {
+			errs = append(errs, fmt.Errorf("event %d: tool %q, want %q", i, event.PrimitiveName, call.Name))
+		}
+		if event.Success != call.Success {
+			errs = append(errs, fmt.Errorf("event %d (%s): success %v, want %v", i, event.PrimitiveName, event.Success, call.Success))
+		}
+		if event.Latency < call.MinLatency {
+			errs = append(errs, fmt.Errorf("event %d (%s): latency %dms, want at least %dms", i, event.PrimitiveName, event.Latency, call.MinLatency))
+		}
+		if len(sessions) > 0 && event.SessionID != sessions[0].SessionID {
+			errs = append(errs, fmt.Errorf("event %d (%s): session %q, want %q", i, event.PrimitiveName, event.SessionID, sessions[0].SessionID))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Close disconnects the client, shuts down the analytics client and stops the
+// collector
+func (h *Harness) Close() {
+	if h.Client != nil {
+		h.Client.Close()
+	}
+	h.Analytics.Shutdown()
+	h.Collector.Close()
+}
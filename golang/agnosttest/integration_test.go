@@ -0,0 +1,200 @@
+package agnosttest_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/mark3labs/mcp-go/client"
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+
+	"github.com/agnostai/agnost-go/agnost"
+	"github.com/agnostai/agnost-go/agnosttest"
+)
+
+// concurrentClients is the number of SSE clients
+// TestConcurrentClientsGetSessionsOfTheirOwn connects at once
+const concurrentClients = 25
+
+// newServer returns the server under test, with an echo and a fail tool
+func newServer(opts ...server.ServerOption) *server.MCPServer {
+	s := server.NewMCPServer("integration-server", "1.0.0", append(opts, server.WithToolCapabilities(true))...)
+	s.AddTool(mcp.NewTool("echo",
+		mcp.WithDescription("Echoes the message back"),
+		mcp.WithString("message", mcp.Required()),
+	), func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		message, err := request.RequireString("message")
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		time.Sleep(5 * time.Millisecond)
+		return mcp.NewToolResultText(message), nil
+	})
+	s.AddTool(mcp.NewTool("fail",
+		mcp.WithDescription("Always fails"),
+	), func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		time.Sleep(5 * time.Millisecond)
+		return nil, errors.New("failed on purpose")
+	})
+	return s
+}
+
+// checkCalls tracks a server against collector and checks its tool calls
+func checkCalls(t *testing.T, collector *agnosttest.Collector) {
+	s := newServer()
+
+	ctx := context.Background()
+	h, err := agnosttest.NewHarnessWithCollector(ctx, s, "integration-org", nil, collector)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer h.Close()
+
+	if _, err := h.CallTool(ctx, "echo", map[string]any{"message": "hello"}); err != nil {
+		t.Fatalf("echo: %v", err)
+	}
+	if _, err := h.CallTool(ctx, "fail", nil); err == nil {
+		t.Fatal("fail: call succeeded")
+	}
+	if _, err := h.CallTool(ctx, "echo", map[string]any{"message": "again"}); err != nil {
+		t.Fatalf("echo: %v", err)
+	}
+
+	err = h.CheckToolCalls([]agnosttest.ToolCall{
+		{Name: "echo", Success: true, MinLatency: 1},
+		{Name: "fail", Success: false, MinLatency: 1},
+		{Name: "echo", Success: true, MinLatency: 1},
+	})
+	if err != nil {
+		t.Error(err)
+	}
+}
+
+func TestToolCallsWithEchoedSessionIDs(t *testing.T) {
+	checkCalls(t, agnosttest.NewCollector())
+}
+
+func TestToolCallsWithAssignedSessionIDs(t *testing.T) {
+	collector := agnosttest.NewCollector()
+	collector.AssignSessionIDs()
+	checkCalls(t, collector)
+}
+
+func TestGzippedEventsReachTheCollector(t *testing.T) {
+	collector := agnosttest.NewCollector()
+	config := collector.Config()
+	config.Compression = agnost.CompressionGzip
+
+	ctx := context.Background()
+	h, err := agnosttest.NewHarnessWithCollector(ctx, newServer(), "integration-org", config, collector)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer h.Close()
+
+	// Large enough to be compressed
+	message := strings.Repeat("hello ", 1000)
+	if _, err := h.CallTool(ctx, "echo", map[string]any{"message": message}); err != nil {
+		t.Fatalf("echo: %v", err)
+	}
+	if err := h.CheckToolCalls([]agnosttest.ToolCall{{Name: "echo", Success: true, MinLatency: 1}}); err != nil {
+		t.Fatal(err)
+	}
+	if output := collector.Events()[0].Output; !strings.Contains(output, "hello hello") {
+		t.Errorf("collector decoded output %q, want the echoed message", output)
+	}
+}
+
+// TestConcurrentClientsGetSessionsOfTheirOwn serves a tracked server over
+// SSE to many clients connecting at once, each calling echo, and checks every
+// client got a session of its own that is evicted and ended once the client
+// disconnects. The first client continues the session started by Track, which
+// stays cached.
+func TestConcurrentClientsGetSessionsOfTheirOwn(t *testing.T) {
+	collector := agnosttest.NewCollector()
+	defer collector.Close()
+
+	analytics := agnost.NewAgnostAnalytics()
+	s := newServer(server.WithHooks(analytics.Hooks()))
+	if err := analytics.TrackMCP(s, "integration-org", collector.Config()); err != nil {
+		t.Fatal(err)
+	}
+	defer analytics.Shutdown()
+
+	sse := httptest.NewServer(server.NewSSEServer(s))
+	defer sse.Close()
+
+	ctx := context.Background()
+	errs := make([]error, concurrentClients)
+	var wg sync.WaitGroup
+	for i := range concurrentClients {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs[i] = callOnce(ctx, sse.URL+"/sse", fmt.Sprintf("client %d", i))
+		}()
+	}
+	wg.Wait()
+	if err := errors.Join(errs...); err != nil {
+		t.Fatal(err)
+	}
+
+	if !collector.WaitForEvents(concurrentClients, 5*time.Second) {
+		t.Fatalf("timed out waiting for %d events", concurrentClients)
+	}
+	sessionIDs := make(map[string]bool)
+	for _, event := range collector.Events() {
+		sessionIDs[event.SessionID] = true
+	}
+	if len(sessionIDs) != concurrentClients {
+		t.Errorf("events of %d sessions, want %d", len(sessionIDs), concurrentClients)
+	}
+	if sessions := len(collector.Sessions()); sessions != concurrentClients {
+		t.Errorf("got %d sessions, want %d", sessions, concurrentClients)
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for len(analytics.Sessions()) > 1 || len(collector.SessionEnds()) < concurrentClients-1 {
+		if time.Now().After(deadline) {
+			t.Fatalf("%d sessions cached and %d ended after the clients disconnected, want 1 and %d",
+				len(analytics.Sessions()), len(collector.SessionEnds()), concurrentClients-1)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	for _, violation := range collector.Violations() {
+		t.Errorf("collector: %s", violation)
+	}
+}
+
+// callOnce connects an SSE client to url, calls echo and disconnects
+func callOnce(ctx context.Context, url string, message string) error {
+	c, err := client.NewSSEMCPClient(url)
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+	if err := c.Start(ctx); err != nil {
+		return fmt.Errorf("%s: %v", message, err)
+	}
+
+	initialize := mcp.InitializeRequest{}
+	initialize.Params.ProtocolVersion = mcp.LATEST_PROTOCOL_VERSION
+	initialize.Params.ClientInfo = mcp.Implementation{Name: "integration", Version: "1.0.0"}
+	if _, err := c.Initialize(ctx, initialize); err != nil {
+		return fmt.Errorf("%s: initialize: %v", message, err)
+	}
+
+	request := mcp.CallToolRequest{}
+	request.Params.Name = "echo"
+	request.Params.Arguments = map[string]any{"message": message}
+	if _, err := c.CallTool(ctx, request); err != nil {
+		return fmt.Errorf("%s: echo: %v", message, err)
+	}
+	return nil
+}
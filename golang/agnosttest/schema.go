@@ -0,0 +1,133 @@
+package agnosttest
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/agnostai/agnost-go/agnost"
+)
+
+// CheckPayloadSchemas validates the wire fixture of every WirePayload against
+// its schema in agnost.PayloadSchemas, returning an error listing every
+// violation. Only the schema keywords PayloadSchemas emits are supported.
+func CheckPayloadSchemas() error {
+	schemas := agnost.PayloadSchemas()
+
+	var errs []error
+	for _, payload := range WirePayloads() {
+		raw, ok := schemas[payload.Schema]
+		if !ok {
+			errs = append(errs, fmt.Errorf("%s: no schema %q", payload.Name, payload.Schema))
+			continue
+		}
+		var schema map[string]any
+		if err := json.Unmarshal(raw, &schema); err != nil {
+			errs = append(errs, fmt.Errorf("%s: invalid schema: %v", payload.Name, err))
+			continue
+		}
+
+		data, err := wireFixtures.ReadFile(fmt.Sprintf("wire/v%d/%s.json", WireFixtureVersion, payload.Name))
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: no fixture: %v", payload.Name, err))
+			continue
+		}
+		decoder := json.NewDecoder(strings.NewReader(string(data)))
+		decoder.UseNumber()
+		var value any
+		if err := decoder.Decode(&value); err != nil {
+			errs = append(errs, fmt.Errorf("%s: invalid fixture: %v", payload.Name, err))
+			continue
+		}
+
+		for _, violation := range validateSchema(schema, value, "$") {
+			errs = append(errs, fmt.Errorf("%s: %s", payload.Name, violation))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// validateSchema returns the violations of schema by value, a JSON value
+// decoded with json.Number numbers, located by path
+func validateSchema(schema map[string]any, value any, path string) []string {
+	if anyOf, ok := schema["anyOf"].([]any); ok {
+		for _, option := range anyOf {
+			if option, ok := option.(map[string]any); ok && len(validateSchema(option, value, path)) == 0 {
+				return nil
+			}
+		}
+		return []string{fmt.Sprintf("%s: matches none of the allowed schemas", path)}
+	}
+
+	want, _ := schema["type"].(string)
+	if want == "" {
+		return nil
+	}
+	if got := jsonType(value); got != want && !(want == "number" && got == "integer") {
+		return []string{fmt.Sprintf("%s: is %s, want %s", path, got, want)}
+	}
+
+	var violations []string
+	switch v := value.(type) {
+	case map[string]any:
+		properties, _ := schema["properties"].(map[string]any)
+		required, _ := schema["required"].([]any)
+		for _, name := range required {
+			if name, ok := name.(string); ok {
+				if _, present := v[name]; !present {
+					violations = append(violations, fmt.Sprintf("%s: missing required field %q", path, name))
+				}
+			}
+		}
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+		slices.Sort(keys)
+		for _, key := range keys {
+			fieldSchema, ok := properties[key].(map[string]any)
+			if !ok {
+				fieldSchema, ok = schema["additionalProperties"].(map[string]any)
+			}
+			if !ok {
+				if properties != nil {
+					violations = append(violations, fmt.Sprintf("%s: field %q is not in the schema", path, key))
+				}
+				continue
+			}
+			violations = append(violations, validateSchema(fieldSchema, v[key], path+"."+key)...)
+		}
+	case []any:
+		if items, ok := schema["items"].(map[string]any); ok {
+			for i, item := range v {
+				violations = append(violations, validateSchema(items, item, fmt.Sprintf("%s[%d]", path, i))...)
+			}
+		}
+	}
+	return violations
+}
+
+// jsonType returns the JSON Schema type of a decoded JSON value
+func jsonType(value any) string {
+	switch v := value.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case string:
+		return "string"
+	case json.Number:
+		if _, err := v.Int64(); err == nil {
+			return "integer"
+		}
+		return "number"
+	case []any:
+		return "array"
+	case map[string]any:
+		return "object"
+	default:
+		return fmt.Sprintf("%T", value)
+	}
+}
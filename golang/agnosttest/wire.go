@@ -0,0 +1,270 @@
+package agnosttest
+
+import (
+	"bytes"
+	"embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/agnostai/agnost-go/agnost"
+)
+
+// WireFixtureVersion is the version of the wire fixtures in wire/v<N>. Bump it
+// and add a new directory for deliberate breaking changes to the payloads.
+const WireFixtureVersion = 1
+
+//go:embed wire/v1/*.json
+var wireFixtures embed.FS
+
+// WirePayload is a representative payload of one kind the SDK sends
+type WirePayload struct {
+	// Name identifies the payload and names its fixture file
+	Name string
+
+	// Path is the API path the payload is posted to
+	Path string
+
+	// Schema names the payload's schema in agnost.PayloadSchemas
+	Schema string
+
+	// Value is the payload, with every field set so that a renamed or
+	// dropped field shows up as a fixture mismatch
+	Value any
+}
+
+// WirePayloads returns one fully populated payload of every kind the SDK sends
+func WirePayloads() []WirePayload {
+	breached := true
+	timeToFirstEvent := int64(4200)
+	session := agnost.SessionData{
+		SessionID:           "8f6c2a1e-0b7d-4c3e-9a5f-1d2e3f4a5b6c",
+		ClientConfig:        "claude-desktop",
+		ConnectionType:      agnost.ConnectionTypeStreamableHTTP,
+		IP:                  "",
+		Region:              "eu",
+		Tools:               []string{"echo", "search"},
+		UserData:            agnost.UserIdentity{"user_id": "user-1", "plan": "pro"},
+		ToolCount:           2,
+		SessionTools:        []string{"search"},
+		ToolHashes:          map[string]string{"echo": "9d4dedf114c7", "search": "3516517cc02a"},
+		ToolOrigins:         map[string]string{"search": "upstream-search"},
+		Resources:           []string{"docs://readme", "file://{path}"},
+		ResourceCount:       2,
+		Prompts:             []string{"summarize"},
+		ServerName:          "example-server",
+		ServerVersion:       "1.2.3",
+		InstructionsHash:    "2c26b46b68ffc68ff99b453c1d30413413422d706483bfa0f98a5e886266e7ae",
+		InstructionsPreview: "Use search before answering.",
+	}
+	second := session
+	second.SessionID = "1a2b3c4d-5e6f-4a7b-8c9d-0e1f2a3b4c5d"
+	second.ClientConfig = "cursor"
+
+	event := agnost.EventData{
+		EventID:            "5d3c1b2a-9e8f-4d7c-b6a5-4e3d2c1b0a9f",
+		SessionID:          session.SessionID,
+		PrimitiveType:      "tool",
+		PrimitiveName:      "search",
+		Latency:            182,
+		Success:            false,
+		Input:              `{"query":"weather"}`,
+		Output:             `{"error":"missing location"}`,
+		ErrorType:          agnost.ErrorTypeDenied,
+		DenialReason:       "missing scope search:read",
+		FailureKind:        agnost.FailureKindToolError,
+		ErrorMessage:       "missing location",
+		ErrorTypeName:      "*errors.errorString",
+		DeliveryMode:       agnost.DeliveryModeLive,
+		EnqueuedAt:         1760000100000,
+		SentAt:             1760000100250,
+		ParentEventID:      "0f9e8d7c-6b5a-4c3d-a2e1-f0e9d8c7b6a5",
+		ConcurrentCalls:    2,
+		ProgressToken:      "42",
+		CorrelationID:      "req-7",
+		ResultSummary:      &agnost.ResultSummary{ContentItems: 1, HasText: true, HasStructured: true},
+		Tags:               map[string]string{"tenant": "acme"},
+		Attributes:         map[string]any{"verdict": "allow", "score": 0.5},
+		Metadata:           map[string]any{"tenant_id": "acme", "variant": "b"},
+		UserData:           agnost.UserIdentity{"user_id": "user-2", "plan": "team"},
+		TraceID:            "4bf92f3577b34da6a3ce929d0e0e4736",
+		SpanID:             "00f067aa0ba902b7",
+		ToolHash:           "3516517cc02a",
+		Origin:             "upstream-search",
+		ToolSchema:         `{"type":"object","properties":{"query":{"type":"string"}}}`,
+		TimeToFirstEventMs: &timeToFirstEvent,
+		SLOMs:              150,
+		SLOBreached:        &breached,
+		PayloadRef:         "7c6b5a4d-3e2f-4a1b-9c8d-7e6f5a4b3c2d",
+		InputChunks:        0,
+		OutputChunks:       2,
+		PayloadIncomplete:  false,
+	}
+	report := agnost.EventData{
+		EventID:       "e4d3c2b1-a0f9-4e8d-8c7b-6a5f4e3d2c1b",
+		SessionID:     session.SessionID,
+		PrimitiveType: "sdk",
+		PrimitiveName: "delivery_report",
+		Success:       true,
+		DeliveryMode:  agnost.DeliveryModeLive,
+		EnqueuedAt:    1760000400000,
+		SentAt:        1760000400010,
+		Attributes: map[string]any{
+			"window_ms":     300000,
+			"target_ms":     10000,
+			"delivered":     120,
+			"failed":        1,
+			"dropped":       2,
+			"on_time":       118,
+			"success_ratio": 0.9672131147540983,
+			"p50_ms":        50,
+			"p90_ms":        250,
+			"p99_ms":        1000,
+		},
+	}
+
+	return []WirePayload{
+		{Name: "session", Path: "/api/v1/capture-session", Schema: "session", Value: session},
+		{Name: "sessions", Path: "/api/v1/capture-sessions", Schema: "sessions", Value: []agnost.SessionData{session, second}},
+		{Name: "session_update", Path: "/api/v1/capture-session-update", Schema: "session_update", Value: agnost.SessionUpdateData{
+			SessionID: session.SessionID,
+			Kind:      agnost.SessionUpdateIdentified,
+			UpdatedAt: 1760000000000,
+			UserData:  agnost.UserIdentity{"user_id": "user-2", "plan": "team"},
+		}},
+		{Name: "session_end", Path: "/api/v1/capture-session-end", Schema: "session_end", Value: agnost.SessionEndData{
+			SessionSummary: agnost.SessionSummary{
+				SessionID:          session.SessionID,
+				FirstEventAt:       1760000000000,
+				LastEventAt:        1760000300000,
+				EventCount:         12,
+				EventsPerMinute:    2.4,
+				MaxConcurrentCalls: 3,
+
+				ResourcesAdvertised:   300,
+				ResourcesReadDistinct: 256,
+				ResourcesReadOverflow: 12,
+
+				CacheHits:     9,
+				CacheMisses:   3,
+				CacheHitRatio: 0.75,
+
+				Denials: map[string]int64{"search": 2},
+
+				HadActivity:        true,
+				TimeToFirstEventMs: &timeToFirstEvent,
+			},
+			EndedAt: 1760000310000,
+		}},
+		{Name: "event", Path: "/api/v1/capture-event", Schema: "event", Value: event},
+		{Name: "events", Path: "/api/v1/capture-events", Schema: "events", Value: []agnost.EventData{event, report}},
+		{Name: "event_chunk", Path: "/api/v1/capture-event-chunk", Schema: "event_chunk", Value: agnost.EventChunk{
+			PayloadRef: "7c6b5a4d-3e2f-4a1b-9c8d-7e6f5a4b3c2d",
+			Field:      "result",
+			Index:      1,
+			Count:      2,
+			Data:       `ng location"}`,
+		}},
+		{Name: "delivery_report", Path: "/api/v1/capture-event", Schema: "event", Value: report},
+	}
+}
+
+// encodeWirePayload serializes a payload the way fixtures store it: the SDK's
+// JSON, indented so that fixture diffs are readable
+func encodeWirePayload(value any) ([]byte, error) {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+	var indented bytes.Buffer
+	if err := json.Indent(&indented, data, "", "  "); err != nil {
+		return nil, err
+	}
+	indented.WriteByte('\n')
+	return indented.Bytes(), nil
+}
+
+// CheckWireFixtures compares the serialization of every WirePayload with its
+// golden fixture byte for byte, returning an error listing every mismatch. A
+// mismatch means a change to the wire format: update the fixtures with
+// WriteWireFixtures only if the change is deliberate.
+func CheckWireFixtures() error {
+	var errs []error
+	for _, payload := range WirePayloads() {
+		got, err := encodeWirePayload(payload.Value)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %v", payload.Name, err))
+			continue
+		}
+		want, err := wireFixtures.ReadFile(fmt.Sprintf("wire/v%d/%s.json", WireFixtureVersion, payload.Name))
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: no fixture: %v", payload.Name, err))
+			continue
+		}
+		if !bytes.Equal(got, want) {
+			errs = append(errs, fmt.Errorf("%s: serialization differs from fixture:\n%s\nwant:\n%s", payload.Name, got, want))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// WriteWireFixtures writes the fixtures of the current wire format to dir,
+// normally agnosttest/wire/v<WireFixtureVersion>
+func WriteWireFixtures(dir string) error {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	for _, payload := range WirePayloads() {
+		data, err := encodeWirePayload(payload.Value)
+		if err != nil {
+			return fmt.Errorf("%s: %v", payload.Name, err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, payload.Name+".json"), data, 0o644); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ValidateWireFixtures posts every fixture to a running collector, such as a
+// local development instance, and returns an error listing the payloads it
+// rejected. Fixtures are sent as recorded, so they create real sessions and
+// events for orgID.
+func ValidateWireFixtures(endpoint string, orgID string) error {
+	client := &http.Client{Timeout: 5 * time.Second}
+	endpoint = strings.TrimRight(endpoint, "/")
+
+	var errs []error
+	for _, payload := range WirePayloads() {
+		data, err := wireFixtures.ReadFile(fmt.Sprintf("wire/v%d/%s.json", WireFixtureVersion, payload.Name))
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: no fixture: %v", payload.Name, err))
+			continue
+		}
+
+		req, err := http.NewRequest(http.MethodPost, endpoint+payload.Path, bytes.NewReader(data))
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("X-Org-id", orgID)
+
+		resp, err := client.Do(req)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %v", payload.Name, err))
+			continue
+		}
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+			errs = append(errs, fmt.Errorf("%s: rejected with status %d: %s", payload.Name, resp.StatusCode, body))
+		}
+	}
+	return errors.Join(errs...)
+}
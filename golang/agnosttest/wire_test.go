@@ -0,0 +1,45 @@
+package agnosttest
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "rewrite the wire fixtures from the current payloads")
+
+func TestWireFixtures(t *testing.T) {
+	if *update {
+		if err := WriteWireFixtures(fmt.Sprintf("wire/v%d", WireFixtureVersion)); err != nil {
+			t.Fatal(err)
+		}
+		t.Skip("rewrote the fixtures; rerun without -update to check them")
+	}
+	if err := CheckWireFixtures(); err != nil {
+		t.Errorf("the wire format changed; rerun with -update only if the change is deliberate:\n%v", err)
+	}
+}
+
+func TestWireFixturesMatchPayloadSchemas(t *testing.T) {
+	if err := CheckPayloadSchemas(); err != nil {
+		t.Error(err)
+	}
+}
+
+// TestRunningCollectorAcceptsWireFixtures validates the fixtures against the
+// collector at AGNOST_WIRE_COLLECTOR, such as a local development instance,
+// for the organization AGNOST_ORG_ID
+func TestRunningCollectorAcceptsWireFixtures(t *testing.T) {
+	endpoint := os.Getenv("AGNOST_WIRE_COLLECTOR")
+	if endpoint == "" {
+		t.Skip("AGNOST_WIRE_COLLECTOR is not set")
+	}
+	orgID := os.Getenv("AGNOST_ORG_ID")
+	if orgID == "" {
+		orgID = "wire-fixtures"
+	}
+	if err := ValidateWireFixtures(endpoint, orgID); err != nil {
+		t.Fatal(err)
+	}
+}